@@ -0,0 +1,425 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"municollect/internal/config"
+	"municollect/internal/encryption"
+	"municollect/internal/models"
+)
+
+// batchSize is how many rows "reencrypt" and "decrypt" load at a time
+const batchSize = 500
+
+// firstID sorts before every UUID, so batches start after it
+const firstID = "00000000-0000-0000-0000-000000000000"
+
+// Key rotation works in three steps:
+//  1. "rotate" adds a new key encryption key to the key file and makes it the active one.
+//     Values encrypted under the older keys stay readable.
+//  2. Deploy the key file to every replica, then run "reencrypt" to re-encrypt every value
+//     under the new key. It also encrypts plaintext written before encryption was enabled.
+//  3. "remove" deletes an old key once "list" shows no values are left under it.
+//
+// The index key never changes: blind indexes computed with another key would stop matching.
+func main() {
+	var (
+		action = flag.String("action", "list", "Key action: generate, rotate, list, remove, reencrypt, decrypt")
+		file   = flag.String("file", "", "Path to the key file (optional, uses FIELD_ENCRYPTION_KEY_FILE env var if not provided)")
+		kid    = flag.String("kid", "", "Key ID for remove")
+	)
+	flag.Parse()
+
+	keyFile := *file
+	if keyFile == "" {
+		keyFile = os.Getenv("FIELD_ENCRYPTION_KEY_FILE")
+	}
+	if keyFile == "" {
+		log.Fatal("Key file is required. Set FIELD_ENCRYPTION_KEY_FILE environment variable or use -file flag")
+	}
+
+	switch *action {
+	case "generate":
+		if err := generate(keyFile); err != nil {
+			log.Fatalf("Failed to generate key file: %v", err)
+		}
+	case "rotate":
+		if err := rotate(keyFile); err != nil {
+			log.Fatalf("Failed to rotate keys: %v", err)
+		}
+	case "list":
+		if err := list(keyFile); err != nil {
+			log.Fatalf("Failed to list keys: %v", err)
+		}
+	case "remove":
+		if err := remove(keyFile, *kid); err != nil {
+			log.Fatalf("Failed to remove key: %v", err)
+		}
+	case "reencrypt":
+		if err := reencrypt(keyFile); err != nil {
+			log.Fatalf("Failed to re-encrypt data: %v", err)
+		}
+	case "decrypt":
+		if err := decrypt(keyFile); err != nil {
+			log.Fatalf("Failed to decrypt data: %v", err)
+		}
+	default:
+		fmt.Printf("Unknown action: %s\n", *action)
+		fmt.Println("Available actions: generate, rotate, list, remove, reencrypt, decrypt")
+		os.Exit(1)
+	}
+}
+
+// generate writes a new key file with a new index key and key encryption key
+func generate(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists; use rotate to add a key", path)
+	}
+
+	provider, err := encryption.GenerateLocalKeyProvider(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, provider.Encode(), 0o600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	fmt.Printf("Generated key file %s with key %s\n", path, provider.ActiveKeyID())
+	return nil
+}
+
+// rotate adds a new active key encryption key to the key file
+func rotate(path string) error {
+	provider, err := encryption.LoadKeyFile(path)
+	if err != nil {
+		return err
+	}
+
+	id, err := provider.Rotate(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, provider.Encode(), 0o600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	fmt.Printf("Added key %s; deploy the key file, then run -action=reencrypt\n", id)
+	return nil
+}
+
+// list prints every key encryption key and, with a database configured, how many values
+// each one protects
+func list(path string) error {
+	provider, err := encryption.LoadKeyFile(path)
+	if err != nil {
+		return err
+	}
+
+	var counts map[string]int64
+	if db, err := connect(path); err != nil {
+		log.Printf("Not counting encrypted values: %v", err)
+	} else if counts, err = countValues(db); err != nil {
+		return err
+	}
+
+	fmt.Printf("%-34s %-10s %s\n", "KID", "STATUS", "VALUES")
+	for _, id := range provider.KeyIDs() {
+		status := "retired"
+		if id == provider.ActiveKeyID() {
+			status = "active"
+		}
+		values := "-"
+		if counts != nil {
+			values = fmt.Sprint(counts[id])
+		}
+		fmt.Printf("%-34s %-10s %s\n", id, status, values)
+	}
+	if counts != nil {
+		fmt.Printf("%-34s %-10s %d\n", "(plaintext)", "", counts[""])
+	}
+
+	return nil
+}
+
+// remove deletes a retired key encryption key from the key file
+func remove(path, kid string) error {
+	if kid == "" {
+		return errors.New("-kid is required")
+	}
+
+	provider, err := encryption.LoadKeyFile(path)
+	if err != nil {
+		return err
+	}
+	if err := provider.Remove(kid); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, provider.Encode(), 0o600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	fmt.Printf("Removed key %s\n", kid)
+	return nil
+}
+
+// encryptedTable describes a table with encrypted columns
+type encryptedTable struct {
+	name    string
+	columns []string
+	// rewrite writes back the next batch of matching rows after the given ID, see rewriteBatch
+	rewrite func(db *gorm.DB, where string, args []interface{}, after string, decrypt bool) (string, int, error)
+}
+
+// encryptedTables lists every table with encrypted columns. Rows are written back from the
+// struct so the serializer encrypts them, or from a map, which bypasses it, to decrypt them.
+var encryptedTables = []encryptedTable{
+	{
+		name:    "users",
+		columns: []string{"email", "phone", "national_id"},
+		rewrite: func(db *gorm.DB, where string, args []interface{}, after string, decrypt bool) (string, int, error) {
+			return rewriteBatch(db, where, args, after, func(tx *gorm.DB, user *models.User) (string, error) {
+				if decrypt {
+					return user.ID, tx.Model(user).UpdateColumns(map[string]interface{}{"email": user.Email, "phone": user.Phone, "national_id": user.NationalID}).Error
+				}
+				user.SetBlindIndexes()
+				return user.ID, tx.Model(user).Select("email", "email_index", "phone", "phone_index", "national_id", "national_id_index").UpdateColumns(user).Error
+			})
+		},
+	},
+	{
+		name:    "user_mfa",
+		columns: []string{"secret"},
+		rewrite: func(db *gorm.DB, where string, args []interface{}, after string, decrypt bool) (string, int, error) {
+			return rewriteBatchBy(db, "user_id", where, args, after, func(tx *gorm.DB, mfa *models.UserMFA) (string, error) {
+				if decrypt {
+					return mfa.UserID, tx.Model(mfa).UpdateColumns(map[string]interface{}{"secret": mfa.Secret}).Error
+				}
+				return mfa.UserID, tx.Model(mfa).Select("secret").UpdateColumns(mfa).Error
+			})
+		},
+	},
+	{
+		name:    "municipalities",
+		columns: []string{"contact_phone"},
+		rewrite: func(db *gorm.DB, where string, args []interface{}, after string, decrypt bool) (string, int, error) {
+			return rewriteBatch(db, where, args, after, func(tx *gorm.DB, municipality *models.Municipality) (string, error) {
+				if decrypt {
+					return municipality.ID, tx.Model(municipality).UpdateColumns(map[string]interface{}{"contact_phone": municipality.ContactPhone}).Error
+				}
+				return municipality.ID, tx.Model(municipality).Select("contact_phone").UpdateColumns(municipality).Error
+			})
+		},
+	},
+	{
+		name:    "payment_transactions",
+		columns: []string{"transaction_data"},
+		rewrite: func(db *gorm.DB, where string, args []interface{}, after string, decrypt bool) (string, int, error) {
+			return rewriteBatch(db, where, args, after, func(tx *gorm.DB, transaction *models.PaymentTransaction) (string, error) {
+				if decrypt {
+					data, err := json.Marshal(transaction.TransactionData)
+					if err != nil {
+						return transaction.ID, err
+					}
+					return transaction.ID, tx.Model(transaction).UpdateColumns(map[string]interface{}{"transaction_data": string(data)}).Error
+				}
+				return transaction.ID, tx.Model(transaction).Select("transaction_data").UpdateColumns(transaction).Error
+			})
+		},
+	},
+	{
+		name:    "idempotency_keys",
+		columns: []string{"response_body"},
+		rewrite: func(db *gorm.DB, where string, args []interface{}, after string, decrypt bool) (string, int, error) {
+			return rewriteBatch(db, where, args, after, func(tx *gorm.DB, key *models.IdempotencyKey) (string, error) {
+				if decrypt {
+					return key.ID, tx.Model(key).UpdateColumns(map[string]interface{}{"response_body": key.ResponseBody}).Error
+				}
+				return key.ID, tx.Model(key).Select("response_body").UpdateColumns(key).Error
+			})
+		},
+	},
+	{
+		name:    "webhook_events",
+		columns: []string{"payload"},
+		rewrite: func(db *gorm.DB, where string, args []interface{}, after string, decrypt bool) (string, int, error) {
+			return rewriteBatch(db, where, args, after, func(tx *gorm.DB, event *models.WebhookEvent) (string, error) {
+				if decrypt {
+					return event.ID, tx.Model(event).UpdateColumns(map[string]interface{}{"payload": event.Payload}).Error
+				}
+				return event.ID, tx.Model(event).Select("payload").UpdateColumns(event).Error
+			})
+		},
+	},
+}
+
+// reencrypt writes every encrypted value that is plaintext or under an older key back
+// under the active key, one batch per transaction
+func reencrypt(path string) error {
+	db, err := connect(path)
+	if err != nil {
+		return err
+	}
+	cipher, err := encryption.Default()
+	if err != nil {
+		return err
+	}
+	active, err := cipher.ActiveKeyID()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range encryptedTables {
+		where, args := staleCondition(table.columns, active)
+		total, err := rewriteTable(db, table, where, args, false)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Re-encrypted %d %s rows under key %s\n", total, table.name, active)
+	}
+
+	return nil
+}
+
+// decrypt writes every encrypted value back as plaintext. Run it before rolling back
+// migrations 020 or 027, which cannot decrypt values in SQL.
+func decrypt(path string) error {
+	db, err := connect(path)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range encryptedTables {
+		conditions := make([]string, len(table.columns))
+		args := make([]interface{}, len(table.columns))
+		for i, column := range table.columns {
+			conditions[i] = column + " LIKE ?"
+			args[i] = encryption.Prefix + "%"
+		}
+
+		total, err := rewriteTable(db, table, strings.Join(conditions, " OR "), args, true)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Decrypted %d %s rows\n", total, table.name)
+	}
+
+	return nil
+}
+
+// rewriteTable writes back every row of table matching where and returns how many there were
+func rewriteTable(db *gorm.DB, table encryptedTable, where string, args []interface{}, decrypt bool) (int, error) {
+	total := 0
+	for after := firstID; ; {
+		last, n, err := table.rewrite(db, where, args, after, decrypt)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", table.name, err)
+		}
+		total += n
+		if last == "" {
+			return total, nil
+		}
+		after = last
+	}
+}
+
+// rewriteBatch loads up to batchSize rows matching where with IDs after the given one and
+// writes each of them back in one transaction. Updating columns directly leaves updated_at
+// alone. It returns the ID of the last row written, or "" when there were none, and how
+// many rows were written.
+func rewriteBatch[T any](db *gorm.DB, where string, args []interface{}, after string, write func(tx *gorm.DB, row *T) (string, error)) (string, int, error) {
+	return rewriteBatchBy(db, "id", where, args, after, write)
+}
+
+// rewriteBatchBy is rewriteBatch for a table whose UUID primary key is the given column
+func rewriteBatchBy[T any](db *gorm.DB, key string, where string, args []interface{}, after string, write func(tx *gorm.DB, row *T) (string, error)) (string, int, error) {
+	var rows []T
+	if err := db.Where(key+" > ?", after).Where(where, args...).
+		Order(key).Limit(batchSize).Find(&rows).Error; err != nil {
+		return "", 0, fmt.Errorf("failed to load rows: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", 0, nil
+	}
+
+	// Start transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var last string
+	for i := range rows {
+		id, err := write(tx, &rows[i])
+		if err != nil {
+			tx.Rollback()
+			return "", 0, fmt.Errorf("failed to write row %s: %w", id, err)
+		}
+		last = id
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return "", 0, fmt.Errorf("failed to commit rows: %w", err)
+	}
+
+	return last, len(rows), nil
+}
+
+// staleCondition returns a condition matching rows with a value in any of the columns that
+// is not encrypted under the active key
+func staleCondition(columns []string, active string) (string, []interface{}) {
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = fmt.Sprintf("(%s IS NOT NULL AND %s NOT LIKE ?)", column, column)
+		args[i] = encryption.Prefix + active + ":%"
+	}
+	return strings.Join(conditions, " OR "), args
+}
+
+// countValues counts the encrypted values under each key encryption key, with plaintext values under ""
+func countValues(db *gorm.DB) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, table := range encryptedTables {
+		for _, column := range table.columns {
+			var rows []struct {
+				KeyID string
+				Count int64
+			}
+			err := db.Table(table.name).
+				Select(fmt.Sprintf("CASE WHEN %[1]s LIKE ? THEN split_part(%[1]s, ':', 3) ELSE '' END AS key_id, COUNT(*) AS count", column), encryption.Prefix+"%").
+				Where(column + " IS NOT NULL").
+				Group("key_id").
+				Scan(&rows).Error
+			if err != nil {
+				return nil, fmt.Errorf("failed to count %s.%s: %w", table.name, column, err)
+			}
+			for _, row := range rows {
+				counts[row.KeyID] += row.Count
+			}
+		}
+	}
+	return counts, nil
+}
+
+// connect opens the database the server uses, with the key file at path as the field encryption keys
+func connect(path string) (*gorm.DB, error) {
+	provider, err := encryption.LoadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	encryption.SetDefault(encryption.NewCipher(provider))
+
+	return config.ConnectDatabase()
+}
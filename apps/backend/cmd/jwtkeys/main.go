@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"municollect/internal/middleware"
+)
+
+// Key rotation works in four steps:
+//  1. "rotate" generates a new signing key that starts signing after -delay. Until then it is
+//     only published in the JWKS, so verifiers that cache the JWKS know it before they see
+//     tokens it signed. The first key of a directory signs straight away.
+//  2. Deploy the key directory to every replica before the new key starts signing.
+//  3. Once it has, "retire" the previous key. Retired keys keep only their public half, so
+//     tokens they signed still verify and stay in the JWKS.
+//  4. Once every token signed by a retired key has expired (7 days, the refresh token
+//     lifetime), "remove" deletes it.
+func main() {
+	var (
+		action = flag.String("action", "list", "Key action: rotate, list, retire, remove")
+		dir    = flag.String("dir", "", "Path to the JWT key directory (optional, uses JWT_KEYS_DIR env var if not provided)")
+		alg    = flag.String("alg", "EdDSA", "Algorithm for new keys: EdDSA or RS256")
+		kid    = flag.String("kid", "", "Key ID for retire and remove")
+		delay  = flag.Duration("delay", time.Hour, "How long rotate publishes a new key before it signs; at least the JWKS cache lifetime")
+	)
+	flag.Parse()
+
+	keysDir := *dir
+	if keysDir == "" {
+		keysDir = os.Getenv("JWT_KEYS_DIR")
+	}
+	if keysDir == "" {
+		log.Fatal("Key directory is required. Set JWT_KEYS_DIR environment variable or use -dir flag")
+	}
+
+	switch *action {
+	case "rotate":
+		if err := rotate(keysDir, *alg, *delay); err != nil {
+			log.Fatalf("Failed to rotate keys: %v", err)
+		}
+	case "list":
+		if err := list(keysDir); err != nil {
+			log.Fatalf("Failed to list keys: %v", err)
+		}
+	case "retire":
+		if err := retire(keysDir, *kid); err != nil {
+			log.Fatalf("Failed to retire key: %v", err)
+		}
+	case "remove":
+		if err := remove(keysDir, *kid); err != nil {
+			log.Fatalf("Failed to remove key: %v", err)
+		}
+	default:
+		fmt.Printf("Unknown action: %s\n", *action)
+		fmt.Println("Available actions: rotate, list, retire, remove")
+		os.Exit(1)
+	}
+}
+
+// rotate generates a new signing key that starts signing once delay has passed, or at once
+// when the directory has no signing key yet
+func rotate(dir, alg string, delay time.Duration) error {
+	if delay < middleware.JWKSMaxAge {
+		return fmt.Errorf("-delay must be at least the JWKS cache lifetime (%s)", middleware.JWKSMaxAge)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	activatesAt := time.Now()
+	if _, err := middleware.LoadKeySet(dir); err == nil {
+		activatesAt = activatesAt.Add(delay)
+	}
+
+	key, err := middleware.GenerateJWTKey(alg, activatesAt)
+	if err != nil {
+		return err
+	}
+
+	data, err := middleware.EncodePrivateKeyPEM(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, key.KID+middleware.PrivateKeySuffix), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	fmt.Printf("Generated %s key %s, signing from %s\n", key.Method.Alg(), key.KID, activatesAt.UTC().Format(time.RFC3339))
+
+	return nil
+}
+
+// list prints every key in the directory
+func list(dir string) error {
+	set, err := middleware.LoadKeySet(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-34s %-6s %s\n", "KID", "ALG", "STATUS")
+	for _, key := range set.Keys() {
+		status := "retired (verify only)"
+		if key.KID == set.ActiveKID() {
+			status = "active (signing)"
+		} else if activatesAt := middleware.KeyActivation(key.KID); key.CanSign() && activatesAt.After(time.Now()) {
+			status = "pending (signing from " + activatesAt.Format(time.RFC3339) + ")"
+		} else if key.CanSign() {
+			status = "inactive (has private key)"
+		}
+		fmt.Printf("%-34s %-6s %s\n", key.KID, key.Method.Alg(), status)
+	}
+
+	return nil
+}
+
+// retire replaces a key's private key file with its public key
+func retire(dir, kid string) error {
+	if kid == "" {
+		return errors.New("-kid is required")
+	}
+
+	set, err := middleware.LoadKeySet(dir)
+	if err != nil {
+		return err
+	}
+	if kid == set.ActiveKID() {
+		return errors.New("cannot retire the active signing key; rotate first")
+	}
+
+	for _, key := range set.Keys() {
+		if key.KID == kid {
+			if !key.CanSign() {
+				return fmt.Errorf("key %s is already retired", kid)
+			}
+			return retireKey(dir, key)
+		}
+	}
+
+	return fmt.Errorf("key %s not found", kid)
+}
+
+// retireKey writes the public key file and then deletes the private key file
+func retireKey(dir string, key *middleware.JWTKey) error {
+	data, err := middleware.EncodePublicKeyPEM(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, key.KID+middleware.PublicKeySuffix), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write public key: %w", err)
+	}
+	if err := os.Remove(filepath.Join(dir, key.KID+middleware.PrivateKeySuffix)); err != nil {
+		return fmt.Errorf("failed to delete private key: %w", err)
+	}
+
+	fmt.Printf("Retired key %s\n", key.KID)
+	return nil
+}
+
+// remove deletes a retired key so it no longer verifies tokens
+func remove(dir, kid string) error {
+	if kid == "" {
+		return errors.New("-kid is required")
+	}
+
+	path := filepath.Join(dir, kid+middleware.PublicKeySuffix)
+	if _, err := os.Stat(path); err != nil {
+		if _, privErr := os.Stat(filepath.Join(dir, kid+middleware.PrivateKeySuffix)); privErr == nil {
+			return fmt.Errorf("key %s still has a private key; retire it first", kid)
+		}
+		return fmt.Errorf("key %s not found", kid)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+
+	fmt.Printf("Removed key %s\n", kid)
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"municollect/internal/oidcmock"
+)
+
+// mockidp runs a local OpenID Connect identity provider for trying staff single sign-on
+// without a real IdP. Start it, then run the server with the environment it prints.
+func main() {
+	var (
+		addr     = flag.String("addr", "localhost:9000", "Address to listen on")
+		clientID = flag.String("client-id", "municollect", "OAuth client ID accepted by the provider")
+		callback = flag.String("callback", "http://localhost:8080/api/auth/oidc/callback", "Backend OIDC callback URL")
+	)
+	flag.Parse()
+
+	provider, err := oidcmock.New(*clientID)
+	if err != nil {
+		log.Fatalf("Failed to create mock identity provider: %v", err)
+	}
+	issuer := "http://" + *addr
+	provider.SetIssuer(issuer)
+
+	provider.AddUser(oidcmock.User{
+		Subject:       "staff-1",
+		Email:         "staff@municollect.test",
+		EmailVerified: true,
+		GivenName:     "Somchai",
+		FamilyName:    "Staff",
+		Groups:        []string{"municollect-staff"},
+	})
+	provider.AddUser(oidcmock.User{
+		Subject:       "admin-1",
+		Email:         "admin@municollect.test",
+		EmailVerified: true,
+		GivenName:     "Suda",
+		FamilyName:    "Admin",
+		Groups:        []string{"municollect-admins", "municollect-staff"},
+	})
+	provider.AddUser(oidcmock.User{
+		Subject:       "guest-1",
+		Email:         "guest@municollect.test",
+		EmailVerified: true,
+		GivenName:     "Guest",
+		FamilyName:    "User",
+		Groups:        []string{"everyone"},
+	})
+	provider.AddUser(oidcmock.User{
+		Subject:    "unverified-1",
+		Email:      "unverified@municollect.test",
+		GivenName:  "Unverified",
+		FamilyName: "User",
+		Groups:     []string{"municollect-staff"},
+	})
+
+	fmt.Println("Mock identity provider listening on " + issuer)
+	fmt.Println("Run the backend with:")
+	fmt.Println("  OIDC_ISSUER=" + issuer)
+	fmt.Println("  OIDC_CLIENT_ID=" + *clientID)
+	fmt.Println("  OIDC_REDIRECT_URL=" + *callback)
+	fmt.Println("  OIDC_ROLE_MAPPING=municollect-staff=municipal_staff,municollect-admins=admin")
+	fmt.Println("Then open http://localhost:8080/api/auth/oidc/login")
+
+	log.Fatal(http.ListenAndServe(*addr, provider.Handler()))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	
 	"municollect/internal/config"
+	"municollect/internal/encryption"
 	"municollect/internal/handlers"
 	"municollect/internal/middleware"
 	"municollect/internal/models"
@@ -18,6 +20,27 @@ import (
 )
 
 func main() {
+	// Load the JWT signing keys before anything else so misconfiguration fails fast
+	keySet, err := middleware.DefaultKeySet()
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	if os.Getenv("GO_ENV") == "production" && !keySet.IsAsymmetric() {
+		log.Fatal("Refusing to start in production with HS256 JWTs, which publish no verification keys; set JWT_KEYS_DIR")
+	}
+	if keySet.IsAsymmetric() {
+		log.Printf("Signing JWTs with key %s", keySet.ActiveKID())
+	}
+	
+	// Load the field encryption keys the same way; personal data cannot be read without them
+	fieldCipher, err := encryption.Default()
+	if err != nil {
+		log.Fatalf("Failed to load field encryption keys: %v", err)
+	}
+	if os.Getenv("GO_ENV") == "production" && fieldCipher.UsesDevelopmentKeys() {
+		log.Fatal("Refusing to start in production with the development encryption keys; set FIELD_ENCRYPTION_KEY_FILE")
+	}
+	
 	// Connect to database
 	db, err := config.ConnectDatabase()
 	if err != nil {
@@ -29,27 +52,164 @@ func main() {
 		log.Fatalf("Failed to run auto-migration: %v", err)
 	}
 	
+	// Initialize the token revocation store (database-backed unless configured otherwise)
+	var revocationStore middleware.RevocationStore
+	if os.Getenv("TOKEN_REVOCATION_STORE") == "memory" {
+		revocationStore = middleware.NewMemoryRevocationStore()
+	} else {
+		revocationStore = services.NewDBRevocationStore(db)
+	}
+	
+	// Initialize the failed login store (database-backed so replicas share counters)
+	var loginAttemptStore services.LoginAttemptStore
+	if os.Getenv("LOGIN_ATTEMPT_STORE") == "memory" {
+		loginAttemptStore = services.NewMemoryLoginAttemptStore()
+	} else {
+		loginAttemptStore = services.NewDBLoginAttemptStore(db)
+	}
+	
+	// Initialize the idempotency key store (database-backed so retries may reach any replica)
+	var idempotencyStore middleware.IdempotencyStore
+	if os.Getenv("IDEMPOTENCY_STORE") == "memory" {
+		idempotencyStore = middleware.NewMemoryIdempotencyStore()
+	} else {
+		idempotencyStore = services.NewDBIdempotencyStore(db)
+	}
+	idempotencyKeyTTL := middleware.IdempotencyKeyTTLFromEnv()
+	
+	// Initialize the payment providers. The mock provider collects online payments unless
+	// PAYMENT_PROVIDER is set to "none"; it is never used in production, which has no real
+	// provider yet and settles payments by hand.
+	paymentProviders := services.NewPaymentProviders()
+	switch os.Getenv("PAYMENT_PROVIDER") {
+	case "none":
+	case "", services.MockPaymentProviderName:
+		if os.Getenv("GO_ENV") == "production" {
+			log.Fatal("Refusing to start in production with the mock payment provider; set PAYMENT_PROVIDER to none")
+		}
+		paymentProviders.Register(services.NewMockPaymentProvider(services.MockProviderConfigFromEnv()), services.MockPaymentMethods...)
+		log.Printf("Collecting online payments with the mock payment provider")
+	default:
+		log.Fatalf("Unknown payment provider %q", os.Getenv("PAYMENT_PROVIDER"))
+	}
+	
+	// Webhooks are verified with PAYMENT_WEBHOOK_SECRET_<PROVIDER>; the mock provider has a
+	// development secret so webhooks can be sent by hand
+	webhookSecrets := services.WebhookSecretsFromEnv(services.MockPaymentProviderName)
+	if _, ok := paymentProviders.Get(services.MockPaymentProviderName); ok && len(webhookSecrets[services.MockPaymentProviderName]) == 0 {
+		webhookSecrets[services.MockPaymentProviderName] = []string{services.MockWebhookSecret}
+		log.Printf("Verifying mock payment webhooks with the development secret")
+	}
+	
 	// Initialize services and handlers
-	authService := middleware.NewAuthService()
-	municipalityService := services.NewMunicipalityService(db)
-	paymentService := services.NewPaymentService(db)
+	sessionService := services.NewSessionService(db)
+	authService := middleware.NewAuthServiceWithKeys(keySet)
+	authService.SetRevocationStore(revocationStore)
+	authService.SetSessionTracker(sessionService)
+	revocationService := services.NewTokenRevocationService(db, revocationStore)
+	mailSender, err := services.NewMailSenderFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+	if _, ok := mailSender.(*services.LogMailSender); ok && os.Getenv("GO_ENV") == "production" {
+		log.Fatal("Refusing to start in production with the log mail sender, which writes reset links and codes to the log; set MAIL_SENDER to smtp")
+	}
+	smsSender, err := services.NewSMSSenderFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid SMS configuration: %v", err)
+	}
+	if _, ok := smsSender.(*services.LogSMSSender); ok && os.Getenv("GO_ENV") == "production" {
+		log.Fatal("Refusing to start in production with the log SMS sender, which writes verification codes to the log; set SMS_SENDER to twilio")
+	}
+	consentService := services.NewConsentService(db, services.ConsentVersionsFromEnv())
+	if err := consentService.RegisterHooks(db); err != nil {
+		log.Fatalf("Failed to register consent hooks: %v", err)
+	}
+	notificationService := services.NewNotificationService(db, consentService, mailSender, smsSender)
+	passwordResetService := services.NewPasswordResetService(db, mailSender, revocationService)
+	verificationService := services.NewVerificationService(db, mailSender, smsSender)
+	mfaService := services.NewMFAService(db)
+	loginProtection := services.NewLoginProtectionService(db, loginAttemptStore, notificationService, services.DefaultLoginProtectionConfig())
+	apiKeyService := services.NewAPIKeyService(db)
+	auditService := services.NewAuditService(db)
+	adminUserService := services.NewAdminUserService(db, auditService, revocationService, passwordResetService, loginProtection)
+	permissionService := services.NewPermissionService(db, auditService)
+	membershipService := services.NewMembershipService(db, auditService)
+	invitationService := services.NewInvitationService(db, mailSender, permissionService, auditService)
+	impersonationService := services.NewImpersonationService(db, auditService, revocationService)
+	authService.SetImpersonationRecorder(impersonationService)
+	dataRequestService := services.NewDataRequestService(db, auditService, revocationService)
+	householdService := services.NewHouseholdService(db, auditService)
+	municipalityService := services.NewMunicipalityService(db, auditService)
+	paymentService := services.NewPaymentService(db, auditService)
+	paymentService.SetProviders(paymentProviders)
+	webhookService := services.NewWebhookService(db, paymentService, paymentProviders, webhookSecrets)
 	qrCodeService := services.NewQRCodeService(db)
 	
-	authHandler := handlers.NewAuthHandler(db)
+	authHandler := handlers.NewAuthHandler(db, revocationService, passwordResetService, verificationService, mfaService, loginProtection, auditService, consentService)
+	verificationHandler := handlers.NewVerificationHandler(verificationService)
+	adminHandler := handlers.NewAdminHandler(db, adminUserService)
 	userHandler := handlers.NewUserHandler(db)
+	sessionHandler := handlers.NewSessionHandler(db, sessionService, revocationService, auditService)
+	membershipHandler := handlers.NewMembershipHandler(membershipService)
+	municipalRoleHandler := handlers.NewMunicipalRoleHandler(permissionService)
+	invitationHandler := handlers.NewInvitationHandler(invitationService)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService)
+	dataRequestHandler := handlers.NewDataRequestHandler(dataRequestService)
+	householdHandler := handlers.NewHouseholdHandler(householdService)
+	consentHandler := handlers.NewConsentHandler(consentService)
+	auditLogHandler := handlers.NewAuditLogHandler(auditService)
 	municipalityHandler := handlers.NewMunicipalityHandler(municipalityService)
 	paymentHandler := handlers.NewPaymentHandler(paymentService)
 	qrCodeHandler := handlers.NewQRCodeHandler(qrCodeService)
+	jwksHandler := handlers.NewJWKSHandler(keySet)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	partnerHandler := handlers.NewPartnerHandler(db, paymentService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	
+	// Approved data export and erasure requests are carried out in the background
+	go dataRequestService.Run(context.Background(), services.DataRequestPollInterval)
+	
+	// Expired refresh tokens, revoked token entries and idempotency keys are deleted in the background
+	expiredRecords := []services.ExpiredRecordDeleter{services.NewRefreshTokenService(db)}
+	if store, ok := revocationStore.(services.ExpiredRecordDeleter); ok {
+		expiredRecords = append(expiredRecords, store)
+	}
+	if store, ok := idempotencyStore.(services.ExpiredRecordDeleter); ok {
+		expiredRecords = append(expiredRecords, store)
+	}
+	go services.RunExpiredRecordCleanup(context.Background(), services.ExpiredRecordCleanupInterval, expiredRecords...)
+	
+	// Pending payments settle from their providers' results, even if a webhook is missed
+	go paymentService.RunProviderSync(context.Background(), services.PaymentProviderSyncInterval)
+	
+	// Webhook events that could not be applied when they arrived are retried from the inbox
+	go webhookService.Run(context.Background(), services.WebhookRetryInterval)
+	
+	// Staff single sign-on is enabled when an OIDC identity provider is configured
+	oidcConfig, err := services.OIDCConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid OIDC configuration: %v", err)
+	}
+	if oidcConfig != nil {
+		authHandler.EnableOIDC(services.NewOIDCLoginService(db, services.NewOIDCProvider(oidcConfig)))
+		log.Printf("OIDC login enabled with issuer %s", oidcConfig.Issuer)
+	}
 	
 	app := fiber.New(fiber.Config{
 		AppName:      "MuniCollect Backend API v1.0.0",
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		ErrorHandler: middleware.ErrorHandler,
+		// Behind a load balancer (e.g. Render) the client IP comes from a proxy header;
+		// login throttling per IP depends on it
+		ProxyHeader:        os.Getenv("PROXY_IP_HEADER"),
+		EnableIPValidation: true,
 	})
 
 	// Basic middleware
 	app.Use(recover.New())
+	app.Use(middleware.NewRequestIDMiddleware())
 	app.Use(logger.New())
 	
 	// CORS middleware
@@ -60,7 +220,7 @@ func main() {
 	
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     corsOrigins,
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Request-ID, Idempotency-Key",
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowCredentials: true,
 	}))
@@ -84,6 +244,9 @@ func main() {
 		})
 	})
 
+	// Public JWT verification keys
+	app.Get("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// API routes
 	api := app.Group("/api")
 	
@@ -101,15 +264,47 @@ func main() {
 	auth.Post("/login", authHandler.Login)
 	auth.Post("/refresh", authHandler.RefreshToken)
 	auth.Delete("/logout", authHandler.Logout)
+	auth.Delete("/logout/all", middleware.JWTMiddleware(authService), middleware.DenyImpersonation(), authHandler.LogoutAll)
+	auth.Put("/password", middleware.JWTMiddleware(authService), middleware.DenyImpersonation(), authHandler.ChangePassword)
+	auth.Post("/password/forgot", authHandler.ForgotPassword)
+	auth.Post("/password/reset", authHandler.ResetPassword)
+	auth.Get("/invitations/:token", invitationHandler.GetInvitation)
+	auth.Post("/invitations/accept", invitationHandler.AcceptInvitation)
+	auth.Post("/impersonation/end", middleware.JWTMiddleware(authService), impersonationHandler.EndImpersonation)
+	
+	// Two-factor authentication routes
+	auth.Post("/mfa/verify", authHandler.VerifyMFA)
+	auth.Post("/mfa/enroll", middleware.OptionalJWTMiddleware(authService), authHandler.BeginMFAEnrollment)
+	auth.Post("/mfa/enroll/confirm", middleware.OptionalJWTMiddleware(authService), authHandler.ConfirmMFAEnrollment)
+	auth.Post("/mfa/recovery-codes", middleware.JWTMiddleware(authService), middleware.DenyImpersonation(), authHandler.RegenerateMFARecoveryCodes)
+	auth.Delete("/mfa", middleware.JWTMiddleware(authService), middleware.DenyImpersonation(), authHandler.DisableMFA)
+	
+	// Single sign-on through the organisation's identity provider
+	if oidcConfig != nil {
+		auth.Get("/oidc/login", authHandler.OIDCLogin)
+		auth.Get("/oidc/callback", authHandler.OIDCCallback)
+	}
 
 	// Protected user routes
 	users := api.Group("/users")
 	users.Use(middleware.JWTMiddleware(authService))
 	users.Get("/profile", userHandler.GetProfile)
-	users.Put("/profile", userHandler.UpdateProfile)
+	users.Put("/profile", middleware.DenyImpersonation(), userHandler.UpdateProfile)
 	users.Get("/municipalities", userHandler.GetUserMunicipalities)
 	users.Post("/municipalities", userHandler.AssociateMunicipality)
 	users.Delete("/municipalities/:municipalityId", userHandler.RemoveMunicipalityAssociation)
+	users.Post("/verification/send", middleware.DenyImpersonation(), verificationHandler.SendCode)
+	users.Post("/verification/confirm", middleware.DenyImpersonation(), verificationHandler.ConfirmCode)
+	users.Get("/sessions", sessionHandler.ListSessions)
+	users.Delete("/sessions/:id", middleware.DenyImpersonation(), sessionHandler.RevokeSession)
+	users.Get("/consents", consentHandler.GetConsents)
+	users.Put("/consents", middleware.DenyImpersonation(), consentHandler.UpdateConsents)
+	users.Get("/consents/history", consentHandler.GetConsentHistory)
+	users.Get("/data-requests", dataRequestHandler.ListDataRequests)
+	users.Post("/data-requests", middleware.DenyImpersonation(), dataRequestHandler.CreateDataRequest)
+	users.Get("/data-requests/:id/archive", middleware.DenyImpersonation(), dataRequestHandler.DownloadDataExport)
+	users.Put("/national-id", middleware.DenyImpersonation(), householdHandler.SetNationalID)
+	users.Get("/household", householdHandler.GetMyHousehold)
 
 	// Municipality routes
 	municipalities := api.Group("/municipalities")
@@ -119,28 +314,97 @@ func main() {
 	municipalities.Get("/:id", municipalityHandler.GetMunicipality)
 	municipalities.Get("/code/:code", municipalityHandler.GetMunicipalityByCode)
 	
-	// Protected municipality routes (admin only)
+	// Protected municipality routes: creating and deleting municipalities is a platform permission,
+	// managing one is granted by the member's role in that municipality
+	requireMunicipalityPermission := func(permission models.Permission) fiber.Handler {
+		return middleware.RequireMunicipalityPermission(permissionService, middleware.MunicipalityFromParam("id"), string(permission))
+	}
 	municipalitiesAdmin := municipalities.Use(middleware.JWTMiddleware(authService))
-	municipalitiesAdmin.Use(middleware.RequireRole("admin"))
-	municipalitiesAdmin.Post("/", municipalityHandler.CreateMunicipality)
-	municipalitiesAdmin.Put("/:id", municipalityHandler.UpdateMunicipality)
-	municipalitiesAdmin.Delete("/:id", municipalityHandler.DeleteMunicipality)
+	municipalitiesAdmin.Post("/", middleware.RequirePermission(permissionService, string(models.PermissionMunicipalitiesManage)), municipalityHandler.CreateMunicipality)
+	municipalitiesAdmin.Put("/:id", requireMunicipalityPermission(models.PermissionMunicipalityManage), municipalityHandler.UpdateMunicipality)
+	municipalitiesAdmin.Delete("/:id", middleware.RequirePermission(permissionService, string(models.PermissionMunicipalitiesManage)), municipalityHandler.DeleteMunicipality)
+	municipalitiesAdmin.Get("/:id/members", requireMunicipalityPermission(models.PermissionStaffManage), membershipHandler.ListMembers)
+	municipalitiesAdmin.Put("/:id/members/:userId", requireMunicipalityPermission(models.PermissionStaffManage), membershipHandler.SetMemberRole)
+	municipalitiesAdmin.Get("/:id/invitations", requireMunicipalityPermission(models.PermissionStaffManage), invitationHandler.ListInvitations)
+	municipalitiesAdmin.Post("/:id/invitations", requireMunicipalityPermission(models.PermissionStaffManage), invitationHandler.CreateInvitation)
+	municipalitiesAdmin.Post("/:id/invitations/:invitationId/resend", requireMunicipalityPermission(models.PermissionStaffManage), invitationHandler.ResendInvitation)
+	municipalitiesAdmin.Delete("/:id/invitations/:invitationId", requireMunicipalityPermission(models.PermissionStaffManage), invitationHandler.RevokeInvitation)
+	municipalitiesAdmin.Get("/:id/roles", requireMunicipalityPermission(models.PermissionStaffManage), municipalRoleHandler.ListRoles)
+	municipalitiesAdmin.Post("/:id/roles", requireMunicipalityPermission(models.PermissionStaffManage), municipalRoleHandler.CreateRole)
+	municipalitiesAdmin.Put("/:id/roles/:key", requireMunicipalityPermission(models.PermissionStaffManage), municipalRoleHandler.UpdateRole)
+	municipalitiesAdmin.Delete("/:id/roles/:key", requireMunicipalityPermission(models.PermissionStaffManage), municipalRoleHandler.DeleteRole)
+	municipalitiesAdmin.Post("/:id/residents/lookup", requireMunicipalityPermission(models.PermissionResidentsRead), householdHandler.LookupResident)
+	municipalitiesAdmin.Get("/:id/households", requireMunicipalityPermission(models.PermissionResidentsRead), householdHandler.ListHouseholds)
+	municipalitiesAdmin.Post("/:id/households", requireMunicipalityPermission(models.PermissionResidentsWrite), householdHandler.CreateHousehold)
+	municipalitiesAdmin.Get("/:id/households/:householdId", requireMunicipalityPermission(models.PermissionResidentsRead), householdHandler.GetHousehold)
+	municipalitiesAdmin.Post("/:id/households/:householdId/members", requireMunicipalityPermission(models.PermissionResidentsWrite), householdHandler.AddHouseholdMember)
+	municipalitiesAdmin.Delete("/:id/households/:householdId/members/:userId", requireMunicipalityPermission(models.PermissionResidentsWrite), householdHandler.RemoveHouseholdMember)
+
+	// Permission catalogue
+	api.Get("/permissions", middleware.JWTMiddleware(authService), municipalRoleHandler.ListPermissions)
 
 	// Payment routes
 	payments := api.Group("/payments")
 	payments.Use(middleware.JWTMiddleware(authService))
 	
 	// User payment routes
-	payments.Post("/initiate", paymentHandler.CreatePayment)
+	payments.Post("/initiate", middleware.Idempotency(idempotencyStore, middleware.IdempotencyScopePaymentInitiate, idempotencyKeyTTL), paymentHandler.CreatePayment)
 	payments.Get("/user", paymentHandler.GetUserPayments)
 	payments.Get("/history", paymentHandler.GetPaymentHistory)
 	payments.Get("/:id", paymentHandler.GetPayment)
 	payments.Get("/:id/status", paymentHandler.GetPaymentStatus)
 	
-	// Admin payment routes
-	paymentsAdmin := payments.Use(middleware.RequireRole("admin"))
-	paymentsAdmin.Put("/:id/status", paymentHandler.UpdatePaymentStatus)
-	paymentsAdmin.Get("/municipality/:municipalityId", paymentHandler.GetMunicipalityPayments)
+	// Staff payment routes, limited to the staff of the payment's municipality
+	payments.Put("/:id/status",
+		middleware.RequireMunicipalityPermission(permissionService, membershipService.PaymentMunicipality("id"), string(models.PermissionPaymentsUpdateStatus)),
+		middleware.Idempotency(idempotencyStore, middleware.IdempotencyScopePaymentStatus, idempotencyKeyTTL),
+		paymentHandler.UpdatePaymentStatus)
+	payments.Post("/:id/refund",
+		middleware.RequireMunicipalityPermission(permissionService, membershipService.PaymentMunicipality("id"), string(models.PermissionPaymentsUpdateStatus)),
+		middleware.Idempotency(idempotencyStore, middleware.IdempotencyScopePaymentRefund, idempotencyKeyTTL),
+		paymentHandler.RefundPayment)
+	payments.Get("/municipality/:municipalityId",
+		middleware.RequireMunicipalityPermission(permissionService, middleware.MunicipalityFromParam("municipalityId"), string(models.PermissionPaymentsRead)),
+		paymentHandler.GetMunicipalityPayments)
+
+	// Admin user management routes
+	admin := api.Group("/admin")
+	admin.Use(middleware.JWTMiddleware(authService))
+	adminUsers := admin.Group("/users", middleware.RequirePermission(permissionService, string(models.PermissionUsersManage)))
+	adminUsers.Get("/", adminHandler.ListUsers)
+	adminUsers.Get("/:id", adminHandler.GetUser)
+	adminUsers.Put("/:id/role", adminHandler.UpdateUserRole)
+	adminUsers.Post("/:id/disable", adminHandler.DisableUser)
+	adminUsers.Post("/:id/enable", adminHandler.EnableUser)
+	adminUsers.Post("/:id/password-reset", adminHandler.ForcePasswordReset)
+	adminUsers.Post("/:id/signout", adminHandler.SignOutUser)
+	adminUsers.Post("/:id/unlock", adminHandler.UnlockUser)
+	adminUsers.Post("/:id/impersonate", middleware.DenyImpersonation(), impersonationHandler.ImpersonateUser)
+	adminUsers.Get("/:id/sessions", sessionHandler.ListUserSessions)
+	adminUsers.Delete("/:id/sessions/:sessionId", sessionHandler.RevokeUserSession)
+	adminAPIKeys := admin.Group("/api-keys", middleware.RequirePermission(permissionService, string(models.PermissionAPIKeysManage)))
+	adminAPIKeys.Get("/", apiKeyHandler.ListAPIKeys)
+	adminAPIKeys.Post("/", apiKeyHandler.CreateAPIKey)
+	adminAPIKeys.Delete("/:id", apiKeyHandler.RevokeAPIKey)
+	adminAuditLogs := admin.Group("/audit-logs", middleware.RequirePermission(permissionService, string(models.PermissionAuditLogsRead)))
+	adminAuditLogs.Get("/", auditLogHandler.ListAuditLogs)
+	adminAuditLogs.Get("/export", auditLogHandler.ExportAuditLogs)
+	adminAuditLogs.Get("/verify", auditLogHandler.VerifyAuditLogs)
+	adminDataRequests := admin.Group("/data-requests", middleware.RequirePermission(permissionService, string(models.PermissionDataRequestsManage)))
+	adminDataRequests.Get("/", dataRequestHandler.ListDataRequestQueue)
+	adminDataRequests.Post("/:id/approve", dataRequestHandler.ApproveDataRequest)
+	adminDataRequests.Post("/:id/reject", dataRequestHandler.RejectDataRequest)
+
+	// Payment provider webhooks (authenticated by their signatures)
+	webhooks := api.Group("/webhooks")
+	webhooks.Post("/payments/:provider", webhookHandler.ReceivePaymentWebhook)
+
+	// Partner integration routes (API key authentication)
+	partner := api.Group("/partner")
+	partner.Use(middleware.APIKeyMiddleware(apiKeyService))
+	partner.Get("/residents/:id", middleware.RequireScope(string(models.APIScopeResidentsRead)), partnerHandler.GetResident)
+	partner.Get("/payments/:id/status", middleware.RequireScope(string(models.APIScopePaymentsRead)), partnerHandler.GetPaymentStatus)
+	partner.Put("/payments/:id/status", middleware.RequireScope(string(models.APIScopePaymentsWrite)), partnerHandler.UpdatePaymentStatus)
 
 	// QR Code routes
 	qr := api.Group("/qr")
@@ -0,0 +1,196 @@
+// Package encryption encrypts personal data at rest with envelope encryption. Every value
+// is encrypted with its own random data key, and the data key is stored next to it wrapped
+// by a key encryption key (KEK) held by a KeyProvider. Blind indexes let encrypted values
+// be looked up by equality without decrypting them.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix marks a value encrypted by this package. Values without it are plaintext that
+// was written before encryption was enabled.
+const Prefix = "enc:v1:"
+
+// KeySize is the size in bytes of data keys, key encryption keys and index keys
+const KeySize = 32
+
+// Encryption errors
+var (
+	ErrMalformed  = errors.New("malformed encrypted value")
+	ErrUnknownKey = errors.New("unknown key encryption key")
+	ErrDecrypt    = errors.New("failed to decrypt value")
+)
+
+// KeyProvider holds the key encryption keys that data keys are wrapped with, and the key
+// blind indexes are computed with. The local key file provider is meant for development
+// and tests; a KMS can be plugged in by implementing this interface.
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the current key encryption key and returns its ID
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped by the key encryption key with the given ID
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+	// IndexKey returns the blind index key. It must not change when key encryption keys
+	// are rotated, or existing indexes stop matching.
+	IndexKey() []byte
+}
+
+// Cipher encrypts and decrypts values and computes blind indexes with the keys of a KeyProvider
+type Cipher struct {
+	provider KeyProvider
+}
+
+// NewCipher creates a cipher using the given key provider
+func NewCipher(provider KeyProvider) *Cipher {
+	return &Cipher{provider: provider}
+}
+
+// UsesDevelopmentKeys reports whether the cipher uses the built-in development keys
+func (c *Cipher) UsesDevelopmentKeys() bool {
+	local, ok := c.provider.(*LocalKeyProvider)
+	return ok && local.development
+}
+
+// ActiveKeyID returns the ID of the key encryption key new values are encrypted under
+func (c *Cipher) ActiveKeyID() (string, error) {
+	// Providers only report the key when wrapping, so wrap a throwaway data key
+	keyID, _, err := c.provider.WrapKey(make([]byte, KeySize))
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return keyID, nil
+}
+
+// Encrypt encrypts plaintext under a new data key and returns it in the stored form
+// "enc:v1:<key id>:<wrapped data key>:<nonce and ciphertext>"
+func (c *Cipher) Encrypt(plaintext []byte) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	sealed, err := seal(dataKey, plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	keyID, wrapped, err := c.provider.WrapKey(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return Prefix + keyID + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt
+func (c *Cipher) Decrypt(value string) ([]byte, error) {
+	keyID, wrapped, sealed, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := c.provider.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(dataKey, sealed)
+}
+
+// BlindIndex returns a keyed hash of value that it can be looked up by. The domain names
+// the field, so equal values in different fields get different indexes.
+func (c *Cipher) BlindIndex(domain, value string) string {
+	mac := hmac.New(sha256.New, c.provider.IndexKey())
+	mac.Write([]byte(domain))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted reports whether value is in the form produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// KeyID returns the ID of the key encryption key that wrapped value's data key
+func KeyID(value string) (string, error) {
+	keyID, _, _, err := parse(value)
+	return keyID, err
+}
+
+// parse splits an encrypted value into its key ID, wrapped data key and sealed data
+func parse(value string) (string, []byte, []byte, error) {
+	if !IsEncrypted(value) {
+		return "", nil, nil, ErrMalformed
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", nil, nil, ErrMalformed
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+
+	return parts[0], wrapped, sealed, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM under key and returns the nonce followed by the ciphertext
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts data sealed by seal
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// newGCM creates an AES-GCM cipher for a 256-bit key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,150 @@
+package encryption
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testProvider(t *testing.T) *LocalKeyProvider {
+	provider, err := GenerateLocalKeyProvider(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	return provider
+}
+
+func TestCipher_EncryptDecrypt(t *testing.T) {
+	cipher := NewCipher(testProvider(t))
+
+	encrypted, err := cipher.Encrypt([]byte("resident@example.com"))
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "resident")
+
+	decrypted, err := cipher.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "resident@example.com", string(decrypted))
+
+	// Every value gets its own data key and nonce
+	again, err := cipher.Encrypt([]byte("resident@example.com"))
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again)
+
+	// Tampering is detected
+	flipped := byte('A')
+	if encrypted[len(encrypted)-10] == flipped {
+		flipped = 'B'
+	}
+	tampered := encrypted[:len(encrypted)-10] + string(flipped) + encrypted[len(encrypted)-9:]
+	_, err = cipher.Decrypt(tampered)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = cipher.Decrypt("resident@example.com")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	// Values cannot be decrypted with other keys, even under the same key ID
+	other := testProvider(t)
+	_, err = NewCipher(other).Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	keyID, err := KeyID(encrypted)
+	require.NoError(t, err)
+	impostor, err := NewLocalKeyProvider(other.IndexKey(), map[string][]byte{keyID: other.keys[other.ActiveKeyID()]})
+	require.NoError(t, err)
+	_, err = NewCipher(impostor).Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestLocalKeyProvider_Rotate(t *testing.T) {
+	provider := testProvider(t)
+	cipher := NewCipher(provider)
+	oldKey := provider.ActiveKeyID()
+
+	old, err := cipher.Encrypt([]byte("+66812345678"))
+	require.NoError(t, err)
+
+	newKey, err := provider.Rotate(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, newKey, provider.ActiveKeyID())
+
+	// New values use the new key; old ones stay readable
+	current, err := cipher.Encrypt([]byte("+66812345678"))
+	require.NoError(t, err)
+	keyID, err := KeyID(current)
+	require.NoError(t, err)
+	assert.Equal(t, newKey, keyID)
+
+	decrypted, err := cipher.Decrypt(old)
+	require.NoError(t, err)
+	assert.Equal(t, "+66812345678", string(decrypted))
+
+	// The active key cannot be removed; once an old key is, its values are unreadable
+	assert.Error(t, provider.Remove(newKey))
+	require.NoError(t, provider.Remove(oldKey))
+	_, err = cipher.Decrypt(old)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestParseKeyFile(t *testing.T) {
+	provider := testProvider(t)
+	_, err := provider.Rotate(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	parsed, err := ParseKeyFile(provider.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, provider.KeyIDs(), parsed.KeyIDs())
+	assert.Equal(t, provider.ActiveKeyID(), parsed.ActiveKeyID())
+	assert.Equal(t, provider.IndexKey(), parsed.IndexKey())
+
+	for _, data := range []string{
+		"",
+		"index c2hvcnQ=\n",
+		"kek 2024 " + strings.Repeat("A", 43) + "=\n",
+		"secret value\n",
+	} {
+		_, err := ParseKeyFile([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestCipher_BlindIndex(t *testing.T) {
+	provider := testProvider(t)
+	cipher := NewCipher(provider)
+
+	index := cipher.BlindIndex("users.email", "resident@example.com")
+	assert.Len(t, index, 64)
+	assert.Equal(t, index, cipher.BlindIndex("users.email", "resident@example.com"))
+	assert.NotEqual(t, index, cipher.BlindIndex("users.email", "staff@example.com"))
+	assert.NotEqual(t, index, cipher.BlindIndex("users.phone", "resident@example.com"))
+
+	// Rotating key encryption keys leaves blind indexes unchanged
+	_, err := provider.Rotate(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, index, cipher.BlindIndex("users.email", "resident@example.com"))
+}
+
+func TestSerializer_FieldValues(t *testing.T) {
+	phone := "+66812345678"
+	for _, value := range []interface{}{
+		"resident@example.com",
+		&phone,
+		map[string]interface{}{"firstName": "Somchai", "amount": 120.5},
+	} {
+		plaintext, err := encodeField(value)
+		require.NoError(t, err)
+
+		decoded := reflect.New(reflect.TypeOf(value))
+		require.NoError(t, decodeField(decoded, plaintext))
+		assert.Equal(t, value, decoded.Elem().Interface())
+	}
+
+	// Nil values are stored as NULL
+	for _, value := range []interface{}{nil, (*string)(nil), map[string]interface{}(nil)} {
+		plaintext, err := encodeField(value)
+		require.NoError(t, err)
+		assert.Nil(t, plaintext)
+	}
+}
@@ -0,0 +1,302 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocalKeyProvider holds key encryption keys and the blind index key in a local key file.
+// New data keys are wrapped with the newest key encryption key; older ones are kept so
+// values they wrapped can still be decrypted until they are re-encrypted.
+//
+// The key file has one key per line, with blank lines and lines starting with # ignored:
+//
+//	index <base64 key>
+//	kek <key id> <base64 key>
+type LocalKeyProvider struct {
+	active      string
+	keys        map[string][]byte
+	indexKey    []byte
+	development bool
+}
+
+// NewLocalKeyProvider creates a provider from a blind index key and key encryption keys by
+// ID. The active key is the one with the greatest ID, which is the newest one for IDs
+// generated by GenerateKeyID.
+func NewLocalKeyProvider(indexKey []byte, keys map[string][]byte) (*LocalKeyProvider, error) {
+	if len(indexKey) != KeySize {
+		return nil, fmt.Errorf("index key must be %d bytes", KeySize)
+	}
+
+	provider := &LocalKeyProvider{
+		keys:     make(map[string][]byte, len(keys)),
+		indexKey: indexKey,
+	}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ": \t") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %s must be %d bytes", id, KeySize)
+		}
+		provider.keys[id] = key
+		if id > provider.active {
+			provider.active = id
+		}
+	}
+	if provider.active == "" {
+		return nil, fmt.Errorf("no key encryption key")
+	}
+
+	return provider, nil
+}
+
+// GenerateLocalKeyProvider creates a provider with a new index key and one new key encryption key
+func GenerateLocalKeyProvider(now time.Time) (*LocalKeyProvider, error) {
+	indexKey, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+	id, err := GenerateKeyID(now)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLocalKeyProvider(indexKey, map[string][]byte{id: key})
+}
+
+// GenerateKeyID returns a new key ID that sorts after the IDs of keys generated earlier
+func GenerateKeyID(now time.Time) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate key id: %w", err)
+	}
+	return fmt.Sprintf("%s-%s", now.UTC().Format("20060102T150405Z"), hex.EncodeToString(suffix)), nil
+}
+
+// ParseKeyFile parses the contents of a key file
+func ParseKeyFile(data []byte) (*LocalKeyProvider, error) {
+	var indexKey []byte
+	keys := make(map[string][]byte)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch {
+		case fields[0] == "index" && len(fields) == 2:
+			if indexKey != nil {
+				return nil, fmt.Errorf("line %d: duplicate index key", line)
+			}
+			key, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid index key: %w", line, err)
+			}
+			indexKey = key
+		case fields[0] == "kek" && len(fields) == 3:
+			if _, exists := keys[fields[1]]; exists {
+				return nil, fmt.Errorf("line %d: duplicate key id %q", line, fields[1])
+			}
+			key, err := base64.StdEncoding.DecodeString(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid key %s: %w", line, fields[1], err)
+			}
+			keys[fields[1]] = key
+		default:
+			return nil, fmt.Errorf("line %d: expected \"index <key>\" or \"kek <id> <key>\"", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewLocalKeyProvider(indexKey, keys)
+}
+
+// LoadKeyFile loads a provider from the key file at path
+func LoadKeyFile(path string) (*LocalKeyProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+	}
+
+	provider, err := ParseKeyFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key file: %w", err)
+	}
+	return provider, nil
+}
+
+// Encode returns the provider's keys in the key file format
+func (p *LocalKeyProvider) Encode() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# MuniCollect field encryption keys. Keep this file secret and never change the index key.\n")
+	fmt.Fprintf(&buf, "index %s\n", base64.StdEncoding.EncodeToString(p.indexKey))
+	for _, id := range p.KeyIDs() {
+		fmt.Fprintf(&buf, "kek %s %s\n", id, base64.StdEncoding.EncodeToString(p.keys[id]))
+	}
+	return buf.Bytes()
+}
+
+// Rotate adds a new key encryption key and makes it the active one
+func (p *LocalKeyProvider) Rotate(now time.Time) (string, error) {
+	key, err := generateKey()
+	if err != nil {
+		return "", err
+	}
+	id, err := GenerateKeyID(now)
+	if err != nil {
+		return "", err
+	}
+	if id <= p.active {
+		return "", fmt.Errorf("new key id %s does not sort after the active key %s", id, p.active)
+	}
+
+	p.keys[id] = key
+	p.active = id
+	return id, nil
+}
+
+// Remove deletes a key encryption key that is no longer active. Values it wrapped can
+// no longer be decrypted, so re-encrypt them first.
+func (p *LocalKeyProvider) Remove(id string) error {
+	if _, exists := p.keys[id]; !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	if id == p.active {
+		return fmt.Errorf("cannot remove the active key %s; rotate first", id)
+	}
+
+	delete(p.keys, id)
+	return nil
+}
+
+// ActiveKeyID returns the ID of the key new data keys are wrapped with
+func (p *LocalKeyProvider) ActiveKeyID() string {
+	return p.active
+}
+
+// KeyIDs returns the IDs of every key encryption key, oldest first
+func (p *LocalKeyProvider) KeyIDs() []string {
+	ids := make([]string, 0, len(p.keys))
+	for id := range p.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// WrapKey encrypts a data key with the active key encryption key
+func (p *LocalKeyProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(p.keys[p.active], dataKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.active, wrapped, nil
+}
+
+// UnwrapKey decrypts a data key wrapped by the key encryption key with the given ID
+func (p *LocalKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	return open(key, wrapped)
+}
+
+// IndexKey returns the blind index key
+func (p *LocalKeyProvider) IndexKey() []byte {
+	return p.indexKey
+}
+
+// generateKey returns a new random key
+func generateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// developmentKeyProvider returns a provider with fixed keys for development and tests.
+// Anyone with the source can decrypt what it encrypts.
+func developmentKeyProvider() *LocalKeyProvider {
+	derive := func(label string) []byte {
+		sum := sha256.Sum256([]byte("municollect development " + label))
+		return sum[:]
+	}
+
+	provider, _ := NewLocalKeyProvider(derive("index key"), map[string][]byte{"development": derive("key encryption key")})
+	provider.development = true
+	return provider
+}
+
+var (
+	defaultCipher   *Cipher
+	defaultCipherMu sync.Mutex
+)
+
+// CipherFromEnv creates a cipher with the key file named by FIELD_ENCRYPTION_KEY_FILE,
+// falling back to fixed development keys when it is not set
+func CipherFromEnv() (*Cipher, error) {
+	if path := os.Getenv("FIELD_ENCRYPTION_KEY_FILE"); path != "" {
+		provider, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewCipher(provider), nil
+	}
+
+	return NewCipher(developmentKeyProvider()), nil
+}
+
+// Default returns the process-wide cipher used by the GORM serializer and blind indexes,
+// loading it from the environment on first use unless SetDefault was called
+func Default() (*Cipher, error) {
+	defaultCipherMu.Lock()
+	defer defaultCipherMu.Unlock()
+
+	if defaultCipher == nil {
+		cipher, err := CipherFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		defaultCipher = cipher
+	}
+	return defaultCipher, nil
+}
+
+// MustDefault returns the process-wide cipher and panics if it cannot be loaded. Servers
+// load it with Default at startup, so later calls cannot fail.
+func MustDefault() *Cipher {
+	cipher, err := Default()
+	if err != nil {
+		panic(fmt.Sprintf("encryption keys are not available: %v", err))
+	}
+	return cipher
+}
+
+// SetDefault replaces the process-wide cipher, for example with one backed by a KMS
+func SetDefault(cipher *Cipher) {
+	defaultCipherMu.Lock()
+	defer defaultCipherMu.Unlock()
+
+	defaultCipher = cipher
+}
@@ -0,0 +1,106 @@
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// Serializer is the GORM serializer registered as "encrypted". String fields are encrypted
+// as they are and other fields, such as maps, as JSON. Nil values are stored as NULL, and
+// plaintext written before encryption was enabled is still read until it is re-encrypted.
+//
+// GORM skips serializers for map updates such as Updates(map[string]interface{}{...}), so
+// encrypted columns must be updated from a struct, for example with Select(...).Updates(&model).
+type Serializer struct{}
+
+// Scan implements the serializer interface
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := reflect.New(field.FieldType)
+
+	if dbValue != nil {
+		var stored string
+		switch v := dbValue.(type) {
+		case []byte:
+			stored = string(v)
+		case string:
+			stored = v
+		default:
+			return fmt.Errorf("unsupported value %T for encrypted field %s", dbValue, field.Name)
+		}
+
+		plaintext := []byte(stored)
+		if IsEncrypted(stored) {
+			cipher, err := Default()
+			if err != nil {
+				return err
+			}
+			if plaintext, err = cipher.Decrypt(stored); err != nil {
+				return fmt.Errorf("failed to decrypt %s: %w", field.Name, err)
+			}
+		}
+
+		if err := decodeField(fieldValue, plaintext); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", field.Name, err)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// Value implements the serializer interface
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, err := encodeField(fieldValue)
+	if err != nil || plaintext == nil {
+		return nil, err
+	}
+
+	cipher, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Encrypt(plaintext)
+}
+
+// encodeField returns the plaintext a field value is stored as, or nil for nil values
+func encodeField(fieldValue interface{}) ([]byte, error) {
+	value := reflect.ValueOf(fieldValue)
+	if !value.IsValid() {
+		return nil, nil
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+	}
+
+	if indirect := reflect.Indirect(value); indirect.Kind() == reflect.String {
+		return []byte(indirect.String()), nil
+	}
+	return json.Marshal(fieldValue)
+}
+
+// decodeField sets the value target points to from its plaintext
+func decodeField(target reflect.Value, plaintext []byte) error {
+	value := target.Elem()
+	switch {
+	case value.Kind() == reflect.String:
+		value.SetString(string(plaintext))
+	case value.Kind() == reflect.Ptr && value.Type().Elem().Kind() == reflect.String:
+		str := reflect.New(value.Type().Elem())
+		str.Elem().SetString(string(plaintext))
+		value.Set(str)
+	case len(plaintext) > 0:
+		return json.Unmarshal(plaintext, target.Interface())
+	}
+	return nil
+}
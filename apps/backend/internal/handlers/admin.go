@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"municollect/internal/middleware"
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// AdminHandler handles admin-only user operations
+type AdminHandler struct {
+	db               *gorm.DB
+	userService      *services.UserService
+	adminUserService *services.AdminUserService
+	validator        *validator.Validate
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(db *gorm.DB, adminUserService *services.AdminUserService) *AdminHandler {
+	return &AdminHandler{
+		db:               db,
+		userService:      services.NewUserService(db),
+		adminUserService: adminUserService,
+		validator:        validator.New(),
+	}
+}
+
+// UpdateUserRoleRequest represents the role change request payload
+type UpdateUserRoleRequest struct {
+	Role models.UserRole `json:"role" validate:"required,oneof=resident municipal_staff admin"`
+}
+
+// DisableUserRequest represents the account disable request payload
+type DisableUserRequest struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+// auditActor identifies the signed-in user making the request, and the admin
+// impersonating them if any, for the audit log
+func auditActor(c *fiber.Ctx) services.AuditActor {
+	userID, _ := c.Locals("user_id").(string)
+	impersonatorID, _ := middleware.GetImpersonatorFromContext(c)
+	return services.AuditActor{
+		UserID:         userID,
+		ImpersonatorID: impersonatorID,
+		IPAddress:      c.IP(),
+		RequestID:      middleware.GetRequestIDFromContext(c),
+	}
+}
+
+// ListUsers returns users matching ?q= (name prefix, or exact email or phone), ?role= and
+// ?status=active|disabled, newest first
+// GET /api/admin/users
+func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100 // Cap at 100 for performance
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	// Build filter
+	filter := &services.UserFilter{}
+	if q := c.Query("q"); q != "" {
+		filter.Query = &q
+	}
+	if roleParam := c.Query("role"); roleParam != "" {
+		role := models.UserRole(roleParam)
+		if err := models.ValidateUserRole(role); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Invalid role",
+					"code":    "INVALID_ROLE",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		filter.Role = &role
+	}
+	switch c.Query("status") {
+	case "":
+	case "active":
+		disabled := false
+		filter.Disabled = &disabled
+	case "disabled":
+		disabled := true
+		filter.Disabled = &disabled
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Status must be active or disabled",
+				"code":    "INVALID_STATUS",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	users, total, err := h.userService.ListUsers(filter, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to list users",
+				"code":    "USERS_FETCH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.JSON(models.NewSuccessResponse(models.PaginatedResponse[models.User]{
+		Data:    users,
+		Total:   int(total),
+		HasMore: int64(offset+len(users)) < total,
+		Limit:   limit,
+		Offset:  offset,
+	}))
+}
+
+// GetUser returns any user
+// GET /api/admin/users/:id
+func (h *AdminHandler) GetUser(c *fiber.Ctx) error {
+	user, err := h.userService.GetUserByID(c.Params("id"))
+	if err != nil {
+		if err.Error() == "user not found" {
+			return h.userError(c, services.ErrUserNotFound, "")
+		}
+		return h.userError(c, err, "Failed to get user")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      user,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// UpdateUserRole changes a user's role and invalidates the access tokens carrying the old role
+// PUT /api/admin/users/:id/role
+func (h *AdminHandler) UpdateUserRole(c *fiber.Ctx) error {
+	var req UpdateUserRoleRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	user, err := h.adminUserService.ChangeRole(auditActor(c), c.Params("id"), req.Role)
+	if err != nil {
+		return h.userError(c, err, "Failed to update user role")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      user,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// DisableUser blocks a user from signing in and signs them out of every device
+// POST /api/admin/users/:id/disable
+func (h *AdminHandler) DisableUser(c *fiber.Ctx) error {
+	var req DisableUserRequest
+
+	// The reason is optional, so an empty body is fine
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Invalid request body",
+					"code":    "INVALID_REQUEST",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	user, err := h.adminUserService.Disable(auditActor(c), c.Params("id"), req.Reason)
+	if err != nil {
+		return h.userError(c, err, "Failed to disable user")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      user,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// EnableUser lets a disabled user sign in again
+// POST /api/admin/users/:id/enable
+func (h *AdminHandler) EnableUser(c *fiber.Ctx) error {
+	user, err := h.adminUserService.Enable(auditActor(c), c.Params("id"))
+	if err != nil {
+		return h.userError(c, err, "Failed to enable user")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      user,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ForcePasswordReset signs a user out and emails them a reset link they must use before signing in again
+// POST /api/admin/users/:id/password-reset
+func (h *AdminHandler) ForcePasswordReset(c *fiber.Ctx) error {
+	user, err := h.adminUserService.ForcePasswordReset(auditActor(c), c.Params("id"))
+	if err != nil {
+		return h.userError(c, err, "Failed to reset password")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      user,
+		"message":   "User signed out and sent a password reset link",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// SignOutUser signs a user out of every device
+// POST /api/admin/users/:id/signout
+func (h *AdminHandler) SignOutUser(c *fiber.Ctx) error {
+	if err := h.adminUserService.SignOut(auditActor(c), c.Params("id")); err != nil {
+		return h.userError(c, err, "Failed to sign user out")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "User signed out of all devices",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// UnlockUser clears a user's failed login counter and any temporary lockout
+// POST /api/admin/users/:id/unlock
+func (h *AdminHandler) UnlockUser(c *fiber.Ctx) error {
+	if err := h.adminUserService.Unlock(auditActor(c), c.Params("id")); err != nil {
+		return h.userError(c, err, "Failed to unlock user")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "User account unlocked",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// userError writes the response for a failed admin user action
+func (h *AdminHandler) userError(c *fiber.Ctx, err error, message string) error {
+	status, code := fiber.StatusInternalServerError, "UPDATE_FAILED"
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		status, code, message = fiber.StatusNotFound, "USER_NOT_FOUND", "User not found"
+	case errors.Is(err, services.ErrUserAlreadyDisabled):
+		status, code, message = fiber.StatusConflict, "USER_ALREADY_DISABLED", "User is already disabled"
+	case errors.Is(err, services.ErrUserNotDisabled):
+		status, code, message = fiber.StatusConflict, "USER_NOT_DISABLED", "User is not disabled"
+	case errors.Is(err, services.ErrUserErased):
+		status, code, message = fiber.StatusConflict, "USER_ERASED", "User's personal data has been erased"
+	case errors.Is(err, services.ErrCannotModifySelf):
+		status, code, message = fiber.StatusBadRequest, "CANNOT_MODIFY_SELF", "You cannot disable or demote your own account"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": message,
+			"code":    code,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/services"
+)
+
+// APIKeyHandler handles API key management for integration partners
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+	validator     *validator.Validate
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		validator:     validator.New(),
+	}
+}
+
+// CreateAPIKey issues a new API key. The key itself is only included in this response.
+// POST /api/admin/api-keys
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	var req services.CreateAPIKeyRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	apiKey, key, err := h.apiKeyService.CreateAPIKey(&req, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "municipality not found") {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Municipality not found",
+					"code":    "MUNICIPALITY_NOT_FOUND",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		if errors.Is(err, services.ErrInvalidAPIScope) || errors.Is(err, services.ErrInvalidAPIKeyExpiry) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": err.Error(),
+					"code":    "VALIDATION_ERROR",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to create API key",
+				"code":    "CREATE_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"apiKey": apiKey,
+			"key":    key,
+		},
+		"message":   "Store this key now; it will not be shown again",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ListAPIKeys lists API keys, optionally filtered by ?municipalityId=
+// GET /api/admin/api-keys
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	var municipalityID *string
+	if id := c.Query("municipalityId"); id != "" {
+		municipalityID = &id
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(municipalityID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to list API keys",
+				"code":    "FETCH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      keys,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// RevokeAPIKey revokes an API key immediately
+// DELETE /api/admin/api-keys/:id
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	keyID := c.Params("id")
+	if keyID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "API key ID is required",
+				"code":    "MISSING_API_KEY_ID",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	apiKey, err := h.apiKeyService.RevokeAPIKey(keyID)
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "API key not found",
+					"code":    "API_KEY_NOT_FOUND",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to revoke API key",
+				"code":    "REVOKE_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      apiKey,
+		"timestamp": time.Now().Unix(),
+	})
+}
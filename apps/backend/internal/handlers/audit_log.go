@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// AuditLogHandler lets admins search, export and verify the audit log
+type AuditLogHandler struct {
+	auditService *services.AuditService
+}
+
+// NewAuditLogHandler creates a new audit log handler
+func NewAuditLogHandler(auditService *services.AuditService) *AuditLogHandler {
+	return &AuditLogHandler{
+		auditService: auditService,
+	}
+}
+
+// ListAuditLogs returns audit log entries, newest first, filtered by ?actorId=, ?impersonatorId=,
+// ?action=, ?resourceType=, ?resourceId=, ?requestId=, ?from= and ?to= (RFC 3339)
+// GET /api/admin/audit-logs
+func (h *AuditLogHandler) ListAuditLogs(c *fiber.Ctx) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100 // Cap at 100 for performance
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter, err := auditLogFilter(c)
+	if err != nil {
+		return h.invalidFilter(c, err)
+	}
+
+	entries, total, err := h.auditService.List(filter, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to list audit log entries",
+				"code":    "AUDIT_LOGS_FETCH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.JSON(models.NewSuccessResponse(models.PaginatedResponse[models.AuditLog]{
+		Data:    entries,
+		Total:   int(total),
+		HasMore: int64(offset+len(entries)) < total,
+		Limit:   limit,
+		Offset:  offset,
+	}))
+}
+
+// ExportAuditLogs streams every matching audit log entry, oldest first, as CSV or as
+// newline-delimited JSON (?format=csv|json). Takes the same filters as ListAuditLogs.
+// GET /api/admin/audit-logs/export
+func (h *AuditLogHandler) ExportAuditLogs(c *fiber.Ctx) error {
+	filter, err := auditLogFilter(c)
+	if err != nil {
+		return h.invalidFilter(c, err)
+	}
+
+	// The export is written after the handler returns, so nothing may refer to the request's buffers
+	format := strings.Clone(c.Query("format", "csv"))
+	filename := "audit-log-" + time.Now().UTC().Format("20060102T150405Z")
+	switch format {
+	case "csv":
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		filename += ".csv"
+	case "json":
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+		filename += ".jsonl"
+	default:
+		return h.invalidFilter(c, services.ErrInvalidAuditExportFormat)
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	// The export is streamed, so a failure part-way can only be logged
+	actor := auditActor(c)
+	actor.RequestID = strings.Clone(actor.RequestID)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.auditService.Export(actor, filter, format, w); err != nil {
+			log.Printf("Failed to export audit log for %s: %v", actor.UserID, err)
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Failed to send audit log export to %s: %v", actor.UserID, err)
+		}
+	})
+
+	return nil
+}
+
+// VerifyAuditLogs checks the audit log hash chain and reports the first entry that breaks it
+// GET /api/admin/audit-logs/verify
+func (h *AuditLogHandler) VerifyAuditLogs(c *fiber.Ctx) error {
+	report, err := h.auditService.Verify()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to verify audit log",
+				"code":    "AUDIT_LOG_VERIFY_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      report,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// invalidFilter writes the response for an unusable audit log query
+func (h *AuditLogHandler) invalidFilter(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": err.Error(),
+			"code":    "INVALID_FILTER",
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// auditLogFilter reads the audit log filter from the query string
+func auditLogFilter(c *fiber.Ctx) (*services.AuditLogFilter, error) {
+	filter := &services.AuditLogFilter{}
+
+	optional := func(name string) *string {
+		if value := c.Query(name); value != "" {
+			value = strings.Clone(value)
+			return &value
+		}
+		return nil
+	}
+	filter.ActorID = optional("actorId")
+	filter.ImpersonatorID = optional("impersonatorId")
+	for name, id := range map[string]*string{"actorId": filter.ActorID, "impersonatorId": filter.ImpersonatorID} {
+		if id != nil {
+			if _, err := uuid.Parse(*id); err != nil {
+				return nil, errors.New(name + " must be a UUID")
+			}
+		}
+	}
+	filter.ResourceType = optional("resourceType")
+	filter.ResourceID = optional("resourceId")
+	filter.RequestID = optional("requestId")
+	if action := c.Query("action"); action != "" {
+		auditAction := models.AuditAction(strings.Clone(action))
+		filter.Action = &auditAction
+	}
+
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New(name + " must be an RFC 3339 timestamp")
+		}
+		*target = &parsed
+	}
+
+	return filter, nil
+}
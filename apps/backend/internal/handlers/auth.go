@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -8,6 +13,7 @@ import (
 	"gorm.io/gorm"
 	
 	"municollect/internal/models"
+	"municollect/internal/services"
 )
 
 // UserRole represents the role of a user in the system
@@ -32,17 +38,36 @@ type Auth struct {
 
 // AuthHandler handles authentication-related requests
 type AuthHandler struct {
-	db          *gorm.DB
-	authService *AuthService
-	validator   *validator.Validate
+	db                   *gorm.DB
+	authService          *AuthService
+	refreshTokenService  *services.RefreshTokenService
+	sessionService       *services.SessionService
+	revocationService    *services.TokenRevocationService
+	passwordResetService *services.PasswordResetService
+	verificationService  *services.VerificationService
+	mfaService           *services.MFAService
+	loginProtection      *services.LoginProtectionService
+	auditService         *services.AuditService
+	consentService       *services.ConsentService
+	oidc                 *services.OIDCLoginService
+	validator            *validator.Validate
 }
 
 // NewAuthHandler creates a new authentication handler
-func NewAuthHandler(db *gorm.DB) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, revocationService *services.TokenRevocationService, passwordResetService *services.PasswordResetService, verificationService *services.VerificationService, mfaService *services.MFAService, loginProtection *services.LoginProtectionService, auditService *services.AuditService, consentService *services.ConsentService) *AuthHandler {
 	return &AuthHandler{
-		db:          db,
-		authService: NewAuthService(),
-		validator:   validator.New(),
+		db:                   db,
+		authService:          NewAuthService(),
+		refreshTokenService:  services.NewRefreshTokenService(db),
+		sessionService:       services.NewSessionService(db),
+		revocationService:    revocationService,
+		passwordResetService: passwordResetService,
+		verificationService:  verificationService,
+		mfaService:           mfaService,
+		loginProtection:      loginProtection,
+		auditService:         auditService,
+		consentService:       consentService,
+		validator:            validator.New(),
 	}
 }
 
@@ -53,6 +78,9 @@ type RegisterRequest struct {
 	FirstName string `json:"firstName" validate:"required,min=1,max=100"`
 	LastName  string `json:"lastName" validate:"required,min=1,max=100"`
 	Phone     string `json:"phone,omitempty" validate:"omitempty,min=10,max=20"`
+	// Consent must accept the current terms of service and privacy policy, and may
+	// opt in to reminder and marketing messages
+	Consent services.ConsentChoices `json:"consent"`
 }
 
 // LoginRequest represents the login request payload
@@ -65,6 +93,7 @@ type LoginRequest struct {
 type AuthResponse struct {
 	AccessToken  string      `json:"accessToken"`
 	RefreshToken string      `json:"refreshToken"`
+	SessionID    string      `json:"sessionId"`
 	User         models.User `json:"user"`
 	ExpiresAt    time.Time   `json:"expiresAt"`
 }
@@ -74,6 +103,28 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refreshToken" validate:"required"`
 }
 
+// LogoutRequest represents the logout request payload
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// ForgotPasswordRequest represents the forgotten password request payload
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the password reset request payload
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=8"`
+}
+
+// ChangePasswordRequest represents the password change request payload
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8"`
+}
+
 // Register handles user registration
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req RegisterRequest
@@ -97,9 +148,19 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 	
+	// The current terms of service and privacy policy must be accepted
+	if err := h.consentService.Validate(&req.Consent, true); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"code":  fiber.StatusBadRequest,
+			"versions": h.consentService.Versions(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
 	// Check if user already exists
 	var existingUser models.User
-	if err := h.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := h.db.Where("email_index = ?", models.EmailIndex(req.Email)).First(&existingUser).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"error": "User with this email already exists",
 			"code":  fiber.StatusConflict,
@@ -157,6 +218,20 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 	
+	// Record the consent given at registration
+	client := services.ClientInfo{
+		IPAddress: c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+	if err := h.consentService.RecordTx(tx, user.ID, &req.Consent, models.ConsentSourceRegistration, client); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record consent",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -166,12 +241,13 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Generate tokens
-	accessToken, refreshToken, expiresAt, err := h.authService.GenerateTokens(
-		user.ID, 
-		user.Email, 
-		string(user.Role),
-	)
+	// Send the email verification code; the user can request another one if this fails
+	if err := h.verificationService.SendCode(user.ID, models.VerificationChannelEmail); err != nil {
+		log.Printf("Failed to send verification code to user %s: %v", user.ID, err)
+	}
+	
+	// Generate tokens, starting a new refresh token family
+	response, err := h.issueTokens(c, &user, "")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate authentication tokens",
@@ -180,14 +256,6 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Return success response
-	response := AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         user,
-		ExpiresAt:    expiresAt,
-	}
-	
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
 		"data": response,
@@ -218,41 +286,57 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 	
+	// Refuse logins while the account or client IP is backing off or locked
+	if err := h.loginProtection.Check(req.Email, c.IP()); err != nil {
+		return h.loginBlocked(c, err)
+	}
+	
 	// Find user by email
 	var user models.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid email or password",
-			"code":  fiber.StatusUnauthorized,
-			"timestamp": time.Now().Unix(),
-		})
+	if err := h.db.Where("email_index = ?", models.EmailIndex(req.Email)).First(&user).Error; err != nil {
+		return h.loginFailed(c, req.Email, "")
 	}
 	
 	// Get auth record
 	var authRecord models.Auth
 	if err := h.db.Where("user_id = ?", user.ID).First(&authRecord).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid email or password",
-			"code":  fiber.StatusUnauthorized,
-			"timestamp": time.Now().Unix(),
-		})
+		return h.loginFailed(c, req.Email, user.ID)
 	}
 	
 	// Check password
 	if !h.authService.CheckPassword(req.Password, authRecord.PasswordHash) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid email or password",
-			"code":  fiber.StatusUnauthorized,
+		return h.loginFailed(c, req.Email, user.ID)
+	}
+	
+	// Disabled accounts and accounts awaiting a forced password reset cannot sign in
+	if err := services.CheckCanSignIn(&user); err != nil {
+		return h.signInRefused(c, err)
+	}
+	
+	// Users with a second factor, or whose role requires one, get a challenge instead of tokens
+	challenge, err := h.mfaChallenge(&user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check two-factor authentication",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	if challenge != nil {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data": challenge,
 			"timestamp": time.Now().Unix(),
 		})
 	}
 	
-	// Generate tokens
-	accessToken, refreshToken, expiresAt, err := h.authService.GenerateTokens(
-		user.ID, 
-		user.Email, 
-		string(user.Role),
-	)
+	// The failure counter is only cleared once the login is complete
+	if err := h.loginProtection.RecordSuccess(req.Email); err != nil {
+		log.Printf("Failed to reset login attempts of user %s: %v", user.ID, err)
+	}
+	
+	// Generate tokens, starting a new refresh token family
+	response, err := h.issueTokens(c, &user, "")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate authentication tokens",
@@ -260,14 +344,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 			"timestamp": time.Now().Unix(),
 		})
 	}
-	
-	// Return success response
-	response := AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         user,
-		ExpiresAt:    expiresAt,
-	}
+	h.recordLogin(c, &user, response.SessionID, "password")
 	
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -276,6 +353,88 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	})
 }
 
+// recordLogin writes a completed sign-in to the audit log. A failure to record it
+// does not fail the sign-in.
+func (h *AuthHandler) recordLogin(c *fiber.Ctx, user *models.User, sessionID, method string) {
+	actor := auditActor(c)
+	actor.UserID = user.ID
+	
+	err := h.auditService.Record(actor, services.AuditEvent{
+		Action:       models.AuditActionAuthLogin,
+		ResourceType: models.AuditResourceUser,
+		ResourceID:   user.ID,
+		Details:      models.AuditDetails{"method": method, "sessionId": sessionID},
+	})
+	if err != nil {
+		log.Printf("Failed to audit login of user %s: %v", user.ID, err)
+	}
+}
+
+// loginFailed records a failed login and returns the generic credentials error.
+// userID is empty when no account has the email.
+func (h *AuthHandler) loginFailed(c *fiber.Ctx, email, userID string) error {
+	if err := h.loginProtection.RecordFailure(email, c.IP()); err != nil {
+		log.Printf("Failed to record failed login: %v", err)
+	}
+	
+	// Attempts on unknown emails are kept without the email itself
+	reason := "unknown_email"
+	if userID != "" {
+		reason = "invalid_credentials"
+	}
+	err := h.auditService.Record(auditActor(c), services.AuditEvent{
+		Action:       models.AuditActionAuthLoginFailed,
+		ResourceType: models.AuditResourceUser,
+		ResourceID:   userID,
+		Details:      models.AuditDetails{"reason": reason},
+	})
+	if err != nil {
+		log.Printf("Failed to audit failed login: %v", err)
+	}
+	
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": "Invalid email or password",
+		"code":  fiber.StatusUnauthorized,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// signInRefused returns 403 for accounts an admin has disabled or flagged for a password reset
+func (h *AuthHandler) signInRefused(c *fiber.Ctx, err error) error {
+	message := "Account is disabled"
+	if errors.Is(err, services.ErrPasswordResetPending) {
+		message = "A password reset is required; use the link sent to your email to choose a new password"
+	}
+	
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": message,
+		"code":  fiber.StatusForbidden,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// loginBlocked returns 429 with a Retry-After header for throttled or locked logins
+func (h *AuthHandler) loginBlocked(c *fiber.Ctx, err error) error {
+	var blocked *services.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check login attempts",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	retryAfter := int(math.Ceil(blocked.RetryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error": blocked.Error(),
+		"code":  fiber.StatusTooManyRequests,
+		"retryAfter": retryAfter,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
 // RefreshToken handles token refresh
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	var req RefreshTokenRequest
@@ -318,6 +477,43 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		})
 	}
 	
+	// Consume the stored refresh token so it cannot be used again
+	consumed, err := h.refreshTokenService.Rotate(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			log.Printf("SECURITY: refresh token reuse detected for user %s, token family revoked", claims.UserID)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Refresh token has already been used; please sign in again",
+				"code":  fiber.StatusUnauthorized,
+				"timestamp": time.Now().Unix(),
+			})
+		case errors.Is(err, services.ErrRefreshTokenNotFound),
+			errors.Is(err, services.ErrRefreshTokenRevoked),
+			errors.Is(err, services.ErrRefreshTokenExpired):
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or expired refresh token",
+				"code":  fiber.StatusUnauthorized,
+				"timestamp": time.Now().Unix(),
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to refresh authentication tokens",
+				"code":  fiber.StatusInternalServerError,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+	
+	// The stored token must belong to the user named in the claims
+	if consumed.UserID != claims.UserID {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired refresh token",
+			"code":  fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
 	// Get user to ensure they still exist
 	var user models.User
 	if err := h.db.Where("id = ?", claims.UserID).First(&user).Error; err != nil {
@@ -328,12 +524,12 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Generate new tokens
-	accessToken, refreshToken, expiresAt, err := h.authService.GenerateTokens(
-		user.ID, 
-		user.Email, 
-		string(user.Role),
-	)
+	if err := services.CheckCanSignIn(&user); err != nil {
+		return h.signInRefused(c, err)
+	}
+	
+	// Generate new tokens in the same refresh token family
+	response, err := h.issueTokens(c, &user, consumed.FamilyID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate authentication tokens",
@@ -342,14 +538,6 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Return success response
-	response := AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         user,
-		ExpiresAt:    expiresAt,
-	}
-	
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": response,
@@ -359,9 +547,52 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 
 // Logout handles user logout (token invalidation)
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	// In a stateless JWT system, logout is typically handled client-side
-	// by removing the token from storage. However, we can add token blacklisting
-	// or other server-side logout logic here if needed.
+	var req LogoutRequest
+	
+	// The body is optional; clients that send their refresh token get it revoked server-side
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+				"code":  fiber.StatusBadRequest,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+	
+	// Revoke the refresh token family so the login cannot be refreshed again
+	if req.RefreshToken != "" {
+		if err := h.refreshTokenService.Revoke(req.RefreshToken); err != nil && !errors.Is(err, services.ErrRefreshTokenNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to revoke refresh token",
+				"code":  fiber.StatusInternalServerError,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+	
+	// Revoke the presented access token, if it is still valid, and end its session
+	if authHeader := c.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		claims, err := h.authService.ValidateAccessToken(strings.TrimPrefix(authHeader, "Bearer "))
+		if err == nil && claims.ID != "" && claims.ExpiresAt != nil {
+			if err := h.revocationService.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to revoke access token",
+					"code":  fiber.StatusInternalServerError,
+					"timestamp": time.Now().Unix(),
+				})
+			}
+		}
+		if err == nil && claims.SessionID != "" {
+			if err := h.revocationService.RevokeSession(claims.SessionID, models.RefreshTokenRevokedLogout); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to end session",
+					"code":  fiber.StatusInternalServerError,
+					"timestamp": time.Now().Unix(),
+				})
+			}
+		}
+	}
 	
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -370,6 +601,271 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	})
 }
 
+// LogoutAll signs the current user out of every device
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	// Get user from context (set by JWT middleware)
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not authenticated",
+			"code":  fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	if err := h.revocationService.SignOutEverywhere(userID, models.RefreshTokenRevokedSignOutAll); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to sign out of all devices",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Signed out of all devices",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ChangePassword changes the current user's password and signs out every other session
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	// Get user from context (set by JWT middleware)
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not authenticated",
+			"code":  fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	var req ChangePasswordRequest
+	
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+			"code":  fiber.StatusBadRequest,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Validation failed",
+			"code":  fiber.StatusBadRequest,
+			"details": err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Get user and auth record
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+			"code":  fiber.StatusNotFound,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	var authRecord models.Auth
+	if err := h.db.Where("user_id = ?", user.ID).First(&authRecord).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Authentication record not found",
+			"code":  fiber.StatusNotFound,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Check current password
+	if !h.authService.CheckPassword(req.CurrentPassword, authRecord.PasswordHash) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Current password is incorrect",
+			"code":  fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Hash and store the new password
+	hashedPassword, err := h.authService.HashPassword(req.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to process password",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	if err := h.db.Model(&authRecord).Update("password_hash", hashedPassword).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update password",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Invalidate every existing session, then start a fresh one for the caller
+	if err := h.revocationService.SignOutEverywhere(user.ID, models.RefreshTokenRevokedPasswordChange); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke existing sessions",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	response, err := h.issueTokens(c, &user, "")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate authentication tokens",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": response,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ForgotPassword emails a password reset link to the given address
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+			"code":  fiber.StatusBadRequest,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Validation failed",
+			"code":  fiber.StatusBadRequest,
+			"details": err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Failures are only logged: they happen only for existing accounts, so reporting them
+	// would reveal which emails are registered
+	if err := h.passwordResetService.RequestReset(req.Email); err != nil {
+		log.Printf("Failed to process password reset request: %v", err)
+	}
+	
+	// The response is the same whether or not the account exists
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "If an account exists for this email, a password reset link has been sent",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs out every existing session
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+			"code":  fiber.StatusBadRequest,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Validation failed",
+			"code":  fiber.StatusBadRequest,
+			"details": err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	// Hash password
+	hashedPassword, err := h.authService.HashPassword(req.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to process password",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	if _, err := h.passwordResetService.ResetPassword(req.Token, hashedPassword); err != nil {
+		if errors.Is(err, services.ErrPasswordResetTokenInvalid) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Password reset link is invalid or has expired",
+				"code":  fiber.StatusBadRequest,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset password",
+			"code":  fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Password has been reset; please sign in with your new password",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// issueTokens generates a token pair for the user and stores the refresh token.
+// An empty familyID starts a new session (a new login); otherwise the session whose
+// refresh token family it is gets refreshed with the client's current details.
+func (h *AuthHandler) issueTokens(c *fiber.Ctx, user *models.User, familyID string) (*AuthResponse, error) {
+	client := services.ClientInfo{
+		IPAddress: c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+	
+	if familyID == "" {
+		session, err := h.sessionService.Start(user.ID, client)
+		if err != nil {
+			return nil, err
+		}
+		familyID = session.ID
+	} else if err := h.sessionService.Refresh(familyID, client); err != nil {
+		return nil, err
+	}
+	
+	accessToken, refreshToken, expiresAt, err := h.authService.GenerateTokens(
+		user.ID,
+		user.Email,
+		string(user.Role),
+		familyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	if _, err := h.refreshTokenService.Store(user.ID, familyID, refreshToken, time.Now().Add(services.RefreshTokenLifetime)); err != nil {
+		return nil, err
+	}
+	
+	return &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		SessionID:    familyID,
+		User:         *user,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
 // GetProfile returns the current user's profile
 func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 	// Get user from context (set by JWT middleware)
@@ -2,82 +2,124 @@ package handlers
 
 import (
 	"fmt"
-	"os"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+
+	"municollect/internal/middleware"
+	"municollect/internal/services"
 )
 
 // JWTClaims represents the JWT token claims
 type JWTClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    string                   `json:"user_id"`
+	Email     string                   `json:"email"`
+	Role      string                   `json:"role"`
+	SessionID string                   `json:"sid,omitempty"`
+	Actor     *middleware.ActorClaims `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// MFAChallengePurpose says what a challenge token allows its holder to do
+type MFAChallengePurpose string
+
+const (
+	// MFAChallengeVerify allows completing login with a TOTP or recovery code
+	MFAChallengeVerify MFAChallengePurpose = "verify"
+	// MFAChallengeEnroll allows enrolling a second factor before the first login completes
+	MFAChallengeEnroll MFAChallengePurpose = "enroll"
+)
+
+// MFAChallengeLifetime is how long a login challenge token stays valid
+const MFAChallengeLifetime = 5 * time.Minute
+
+// MFAChallengeClaims represents the claims of a short-lived login challenge token.
+// Challenge tokens use their own issuer so they are never accepted as access tokens.
+type MFAChallengeClaims struct {
+	UserID  string              `json:"user_id"`
+	Purpose MFAChallengePurpose `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// OIDCFlowLifetime is how long a user has to complete a login at the identity provider
+const OIDCFlowLifetime = 10 * time.Minute
+
+// OIDCFlowClaims carries the state, nonce and PKCE verifier of an OIDC login between the
+// redirect to the identity provider and the callback. The token is kept in an HttpOnly
+// cookie, so the verifier never reaches the browser's scripts or the identity provider.
+type OIDCFlowClaims struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
 	jwt.RegisteredClaims
 }
 
 // AuthService handles JWT token operations
 type AuthService struct {
-	jwtSecret []byte
+	keys *middleware.KeySet
 }
 
-// NewAuthService creates a new authentication service
+// NewAuthService creates a new authentication service using the key set configured in the environment
 func NewAuthService() *AuthService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key" // Default for development - should be set via env var
+	keys, err := middleware.DefaultKeySet()
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
 	}
 	
 	return &AuthService{
-		jwtSecret: []byte(secret),
+		keys: keys,
 	}
 }
 
-// GenerateTokens generates access and refresh tokens for a user
-func (a *AuthService) GenerateTokens(userID, email, role string) (string, string, time.Time, error) {
-	// Access token expires in 24 hours
-	expiresAt := time.Now().Add(24 * time.Hour)
+// GenerateTokens generates access and refresh tokens for a user's session
+func (a *AuthService) GenerateTokens(userID, email, role, sessionID string) (string, string, time.Time, error) {
+	// Access token expires after the access token lifetime (24 hours)
+	expiresAt := time.Now().Add(services.AccessTokenLifetime)
 	
 	// Create access token claims
 	accessClaims := &JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "municollect",
 			Subject:   userID,
+			ID:        uuid.NewString(),
 		},
 	}
 	
 	// Generate access token
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(a.jwtSecret)
+	accessTokenString, err := a.keys.Sign(accessClaims)
 	if err != nil {
 		return "", "", time.Time{}, fmt.Errorf("failed to generate access token: %w", err)
 	}
 	
-	// Refresh token expires in 7 days
-	refreshExpiresAt := time.Now().Add(7 * 24 * time.Hour)
+	// Refresh token expires after the refresh token lifetime (7 days)
+	refreshExpiresAt := time.Now().Add(services.RefreshTokenLifetime)
 	refreshClaims := &JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(refreshExpiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "municollect-refresh",
 			Subject:   userID,
+			ID:        uuid.NewString(),
 		},
 	}
 	
 	// Generate refresh token
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(a.jwtSecret)
+	refreshTokenString, err := a.keys.Sign(refreshClaims)
 	if err != nil {
 		return "", "", time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
@@ -85,15 +127,41 @@ func (a *AuthService) GenerateTokens(userID, email, role string) (string, string
 	return accessTokenString, refreshTokenString, expiresAt, nil
 }
 
+// GenerateImpersonationToken generates a short-lived access token that lets an admin act as
+// a user. The token carries the admin in its act claim, belongs to no session and comes
+// without a refresh token, so it simply expires.
+func (a *AuthService) GenerateImpersonationToken(userID, email, role, actorID, actorEmail string) (string, *JWTClaims, error) {
+	now := time.Now()
+	
+	claims := &JWTClaims{
+		UserID: userID,
+		Email:  email,
+		Role:   role,
+		Actor: &middleware.ActorClaims{
+			Subject: actorID,
+			Email:   actorEmail,
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(services.ImpersonationLifetime)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "municollect",
+			Subject:   userID,
+			ID:        uuid.NewString(),
+		},
+	}
+	
+	tokenString, err := a.keys.Sign(claims)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate impersonation token: %w", err)
+	}
+	
+	return tokenString, claims, nil
+}
+
 // ValidateToken validates a JWT token and returns the claims
 func (a *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return a.jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, a.keys.Keyfunc, a.keys.ParserOptions()...)
 	
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -106,6 +174,106 @@ func (a *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// ValidateAccessToken validates an access token, rejecting refresh, challenge and
+// sign-in flow tokens signed with the same keys
+func (a *AuthService) ValidateAccessToken(tokenString string) (*JWTClaims, error) {
+	options := append(a.keys.ParserOptions(), jwt.WithIssuer("municollect"))
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, a.keys.Keyfunc, options...)
+	
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		return claims, nil
+	}
+	
+	return nil, fmt.Errorf("invalid token")
+}
+
+// GenerateMFAChallenge issues a challenge token for the second step of a login
+func (a *AuthService) GenerateMFAChallenge(userID string, purpose MFAChallengePurpose) (string, time.Time, error) {
+	expiresAt := time.Now().Add(MFAChallengeLifetime)
+	
+	claims := &MFAChallengeClaims{
+		UserID:  userID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "municollect-mfa",
+			Subject:   userID,
+			ID:        uuid.NewString(),
+		},
+	}
+	
+	tokenString, err := a.keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate challenge token: %w", err)
+	}
+	
+	return tokenString, expiresAt, nil
+}
+
+// ValidateMFAChallenge validates a challenge token issued for the given purpose
+func (a *AuthService) ValidateMFAChallenge(tokenString string, purpose MFAChallengePurpose) (*MFAChallengeClaims, error) {
+	options := append(a.keys.ParserOptions(), jwt.WithIssuer("municollect-mfa"))
+	token, err := jwt.ParseWithClaims(tokenString, &MFAChallengeClaims{}, a.keys.Keyfunc, options...)
+	
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse challenge token: %w", err)
+	}
+	
+	claims, ok := token.Claims.(*MFAChallengeClaims)
+	if !ok || !token.Valid || claims.Purpose != purpose {
+		return nil, fmt.Errorf("invalid challenge token")
+	}
+	
+	return claims, nil
+}
+
+// GenerateOIDCFlow issues the token that binds an OIDC callback to the login that started it
+func (a *AuthService) GenerateOIDCFlow(state, nonce, codeVerifier string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(OIDCFlowLifetime)
+	
+	claims := &OIDCFlowClaims{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "municollect-oidc",
+			ID:        uuid.NewString(),
+		},
+	}
+	
+	tokenString, err := a.keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate OIDC flow token: %w", err)
+	}
+	
+	return tokenString, expiresAt, nil
+}
+
+// ValidateOIDCFlow validates an OIDC flow token
+func (a *AuthService) ValidateOIDCFlow(tokenString string) (*OIDCFlowClaims, error) {
+	options := append(a.keys.ParserOptions(), jwt.WithIssuer("municollect-oidc"))
+	token, err := jwt.ParseWithClaims(tokenString, &OIDCFlowClaims{}, a.keys.Keyfunc, options...)
+	
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OIDC flow token: %w", err)
+	}
+	
+	claims, ok := token.Claims.(*OIDCFlowClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid OIDC flow token")
+	}
+	
+	return claims, nil
+}
+
 // HashPassword hashes a password using bcrypt
 func (a *AuthService) HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
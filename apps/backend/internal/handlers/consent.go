@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/services"
+)
+
+// ConsentHandler lets users review and change their consent
+type ConsentHandler struct {
+	consentService *services.ConsentService
+}
+
+// NewConsentHandler creates a new consent handler
+func NewConsentHandler(consentService *services.ConsentService) *ConsentHandler {
+	return &ConsentHandler{
+		consentService: consentService,
+	}
+}
+
+// GetConsents returns the current user's consent to each document and kind of message,
+// and whether they have accepted the current document versions
+// GET /api/users/consents
+func (h *ConsentHandler) GetConsents(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	state, err := h.consentService.State(userID)
+	if err != nil {
+		return h.consentError(c, err, "Failed to get consent")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      state,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// UpdateConsents accepts new document versions and gives or withdraws message consent.
+// Choices that are left out stay as they are.
+// PUT /api/users/consents
+func (h *ConsentHandler) UpdateConsents(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	var req services.ConsentChoices
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	state, err := h.consentService.Update(userID, &req, services.ClientInfo{
+		IPAddress: c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	})
+	if err != nil {
+		return h.consentError(c, err, "Failed to update consent")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      state,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// GetConsentHistory returns every consent choice the current user has made, newest first
+// GET /api/users/consents/history
+func (h *ConsentHandler) GetConsentHistory(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	records, err := h.consentService.History(userID)
+	if err != nil {
+		return h.consentError(c, err, "Failed to get consent history")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      records,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// consentError writes the response for a failed consent action
+func (h *ConsentHandler) consentError(c *fiber.Ctx, err error, message string) error {
+	status, code := fiber.StatusInternalServerError, "CONSENT_FAILED"
+	switch {
+	case errors.Is(err, services.ErrConsentVersionOutdated):
+		status, code, message = fiber.StatusConflict, "CONSENT_VERSION_OUTDATED", err.Error()
+	case errors.Is(err, services.ErrInvalidConsent):
+		status, code, message = fiber.StatusBadRequest, "INVALID_CONSENT", err.Error()
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": message,
+			"code":    code,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
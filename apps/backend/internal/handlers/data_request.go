@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// DataRequestHandler handles residents' PDPA data export and erasure requests and their review
+type DataRequestHandler struct {
+	dataRequestService *services.DataRequestService
+	validator          *validator.Validate
+}
+
+// NewDataRequestHandler creates a new data request handler
+func NewDataRequestHandler(dataRequestService *services.DataRequestService) *DataRequestHandler {
+	return &DataRequestHandler{
+		dataRequestService: dataRequestService,
+		validator:          validator.New(),
+	}
+}
+
+// CreateDataRequestRequest represents the data subject request payload
+type CreateDataRequestRequest struct {
+	Type   models.DataSubjectRequestType `json:"type" validate:"required,oneof=export erasure"`
+	Reason string                        `json:"reason" validate:"max=500"`
+}
+
+// ReviewDataRequestRequest represents the admin's decision payload
+type ReviewDataRequestRequest struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+// CreateDataRequest asks for a copy of the signed-in user's personal data, or for its erasure.
+// The request is carried out once an admin approves it.
+// POST /api/users/data-requests
+func (h *DataRequestHandler) CreateDataRequest(c *fiber.Ctx) error {
+	var req CreateDataRequestRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	request, err := h.dataRequestService.Create(auditActor(c), req.Type, req.Reason)
+	if err != nil {
+		return h.dataRequestError(c, err, "Failed to create data request")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success":   true,
+		"data":      request,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ListDataRequests returns the signed-in user's data requests, newest first
+// GET /api/users/data-requests
+func (h *DataRequestHandler) ListDataRequests(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	requests, err := h.dataRequestService.ListForUser(userID)
+	if err != nil {
+		return h.dataRequestError(c, err, "Failed to list data requests")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      requests,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// DownloadDataExport sends the ZIP archive of a completed export request
+// GET /api/users/data-requests/:id/archive
+func (h *DataRequestHandler) DownloadDataExport(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	request, err := h.dataRequestService.Archive(userID, c.Params("id"))
+	if err != nil {
+		return h.dataRequestError(c, err, "Failed to download data export")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="municollect-data-`+request.ID+`.zip"`)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Send(request.Archive)
+}
+
+// ListDataRequestQueue returns data requests for review, oldest first, filtered by
+// ?status= (pending by default, or "all")
+// GET /api/admin/data-requests
+func (h *DataRequestHandler) ListDataRequestQueue(c *fiber.Ctx) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100 // Cap at 100 for performance
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	var status *models.DataSubjectRequestStatus
+	switch value := models.DataSubjectRequestStatus(c.Query("status", string(models.DataSubjectRequestPending))); value {
+	case "all":
+	case models.DataSubjectRequestPending, models.DataSubjectRequestApproved, models.DataSubjectRequestRejected,
+		models.DataSubjectRequestCompleted, models.DataSubjectRequestFailed:
+		status = &value
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Status must be pending, approved, rejected, completed, failed or all",
+				"code":    "INVALID_STATUS",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	requests, total, err := h.dataRequestService.List(status, limit, offset)
+	if err != nil {
+		return h.dataRequestError(c, err, "Failed to list data requests")
+	}
+
+	return c.JSON(models.NewSuccessResponse(models.PaginatedResponse[models.DataSubjectRequest]{
+		Data:    requests,
+		Total:   int(total),
+		HasMore: int64(offset+len(requests)) < total,
+		Limit:   limit,
+		Offset:  offset,
+	}))
+}
+
+// ApproveDataRequest queues a pending or failed data request for processing
+// POST /api/admin/data-requests/:id/approve
+func (h *DataRequestHandler) ApproveDataRequest(c *fiber.Ctx) error {
+	return h.review(c, h.dataRequestService.Approve, "Failed to approve data request")
+}
+
+// RejectDataRequest closes a pending data request without carrying it out
+// POST /api/admin/data-requests/:id/reject
+func (h *DataRequestHandler) RejectDataRequest(c *fiber.Ctx) error {
+	return h.review(c, h.dataRequestService.Reject, "Failed to reject data request")
+}
+
+// review parses the admin's note and applies their decision
+func (h *DataRequestHandler) review(c *fiber.Ctx, decide func(actor services.AuditActor, requestID, note string) (*models.DataSubjectRequest, error), message string) error {
+	var req ReviewDataRequestRequest
+
+	// The note is optional, so an empty body is fine
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Invalid request body",
+					"code":    "INVALID_REQUEST",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	request, err := decide(auditActor(c), c.Params("id"), req.Note)
+	if err != nil {
+		return h.dataRequestError(c, err, message)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      request,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// dataRequestError writes the response for a failed data request action
+func (h *DataRequestHandler) dataRequestError(c *fiber.Ctx, err error, message string) error {
+	status, code := fiber.StatusInternalServerError, "DATA_REQUEST_FAILED"
+	switch {
+	case errors.Is(err, services.ErrDataRequestNotFound):
+		status, code, message = fiber.StatusNotFound, "DATA_REQUEST_NOT_FOUND", "Data request not found"
+	case errors.Is(err, services.ErrDataRequestOpen):
+		status, code, message = fiber.StatusConflict, "DATA_REQUEST_OPEN", "You already have an open request of this type"
+	case errors.Is(err, services.ErrDataRequestNotReviewable):
+		status, code, message = fiber.StatusConflict, "DATA_REQUEST_NOT_REVIEWABLE", "Data request is not awaiting review"
+	case errors.Is(err, services.ErrDataRequestOwn):
+		status, code, message = fiber.StatusForbidden, "CANNOT_REVIEW_OWN_REQUEST", "You cannot review your own data request"
+	case errors.Is(err, services.ErrDataExportUnavailable):
+		status, code, message = fiber.StatusNotFound, "DATA_EXPORT_UNAVAILABLE", "Data export is not ready or has expired"
+	case errors.Is(err, services.ErrUserNotFound):
+		status, code, message = fiber.StatusNotFound, "USER_NOT_FOUND", "User not found"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": message,
+			"code":    code,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
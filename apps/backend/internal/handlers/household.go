@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// HouseholdHandler handles residents' national IDs and the municipalities' house registrations
+type HouseholdHandler struct {
+	householdService *services.HouseholdService
+	validator        *validator.Validate
+}
+
+// NewHouseholdHandler creates a new household handler
+func NewHouseholdHandler(householdService *services.HouseholdService) *HouseholdHandler {
+	return &HouseholdHandler{
+		householdService: householdService,
+		validator:        validator.New(),
+	}
+}
+
+// NationalIDRequest carries a 13-digit Thai citizen ID. It is sent in request bodies rather
+// than URLs so it stays out of access logs.
+type NationalIDRequest struct {
+	NationalID string `json:"nationalId" validate:"required,max=17"`
+}
+
+// CreateHouseholdRequest represents the household registration request payload
+type CreateHouseholdRequest struct {
+	HouseNumber string `json:"houseNumber" validate:"required,max=20"`
+	Address     string `json:"address" validate:"required,max=500"`
+}
+
+// AddHouseholdMemberRequest represents the request payload binding a resident to a household
+type AddHouseholdMemberRequest struct {
+	NationalID string `json:"nationalId" validate:"required,max=17"`
+	IsHead     bool   `json:"isHead"`
+}
+
+// SetNationalID registers the current user's national ID
+// PUT /api/users/national-id
+func (h *HouseholdHandler) SetNationalID(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	var req NationalIDRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	user, err := h.householdService.SetNationalID(auditActor(c), userID, req.NationalID)
+	if err != nil {
+		return h.householdError(c, err, "Failed to set national ID")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      user,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// GetMyHousehold returns the household the current user is registered to
+// GET /api/users/household
+func (h *HouseholdHandler) GetMyHousehold(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	household, err := h.householdService.UserHousehold(userID)
+	if err != nil {
+		return h.householdError(c, err, "Failed to get household")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      household,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// LookupResident finds a resident of the municipality by national ID
+// POST /api/municipalities/:id/residents/lookup
+func (h *HouseholdHandler) LookupResident(c *fiber.Ctx) error {
+	var req NationalIDRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	resident, err := h.householdService.FindResident(auditActor(c), c.Params("id"), req.NationalID)
+	if err != nil {
+		return h.householdError(c, err, "Failed to look up resident")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      resident,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ListHouseholds returns the municipality's households ordered by house number
+// GET /api/municipalities/:id/households
+func (h *HouseholdHandler) ListHouseholds(c *fiber.Ctx) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100 // Cap at 100 for performance
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	households, total, err := h.householdService.ListHouseholds(c.Params("id"), limit, offset)
+	if err != nil {
+		return h.householdError(c, err, "Failed to list households")
+	}
+
+	return c.JSON(models.NewSuccessResponse(models.PaginatedResponse[models.Household]{
+		Data:    households,
+		Total:   int(total),
+		HasMore: int64(offset+len(households)) < total,
+		Limit:   limit,
+		Offset:  offset,
+	}))
+}
+
+// CreateHousehold registers a house in the municipality
+// POST /api/municipalities/:id/households
+func (h *HouseholdHandler) CreateHousehold(c *fiber.Ctx) error {
+	var req CreateHouseholdRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	household, err := h.householdService.CreateHousehold(auditActor(c), c.Params("id"), req.HouseNumber, req.Address)
+	if err != nil {
+		return h.householdError(c, err, "Failed to create household")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success":   true,
+		"data":      household,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// GetHousehold returns one of the municipality's households with its members
+// GET /api/municipalities/:id/households/:householdId
+func (h *HouseholdHandler) GetHousehold(c *fiber.Ctx) error {
+	household, err := h.householdService.GetHousehold(c.Params("id"), c.Params("householdId"))
+	if err != nil {
+		return h.householdError(c, err, "Failed to get household")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      household,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// AddHouseholdMember binds the resident with the given national ID to the household
+// POST /api/municipalities/:id/households/:householdId/members
+func (h *HouseholdHandler) AddHouseholdMember(c *fiber.Ctx) error {
+	var req AddHouseholdMemberRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	member, err := h.householdService.AddMember(auditActor(c), c.Params("id"), c.Params("householdId"), req.NationalID, req.IsHead)
+	if err != nil {
+		return h.householdError(c, err, "Failed to add household member")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success":   true,
+		"data":      member,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// RemoveHouseholdMember unbinds a user from the household
+// DELETE /api/municipalities/:id/households/:householdId/members/:userId
+func (h *HouseholdHandler) RemoveHouseholdMember(c *fiber.Ctx) error {
+	if err := h.householdService.RemoveMember(auditActor(c), c.Params("id"), c.Params("householdId"), c.Params("userId")); err != nil {
+		return h.householdError(c, err, "Failed to remove household member")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "Household member removed",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// householdError writes the response for a failed household action
+func (h *HouseholdHandler) householdError(c *fiber.Ctx, err error, message string) error {
+	status, code := fiber.StatusInternalServerError, "HOUSEHOLD_FAILED"
+	switch {
+	case errors.Is(err, services.ErrInvalidNationalID):
+		status, code, message = fiber.StatusBadRequest, "INVALID_NATIONAL_ID", err.Error()
+	case errors.Is(err, services.ErrNationalIDInUse):
+		status, code, message = fiber.StatusConflict, "NATIONAL_ID_IN_USE", err.Error()
+	case errors.Is(err, services.ErrNationalIDLocked):
+		status, code, message = fiber.StatusConflict, "NATIONAL_ID_LOCKED", err.Error()
+	case errors.Is(err, services.ErrHouseholdExists):
+		status, code, message = fiber.StatusConflict, "HOUSEHOLD_EXISTS", err.Error()
+	case errors.Is(err, services.ErrHouseholdMemberExists):
+		status, code, message = fiber.StatusConflict, "HOUSEHOLD_MEMBER_EXISTS", err.Error()
+	case errors.Is(err, services.ErrHouseholdNationalIDUnset):
+		status, code, message = fiber.StatusUnprocessableEntity, "NATIONAL_ID_NOT_REGISTERED", err.Error()
+	case errors.Is(err, services.ErrResidentNotFound):
+		status, code, message = fiber.StatusNotFound, "RESIDENT_NOT_FOUND", err.Error()
+	case errors.Is(err, services.ErrHouseholdNotFound):
+		status, code, message = fiber.StatusNotFound, "HOUSEHOLD_NOT_FOUND", err.Error()
+	case errors.Is(err, services.ErrHouseholdMemberNotFound):
+		status, code, message = fiber.StatusNotFound, "HOUSEHOLD_MEMBER_NOT_FOUND", err.Error()
+	case errors.Is(err, services.ErrUserNotFound):
+		status, code, message = fiber.StatusNotFound, "USER_NOT_FOUND", err.Error()
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": message,
+			"code":    code,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
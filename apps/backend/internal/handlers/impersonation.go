@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/middleware"
+	"municollect/internal/services"
+)
+
+// ImpersonationHandler lets admins act as another user for support purposes
+type ImpersonationHandler struct {
+	impersonationService *services.ImpersonationService
+	authService          *AuthService
+	validator            *validator.Validate
+}
+
+// NewImpersonationHandler creates a new impersonation handler
+func NewImpersonationHandler(impersonationService *services.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		impersonationService: impersonationService,
+		authService:          NewAuthService(),
+		validator:            validator.New(),
+	}
+}
+
+// ImpersonateUserRequest represents the impersonation request payload
+type ImpersonateUserRequest struct {
+	Reason string `json:"reason" validate:"required,min=1,max=255"`
+}
+
+// ImpersonateUser issues a short-lived access token that acts as the user. The token names
+// the admin in its act claim, cannot be refreshed and cannot change the user's credentials;
+// every request made with it is written to the audit log.
+// POST /api/admin/users/:id/impersonate
+func (h *ImpersonationHandler) ImpersonateUser(c *fiber.Ctx) error {
+	var req ImpersonateUserRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	actor := auditActor(c)
+	user, err := h.impersonationService.Target(actor.UserID, c.Params("id"))
+	if err != nil {
+		return h.impersonationError(c, err, "Failed to impersonate user")
+	}
+
+	actorEmail, _ := c.Locals("user_email").(string)
+	token, claims, err := h.authService.GenerateImpersonationToken(user.ID, user.Email, string(user.Role), actor.UserID, actorEmail)
+	if err != nil {
+		return h.impersonationError(c, err, "Failed to generate impersonation token")
+	}
+
+	// The token is only handed out once its issue has been audited
+	if err := h.impersonationService.Start(actor, user.ID, req.Reason, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return h.impersonationError(c, err, "Failed to impersonate user")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"accessToken": token,
+			"expiresAt":   claims.ExpiresAt.Time,
+			"user":        user,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// EndImpersonation revokes the impersonation token used for the request
+// POST /api/auth/impersonation/end
+func (h *ImpersonationHandler) EndImpersonation(c *fiber.Ctx) error {
+	claims, ok := middleware.GetClaimsFromContext(c)
+	if !ok || claims.ExpiresAt == nil {
+		return h.impersonationError(c, services.ErrNotImpersonating, "")
+	}
+
+	if err := h.impersonationService.End(auditActor(c), claims.ID, claims.ExpiresAt.Time); err != nil {
+		return h.impersonationError(c, err, "Failed to end impersonation")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "Impersonation ended",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// impersonationError writes the response for a failed impersonation action
+func (h *ImpersonationHandler) impersonationError(c *fiber.Ctx, err error, message string) error {
+	status, code := fiber.StatusInternalServerError, "IMPERSONATION_FAILED"
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		status, code, message = fiber.StatusNotFound, "USER_NOT_FOUND", "User not found"
+	case errors.Is(err, services.ErrImpersonateSelf):
+		status, code, message = fiber.StatusBadRequest, "CANNOT_IMPERSONATE_SELF", "You cannot impersonate yourself"
+	case errors.Is(err, services.ErrImpersonateAdmin):
+		status, code, message = fiber.StatusForbidden, "CANNOT_IMPERSONATE_ADMIN", "Admins cannot be impersonated"
+	case errors.Is(err, services.ErrAccountDisabled):
+		status, code, message = fiber.StatusConflict, "USER_DISABLED", "Disabled users cannot be impersonated"
+	case errors.Is(err, services.ErrNotImpersonating):
+		status, code, message = fiber.StatusBadRequest, "NOT_IMPERSONATING", "This request was not made with an impersonation token"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": message,
+			"code":    code,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
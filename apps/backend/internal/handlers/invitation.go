@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/services"
+)
+
+// InvitationHandler handles staff invitations and their acceptance
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+	authService       *AuthService
+	validator         *validator.Validate
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(invitationService *services.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+		authService:       NewAuthService(),
+		validator:         validator.New(),
+	}
+}
+
+// CreateInvitationRequest represents the staff invitation request payload
+type CreateInvitationRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  string `json:"role" validate:"required,max=50"`
+}
+
+// AcceptInvitationRequest represents the invitation acceptance request payload
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	Password  string `json:"password" validate:"required,min=8"`
+	FirstName string `json:"firstName" validate:"required,min=1,max=100"`
+	LastName  string `json:"lastName" validate:"required,min=1,max=100"`
+	Phone     string `json:"phone,omitempty" validate:"omitempty,min=10,max=20"`
+}
+
+// CreateInvitation invites someone by email to join the municipality with a role
+// POST /api/municipalities/:id/invitations
+func (h *InvitationHandler) CreateInvitation(c *fiber.Ctx) error {
+	var req CreateInvitationRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	invitation, err := h.invitationService.Invite(auditActor(c), c.Params("id"), req.Email, req.Role)
+	if err != nil {
+		return h.invitationError(c, err, "Failed to create invitation")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success":   true,
+		"data":      invitation,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ListInvitations returns the municipality's invitations that are still pending
+// GET /api/municipalities/:id/invitations
+func (h *InvitationHandler) ListInvitations(c *fiber.Ctx) error {
+	invitations, err := h.invitationService.ListPending(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to list invitations",
+				"code":    "INVITATIONS_FETCH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      invitations,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ResendInvitation emails a fresh link for a pending invitation and restarts its expiry
+// POST /api/municipalities/:id/invitations/:invitationId/resend
+func (h *InvitationHandler) ResendInvitation(c *fiber.Ctx) error {
+	invitation, err := h.invitationService.Resend(auditActor(c), c.Params("id"), c.Params("invitationId"))
+	if err != nil {
+		return h.invitationError(c, err, "Failed to resend invitation")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      invitation,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// RevokeInvitation cancels a pending invitation
+// DELETE /api/municipalities/:id/invitations/:invitationId
+func (h *InvitationHandler) RevokeInvitation(c *fiber.Ctx) error {
+	if err := h.invitationService.Revoke(auditActor(c), c.Params("id"), c.Params("invitationId")); err != nil {
+		return h.invitationError(c, err, "Failed to revoke invitation")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "Invitation revoked",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// GetInvitation shows the invitee which municipality and role an invitation link is for
+// GET /api/auth/invitations/:token
+func (h *InvitationHandler) GetInvitation(c *fiber.Ctx) error {
+	invitation, err := h.invitationService.GetByToken(c.Params("token"))
+	if err != nil {
+		return h.invitationError(c, err, "Failed to get invitation")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"email":            invitation.Email,
+			"role":             invitation.Role,
+			"municipalityId":   invitation.MunicipalityID,
+			"municipalityName": invitation.Municipality.Name,
+			"expiresAt":        invitation.ExpiresAt,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// AcceptInvitation sets the invitee's password and creates their account and membership.
+// The invitee then signs in as usual, enrolling in two-factor authentication if their role requires it.
+// POST /api/auth/invitations/accept
+func (h *InvitationHandler) AcceptInvitation(c *fiber.Ctx) error {
+	var req AcceptInvitationRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Hash password
+	hashedPassword, err := h.authService.HashPassword(req.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to process password",
+				"code":    "PASSWORD_HASH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	details := services.AcceptInvitationDetails{
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		PasswordHash: hashedPassword,
+	}
+	if req.Phone != "" {
+		details.Phone = &req.Phone
+	}
+
+	user, err := h.invitationService.Accept(auditActor(c), req.Token, details)
+	if err != nil {
+		return h.invitationError(c, err, "Failed to accept invitation")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success":   true,
+		"data":      user,
+		"message":   "Account created; please sign in",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// invitationError writes the response for a failed invitation action
+func (h *InvitationHandler) invitationError(c *fiber.Ctx, err error, message string) error {
+	status, code := fiber.StatusInternalServerError, "INVITATION_FAILED"
+	switch {
+	case errors.Is(err, services.ErrInvitationNotFound):
+		status, code, message = fiber.StatusNotFound, "INVITATION_NOT_FOUND", "Invitation not found"
+	case errors.Is(err, services.ErrInvitationInvalid):
+		status, code, message = fiber.StatusBadRequest, "INVITATION_INVALID", "Invitation link is invalid or has expired"
+	case errors.Is(err, services.ErrInvitationClosed):
+		status, code, message = fiber.StatusConflict, "INVITATION_CLOSED", "Invitation has already been accepted or revoked"
+	case errors.Is(err, services.ErrInvitationPending):
+		status, code, message = fiber.StatusConflict, "INVITATION_PENDING", "An invitation for this email is already pending"
+	case errors.Is(err, services.ErrInvitationEmailInUse):
+		status, code, message = fiber.StatusConflict, "EMAIL_IN_USE", "A user with this email already exists; add them as a member instead"
+	case errors.Is(err, services.ErrMunicipalRoleNotFound):
+		status, code, message = fiber.StatusBadRequest, "INVALID_ROLE", "Role is not defined in this municipality"
+	case errors.Is(err, services.ErrMembershipMunicipalityNotFound):
+		status, code, message = fiber.StatusNotFound, "MUNICIPALITY_NOT_FOUND", "Municipality not found"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": message,
+			"code":    code,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
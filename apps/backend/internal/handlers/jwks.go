@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/middleware"
+)
+
+// JWKSHandler publishes the public keys that verify issued JWTs
+type JWKSHandler struct {
+	keys *middleware.KeySet
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(keys *middleware.KeySet) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+// GetJWKS returns the JSON Web Key Set
+// GET /.well-known/jwks.json
+func (h *JWKSHandler) GetJWKS(c *fiber.Ctx) error {
+	// Verifiers may cache the set briefly; new keys are listed before they sign, and rotated
+	// keys stay listed until they are removed
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", int(middleware.JWKSMaxAge.Seconds())))
+	return c.JSON(h.keys.JWKS())
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/services"
+)
+
+// MembershipHandler handles the roles users hold in a municipality
+type MembershipHandler struct {
+	membershipService *services.MembershipService
+	validator         *validator.Validate
+}
+
+// NewMembershipHandler creates a new membership handler
+func NewMembershipHandler(membershipService *services.MembershipService) *MembershipHandler {
+	return &MembershipHandler{
+		membershipService: membershipService,
+		validator:         validator.New(),
+	}
+}
+
+// SetMemberRoleRequest represents the municipality role change request payload
+type SetMemberRoleRequest struct {
+	Role string `json:"role" validate:"required,max=50"`
+}
+
+// ListMembers returns the members of a municipality, optionally filtered by ?role=
+// GET /api/municipalities/:id/members
+func (h *MembershipHandler) ListMembers(c *fiber.Ctx) error {
+	var role *string
+	if roleParam := c.Query("role"); roleParam != "" {
+		role = &roleParam
+	}
+
+	members, err := h.membershipService.ListMembers(c.Params("id"), role)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to list municipality members",
+				"code":    "MEMBERS_FETCH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      members,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// SetMemberRole gives a user a role in the municipality, adding them as a member if needed
+// PUT /api/municipalities/:id/members/:userId
+func (h *MembershipHandler) SetMemberRole(c *fiber.Ctx) error {
+	var req SetMemberRoleRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	membership, err := h.membershipService.SetMembershipRole(auditActor(c), c.Params("userId"), c.Params("id"), req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMembershipUserNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "User not found",
+					"code":    "USER_NOT_FOUND",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		case errors.Is(err, services.ErrMunicipalRoleNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Role is not defined in this municipality",
+					"code":    "INVALID_ROLE",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		case errors.Is(err, services.ErrMembershipMunicipalityNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Municipality not found",
+					"code":    "MUNICIPALITY_NOT_FOUND",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Failed to update municipality role",
+					"code":    "UPDATE_FAILED",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      membership,
+		"timestamp": time.Now().Unix(),
+	})
+}
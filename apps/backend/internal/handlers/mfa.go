@@ -0,0 +1,412 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// MFAChallengeResponse is returned by Login instead of tokens when a second step is needed
+type MFAChallengeResponse struct {
+	MFARequired        bool      `json:"mfaRequired"`
+	EnrollmentRequired bool      `json:"enrollmentRequired"`
+	ChallengeToken     string    `json:"challengeToken"`
+	ExpiresAt          time.Time `json:"expiresAt"`
+}
+
+// MFAVerifyRequest represents the second login step payload
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// MFAEnrollRequest represents the enrolment start payload.
+// The challenge token is only needed when enrolling during login.
+type MFAEnrollRequest struct {
+	ChallengeToken string `json:"challengeToken,omitempty"`
+}
+
+// MFAConfirmEnrollmentRequest represents the enrolment confirmation payload
+type MFAConfirmEnrollmentRequest struct {
+	ChallengeToken string `json:"challengeToken,omitempty"`
+	Code           string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// MFACodeRequest represents a request authorised by a current TOTP or recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// mfaChallenge returns the challenge a user must answer before tokens are issued,
+// or nil when the password alone is enough
+func (h *AuthHandler) mfaChallenge(user *models.User) (*MFAChallengeResponse, error) {
+	enabled, err := h.mfaService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	purpose := MFAChallengeVerify
+	if !enabled {
+		if !h.mfaService.IsRequired(user.Role) {
+			return nil, nil
+		}
+		purpose = MFAChallengeEnroll
+	}
+
+	token, expiresAt, err := h.authService.GenerateMFAChallenge(user.ID, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAChallengeResponse{
+		MFARequired:        true,
+		EnrollmentRequired: purpose == MFAChallengeEnroll,
+		ChallengeToken:     token,
+		ExpiresAt:          expiresAt,
+	}, nil
+}
+
+// VerifyMFA completes a login by checking a TOTP or recovery code against a challenge token
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var req MFAVerifyRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Invalid request body",
+			"code":      fiber.StatusBadRequest,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Validation failed",
+			"code":      fiber.StatusBadRequest,
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	claims, err := h.authService.ValidateMFAChallenge(req.ChallengeToken, MFAChallengeVerify)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":     "Invalid or expired challenge token",
+			"code":      fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":     "User not found",
+			"code":      fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if err := services.CheckCanSignIn(&user); err != nil {
+		return h.signInRefused(c, err)
+	}
+
+	// Wrong codes count towards the same limits as wrong passwords
+	if err := h.loginProtection.Check(user.Email, c.IP()); err != nil {
+		return h.loginBlocked(c, err)
+	}
+
+	if err := h.mfaService.Verify(claims.UserID, req.Code); err != nil {
+		if errors.Is(err, services.ErrMFAInvalidCode) {
+			if err := h.loginProtection.RecordFailure(user.Email, c.IP()); err != nil {
+				log.Printf("Failed to record failed login of user %s: %v", user.ID, err)
+			}
+		}
+		return h.mfaError(c, err)
+	}
+
+	if err := h.loginProtection.RecordSuccess(user.Email); err != nil {
+		log.Printf("Failed to reset login attempts of user %s: %v", user.ID, err)
+	}
+
+	// Generate tokens, starting a new refresh token family
+	response, err := h.issueTokens(c, &user, "")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":     "Failed to generate authentication tokens",
+			"code":      fiber.StatusInternalServerError,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	h.recordLogin(c, &user, response.SessionID, "mfa")
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      response,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// BeginMFAEnrollment creates a new TOTP secret and returns it with a provisioning URI and QR code.
+// Signed-in users call it with their access token; users who must enrol before their first
+// login completes pass the enrolment challenge token instead.
+func (h *AuthHandler) BeginMFAEnrollment(c *fiber.Ctx) error {
+	var req MFAEnrollRequest
+
+	// Parse request body
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":     "Invalid request body",
+				"code":      fiber.StatusBadRequest,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+
+	user, viaChallenge, err := h.mfaSubject(c, req.ChallengeToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":     "Authentication required",
+			"code":      fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// The account may have been disabled since the challenge was issued
+	if viaChallenge {
+		if err := services.CheckCanSignIn(user); err != nil {
+			return h.signInRefused(c, err)
+		}
+	}
+
+	enrollment, err := h.mfaService.BeginEnrollment(user)
+	if err != nil {
+		return h.mfaError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      enrollment,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ConfirmMFAEnrollment enables the second factor and returns the recovery codes.
+// When enrolment was part of a login, the login completes and tokens are returned too.
+func (h *AuthHandler) ConfirmMFAEnrollment(c *fiber.Ctx) error {
+	var req MFAConfirmEnrollmentRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Invalid request body",
+			"code":      fiber.StatusBadRequest,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Validation failed",
+			"code":      fiber.StatusBadRequest,
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	user, viaChallenge, err := h.mfaSubject(c, req.ChallengeToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":     "Authentication required",
+			"code":      fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// The account may have been disabled, or a password reset forced, since the challenge
+	// was issued; the login must not complete then
+	if viaChallenge {
+		if err := services.CheckCanSignIn(user); err != nil {
+			return h.signInRefused(c, err)
+		}
+	}
+
+	recoveryCodes, err := h.mfaService.ConfirmEnrollment(user.ID, req.Code)
+	if err != nil {
+		return h.mfaError(c, err)
+	}
+
+	data := fiber.Map{
+		"recoveryCodes": recoveryCodes,
+	}
+
+	if viaChallenge {
+		if err := h.loginProtection.RecordSuccess(user.Email); err != nil {
+			log.Printf("Failed to reset login attempts of user %s: %v", user.ID, err)
+		}
+
+		// Generate tokens, starting a new refresh token family
+		response, err := h.issueTokens(c, user, "")
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":     "Failed to generate authentication tokens",
+				"code":      fiber.StatusInternalServerError,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		h.recordLogin(c, user, response.SessionID, "mfa_enrollment")
+		data["auth"] = response
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      data,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// RegenerateMFARecoveryCodes replaces the current user's recovery codes
+func (h *AuthHandler) RegenerateMFARecoveryCodes(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":     "User not authenticated",
+			"code":      fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	var req MFACodeRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Invalid request body",
+			"code":      fiber.StatusBadRequest,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Validation failed",
+			"code":      fiber.StatusBadRequest,
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	recoveryCodes, err := h.mfaService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		return h.mfaError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"recoveryCodes": recoveryCodes,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// DisableMFA removes the current user's second factor, unless their role requires one
+func (h *AuthHandler) DisableMFA(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":     "User not authenticated",
+			"code":      fiber.StatusUnauthorized,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	userRole, _ := c.Locals("user_role").(string)
+
+	var req MFACodeRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Invalid request body",
+			"code":      fiber.StatusBadRequest,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Validation failed",
+			"code":      fiber.StatusBadRequest,
+			"details":   err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if err := h.mfaService.Disable(userID, models.UserRole(userRole), req.Code); err != nil {
+		return h.mfaError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "Two-factor authentication disabled",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// mfaSubject resolves the user an enrolment request is for: the signed-in user, or the
+// holder of an enrolment challenge token. The boolean reports the challenge case.
+func (h *AuthHandler) mfaSubject(c *fiber.Ctx, challengeToken string) (*models.User, bool, error) {
+	userID, ok := c.Locals("user_id").(string)
+	viaChallenge := false
+
+	if challengeToken != "" {
+		claims, err := h.authService.ValidateMFAChallenge(challengeToken, MFAChallengeEnroll)
+		if err != nil {
+			return nil, false, err
+		}
+		userID, ok, viaChallenge = claims.UserID, true, true
+	}
+
+	if !ok {
+		return nil, false, errors.New("user not authenticated")
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, false, err
+	}
+
+	return &user, viaChallenge, nil
+}
+
+// mfaError maps MFA service errors to responses
+func (h *AuthHandler) mfaError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := "Two-factor authentication failed"
+
+	switch {
+	case errors.Is(err, services.ErrMFAInvalidCode):
+		status, message = fiber.StatusUnauthorized, err.Error()
+	case errors.Is(err, services.ErrMFANotEnrolled):
+		status, message = fiber.StatusBadRequest, err.Error()
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		status, message = fiber.StatusConflict, err.Error()
+	case errors.Is(err, services.ErrMFARequiredForRole):
+		status, message = fiber.StatusForbidden, err.Error()
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error":     message,
+		"code":      status,
+		"timestamp": time.Now().Unix(),
+	})
+}
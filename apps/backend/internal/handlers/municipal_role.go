@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// MunicipalRoleHandler handles the permission catalogue and the roles each municipality defines
+type MunicipalRoleHandler struct {
+	permissionService *services.PermissionService
+	validator         *validator.Validate
+}
+
+// NewMunicipalRoleHandler creates a new municipal role handler
+func NewMunicipalRoleHandler(permissionService *services.PermissionService) *MunicipalRoleHandler {
+	return &MunicipalRoleHandler{
+		permissionService: permissionService,
+		validator:         validator.New(),
+	}
+}
+
+// CreateMunicipalRoleRequest represents the municipal role creation request payload
+type CreateMunicipalRoleRequest struct {
+	Key         string   `json:"key" validate:"required,max=50"`
+	Name        string   `json:"name" validate:"required,max=100"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// UpdateMunicipalRoleRequest represents the municipal role update request payload
+type UpdateMunicipalRoleRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// ListPermissions returns the permission catalogue
+// GET /api/permissions
+func (h *MunicipalRoleHandler) ListPermissions(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"municipal": models.MunicipalPermissions,
+			"platform":  models.PlatformPermissions,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ListRoles returns the built-in and custom roles of a municipality
+// GET /api/municipalities/:id/roles
+func (h *MunicipalRoleHandler) ListRoles(c *fiber.Ctx) error {
+	roles, err := h.permissionService.ListRoles(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to list roles",
+				"code":    "ROLES_FETCH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      roles,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// CreateRole defines a new role in a municipality
+// POST /api/municipalities/:id/roles
+func (h *MunicipalRoleHandler) CreateRole(c *fiber.Ctx) error {
+	var req CreateMunicipalRoleRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	role := &models.MunicipalRole{
+		Key:         req.Key,
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+	if err := h.permissionService.CreateRole(auditActor(c), c.Params("id"), role); err != nil {
+		return h.roleError(c, err, "Failed to create role")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success":   true,
+		"data":      role,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// UpdateRole changes a custom role of a municipality
+// PUT /api/municipalities/:id/roles/:key
+func (h *MunicipalRoleHandler) UpdateRole(c *fiber.Ctx) error {
+	var req UpdateMunicipalRoleRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	role, err := h.permissionService.UpdateRole(auditActor(c), c.Params("id"), c.Params("key"), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		return h.roleError(c, err, "Failed to update role")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      role,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// DeleteRole removes a custom role that no member holds
+// DELETE /api/municipalities/:id/roles/:key
+func (h *MunicipalRoleHandler) DeleteRole(c *fiber.Ctx) error {
+	if err := h.permissionService.DeleteRole(auditActor(c), c.Params("id"), c.Params("key")); err != nil {
+		return h.roleError(c, err, "Failed to delete role")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "Role deleted",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// roleError writes the response for a failed role change
+func (h *MunicipalRoleHandler) roleError(c *fiber.Ctx, err error, message string) error {
+	status, code := fiber.StatusInternalServerError, "ROLE_UPDATE_FAILED"
+	switch {
+	case errors.Is(err, services.ErrMunicipalRoleNotFound):
+		status, code, message = fiber.StatusNotFound, "ROLE_NOT_FOUND", "Role not found"
+	case errors.Is(err, services.ErrMunicipalRoleExists):
+		status, code, message = fiber.StatusConflict, "ROLE_EXISTS", "A role with this key already exists"
+	case errors.Is(err, services.ErrMunicipalRoleInUse):
+		status, code, message = fiber.StatusConflict, "ROLE_IN_USE", "Role is still assigned to members"
+	case errors.Is(err, services.ErrBuiltInMunicipalRole):
+		status, code, message = fiber.StatusForbidden, "BUILT_IN_ROLE", "Built-in roles cannot be changed"
+	case errors.Is(err, services.ErrInvalidMunicipalRole):
+		status, code, message = fiber.StatusBadRequest, "INVALID_ROLE_KEY", "Role key must be a lowercase slug"
+	case errors.Is(err, services.ErrInvalidPermission):
+		status, code, message = fiber.StatusBadRequest, "INVALID_PERMISSION", err.Error()
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": message,
+			"code":    code,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -31,7 +31,7 @@ func (h *MunicipalityHandler) CreateMunicipality(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.municipalityService.CreateMunicipality(&municipality); err != nil {
+	if err := h.municipalityService.CreateMunicipality(auditActor(c), &municipality); err != nil {
 		if strings.Contains(err.Error(), "already exists") {
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 				"error": err.Error(),
@@ -131,7 +131,7 @@ func (h *MunicipalityHandler) UpdateMunicipality(c *fiber.Ctx) error {
 		})
 	}
 
-	municipality, err := h.municipalityService.UpdateMunicipality(id, &updates)
+	municipality, err := h.municipalityService.UpdateMunicipality(auditActor(c), id, &updates)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -161,7 +161,7 @@ func (h *MunicipalityHandler) DeleteMunicipality(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.municipalityService.DeleteMunicipality(id); err != nil {
+	if err := h.municipalityService.DeleteMunicipality(auditActor(c), id); err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": err.Error(),
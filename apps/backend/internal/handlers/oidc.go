@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/services"
+)
+
+// oidcFlowCookie holds the signed OIDC flow token between login and callback
+const oidcFlowCookie = "oidc_flow"
+
+// EnableOIDC turns on single sign-on through an OpenID Connect identity provider
+func (h *AuthHandler) EnableOIDC(oidc *services.OIDCLoginService) {
+	h.oidc = oidc
+}
+
+// OIDCLogin starts a login at the identity provider using the authorization code flow with PKCE
+// GET /api/auth/oidc/login
+func (h *AuthHandler) OIDCLogin(c *fiber.Ctx) error {
+	state, err := services.NewOIDCState()
+	if err != nil {
+		return h.oidcFailed(c, fiber.StatusInternalServerError, "Failed to start single sign-on", err)
+	}
+	nonce, err := services.NewOIDCState()
+	if err != nil {
+		return h.oidcFailed(c, fiber.StatusInternalServerError, "Failed to start single sign-on", err)
+	}
+	verifier, err := services.NewPKCEVerifier()
+	if err != nil {
+		return h.oidcFailed(c, fiber.StatusInternalServerError, "Failed to start single sign-on", err)
+	}
+
+	authURL, err := h.oidc.Provider().AuthCodeURL(c.UserContext(), state, nonce, verifier)
+	if err != nil {
+		return h.oidcFailed(c, fiber.StatusBadGateway, "Identity provider is unavailable", err)
+	}
+
+	flowToken, expiresAt, err := h.authService.GenerateOIDCFlow(state, nonce, verifier)
+	if err != nil {
+		return h.oidcFailed(c, fiber.StatusInternalServerError, "Failed to start single sign-on", err)
+	}
+
+	// Lax lets the cookie accompany the top-level redirect back from the identity provider
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    flowToken,
+		Path:     "/api/auth/oidc",
+		Expires:  expiresAt,
+		HTTPOnly: true,
+		Secure:   os.Getenv("GO_ENV") == "production",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback completes a login started by OIDCLogin and issues the usual token pair.
+// Users with a second factor, or whose role requires one, get the same challenge as a
+// password login instead, and complete it through VerifyMFA or the enrolment endpoints.
+// GET /api/auth/oidc/callback
+func (h *AuthHandler) OIDCCallback(c *fiber.Ctx) error {
+	// The flow token is only needed once
+	flowToken := c.Cookies(oidcFlowCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcFlowCookie,
+		Path:     "/api/auth/oidc",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		Secure:   os.Getenv("GO_ENV") == "production",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	if idpError := c.Query("error"); idpError != "" {
+		return h.oidcFailed(c, fiber.StatusUnauthorized, "Sign-in was cancelled or refused by the identity provider",
+			errors.New(idpError+": "+c.Query("error_description")))
+	}
+
+	flow, err := h.authService.ValidateOIDCFlow(flowToken)
+	if err != nil || c.Query("state") == "" || c.Query("state") != flow.State {
+		return h.oidcFailed(c, fiber.StatusBadRequest, "Sign-in session is invalid or has expired", err)
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return h.oidcFailed(c, fiber.StatusBadRequest, "Authorization code is required", nil)
+	}
+
+	identity, err := h.oidc.Provider().Exchange(c.UserContext(), code, flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		return h.oidcFailed(c, fiber.StatusUnauthorized, "Failed to verify identity provider response", err)
+	}
+
+	user, roleChanged, err := h.oidc.ResolveUser(identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOIDCEmailNotVerified):
+			return h.oidcFailed(c, fiber.StatusForbidden, "Your email address is not verified by the identity provider", err)
+		case errors.Is(err, services.ErrOIDCNoRole), errors.Is(err, services.ErrOIDCUnknownUser),
+			errors.Is(err, services.ErrOIDCLinkRefused):
+			return h.oidcFailed(c, fiber.StatusForbidden, "Your account is not allowed to sign in here", err)
+		default:
+			return h.oidcFailed(c, fiber.StatusInternalServerError, "Failed to sign in", err)
+		}
+	}
+
+	// Disabled accounts stay disabled even if the identity provider still accepts them.
+	// A forced password reset does not apply here; the identity provider checks the password.
+	if err := services.CheckCanSignIn(user); err != nil {
+		if errors.Is(err, services.ErrAccountDisabled) {
+			return h.oidcFailed(c, fiber.StatusForbidden, "Your account is disabled", err)
+		}
+	}
+
+	// Tokens issued before an IdP group change still carry the old role
+	if roleChanged {
+		if err := h.revocationService.InvalidateAccessTokens(user.ID); err != nil {
+			return h.oidcFailed(c, fiber.StatusInternalServerError, "Failed to sign in", err)
+		}
+	}
+
+	// The identity provider's own second factor is not known to be enforced
+	challenge, err := h.mfaChallenge(user)
+	if err != nil {
+		return h.oidcFailed(c, fiber.StatusInternalServerError, "Failed to check two-factor authentication", err)
+	}
+	if challenge != nil {
+		if postLoginURL := h.oidc.Provider().Config().PostLoginURL; postLoginURL != "" {
+			fragment := url.Values{
+				"mfaRequired":        {"true"},
+				"enrollmentRequired": {strconv.FormatBool(challenge.EnrollmentRequired)},
+				"challengeToken":     {challenge.ChallengeToken},
+				"expiresAt":          {challenge.ExpiresAt.Format(time.RFC3339)},
+			}
+			return c.Redirect(postLoginURL+"#"+fragment.Encode(), fiber.StatusFound)
+		}
+
+		return c.JSON(fiber.Map{
+			"success":   true,
+			"data":      challenge,
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	response, err := h.issueTokens(c, user, "")
+	if err != nil {
+		return h.oidcFailed(c, fiber.StatusInternalServerError, "Failed to generate authentication tokens", err)
+	}
+	h.recordLogin(c, user, response.SessionID, "oidc")
+
+	if postLoginURL := h.oidc.Provider().Config().PostLoginURL; postLoginURL != "" {
+		fragment := url.Values{
+			"accessToken":  {response.AccessToken},
+			"refreshToken": {response.RefreshToken},
+			"expiresAt":    {response.ExpiresAt.Format(time.RFC3339)},
+		}
+		return c.Redirect(postLoginURL+"#"+fragment.Encode(), fiber.StatusFound)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      response,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// oidcFailed logs the cause of a failed single sign-on and reports it to the browser,
+// either as JSON or, when a post-login page is configured, in that page's URL fragment
+func (h *AuthHandler) oidcFailed(c *fiber.Ctx, status int, message string, cause error) error {
+	if cause != nil {
+		log.Printf("OIDC login failed: %s: %v", message, cause)
+	}
+
+	if postLoginURL := h.oidc.Provider().Config().PostLoginURL; postLoginURL != "" {
+		fragment := url.Values{"error": {message}}
+		return c.Redirect(postLoginURL+"#"+fragment.Encode(), fiber.StatusFound)
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error":     message,
+		"code":      status,
+		"timestamp": time.Now().Unix(),
+	})
+}
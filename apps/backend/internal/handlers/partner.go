@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"municollect/internal/middleware"
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// PartnerHandler serves the endpoints called by integration partners (banks, utilities,
+// kiosks) with an API key. Every request is limited to the key's municipality.
+type PartnerHandler struct {
+	userService    *services.UserService
+	paymentService *services.PaymentService
+}
+
+// NewPartnerHandler creates a new partner handler
+func NewPartnerHandler(db *gorm.DB, paymentService *services.PaymentService) *PartnerHandler {
+	return &PartnerHandler{
+		userService:    services.NewUserService(db),
+		paymentService: paymentService,
+	}
+}
+
+// GetResident reports whether a resident is registered with the key's municipality.
+// Only non-sensitive data is returned.
+// GET /api/partner/residents/:id
+func (h *PartnerHandler) GetResident(c *fiber.Ctx) error {
+	principal, _ := middleware.GetAPIKeyFromContext(c)
+	residentID := c.Params("id")
+
+	notFound := func() error {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"id":           residentID,
+			"isRegistered": false,
+			"error":        "Resident not found",
+		})
+	}
+
+	user, err := h.userService.GetUserByID(residentID)
+	if err != nil || user.Role != models.UserRoleResident {
+		return notFound()
+	}
+
+	municipalities, err := h.userService.GetUserMunicipalities(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve resident",
+		})
+	}
+
+	for _, municipality := range municipalities {
+		if municipality.ID == principal.MunicipalityID {
+			return c.JSON(fiber.Map{
+				"id":           user.ID,
+				"name":         user.FirstName + " " + user.LastName,
+				"isRegistered": true,
+			})
+		}
+	}
+
+	return notFound()
+}
+
+// GetPaymentStatus retrieves the status of one of the municipality's payments
+// GET /api/partner/payments/:id/status
+func (h *PartnerHandler) GetPaymentStatus(c *fiber.Ctx) error {
+	payment, err := h.municipalityPayment(c)
+	if err != nil {
+		return err
+	}
+	if payment == nil {
+		return nil
+	}
+
+	return c.JSON(fiber.Map{
+		"id":        payment.ID,
+		"status":    payment.Status,
+		"amount":    payment.Amount,
+		"currency":  payment.Currency,
+		"createdAt": payment.CreatedAt,
+		"paidAt":    payment.PaidAt,
+	})
+}
+
+// UpdatePaymentStatus records a status change reported by the partner. Payments collected
+// by a payment provider are refused, as for staff.
+// PUT /api/partner/payments/:id/status
+func (h *PartnerHandler) UpdatePaymentStatus(c *fiber.Ctx) error {
+	payment, err := h.municipalityPayment(c)
+	if err != nil {
+		return err
+	}
+	if payment == nil {
+		return nil
+	}
+
+	var req struct {
+		Status          models.PaymentStatus   `json:"status"`
+		TransactionData map[string]interface{} `json:"transactionData,omitempty"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Status == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Status is required",
+		})
+	}
+
+	// Record which key reported the change
+	principal, _ := middleware.GetAPIKeyFromContext(c)
+	if req.TransactionData == nil {
+		req.TransactionData = make(map[string]interface{})
+	}
+	req.TransactionData["apiKeyId"] = principal.KeyID
+
+	// Payments collected by a payment provider get their status from the provider, not partners
+	updated, err := h.paymentService.UpdatePaymentStatusManually(auditActor(c), payment.ID, req.Status, req.TransactionData)
+	if err != nil {
+		if errors.Is(err, services.ErrProviderManagedPayment) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrInvalidStatusTransition) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrPaymentProviderFailed) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update payment status",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"id":     updated.ID,
+		"status": updated.Status,
+		"paidAt": updated.PaidAt,
+	})
+}
+
+// municipalityPayment loads the payment named in the path if it belongs to the key's
+// municipality. It writes the error response and returns nil when it does not.
+func (h *PartnerHandler) municipalityPayment(c *fiber.Ctx) (*models.Payment, error) {
+	principal, _ := middleware.GetAPIKeyFromContext(c)
+
+	payment, err := h.paymentService.GetPaymentByID(c.Params("id"), nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Payment not found",
+			})
+		}
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve payment",
+		})
+	}
+
+	// Payments of other municipalities look exactly like missing ones
+	if payment.MunicipalityID != principal.MunicipalityID {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Payment not found",
+		})
+	}
+
+	return payment, nil
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"municollect/internal/middleware"
 	"municollect/internal/models"
 	"municollect/internal/services"
 )
@@ -26,7 +28,7 @@ func NewPaymentHandler(paymentService *services.PaymentService) *PaymentHandler
 // POST /api/payments/initiate
 func (h *PaymentHandler) CreatePayment(c *fiber.Ctx) error {
 	// Get user ID from context (set by JWT middleware)
-	userID := c.Locals("userID").(string)
+	userID := c.Locals("user_id").(string)
 
 	var req services.PaymentRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -46,7 +48,7 @@ func (h *PaymentHandler) CreatePayment(c *fiber.Ctx) error {
 			"error": "Service type is required",
 		})
 	}
-	if req.Amount <= 0 {
+	if !req.Amount.IsPositive() {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Amount must be greater than 0",
 		})
@@ -54,6 +56,20 @@ func (h *PaymentHandler) CreatePayment(c *fiber.Ctx) error {
 
 	payment, err := h.paymentService.CreatePayment(userID, &req)
 	if err != nil {
+		// A payment stored before the failure must not be created again by a retry
+		if payment != nil {
+			middleware.MarkIdempotentSideEffect(c)
+		}
+		if errors.Is(err, services.ErrUserNotVerified) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrPaymentProviderFailed) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		if strings.Contains(err.Error(), "not found") {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": err.Error(),
@@ -78,8 +94,8 @@ func (h *PaymentHandler) GetPayment(c *fiber.Ctx) error {
 	}
 
 	// Get user ID from context for authorization
-	userID := c.Locals("userID").(string)
-	userRole := c.Locals("userRole").(string)
+	userID := c.Locals("user_id").(string)
+	userRole := c.Locals("user_role").(string)
 
 	// Admin can view any payment, regular users can only view their own
 	var userIDFilter *string
@@ -113,8 +129,8 @@ func (h *PaymentHandler) GetPaymentStatus(c *fiber.Ctx) error {
 	}
 
 	// Get user ID from context for authorization
-	userID := c.Locals("userID").(string)
-	userRole := c.Locals("userRole").(string)
+	userID := c.Locals("user_id").(string)
+	userRole := c.Locals("user_role").(string)
 
 	// Admin can view any payment, regular users can only view their own
 	var userIDFilter *string
@@ -144,7 +160,7 @@ func (h *PaymentHandler) GetPaymentStatus(c *fiber.Ctx) error {
 	})
 }
 
-// UpdatePaymentStatus updates the status of a payment
+// UpdatePaymentStatus updates the status of a payment (staff of the payment's municipality only)
 // PUT /api/payments/:id/status
 func (h *PaymentHandler) UpdatePaymentStatus(c *fiber.Ctx) error {
 	paymentID := c.Params("id")
@@ -171,18 +187,32 @@ func (h *PaymentHandler) UpdatePaymentStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	payment, err := h.paymentService.UpdatePaymentStatus(paymentID, req.Status, req.TransactionData)
+	payment, err := h.paymentService.UpdatePaymentStatusManually(auditActor(c), paymentID, req.Status, req.TransactionData)
 	if err != nil {
+		// A status change committed before the failure must not be repeated by a retry
+		if payment != nil {
+			middleware.MarkIdempotentSideEffect(c)
+		}
 		if strings.Contains(err.Error(), "not found") {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
+		if errors.Is(err, services.ErrProviderManagedPayment) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		if strings.Contains(err.Error(), "invalid status transition") {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
+		if errors.Is(err, services.ErrPaymentProviderFailed) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update payment status",
 		})
@@ -191,6 +221,60 @@ func (h *PaymentHandler) UpdatePaymentStatus(c *fiber.Ctx) error {
 	return c.JSON(payment)
 }
 
+// RefundPayment refunds a completed payment through its payment provider
+// POST /api/payments/:id/refund
+func (h *PaymentHandler) RefundPayment(c *fiber.Ctx) error {
+	paymentID := c.Params("id")
+	if paymentID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Payment ID is required",
+		})
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	payment, err := h.paymentService.RefundPayment(auditActor(c), paymentID, req.Reason)
+	if err != nil {
+		// A refund made before the failure must not be repeated by a retry
+		if payment != nil {
+			middleware.MarkIdempotentSideEffect(c)
+		}
+		if strings.Contains(err.Error(), "not found") {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if strings.Contains(err.Error(), "invalid status transition") || errors.Is(err, services.ErrRefundNotAllowed) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrRefundInProgress) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrPaymentProviderFailed) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to refund payment",
+		})
+	}
+
+	return c.JSON(payment)
+}
+
 // GetPaymentHistory retrieves payment history with filtering
 // GET /api/payments/history
 func (h *PaymentHandler) GetPaymentHistory(c *fiber.Ctx) error {
@@ -217,8 +301,8 @@ func (h *PaymentHandler) GetPaymentHistory(c *fiber.Ctx) error {
 	}
 
 	// Get user ID from context
-	userID := c.Locals("userID").(string)
-	userRole := c.Locals("userRole").(string)
+	userID := c.Locals("user_id").(string)
+	userRole := c.Locals("user_role").(string)
 
 	// Build filter
 	filter := &services.PaymentFilter{}
@@ -289,7 +373,7 @@ func (h *PaymentHandler) GetUserPayments(c *fiber.Ctx) error {
 	}
 
 	// Get user ID from context
-	userID := c.Locals("userID").(string)
+	userID := c.Locals("user_id").(string)
 
 	payments, total, err := h.paymentService.GetPaymentsByUser(userID, limit, offset)
 	if err != nil {
@@ -306,7 +390,7 @@ func (h *PaymentHandler) GetUserPayments(c *fiber.Ctx) error {
 	})
 }
 
-// GetMunicipalityPayments retrieves all payments for a specific municipality (admin only)
+// GetMunicipalityPayments retrieves all payments for a specific municipality (staff of that municipality only)
 // GET /api/payments/municipality/:municipalityId
 func (h *PaymentHandler) GetMunicipalityPayments(c *fiber.Ctx) error {
 	municipalityID := c.Params("municipalityId")
@@ -67,8 +67,8 @@ func TestPaymentHandler_CreatePayment(t *testing.T) {
 
 	app.Post("/payments", func(c *fiber.Ctx) error {
 		// Mock JWT middleware setting user context
-		c.Locals("userID", "test-user-id")
-		c.Locals("userRole", "resident")
+		c.Locals("user_id", "test-user-id")
+		c.Locals("user_role", "resident")
 		return handler.CreatePayment(c)
 	})
 
@@ -76,7 +76,7 @@ func TestPaymentHandler_CreatePayment(t *testing.T) {
 		req := services.PaymentRequest{
 			MunicipalityID: "test-municipality-id",
 			ServiceType:    models.ServiceTypeWasteManagement,
-			Amount:         100.00,
+			Amount:         models.NewMoney(10000, models.CurrencyUSD),
 			Currency:       models.CurrencyUSD,
 		}
 
@@ -116,7 +116,7 @@ func TestPaymentHandler_CreatePayment(t *testing.T) {
 	t.Run("missing municipality ID", func(t *testing.T) {
 		req := services.PaymentRequest{
 			ServiceType: models.ServiceTypeWasteManagement,
-			Amount:      100.00,
+			Amount:      models.NewMoney(10000, models.CurrencyUSD),
 		}
 
 		body, _ := json.Marshal(req)
@@ -132,7 +132,7 @@ func TestPaymentHandler_CreatePayment(t *testing.T) {
 		req := services.PaymentRequest{
 			MunicipalityID: "test-municipality-id",
 			ServiceType:    models.ServiceTypeWasteManagement,
-			Amount:         0,
+			Amount:         models.Money{},
 		}
 
 		body, _ := json.Marshal(req)
@@ -152,8 +152,8 @@ func TestPaymentHandler_GetPayment(t *testing.T) {
 
 	app.Get("/payments/:id", func(c *fiber.Ctx) error {
 		// Mock JWT middleware setting user context
-		c.Locals("userID", "test-user-id")
-		c.Locals("userRole", "resident")
+		c.Locals("user_id", "test-user-id")
+		c.Locals("user_role", "resident")
 		return handler.GetPayment(c)
 	})
 
@@ -163,7 +163,7 @@ func TestPaymentHandler_GetPayment(t *testing.T) {
 			MunicipalityID: "test-municipality-id",
 			UserID:         "test-user-id",
 			ServiceType:    models.ServiceTypeWasteManagement,
-			Amount:         100.00,
+			Amount:         models.NewMoney(10000, models.CurrencyUSD),
 			Status:         models.PaymentStatusPending,
 		}
 
@@ -201,8 +201,8 @@ func TestPaymentHandler_GetPaymentStatus(t *testing.T) {
 
 	app.Get("/payments/:id/status", func(c *fiber.Ctx) error {
 		// Mock JWT middleware setting user context
-		c.Locals("userID", "test-user-id")
-		c.Locals("userRole", "resident")
+		c.Locals("user_id", "test-user-id")
+		c.Locals("user_role", "resident")
 		return handler.GetPaymentStatus(c)
 	})
 
@@ -211,7 +211,7 @@ func TestPaymentHandler_GetPaymentStatus(t *testing.T) {
 		payment := &models.Payment{
 			ID:        "test-payment-id",
 			Status:    models.PaymentStatusCompleted,
-			Amount:    100.00,
+			Amount:    models.NewMoney(10000, models.CurrencyUSD),
 			Currency:  models.CurrencyUSD,
 			CreatedAt: now,
 			PaidAt:    &now,
@@ -237,8 +237,8 @@ func TestPaymentHandler_UpdatePaymentStatus(t *testing.T) {
 
 	app.Put("/payments/:id/status", func(c *fiber.Ctx) error {
 		// Mock JWT middleware setting user context
-		c.Locals("userID", "admin-user-id")
-		c.Locals("userRole", "admin")
+		c.Locals("user_id", "admin-user-id")
+		c.Locals("user_role", "admin")
 		return handler.UpdatePaymentStatus(c)
 	})
 
@@ -298,8 +298,8 @@ func TestPaymentHandler_GetPaymentHistory(t *testing.T) {
 
 	app.Get("/payments/history", func(c *fiber.Ctx) error {
 		// Mock JWT middleware setting user context
-		c.Locals("userID", "test-user-id")
-		c.Locals("userRole", "resident")
+		c.Locals("user_id", "test-user-id")
+		c.Locals("user_role", "resident")
 		return handler.GetPaymentHistory(c)
 	})
 
@@ -310,7 +310,7 @@ func TestPaymentHandler_GetPaymentHistory(t *testing.T) {
 				MunicipalityID: "municipality-1",
 				UserID:         "test-user-id",
 				ServiceType:    models.ServiceTypeWasteManagement,
-				Amount:         100.00,
+				Amount:         models.NewMoney(10000, models.CurrencyUSD),
 				Status:         models.PaymentStatusCompleted,
 			},
 		}
@@ -201,7 +201,7 @@ func (h *QRCodeHandler) GetQRCodeByPayment(c *fiber.Ctx) error {
 	}
 
 	// Get user role from context for authorization
-	userRole := c.Locals("userRole").(string)
+	userRole := c.Locals("user_role").(string)
 
 	// Check if user has access to this payment
 	// This is a simplified check - in a real implementation, you'd verify payment ownership
@@ -73,7 +73,7 @@ func TestQRCodeHandler_GenerateQRCode(t *testing.T) {
 			Data: models.QRCodeData{
 				PaymentID:      req.PaymentID,
 				MunicipalityID: "test-municipality-id",
-				Amount:         100.00,
+				Amount:         models.NewMoney(10000, models.CurrencyUSD),
 				Currency:       models.CurrencyUSD,
 				ServiceType:    models.ServiceTypeWasteManagement,
 				ExpiresAt:      time.Now().Add(60 * time.Minute),
@@ -157,7 +157,7 @@ func TestQRCodeHandler_ValidateQRCode(t *testing.T) {
 			MunicipalityID: "test-municipality-id",
 			UserID:         "test-user-id",
 			ServiceType:    models.ServiceTypeWasteManagement,
-			Amount:         100.00,
+			Amount:         models.NewMoney(10000, models.CurrencyUSD),
 			Status:         models.PaymentStatusPending,
 		}
 
@@ -225,7 +225,7 @@ func TestQRCodeHandler_GetQRCodeDetails(t *testing.T) {
 			Data: models.QRCodeData{
 				PaymentID:      "test-payment-id",
 				MunicipalityID: "test-municipality-id",
-				Amount:         100.00,
+				Amount:         models.NewMoney(10000, models.CurrencyUSD),
 				Currency:       models.CurrencyUSD,
 				ServiceType:    models.ServiceTypeWasteManagement,
 				ExpiresAt:      time.Now().Add(60 * time.Minute),
@@ -271,7 +271,7 @@ func TestQRCodeHandler_RegenerateQRCode(t *testing.T) {
 			Data: models.QRCodeData{
 				PaymentID:      "test-payment-id",
 				MunicipalityID: "test-municipality-id",
-				Amount:         100.00,
+				Amount:         models.NewMoney(10000, models.CurrencyUSD),
 				Currency:       models.CurrencyUSD,
 				ServiceType:    models.ServiceTypeWasteManagement,
 				ExpiresAt:      time.Now().Add(60 * time.Minute),
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"municollect/internal/middleware"
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// SessionHandler handles listing and revoking login sessions
+type SessionHandler struct {
+	sessionService    *services.SessionService
+	userService       *services.UserService
+	revocationService *services.TokenRevocationService
+	auditService      *services.AuditService
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(db *gorm.DB, sessionService *services.SessionService, revocationService *services.TokenRevocationService, auditService *services.AuditService) *SessionHandler {
+	return &SessionHandler{
+		sessionService:    sessionService,
+		userService:       services.NewUserService(db),
+		revocationService: revocationService,
+		auditService:      auditService,
+	}
+}
+
+// SessionResponse is a session as shown to its user or an admin
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the current user's active sessions
+// GET /api/users/sessions
+func (h *SessionHandler) ListSessions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "User not authenticated",
+				"code":    "UNAUTHORIZED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return h.listSessions(c, userID)
+}
+
+// RevokeSession signs the current user out of one of their sessions
+// DELETE /api/users/sessions/:id
+func (h *SessionHandler) RevokeSession(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "User not authenticated",
+				"code":    "UNAUTHORIZED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return h.revokeSession(c, userID, c.Params("id"), nil)
+}
+
+// ListUserSessions returns the active sessions of any user
+// GET /api/admin/users/:id/sessions
+func (h *SessionHandler) ListUserSessions(c *fiber.Ctx) error {
+	userID := c.Params("id")
+	if _, err := h.userService.GetUserByID(userID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "User not found",
+				"code":    "USER_NOT_FOUND",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	return h.listSessions(c, userID)
+}
+
+// RevokeUserSession signs any user out of one of their sessions
+// DELETE /api/admin/users/:id/sessions/:sessionId
+func (h *SessionHandler) RevokeUserSession(c *fiber.Ctx) error {
+	actor := auditActor(c)
+	return h.revokeSession(c, c.Params("id"), c.Params("sessionId"), &actor)
+}
+
+// listSessions writes the user's active sessions, marking the one the request was made from
+func (h *SessionHandler) listSessions(c *fiber.Ctx, userID string) error {
+	sessions, err := h.sessionService.ListActive(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to list sessions",
+				"code":    "SESSIONS_FETCH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	currentID, _ := middleware.GetSessionIDFromContext(c)
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			Session: session,
+			Current: session.ID == currentID,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      response,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// revokeSession ends one of the user's sessions, auditing it when done on behalf of an admin
+func (h *SessionHandler) revokeSession(c *fiber.Ctx, userID, sessionID string, actor *services.AuditActor) error {
+	if _, err := h.sessionService.GetForUser(userID, sessionID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Session not found",
+					"code":    "SESSION_NOT_FOUND",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to get session",
+				"code":    "SESSION_FETCH_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if err := h.revocationService.RevokeSession(sessionID, models.RefreshTokenRevokedSessionEnded); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Failed to revoke session",
+				"code":    "REVOCATION_FAILED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if actor != nil {
+		err := h.auditService.Record(*actor, services.AuditEvent{
+			Action:       models.AuditActionUserSessionRevoked,
+			ResourceType: models.AuditResourceUser,
+			ResourceID:   userID,
+			Details:      models.AuditDetails{"sessionId": sessionID},
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"message": "Failed to record session revocation",
+					"code":    "AUDIT_FAILED",
+				},
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "Session revoked",
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+
+	"municollect/internal/models"
+	"municollect/internal/services"
+)
+
+// VerificationHandler handles email and phone verification requests
+type VerificationHandler struct {
+	verificationService *services.VerificationService
+	validator           *validator.Validate
+}
+
+// NewVerificationHandler creates a new verification handler
+func NewVerificationHandler(verificationService *services.VerificationService) *VerificationHandler {
+	return &VerificationHandler{
+		verificationService: verificationService,
+		validator:           validator.New(),
+	}
+}
+
+// SendVerificationRequest represents the verification code request payload
+type SendVerificationRequest struct {
+	Channel models.VerificationChannel `json:"channel" validate:"required,oneof=email phone"`
+}
+
+// ConfirmVerificationRequest represents the verification code confirmation payload
+type ConfirmVerificationRequest struct {
+	Channel models.VerificationChannel `json:"channel" validate:"required,oneof=email phone"`
+	Code    string                     `json:"code" validate:"required,len=6,numeric"`
+}
+
+// SendCode sends a verification code to the current user's email address or phone number
+// POST /api/users/verification/send
+func (h *VerificationHandler) SendCode(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "User not authenticated",
+				"code":    "UNAUTHORIZED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	var req SendVerificationRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	if err := h.verificationService.SendCode(userID, req.Channel); err != nil {
+		return h.verificationError(c, err, "Failed to send verification code", "SEND_FAILED")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"message":   "Verification code sent",
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ConfirmCode confirms a verification code and marks the email address or phone number as verified
+// POST /api/users/verification/confirm
+func (h *VerificationHandler) ConfirmCode(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "User not authenticated",
+				"code":    "UNAUTHORIZED",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	var req ConfirmVerificationRequest
+
+	// Parse request body
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Invalid request body",
+				"code":    "INVALID_REQUEST",
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"message": "Validation failed",
+				"code":    "VALIDATION_ERROR",
+				"details": err.Error(),
+			},
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	user, err := h.verificationService.ConfirmCode(userID, req.Channel, req.Code)
+	if err != nil {
+		return h.verificationError(c, err, "Failed to confirm verification code", "CONFIRM_FAILED")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      user,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// verificationError maps verification service errors to responses
+func (h *VerificationHandler) verificationError(c *fiber.Ctx, err error, fallbackMessage, fallbackCode string) error {
+	status := fiber.StatusInternalServerError
+	message := fallbackMessage
+	code := fallbackCode
+
+	switch {
+	case err.Error() == "user not found":
+		status, message, code = fiber.StatusNotFound, "User not found", "USER_NOT_FOUND"
+	case errors.Is(err, services.ErrVerificationCodeInvalid):
+		status, message, code = fiber.StatusBadRequest, err.Error(), "INVALID_CODE"
+	case errors.Is(err, services.ErrVerificationCodeCooldown):
+		status, message, code = fiber.StatusTooManyRequests, err.Error(), "TOO_MANY_REQUESTS"
+	case errors.Is(err, services.ErrVerificationNoPhone):
+		status, message, code = fiber.StatusBadRequest, err.Error(), "NO_PHONE"
+	case errors.Is(err, services.ErrVerificationAlreadyDone):
+		status, message, code = fiber.StatusConflict, err.Error(), "ALREADY_VERIFIED"
+	case errors.Is(err, services.ErrInvalidVerificationChannel):
+		status, message, code = fiber.StatusBadRequest, err.Error(), "INVALID_CHANNEL"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"message": message,
+			"code":    code,
+		},
+		"timestamp": time.Now().Unix(),
+	})
+}
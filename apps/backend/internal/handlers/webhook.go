@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"municollect/internal/services"
+)
+
+// Headers carrying a payment webhook's signature
+const (
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookHandler handles notifications sent by payment providers
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// ReceivePaymentWebhook receives a signed payment notification from a provider. Any 2xx
+// response means the event is stored and the provider may stop sending it; a 5xx asks the
+// provider to deliver it again.
+// POST /api/webhooks/payments/:provider
+func (h *WebhookHandler) ReceivePaymentWebhook(c *fiber.Ctx) error {
+	receipt, err := h.webhookService.Receive(
+		c.Params("provider"),
+		c.Get(WebhookTimestampHeader),
+		c.Get(WebhookSignatureHeader),
+		append([]byte(nil), c.Body()...),
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownWebhookProvider) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Unknown payment provider",
+			})
+		}
+		if errors.Is(err, services.ErrInvalidWebhookSignature) || errors.Is(err, services.ErrStaleWebhook) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrInvalidProviderEvent) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to receive webhook",
+		})
+	}
+
+	if receipt.Duplicate {
+		return c.JSON(fiber.Map{
+			"received":  true,
+			"duplicate": true,
+			"status":    receipt.Event.Status,
+		})
+	}
+	if receipt.Queued {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"received": true,
+			"status":   receipt.Event.Status,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"received": true,
+		"status":   receipt.Event.Status,
+	})
+}
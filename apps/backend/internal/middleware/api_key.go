@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyHeader is the request header carrying a machine API key
+const APIKeyHeader = "X-API-Key"
+
+// ErrInvalidAPIKey is returned for unknown, revoked or expired API keys
+var ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
+// APIKeyPrincipal identifies the integration partner behind an authenticated API key
+type APIKeyPrincipal struct {
+	KeyID          string
+	Name           string
+	MunicipalityID string
+	Scopes         []string
+}
+
+// HasScope reports whether the key was granted the scope
+func (p *APIKeyPrincipal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyAuthenticator resolves a raw API key to its principal. It returns
+// ErrInvalidAPIKey when the key must be rejected.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key, ip string) (*APIKeyPrincipal, error)
+}
+
+// APIKeyMiddleware creates a middleware for API key authentication
+func APIKeyMiddleware(authenticator APIKeyAuthenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(APIKeyHeader)
+		if key == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":     "X-API-Key header is required",
+				"code":      fiber.StatusUnauthorized,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		principal, err := authenticator.AuthenticateAPIKey(key, c.IP())
+		if err != nil {
+			if errors.Is(err, ErrInvalidAPIKey) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error":     "Invalid or expired API key",
+					"code":      fiber.StatusUnauthorized,
+					"timestamp": time.Now().Unix(),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":     "Failed to verify API key",
+				"code":      fiber.StatusInternalServerError,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		// Store the key information in context
+		c.Locals("api_key", principal)
+
+		return c.Next()
+	}
+}
+
+// RequireScope creates middleware that requires the API key to carry a scope
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := GetAPIKeyFromContext(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":     "API key required",
+				"code":      fiber.StatusUnauthorized,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		if !principal.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":     "API key is missing scope " + scope,
+				"code":      fiber.StatusForbidden,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// GetAPIKeyFromContext extracts the API key principal from fiber context
+func GetAPIKeyFromContext(c *fiber.Ctx) (*APIKeyPrincipal, bool) {
+	principal, ok := c.Locals("api_key").(*APIKeyPrincipal)
+	return principal, ok
+}
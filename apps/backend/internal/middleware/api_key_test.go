@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAPIKeyAuthenticator map[string]*APIKeyPrincipal
+
+func (f fakeAPIKeyAuthenticator) AuthenticateAPIKey(key, ip string) (*APIKeyPrincipal, error) {
+	if key == "broken" {
+		return nil, errors.New("database unavailable")
+	}
+	principal, ok := f[key]
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	return principal, nil
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	authenticator := fakeAPIKeyAuthenticator{
+		"reader": {KeyID: "key-1", MunicipalityID: "muni-1", Scopes: []string{"payments:read"}},
+	}
+
+	app := fiber.New()
+	app.Use(APIKeyMiddleware(authenticator))
+	app.Get("/read", RequireScope("payments:read"), func(c *fiber.Ctx) error {
+		principal, ok := GetAPIKeyFromContext(c)
+		require.True(t, ok)
+		return c.SendString(principal.MunicipalityID)
+	})
+	app.Put("/write", RequireScope("payments:write"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		status int
+	}{
+		{"missing key", "GET", "/read", "", fiber.StatusUnauthorized},
+		{"unknown key", "GET", "/read", "unknown", fiber.StatusUnauthorized},
+		{"authenticator failure", "GET", "/read", "broken", fiber.StatusInternalServerError},
+		{"granted scope", "GET", "/read", "reader", fiber.StatusOK},
+		{"missing scope", "PUT", "/write", "reader", fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestRequireScope_WithoutAPIKey(t *testing.T) {
+	app := fiber.New()
+	app.Get("/read", RequireScope("payments:read"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/read", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
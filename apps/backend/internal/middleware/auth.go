@@ -1,41 +1,113 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"log"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrTokenRevoked is returned by ValidateToken for tokens that were revoked server-side
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// TokenTimePrecision is the precision of the timestamps in issued tokens. Whole seconds would
+// let a token issued earlier in the same second as a user's cut-off pass it.
+const TokenTimePrecision = time.Microsecond
+
+func init() {
+	jwt.TimePrecision = TokenTimePrecision
+}
+
+// NextTokenCutoff returns a cut-off after every token issued so far: the current time rounded
+// up to TokenTimePrecision. Tokens issued once it has passed are accepted.
+func NextTokenCutoff() time.Time {
+	return time.Now().Truncate(TokenTimePrecision).Add(TokenTimePrecision)
+}
+
 // JWTClaims represents the JWT token claims
 type JWTClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    string       `json:"user_id"`
+	Email     string       `json:"email"`
+	Role      string       `json:"role"`
+	SessionID string       `json:"sid,omitempty"`
+	Actor     *ActorClaims `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ActorClaims identifies the admin acting as the token's user (RFC 8693 "act" claim).
+// Only impersonation tokens carry it.
+type ActorClaims struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+}
+
+// IsImpersonation reports whether the token was issued to an admin acting as its user
+func (c *JWTClaims) IsImpersonation() bool {
+	return c.Actor != nil && c.Actor.Subject != ""
+}
+
+// ImpersonationRecorder records every request made with an impersonation token
+type ImpersonationRecorder interface {
+	RecordImpersonatedRequest(impersonatorID, userID, method, path, ipAddress, requestID string) error
+}
+
+// SessionTracker records activity on the server-side session named by a token's "sid" claim
+type SessionTracker interface {
+	TouchSession(sessionID, ipAddress string) error
+}
+
 // AuthService handles JWT token operations
 type AuthService struct {
-	jwtSecret []byte
+	keys        *KeySet
+	revocations   RevocationStore
+	sessions      SessionTracker
+	impersonation ImpersonationRecorder
 }
 
-// NewAuthService creates a new authentication service
+// NewAuthService creates a new authentication service using the key set configured in the environment
 func NewAuthService() *AuthService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key" // Default for development - should be set via env var
+	keys, err := DefaultKeySet()
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
 	}
 	
+	return NewAuthServiceWithKeys(keys)
+}
+
+// NewAuthServiceWithKeys creates a new authentication service using the given key set
+func NewAuthServiceWithKeys(keys *KeySet) *AuthService {
 	return &AuthService{
-		jwtSecret: []byte(secret),
+		keys: keys,
 	}
 }
 
+// Keys returns the key set used to sign and verify tokens
+func (a *AuthService) Keys() *KeySet {
+	return a.keys
+}
+
+// SetRevocationStore enables revocation checks in ValidateToken
+func (a *AuthService) SetRevocationStore(store RevocationStore) {
+	a.revocations = store
+}
+
+// SetSessionTracker enables last-seen tracking of sessions in JWTMiddleware
+func (a *AuthService) SetSessionTracker(tracker SessionTracker) {
+	a.sessions = tracker
+}
+
+// SetImpersonationRecorder enables impersonation tokens in JWTMiddleware. Without a
+// recorder, impersonation tokens are rejected because their use could not be audited.
+func (a *AuthService) SetImpersonationRecorder(recorder ImpersonationRecorder) {
+	a.impersonation = recorder
+}
+
 // GenerateTokens generates access and refresh tokens for a user
 func (a *AuthService) GenerateTokens(userID, email, role string) (string, string, time.Time, error) {
 	// Access token expires in 24 hours
@@ -52,12 +124,12 @@ func (a *AuthService) GenerateTokens(userID, email, role string) (string, string
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "municollect",
 			Subject:   userID,
+			ID:        uuid.NewString(),
 		},
 	}
 	
 	// Generate access token
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(a.jwtSecret)
+	accessTokenString, err := a.keys.Sign(accessClaims)
 	if err != nil {
 		return "", "", time.Time{}, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -74,12 +146,12 @@ func (a *AuthService) GenerateTokens(userID, email, role string) (string, string
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "municollect-refresh",
 			Subject:   userID,
+			ID:        uuid.NewString(),
 		},
 	}
 	
 	// Generate refresh token
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(a.jwtSecret)
+	refreshTokenString, err := a.keys.Sign(refreshClaims)
 	if err != nil {
 		return "", "", time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
@@ -89,23 +161,78 @@ func (a *AuthService) GenerateTokens(userID, email, role string) (string, string
 
 // ValidateToken validates a JWT token and returns the claims
 func (a *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return a.jwtSecret, nil
-	})
+	options := append(a.keys.ParserOptions(), jwt.WithIssuer("municollect"))
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, a.keys.Keyfunc, options...)
 	
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
 	
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	
+	// Check the token against the revocation store, if one is configured
+	if a.revocations != nil {
+		if err := a.checkRevocation(claims); err != nil {
+			return nil, err
+		}
 	}
 	
-	return nil, fmt.Errorf("invalid token")
+	return claims, nil
+}
+
+// checkRevocation rejects tokens revoked by jti or session, or issued before the user's cut-off time
+func (a *AuthService) checkRevocation(claims *JWTClaims) error {
+	if claims.ID != "" {
+		revoked, err := a.revocations.IsTokenRevoked(claims.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	
+	if claims.SessionID != "" {
+		revoked, err := a.revocations.IsSessionRevoked(claims.SessionID)
+		if err != nil {
+			return fmt.Errorf("failed to check session revocation: %w", err)
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	
+	if err := a.checkTokensValidAfter(claims.UserID, claims); err != nil {
+		return err
+	}
+	
+	// Signing the impersonating admin out ends their impersonation too
+	if claims.IsImpersonation() {
+		if err := a.checkTokensValidAfter(claims.Actor.Subject, claims); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+// checkTokensValidAfter rejects tokens issued before the user's cut-off time
+func (a *AuthService) checkTokensValidAfter(userID string, claims *JWTClaims) error {
+	validAfter, err := a.revocations.GetTokensValidAfter(userID)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	
+	if !validAfter.IsZero() {
+		if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(validAfter) {
+			return ErrTokenRevoked
+		}
+	}
+	
+	return nil
 }
 
 // HashPassword hashes a password using bcrypt
@@ -158,12 +285,41 @@ func JWTMiddleware(authService *AuthService) fiber.Handler {
 			})
 		}
 		
+		// Every request made while impersonating is recorded with both identities
+		// before it is handled; if it cannot be recorded, it is refused
+		if claims.IsImpersonation() {
+			if authService.impersonation == nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Invalid or expired token",
+					"code":  fiber.StatusUnauthorized,
+					"timestamp": time.Now().Unix(),
+				})
+			}
+			if err := authService.impersonation.RecordImpersonatedRequest(claims.Actor.Subject, claims.UserID, c.Method(), c.Path(), c.IP(), GetRequestIDFromContext(c)); err != nil {
+				log.Printf("Failed to record impersonated request by %s as %s: %v", claims.Actor.Subject, claims.UserID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to record impersonated request",
+					"code":  fiber.StatusInternalServerError,
+					"timestamp": time.Now().Unix(),
+				})
+			}
+			c.Locals("impersonator_id", claims.Actor.Subject)
+		}
+		
 		// Store user information in context
 		c.Locals("user_id", claims.UserID)
 		c.Locals("user_email", claims.Email)
 		c.Locals("user_role", claims.Role)
+		c.Locals("session_id", claims.SessionID)
 		c.Locals("claims", claims)
 		
+		// Keep the session's last-seen time current; a failure here must not fail the request
+		if authService.sessions != nil && claims.SessionID != "" {
+			if err := authService.sessions.TouchSession(claims.SessionID, c.IP()); err != nil {
+				log.Printf("Failed to record activity for session %s: %v", claims.SessionID, err)
+			}
+		}
+		
 		return c.Next()
 	}
 }
@@ -195,27 +351,65 @@ func OptionalJWTMiddleware(authService *AuthService) fiber.Handler {
 			return c.Next()
 		}
 		
+		// Optional authentication is used by sign-in flows, which impersonation tokens may not take part in
+		if claims.IsImpersonation() {
+			return c.Next()
+		}
+		
 		// Store user information in context
 		c.Locals("user_id", claims.UserID)
 		c.Locals("user_email", claims.Email)
 		c.Locals("user_role", claims.Role)
+		c.Locals("session_id", claims.SessionID)
 		c.Locals("claims", claims)
 		
 		return c.Next()
 	}
 }
 
-// GetUserFromContext extracts user information from fiber context
-func GetUserFromContext(c *fiber.Ctx) (userID, email, role string, ok bool) {
+// GetUserFromContext extracts user information from fiber context. For requests made with
+// an impersonation token, impersonatorID is the admin acting as the user; otherwise it is empty.
+func GetUserFromContext(c *fiber.Ctx) (userID, email, role, impersonatorID string, ok bool) {
 	userID, ok1 := c.Locals("user_id").(string)
 	email, ok2 := c.Locals("user_email").(string)
 	role, ok3 := c.Locals("user_role").(string)
+	impersonatorID, _ = c.Locals("impersonator_id").(string)
 	
-	return userID, email, role, ok1 && ok2 && ok3
+	return userID, email, role, impersonatorID, ok1 && ok2 && ok3
+}
+
+// GetImpersonatorFromContext returns the admin acting as the user when the request was
+// made with an impersonation token
+func GetImpersonatorFromContext(c *fiber.Ctx) (string, bool) {
+	impersonatorID, ok := c.Locals("impersonator_id").(string)
+	return impersonatorID, ok && impersonatorID != ""
+}
+
+// DenyImpersonation creates a middleware that refuses requests made with an impersonation
+// token, for operations such as changing credentials that only the user may perform
+func DenyImpersonation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, impersonating := GetImpersonatorFromContext(c); impersonating {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "This action is not allowed while impersonating a user",
+				"code":  fiber.StatusForbidden,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		
+		return c.Next()
+	}
 }
 
 // GetClaimsFromContext extracts JWT claims from fiber context
 func GetClaimsFromContext(c *fiber.Ctx) (*JWTClaims, bool) {
 	claims, ok := c.Locals("claims").(*JWTClaims)
 	return claims, ok
-}
\ No newline at end of file
+}
+
+// GetSessionIDFromContext returns the session ID of the access token used for the request.
+// Tokens issued before sessions were recorded carry no session ID.
+func GetSessionIDFromContext(c *fiber.Ctx) (string, bool) {
+	sessionID, ok := c.Locals("session_id").(string)
+	return sessionID, ok && sessionID != ""
+}
@@ -0,0 +1,287 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthService_ValidateToken_Revocation(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	authService := NewAuthService()
+	authService.SetRevocationStore(store)
+
+	t.Run("valid token passes", func(t *testing.T) {
+		accessToken, _, _, err := authService.GenerateTokens("user-1", "user1@example.com", "resident")
+		require.NoError(t, err)
+
+		claims, err := authService.ValidateToken(accessToken)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims.UserID)
+		assert.NotEmpty(t, claims.ID)
+	})
+
+	t.Run("revoked jti is rejected", func(t *testing.T) {
+		accessToken, _, expiresAt, err := authService.GenerateTokens("user-2", "user2@example.com", "resident")
+		require.NoError(t, err)
+
+		claims, err := authService.ValidateToken(accessToken)
+		require.NoError(t, err)
+
+		require.NoError(t, store.RevokeToken(claims.ID, expiresAt))
+
+		_, err = authService.ValidateToken(accessToken)
+		assert.True(t, errors.Is(err, ErrTokenRevoked))
+	})
+
+	t.Run("tokens issued before the cut-off are rejected", func(t *testing.T) {
+		accessToken, _, _, err := authService.GenerateTokens("user-3", "user3@example.com", "admin")
+		require.NoError(t, err)
+
+		require.NoError(t, store.SetTokensValidAfter("user-3", time.Now().Add(time.Second)))
+
+		_, err = authService.ValidateToken(accessToken)
+		assert.True(t, errors.Is(err, ErrTokenRevoked))
+
+		// Other users are unaffected
+		otherToken, _, _, err := authService.GenerateTokens("user-4", "user4@example.com", "admin")
+		require.NoError(t, err)
+		_, err = authService.ValidateToken(otherToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("tokens of a revoked session are rejected", func(t *testing.T) {
+		sessionToken := signSessionToken(t, authService, "user-6", "session-1")
+		otherSessionToken := signSessionToken(t, authService, "user-6", "session-2")
+
+		require.NoError(t, store.RevokeSession("session-1", time.Now().Add(time.Hour)))
+
+		_, err := authService.ValidateToken(sessionToken)
+		assert.True(t, errors.Is(err, ErrTokenRevoked))
+
+		// The user's other sessions are unaffected
+		claims, err := authService.ValidateToken(otherSessionToken)
+		require.NoError(t, err)
+		assert.Equal(t, "session-2", claims.SessionID)
+	})
+
+	t.Run("tokens issued earlier in the same second as the cut-off are rejected", func(t *testing.T) {
+		accessToken, _, _, err := authService.GenerateTokens("user-7", "user7@example.com", "admin")
+		require.NoError(t, err)
+
+		cutoff := NextTokenCutoff()
+		require.NoError(t, store.SetTokensValidAfter("user-7", cutoff))
+
+		_, err = authService.ValidateToken(accessToken)
+		assert.True(t, errors.Is(err, ErrTokenRevoked))
+
+		// Replacement tokens issued once the cut-off has passed are accepted
+		time.Sleep(time.Until(cutoff))
+		replacement, _, _, err := authService.GenerateTokens("user-7", "user7@example.com", "resident")
+		require.NoError(t, err)
+		_, err = authService.ValidateToken(replacement)
+		assert.NoError(t, err)
+	})
+
+	t.Run("tokens issued after the cut-off are accepted", func(t *testing.T) {
+		require.NoError(t, store.SetTokensValidAfter("user-5", time.Now().Add(-time.Minute)))
+
+		accessToken, _, _, err := authService.GenerateTokens("user-5", "user5@example.com", "resident")
+		require.NoError(t, err)
+
+		_, err = authService.ValidateToken(accessToken)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAuthService_ValidateToken_RejectsRefreshTokens(t *testing.T) {
+	authService := NewAuthService()
+
+	_, refreshToken, _, err := authService.GenerateTokens("user-1", "user1@example.com", "resident")
+	require.NoError(t, err)
+
+	_, err = authService.ValidateToken(refreshToken)
+	assert.Error(t, err)
+}
+
+func TestJWTMiddleware_TracksSession(t *testing.T) {
+	authService := NewAuthService()
+	tracker := &fakeSessionTracker{}
+	authService.SetSessionTracker(tracker)
+
+	app := fiber.New()
+	app.Get("/", JWTMiddleware(authService), func(c *fiber.Ctx) error {
+		sessionID, _ := GetSessionIDFromContext(c)
+		return c.SendString(sessionID)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signSessionToken(t, authService, "user-1", "session-1"))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "session-1", string(body))
+	assert.Equal(t, []string{"session-1"}, tracker.touched)
+}
+
+// fakeSessionTracker records the sessions it was told about
+type fakeSessionTracker struct {
+	touched []string
+}
+
+func (f *fakeSessionTracker) TouchSession(sessionID, ipAddress string) error {
+	f.touched = append(f.touched, sessionID)
+	return nil
+}
+
+// signSessionToken issues an access token bound to a session
+func signSessionToken(t *testing.T, authService *AuthService, userID, sessionID string) string {
+	t.Helper()
+
+	now := time.Now()
+	token, err := authService.Keys().Sign(&JWTClaims{
+		UserID:    userID,
+		Role:      "resident",
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "municollect",
+			Subject:   userID,
+			ID:        uuid.NewString(),
+		},
+	})
+	require.NoError(t, err)
+	return token
+}
+
+func TestJWTMiddleware_Impersonation(t *testing.T) {
+	newApp := func(authService *AuthService) *fiber.App {
+		app := fiber.New()
+		app.Get("/", JWTMiddleware(authService), func(c *fiber.Ctx) error {
+			userID, _, _, impersonatorID, _ := GetUserFromContext(c)
+			return c.SendString(userID + "/" + impersonatorID)
+		})
+		app.Put("/password", JWTMiddleware(authService), DenyImpersonation(), func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusNoContent)
+		})
+		return app
+	}
+	send := func(app *fiber.App, method, path, token string) (*http.Response, string) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	t.Run("requests are recorded with both identities", func(t *testing.T) {
+		authService := NewAuthService()
+		recorder := &fakeImpersonationRecorder{}
+		authService.SetImpersonationRecorder(recorder)
+		app := newApp(authService)
+
+		resp, body := send(app, "GET", "/", signImpersonationToken(t, authService, "user-1", "admin-1"))
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "user-1/admin-1", body)
+		assert.Equal(t, []string{"admin-1 user-1 GET /"}, recorder.recorded)
+	})
+
+	t.Run("ordinary tokens have no impersonator", func(t *testing.T) {
+		authService := NewAuthService()
+		recorder := &fakeImpersonationRecorder{}
+		authService.SetImpersonationRecorder(recorder)
+		app := newApp(authService)
+
+		resp, body := send(app, "GET", "/", signSessionToken(t, authService, "user-1", "session-1"))
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "user-1/", body)
+		assert.Empty(t, recorder.recorded)
+
+		resp, _ = send(app, "PUT", "/password", signSessionToken(t, authService, "user-1", "session-1"))
+		assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("credential changes are refused", func(t *testing.T) {
+		authService := NewAuthService()
+		authService.SetImpersonationRecorder(&fakeImpersonationRecorder{})
+		app := newApp(authService)
+
+		resp, _ := send(app, "PUT", "/password", signImpersonationToken(t, authService, "user-1", "admin-1"))
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("requests that cannot be recorded are refused", func(t *testing.T) {
+		authService := NewAuthService()
+		authService.SetImpersonationRecorder(&fakeImpersonationRecorder{err: errors.New("database unavailable")})
+		app := newApp(authService)
+
+		resp, _ := send(app, "GET", "/", signImpersonationToken(t, authService, "user-1", "admin-1"))
+		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+
+		// Without a recorder, impersonation tokens are not accepted at all
+		resp, _ = send(newApp(NewAuthService()), "GET", "/", signImpersonationToken(t, authService, "user-1", "admin-1"))
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("signing the admin out ends the impersonation", func(t *testing.T) {
+		store := NewMemoryRevocationStore()
+		authService := NewAuthService()
+		authService.SetRevocationStore(store)
+		token := signImpersonationToken(t, authService, "user-1", "admin-1")
+
+		require.NoError(t, store.SetTokensValidAfter("admin-1", time.Now().Add(time.Second)))
+
+		_, err := authService.ValidateToken(token)
+		assert.True(t, errors.Is(err, ErrTokenRevoked))
+	})
+}
+
+// fakeImpersonationRecorder records the impersonated requests it was told about
+type fakeImpersonationRecorder struct {
+	recorded []string
+	err      error
+}
+
+func (f *fakeImpersonationRecorder) RecordImpersonatedRequest(impersonatorID, userID, method, path, ipAddress, requestID string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.recorded = append(f.recorded, impersonatorID+" "+userID+" "+method+" "+path)
+	return nil
+}
+
+// signImpersonationToken issues an access token with which actorID acts as userID
+func signImpersonationToken(t *testing.T, authService *AuthService, userID, actorID string) string {
+	t.Helper()
+
+	now := time.Now()
+	token, err := authService.Keys().Sign(&JWTClaims{
+		UserID: userID,
+		Role:   "resident",
+		Actor:  &ActorClaims{Subject: actorID},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "municollect",
+			Subject:   userID,
+			ID:        uuid.NewString(),
+		},
+	})
+	require.NoError(t, err)
+	return token
+}
@@ -26,7 +26,7 @@ var RoleHierarchy = map[Role]int{
 func RequireRole(requiredRole Role) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get user role from context (set by JWT middleware)
-		userRole, _, _, ok := GetUserFromContext(c)
+		_, _, userRole, _, ok := GetUserFromContext(c)
 		if !ok {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Authentication required",
@@ -52,7 +52,7 @@ func RequireRole(requiredRole Role) fiber.Handler {
 func RequireRoles(allowedRoles ...Role) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get user role from context (set by JWT middleware)
-		_, _, userRoleStr, ok := GetUserFromContext(c)
+		_, _, userRoleStr, _, ok := GetUserFromContext(c)
 		if !ok {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Authentication required",
@@ -92,7 +92,7 @@ func RequireMunicipalStaffOrAdmin() fiber.Handler {
 func RequireOwnershipOrAdmin(getUserIDFromParams func(*fiber.Ctx) string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get user from context
-		currentUserID, _, userRoleStr, ok := GetUserFromContext(c)
+		currentUserID, _, userRoleStr, _, ok := GetUserFromContext(c)
 		if !ok {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Authentication required",
@@ -151,7 +151,7 @@ func GetUserIDFromQuery(paramName string) func(*fiber.Ctx) string {
 // GetCurrentUserID returns a function that gets the current user's ID from context
 func GetCurrentUserID() func(*fiber.Ctx) string {
 	return func(c *fiber.Ctx) string {
-		userID, _, _, _ := GetUserFromContext(c)
+		userID, _, _, _, _ := GetUserFromContext(c)
 		return userID
 	}
 }
\ No newline at end of file
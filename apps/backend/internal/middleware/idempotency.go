@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// IdempotencyKeyHeader is the header a client sets to make a request safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader is set to "true" on responses replayed for a retried request
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// DefaultIdempotencyKeyTTL is how long a key is remembered when IDEMPOTENCY_KEY_TTL is not set
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength is the longest idempotency key accepted
+const maxIdempotencyKeyLength = 255
+
+// idempotentSideEffectLocal marks a request whose handler has made changes that a retry must not repeat
+const idempotentSideEffectLocal = "idempotent_side_effect"
+
+// Idempotency scopes. A key is unique per user and scope.
+const (
+	IdempotencyScopePaymentInitiate = "payments.initiate"
+	IdempotencyScopePaymentStatus   = "payments.status"
+	IdempotencyScopePaymentRefund   = "payments.refund"
+)
+
+// Idempotency errors
+var (
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key was used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// IdempotentResponse is the stored response of a request made with an idempotency key
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyStore remembers idempotency keys with the fingerprint of the request that
+// first used them and, once it has completed, its response
+type IdempotencyStore interface {
+	// Begin claims the key for a request with the given fingerprint until expiresAt. It
+	// returns nil when the caller now holds the key, the stored response when a request with
+	// the same fingerprint has already completed, ErrIdempotencyKeyMismatch when the key was
+	// used for a different request and ErrIdempotencyKeyInProgress while the first request
+	// has not completed. Expired keys are claimed again.
+	Begin(userID, scope, key, fingerprint string, expiresAt time.Time) (*IdempotentResponse, error)
+	// Complete stores the response of the request holding the key
+	Complete(userID, scope, key string, response *IdempotentResponse) error
+	// Release forgets the key without a response, so the request can be retried
+	Release(userID, scope, key string) error
+}
+
+// IdempotencyKeyTTLFromEnv returns the key lifetime from IDEMPOTENCY_KEY_TTL (a Go duration
+// such as "24h"), or DefaultIdempotencyKeyTTL
+func IdempotencyKeyTTLFromEnv() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return DefaultIdempotencyKeyTTL
+}
+
+// MarkIdempotentSideEffect records that the request has made changes, such as a stored
+// payment or a provider charge, that a retry must not repeat. Its response is then stored
+// even if it is a server error.
+func MarkIdempotentSideEffect(c *fiber.Ctx) {
+	c.Locals(idempotentSideEffectLocal, true)
+}
+
+// hasIdempotentSideEffect reports whether MarkIdempotentSideEffect was called for the request
+func hasIdempotentSideEffect(c *fiber.Ctx) bool {
+	marked, _ := c.Locals(idempotentSideEffectLocal).(bool)
+	return marked
+}
+
+// Idempotency creates middleware that makes the requests of an authenticated user that
+// carry an Idempotency-Key header safe to retry. The first request with a key runs and its
+// response is stored; a retry with the same key, method, path and body gets that response
+// again, and a different request with the same key gets 409. Server errors are not stored,
+// so the request can be retried with the same key, unless the handler marked a side effect
+// with MarkIdempotentSideEffect. Requests without the header pass through.
+func Idempotency(store IdempotencyStore, scope string, ttl time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":     "Idempotency key must be at most 255 characters",
+				"code":      fiber.StatusBadRequest,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		userID, _, _, _, ok := GetUserFromContext(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":     "Authentication required",
+				"code":      fiber.StatusUnauthorized,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		stored, err := store.Begin(userID, scope, key, requestFingerprint(c), time.Now().Add(ttl))
+		switch {
+		case errors.Is(err, ErrIdempotencyKeyMismatch):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":     "Idempotency key was already used for a different request",
+				"code":      fiber.StatusConflict,
+				"timestamp": time.Now().Unix(),
+			})
+		case errors.Is(err, ErrIdempotencyKeyInProgress):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":     "A request with this idempotency key is still in progress",
+				"code":      fiber.StatusConflict,
+				"timestamp": time.Now().Unix(),
+			})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":     "Failed to check idempotency key",
+				"code":      fiber.StatusInternalServerError,
+				"timestamp": time.Now().Unix(),
+			})
+		case stored != nil:
+			c.Set(IdempotentReplayHeader, "true")
+			if stored.ContentType != "" {
+				c.Set(fiber.HeaderContentType, stored.ContentType)
+			}
+			return c.Status(stored.Status).Send(stored.Body)
+		}
+
+		// A request that failed after making changes keeps its key, so it is not repeated
+		if err := c.Next(); err != nil {
+			if !hasIdempotentSideEffect(c) {
+				if releaseErr := store.Release(userID, scope, key); releaseErr != nil {
+					log.Printf("Failed to release idempotency key: %v", releaseErr)
+				}
+			}
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError && !hasIdempotentSideEffect(c) {
+			if err := store.Release(userID, scope, key); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+			return nil
+		}
+
+		// A key whose response cannot be stored stays claimed until it expires, so a retry
+		// gets 409 rather than repeating the request
+		response := &IdempotentResponse{
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+		if err := store.Complete(userID, scope, key, response); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+
+		return nil
+	}
+}
+
+// requestFingerprint returns a SHA-256 hash of the request's method, path and body
+func requestFingerprint(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(c.Path()))
+	hash.Write([]byte{0})
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// memoryIdempotencyEntry is a key held by MemoryIdempotencyStore
+type memoryIdempotencyEntry struct {
+	fingerprint string
+	expiresAt   time.Time
+	response    *IdempotentResponse
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore.
+// It is suitable for a single backend instance and for tests.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryIdempotencyEntry
+}
+
+// NewMemoryIdempotencyStore creates a new in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]*memoryIdempotencyEntry),
+	}
+}
+
+// Begin claims the key for a request with the given fingerprint
+func (s *MemoryIdempotencyStore) Begin(userID, scope, key, fingerprint string, expiresAt time.Time) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop keys that have expired
+	now := time.Now()
+	for id, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, id)
+		}
+	}
+
+	id := memoryIdempotencyID(userID, scope, key)
+	entry, ok := s.entries[id]
+	if !ok {
+		s.entries[id] = &memoryIdempotencyEntry{fingerprint: fingerprint, expiresAt: expiresAt}
+		return nil, nil
+	}
+	if entry.fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyMismatch
+	}
+	if entry.response == nil {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	copied := *entry.response
+	return &copied, nil
+}
+
+// Complete stores the response of the request holding the key
+func (s *MemoryIdempotencyStore) Complete(userID, scope, key string, response *IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[memoryIdempotencyID(userID, scope, key)]; ok {
+		copied := *response
+		entry.response = &copied
+	}
+	return nil
+}
+
+// Release forgets the key without a response
+func (s *MemoryIdempotencyStore) Release(userID, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, memoryIdempotencyID(userID, scope, key))
+	return nil
+}
+
+// memoryIdempotencyID returns the map key of a user's idempotency key in a scope
+func memoryIdempotencyID(userID, scope, key string) string {
+	return userID + "\x00" + scope + "\x00" + key
+}
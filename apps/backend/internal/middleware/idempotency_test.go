@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIdempotencyTestApp(store IdempotencyStore, created *int) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if user := c.Get("X-User"); user != "" {
+			c.Locals("user_id", user)
+			c.Locals("user_email", user+"@example.com")
+			c.Locals("user_role", string(RoleResident))
+		}
+		return c.Next()
+	})
+	app.Post("/payments/initiate", Idempotency(store, IdempotencyScopePaymentInitiate, time.Hour), func(c *fiber.Ctx) error {
+		if strings.Contains(string(c.Body()), "broken") {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "database unavailable"})
+		}
+		*created++
+		if strings.Contains(string(c.Body()), "unreachable") {
+			MarkIdempotentSideEffect(c)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "provider unavailable", "id": "payment-" + strconv.Itoa(*created)})
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"id": "payment-" + strconv.Itoa(*created)})
+	})
+	return app
+}
+
+func sendIdempotent(t *testing.T, app *fiber.App, user, key, body string) (int, string, string) {
+	req := httptest.NewRequest("POST", "/payments/initiate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data), resp.Header.Get(IdempotentReplayHeader)
+}
+
+func TestIdempotency_ReplaysRetries(t *testing.T) {
+	created := 0
+	app := newIdempotencyTestApp(NewMemoryIdempotencyStore(), &created)
+	body := `{"amount": 100.50}`
+
+	status, first, replayed := sendIdempotent(t, app, "user-1", "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Empty(t, replayed)
+
+	// A retry gets the stored response without creating another payment
+	status, retry, replayed := sendIdempotent(t, app, "user-1", "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, first, retry)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, 1, created)
+
+	// The same key with a different body is a conflict
+	status, _, _ = sendIdempotent(t, app, "user-1", "key-1", `{"amount": 200}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, 1, created)
+
+	// Keys are per user, and requests without a key are not deduplicated
+	status, _, _ = sendIdempotent(t, app, "user-2", "key-1", body)
+	assert.Equal(t, fiber.StatusCreated, status)
+	sendIdempotent(t, app, "user-1", "", body)
+	sendIdempotent(t, app, "user-1", "", body)
+	assert.Equal(t, 4, created)
+}
+
+func TestIdempotency_ServerErrorsCanBeRetried(t *testing.T) {
+	created := 0
+	app := newIdempotencyTestApp(NewMemoryIdempotencyStore(), &created)
+
+	status, _, _ := sendIdempotent(t, app, "user-1", "key-1", `{"note": "broken"}`)
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+
+	status, _, replayed := sendIdempotent(t, app, "user-1", "key-1", `{"note": "broken"}`)
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+	assert.Empty(t, replayed)
+}
+
+func TestIdempotency_ServerErrorsAfterSideEffectsAreStored(t *testing.T) {
+	created := 0
+	app := newIdempotencyTestApp(NewMemoryIdempotencyStore(), &created)
+	body := `{"note": "unreachable"}`
+
+	status, first, _ := sendIdempotent(t, app, "user-1", "key-1", body)
+	assert.Equal(t, fiber.StatusBadGateway, status)
+
+	// The payment was stored, so a retry gets the same response instead of a second payment
+	status, second, replayed := sendIdempotent(t, app, "user-1", "key-1", body)
+	assert.Equal(t, fiber.StatusBadGateway, status)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, created)
+}
+
+func TestIdempotency_InProgressAndExpiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+
+	_, err := store.Begin("user-1", IdempotencyScopePaymentStatus, "key-1", "fingerprint", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = store.Begin("user-1", IdempotencyScopePaymentStatus, "key-1", "fingerprint", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrIdempotencyKeyInProgress)
+
+	// The same key in another scope is independent
+	response, err := store.Begin("user-1", IdempotencyScopePaymentInitiate, "key-1", "other", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	// Expired keys are claimed again
+	_, err = store.Begin("user-1", IdempotencyScopePaymentStatus, "key-2", "fingerprint", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	response, err = store.Begin("user-1", IdempotencyScopePaymentStatus, "key-2", "different", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Nil(t, response)
+}
+
+func TestIdempotency_RequiresAuthentication(t *testing.T) {
+	created := 0
+	app := newIdempotencyTestApp(NewMemoryIdempotencyStore(), &created)
+
+	status, _, _ := sendIdempotent(t, app, "", "key-1", `{}`)
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+	assert.Equal(t, 0, created)
+}
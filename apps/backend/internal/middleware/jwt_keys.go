@@ -0,0 +1,424 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultJWTSecret is the development fallback used when neither JWT_KEYS_DIR nor JWT_SECRET is set
+const DefaultJWTSecret = "your-secret-key"
+
+// JWKSMaxAge is how long verifiers may cache the published JWKS. A new signing key must be
+// published for at least this long before it signs anything.
+const JWKSMaxAge = 5 * time.Minute
+
+// kidTimeLayout is the time prefix of the kids generated by GenerateJWTKey
+const kidTimeLayout = "20060102T150405Z"
+
+// Key file suffixes in a JWT key directory. Signing keys hold a private key; retired keys
+// keep only the public key so tokens they signed can still be verified.
+const (
+	PrivateKeySuffix = ".pem"
+	PublicKeySuffix  = ".pub.pem"
+)
+
+// JWTKey is one key in a key set
+type JWTKey struct {
+	KID       string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer
+	PublicKey crypto.PublicKey
+}
+
+// CanSign reports whether the key holds a private key
+func (k *JWTKey) CanSign() bool {
+	return k.Private != nil
+}
+
+// KeySet holds the keys used to sign and verify JWTs. With asymmetric keys, tokens are
+// signed by the newest private key that has become active and verified by any key in the
+// set, selected by the token's kid header. Without them it falls back to HS256 with a
+// shared secret, which is only meant for development.
+type KeySet struct {
+	signers    []*JWTKey
+	keys       map[string]*JWTKey
+	hmacSecret []byte
+	now        func() time.Time
+}
+
+// NewHMACKeySet creates a key set that signs and verifies with HS256 and a shared secret
+func NewHMACKeySet(secret []byte) *KeySet {
+	return &KeySet{
+		keys:       make(map[string]*JWTKey),
+		hmacSecret: secret,
+		now:        time.Now,
+	}
+}
+
+// NewKeySet creates a key set from asymmetric keys. The signing key is the private key
+// with the greatest kid whose activation time has passed (see KeyActivation).
+func NewKeySet(keys []*JWTKey) (*KeySet, error) {
+	set := &KeySet{
+		keys: make(map[string]*JWTKey),
+		now:  time.Now,
+	}
+
+	for _, key := range keys {
+		if _, exists := set.keys[key.KID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.KID)
+		}
+		set.keys[key.KID] = key
+		if key.CanSign() {
+			set.signers = append(set.signers, key)
+		}
+	}
+
+	if len(set.signers) == 0 {
+		return nil, errors.New("key set has no signing key")
+	}
+	sort.Slice(set.signers, func(i, j int) bool { return set.signers[i].KID < set.signers[j].KID })
+
+	return set, nil
+}
+
+// KeyActivation returns when a private key starts signing: the time at the start of a kid
+// generated by GenerateJWTKey, or the zero time (immediately) for other kids
+func KeyActivation(kid string) time.Time {
+	prefix, _, _ := strings.Cut(kid, "-")
+	activatesAt, err := time.Parse(kidTimeLayout, prefix)
+	if err != nil {
+		return time.Time{}
+	}
+	return activatesAt
+}
+
+// LoadKeySet loads every key in dir. Files named <kid>.pem hold private keys and
+// <kid>.pub.pem hold verification-only public keys, in PKCS#8, PKCS#1 or PKIX PEM form.
+func LoadKeySet(dir string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key directory: %w", err)
+	}
+
+	var keys []*JWTKey
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		var kid string
+		var public bool
+		switch {
+		case strings.HasSuffix(name, PublicKeySuffix):
+			kid, public = strings.TrimSuffix(name, PublicKeySuffix), true
+		case strings.HasSuffix(name, PrivateKeySuffix):
+			kid = strings.TrimSuffix(name, PrivateKeySuffix)
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT key %s: %w", name, err)
+		}
+
+		var key *JWTKey
+		if public {
+			key, err = ParsePublicKeyPEM(kid, data)
+		} else {
+			key, err = ParsePrivateKeyPEM(kid, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWT key %s: %w", name, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys)
+}
+
+// ParsePrivateKeyPEM parses an RSA or Ed25519 private key
+func ParsePrivateKeyPEM(kid string, data []byte) (*JWTKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &JWTKey{KID: kid, Method: jwt.SigningMethodRS256, Private: k, PublicKey: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &JWTKey{KID: kid, Method: jwt.SigningMethodEdDSA, Private: k, PublicKey: k.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+}
+
+// ParsePublicKeyPEM parses an RSA or Ed25519 public key
+func ParsePublicKeyPEM(kid string, data []byte) (*JWTKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PublicKey:
+		return &JWTKey{KID: kid, Method: jwt.SigningMethodRS256, PublicKey: k}, nil
+	case ed25519.PublicKey:
+		return &JWTKey{KID: kid, Method: jwt.SigningMethodEdDSA, PublicKey: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", parsed)
+	}
+}
+
+// GenerateJWTKey creates a new private key for the given algorithm ("EdDSA" or "RS256").
+// The kid is derived from the time the key starts signing, so that newer keys sort after
+// older ones and a key generated ahead of time is only published until then.
+func GenerateJWTKey(alg string, activatesAt time.Time) (*JWTKey, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+	kid := fmt.Sprintf("%s-%s", activatesAt.UTC().Format(kidTimeLayout), hex.EncodeToString(suffix))
+
+	switch alg {
+	case jwt.SigningMethodEdDSA.Alg():
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		return &JWTKey{KID: kid, Method: jwt.SigningMethodEdDSA, Private: private, PublicKey: private.Public()}, nil
+	case jwt.SigningMethodRS256.Alg():
+		private, err := rsa.GenerateKey(rand.Reader, 3072)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		return &JWTKey{KID: kid, Method: jwt.SigningMethodRS256, Private: private, PublicKey: &private.PublicKey}, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q (use EdDSA or RS256)", alg)
+	}
+}
+
+// EncodePrivateKeyPEM encodes the key's private part as a PKCS#8 PEM block
+func EncodePrivateKeyPEM(key *JWTKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// EncodePublicKeyPEM encodes the key's public part as a PKIX PEM block
+func EncodePublicKeyPEM(key *JWTKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// Keys returns every key in the set, ordered by kid
+func (k *KeySet) Keys() []*JWTKey {
+	keys := make([]*JWTKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].KID < keys[j].KID })
+
+	return keys
+}
+
+// IsAsymmetric reports whether the key set signs with asymmetric keys
+func (k *KeySet) IsAsymmetric() bool {
+	return len(k.signers) > 0
+}
+
+// activeKey returns the newest private key whose activation time has passed. Until the
+// first one activates, the oldest private key signs.
+func (k *KeySet) activeKey() *JWTKey {
+	if len(k.signers) == 0 {
+		return nil
+	}
+
+	now := k.now()
+	for i := len(k.signers) - 1; i >= 0; i-- {
+		if !KeyActivation(k.signers[i].KID).After(now) {
+			return k.signers[i]
+		}
+	}
+	return k.signers[0]
+}
+
+// UsesDefaultSecret reports whether the key set relies on the development secret
+func (k *KeySet) UsesDefaultSecret() bool {
+	return !k.IsAsymmetric() && string(k.hmacSecret) == DefaultJWTSecret
+}
+
+// ActiveKID returns the kid of the signing key, or "" for HS256
+func (k *KeySet) ActiveKID() string {
+	active := k.activeKey()
+	if active == nil {
+		return ""
+	}
+	return active.KID
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if !k.IsAsymmetric() {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.hmacSecret)
+	}
+
+	active := k.activeKey()
+	token := jwt.NewWithClaims(active.Method, claims)
+	token.Header["kid"] = active.KID
+	return token.SignedString(active.Private)
+}
+
+// Keyfunc selects the verification key for a token, for use with jwt.ParseWithClaims
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if !k.IsAsymmetric() {
+		// Validate the signing method
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return k.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+	}
+
+	return key.PublicKey, nil
+}
+
+// ParserOptions returns the parser options matching the key set's algorithms
+func (k *KeySet) ParserOptions() []jwt.ParserOption {
+	if !k.IsAsymmetric() {
+		return []jwt.ParserOption{jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()})}
+	}
+
+	return []jwt.ParserOption{jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()})}
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public verification keys, ordered by kid, including keys that have not
+// started signing yet. It is empty for HS256.
+func (k *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+
+	for _, key := range k.Keys() {
+		jwk := JWK{KID: key.KID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KTY = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KTY = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+var (
+	defaultKeySet     *KeySet
+	defaultKeySetErr  error
+	defaultKeySetOnce sync.Once
+)
+
+// KeySetFromEnv loads the key set configured by JWT_KEYS_DIR, falling back to HS256 with
+// JWT_SECRET (or the development default) when no key directory is set. The fallback
+// publishes no keys, so it is refused in production.
+func KeySetFromEnv() (*KeySet, error) {
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		return LoadKeySet(dir)
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = DefaultJWTSecret // Default for development - should be set via env var
+	}
+
+	return NewHMACKeySet([]byte(secret)), nil
+}
+
+// DefaultKeySet returns the process-wide key set, loading it from the environment once
+func DefaultKeySet() (*KeySet, error) {
+	defaultKeySetOnce.Do(func() {
+		defaultKeySet, defaultKeySetErr = KeySetFromEnv()
+	})
+
+	return defaultKeySet, defaultKeySetErr
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, dir string, key *JWTKey, public bool) {
+	t.Helper()
+
+	if public {
+		data, err := EncodePublicKeyPEM(key)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, key.KID+PublicKeySuffix), data, 0o644))
+		return
+	}
+
+	data, err := EncodePrivateKeyPEM(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, key.KID+PrivateKeySuffix), data, 0o600))
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	for _, alg := range []string{"EdDSA", "RS256"} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := GenerateJWTKey(alg, time.Now())
+			require.NoError(t, err)
+			keys, err := NewKeySet([]*JWTKey{key})
+			require.NoError(t, err)
+
+			authService := NewAuthServiceWithKeys(keys)
+			accessToken, _, _, err := authService.GenerateTokens("user-1", "user1@example.com", "admin")
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(accessToken, &JWTClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, key.KID, parsed.Header["kid"])
+			assert.Equal(t, alg, parsed.Header["alg"])
+
+			claims, err := authService.ValidateToken(accessToken)
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims.UserID)
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	dir := t.TempDir()
+
+	oldKey, err := GenerateJWTKey("EdDSA", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	writeKey(t, dir, oldKey, false)
+
+	oldSet, err := LoadKeySet(dir)
+	require.NoError(t, err)
+	oldToken, _, _, err := NewAuthServiceWithKeys(oldSet).GenerateTokens("user-1", "user1@example.com", "resident")
+	require.NoError(t, err)
+
+	// Rotate: a newer signing key, and the old key kept as verification-only
+	newKey, err := GenerateJWTKey("RS256", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	writeKey(t, dir, newKey, false)
+	require.NoError(t, os.Remove(filepath.Join(dir, oldKey.KID+PrivateKeySuffix)))
+	writeKey(t, dir, oldKey, true)
+
+	rotated, err := LoadKeySet(dir)
+	require.NoError(t, err)
+	assert.Equal(t, newKey.KID, rotated.ActiveKID())
+
+	authService := NewAuthServiceWithKeys(rotated)
+
+	// Tokens signed before the rotation still verify
+	_, err = authService.ValidateToken(oldToken)
+	assert.NoError(t, err)
+
+	newToken, _, _, err := authService.GenerateTokens("user-2", "user2@example.com", "resident")
+	require.NoError(t, err)
+	_, err = authService.ValidateToken(newToken)
+	assert.NoError(t, err)
+
+	jwks := rotated.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, oldKey.KID, jwks.Keys[0].KID)
+	assert.Equal(t, "OKP", jwks.Keys[0].KTY)
+	assert.Equal(t, "Ed25519", jwks.Keys[0].Crv)
+	assert.NotEmpty(t, jwks.Keys[0].X)
+	assert.Equal(t, newKey.KID, jwks.Keys[1].KID)
+	assert.Equal(t, "RSA", jwks.Keys[1].KTY)
+	assert.Equal(t, "AQAB", jwks.Keys[1].E)
+
+	// Once the old key is removed its tokens stop verifying
+	require.NoError(t, os.Remove(filepath.Join(dir, oldKey.KID+PublicKeySuffix)))
+	pruned, err := LoadKeySet(dir)
+	require.NoError(t, err)
+	_, err = NewAuthServiceWithKeys(pruned).ValidateToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestKeySet_PendingKeys(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	current, err := GenerateJWTKey("EdDSA", now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	next, err := GenerateJWTKey("EdDSA", now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), KeyActivation(next.KID))
+
+	keys, err := NewKeySet([]*JWTKey{current, next})
+	require.NoError(t, err)
+	keys.now = func() time.Time { return now }
+
+	// The next key is published before it signs anything
+	assert.Equal(t, current.KID, keys.ActiveKID())
+	require.Len(t, keys.JWKS().Keys, 2)
+	assert.Equal(t, next.KID, keys.JWKS().Keys[1].KID)
+
+	token, _, _, err := NewAuthServiceWithKeys(keys).GenerateTokens("user-1", "user1@example.com", "resident")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &JWTClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, current.KID, parsed.Header["kid"])
+
+	keys.now = func() time.Time { return now.Add(time.Hour) }
+	assert.Equal(t, next.KID, keys.ActiveKID())
+
+	// Keys whose kid carries no time sign straight away; a key set with only pending keys
+	// signs with the oldest
+	assert.True(t, KeyActivation("legacy-key").IsZero())
+	pending, err := NewKeySet([]*JWTKey{next})
+	require.NoError(t, err)
+	pending.now = func() time.Time { return now }
+	assert.Equal(t, next.KID, pending.ActiveKID())
+}
+
+func TestKeySet_RejectsForeignTokens(t *testing.T) {
+	key, err := GenerateJWTKey("EdDSA", time.Now())
+	require.NoError(t, err)
+	keys, err := NewKeySet([]*JWTKey{key})
+	require.NoError(t, err)
+	authService := NewAuthServiceWithKeys(keys)
+
+	// An HS256 token must not be accepted once asymmetric keys are configured
+	hmacToken, _, _, err := NewAuthServiceWithKeys(NewHMACKeySet([]byte(DefaultJWTSecret))).GenerateTokens("user-1", "user1@example.com", "admin")
+	require.NoError(t, err)
+	_, err = authService.ValidateToken(hmacToken)
+	assert.Error(t, err)
+
+	// Neither is a token signed by a key outside the set
+	otherKey, err := GenerateJWTKey("EdDSA", time.Now())
+	require.NoError(t, err)
+	otherKeys, err := NewKeySet([]*JWTKey{otherKey})
+	require.NoError(t, err)
+	otherToken, _, _, err := NewAuthServiceWithKeys(otherKeys).GenerateTokens("user-1", "user1@example.com", "admin")
+	require.NoError(t, err)
+	_, err = authService.ValidateToken(otherToken)
+	assert.Error(t, err)
+}
+
+func TestKeySet_DefaultSecret(t *testing.T) {
+	assert.True(t, NewHMACKeySet([]byte(DefaultJWTSecret)).UsesDefaultSecret())
+	assert.False(t, NewHMACKeySet([]byte("a-real-secret")).UsesDefaultSecret())
+	assert.Empty(t, NewHMACKeySet([]byte("a-real-secret")).JWKS().Keys)
+
+	_, err := NewKeySet(nil)
+	assert.Error(t, err)
+}
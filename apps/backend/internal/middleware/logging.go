@@ -39,6 +39,12 @@ func NewRequestIDMiddleware() fiber.Handler {
 	})
 }
 
+// GetRequestIDFromContext returns the ID NewRequestIDMiddleware assigned to the request
+func GetRequestIDFromContext(c *fiber.Ctx) string {
+	requestID, _ := c.Locals("requestid").(string)
+	return requestID
+}
+
 // NewLoggingMiddleware creates a new logging middleware
 func NewLoggingMiddleware(config ...LoggingConfig) fiber.Handler {
 	var cfg LoggingConfig
@@ -85,10 +91,13 @@ func StructuredLoggingMiddleware() fiber.Handler {
 		}
 		
 		// Add user information if available
-		if userID, email, role, ok := GetUserFromContext(c); ok {
+		if userID, email, role, impersonatorID, ok := GetUserFromContext(c); ok {
 			logEntry["user_id"] = userID
 			logEntry["user_email"] = email
 			logEntry["user_role"] = role
+			if impersonatorID != "" {
+				logEntry["impersonator_id"] = impersonatorID
+			}
 		}
 		
 		// Add error information if present
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrMunicipalityNotResolved is returned by a MunicipalityResolver when the resource
+// named in the request does not exist, so no municipality can be determined
+var ErrMunicipalityNotResolved = errors.New("municipality could not be resolved")
+
+// MunicipalityResolver determines which municipality a request acts on
+type MunicipalityResolver func(c *fiber.Ctx) (string, error)
+
+// MunicipalityFromParam resolves the municipality from a path parameter such as :municipalityId
+func MunicipalityFromParam(paramName string) MunicipalityResolver {
+	return func(c *fiber.Ctx) (string, error) {
+		municipalityID := c.Params(paramName)
+		if municipalityID == "" {
+			return "", ErrMunicipalityNotResolved
+		}
+		return municipalityID, nil
+	}
+}
+
+// GetMunicipalityFromContext returns the municipality resolved by RequireMunicipalityPermission
+func GetMunicipalityFromContext(c *fiber.Ctx) (string, bool) {
+	municipalityID, ok := c.Locals("municipality_id").(string)
+	return municipalityID, ok
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Authorizer decides whether a user holds a permission, either everywhere through
+// their global role or within one municipality through their membership there
+type Authorizer interface {
+	HasPermission(userID, role, permission string) (bool, error)
+	HasMunicipalityPermission(userID, role, municipalityID, permission string) (bool, error)
+}
+
+// RequirePermission creates middleware that requires a platform-wide permission
+func RequirePermission(authorizer Authorizer, permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _, userRole, _, ok := GetUserFromContext(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":     "Authentication required",
+				"code":      fiber.StatusUnauthorized,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		allowed, err := authorizer.HasPermission(userID, userRole, permission)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":     "Failed to check permissions",
+				"code":      fiber.StatusInternalServerError,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":     "Insufficient permissions",
+				"code":      fiber.StatusForbidden,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireMunicipalityPermission creates middleware that requires a permission in the
+// municipality the request acts on, as determined by resolve. Staff of one municipality
+// get 403 for resources of another.
+func RequireMunicipalityPermission(authorizer Authorizer, resolve MunicipalityResolver, permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _, userRole, _, ok := GetUserFromContext(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":     "Authentication required",
+				"code":      fiber.StatusUnauthorized,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		municipalityID, err := resolve(c)
+		if err != nil {
+			if errors.Is(err, ErrMunicipalityNotResolved) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error":     "Resource not found",
+					"code":      fiber.StatusNotFound,
+					"timestamp": time.Now().Unix(),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":     "Failed to check permissions",
+				"code":      fiber.StatusInternalServerError,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		allowed, err := authorizer.HasMunicipalityPermission(userID, userRole, municipalityID, permission)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":     "Failed to check permissions",
+				"code":      fiber.StatusInternalServerError,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":     "Insufficient permissions for this municipality",
+				"code":      fiber.StatusForbidden,
+				"timestamp": time.Now().Unix(),
+			})
+		}
+
+		// Store the municipality the request was authorized for
+		c.Locals("municipality_id", municipalityID)
+
+		return c.Next()
+	}
+}
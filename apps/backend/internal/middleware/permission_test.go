@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuthorizer grants global admins every permission and other users the permissions
+// listed under "userID/municipalityID"
+type fakeAuthorizer map[string][]string
+
+func (f fakeAuthorizer) HasPermission(userID, role, permission string) (bool, error) {
+	return role == string(RoleAdmin), nil
+}
+
+func (f fakeAuthorizer) HasMunicipalityPermission(userID, role, municipalityID, permission string) (bool, error) {
+	if municipalityID == "broken" {
+		return false, errors.New("database unavailable")
+	}
+	if role == string(RoleAdmin) {
+		return true, nil
+	}
+	for _, p := range f[userID+"/"+municipalityID] {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestRequireMunicipalityPermission(t *testing.T) {
+	authorizer := fakeAuthorizer{
+		"finance-a/muni-a":   {"payments.read", "payments.update_status"},
+		"general-a/muni-a":   {"payments.read"},
+		"collector-a/muni-a": {"routes.read"},
+		"resident-a/muni-a":  {},
+	}
+
+	// Payments p-a and p-b belong to municipalities A and B
+	paymentMunicipalities := map[string]string{"p-a": "muni-a", "p-b": "muni-b"}
+	paymentResolver := func(c *fiber.Ctx) (string, error) {
+		municipalityID, ok := paymentMunicipalities[c.Params("id")]
+		if !ok {
+			return "", ErrMunicipalityNotResolved
+		}
+		return municipalityID, nil
+	}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", c.Get("X-User"))
+		c.Locals("user_email", c.Get("X-User")+"@example.com")
+		c.Locals("user_role", c.Get("X-Role"))
+		return c.Next()
+	})
+	app.Get("/municipalities/:municipalityId/payments", RequireMunicipalityPermission(authorizer, MunicipalityFromParam("municipalityId"), "payments.read"), func(c *fiber.Ctx) error {
+		municipalityID, ok := GetMunicipalityFromContext(c)
+		require.True(t, ok)
+		return c.SendString(municipalityID)
+	})
+	app.Put("/payments/:id/status", RequireMunicipalityPermission(authorizer, paymentResolver, "payments.update_status"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   string
+		role   Role
+		status int
+	}{
+		{"finance staff of the municipality", "GET", "/municipalities/muni-a/payments", "finance-a", RoleMunicipalStaff, fiber.StatusOK},
+		{"general staff of the municipality", "GET", "/municipalities/muni-a/payments", "general-a", RoleMunicipalStaff, fiber.StatusOK},
+		{"staff of another municipality", "GET", "/municipalities/muni-b/payments", "finance-a", RoleMunicipalStaff, fiber.StatusForbidden},
+		{"collector without payment access", "GET", "/municipalities/muni-a/payments", "collector-a", RoleMunicipalStaff, fiber.StatusForbidden},
+		{"resident member", "GET", "/municipalities/muni-a/payments", "resident-a", RoleResident, fiber.StatusForbidden},
+		{"global staff role without membership", "GET", "/municipalities/muni-a/payments", "staff-b", RoleMunicipalStaff, fiber.StatusForbidden},
+		{"global admin", "GET", "/municipalities/muni-b/payments", "admin", RoleAdmin, fiber.StatusOK},
+		{"authorizer failure", "GET", "/municipalities/broken/payments", "finance-a", RoleMunicipalStaff, fiber.StatusInternalServerError},
+		{"payment of own municipality", "PUT", "/payments/p-a/status", "finance-a", RoleMunicipalStaff, fiber.StatusNoContent},
+		{"read-only staff updating a payment", "PUT", "/payments/p-a/status", "general-a", RoleMunicipalStaff, fiber.StatusForbidden},
+		{"payment of another municipality", "PUT", "/payments/p-b/status", "finance-a", RoleMunicipalStaff, fiber.StatusForbidden},
+		{"unknown payment", "PUT", "/payments/p-x/status", "finance-a", RoleMunicipalStaff, fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-User", tt.user)
+			req.Header.Set("X-Role", string(tt.role))
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestRequireMunicipalityPermission_Unauthenticated(t *testing.T) {
+	app := fiber.New()
+	app.Get("/municipalities/:municipalityId", RequireMunicipalityPermission(fakeAuthorizer{}, MunicipalityFromParam("municipalityId"), "payments.read"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/municipalities/muni-a", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestRequirePermission(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if user := c.Get("X-User"); user != "" {
+			c.Locals("user_id", user)
+			c.Locals("user_email", user+"@example.com")
+			c.Locals("user_role", c.Get("X-Role"))
+		}
+		return c.Next()
+	})
+	app.Post("/municipalities", RequirePermission(fakeAuthorizer{}, "municipalities.manage"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	tests := []struct {
+		name   string
+		user   string
+		role   Role
+		status int
+	}{
+		{"global admin", "admin", RoleAdmin, fiber.StatusCreated},
+		{"municipal staff", "staff", RoleMunicipalStaff, fiber.StatusForbidden},
+		{"resident", "resident", RoleResident, fiber.StatusForbidden},
+		{"unauthenticated", "", "", fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/municipalities", nil)
+			req.Header.Set("X-User", tt.user)
+			req.Header.Set("X-Role", string(tt.role))
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
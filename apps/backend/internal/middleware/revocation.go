@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps track of revoked access tokens (by jti or session) and of the
+// per-user "tokens valid after" timestamp checked by AuthService.ValidateToken
+type RevocationStore interface {
+	// RevokeToken revokes a single token until it would have expired anyway
+	RevokeToken(jti string, expiresAt time.Time) error
+	// IsTokenRevoked reports whether the token with the given jti has been revoked
+	IsTokenRevoked(jti string) (bool, error)
+	// RevokeSession revokes every token carrying the session ID (the "sid" claim)
+	RevokeSession(sessionID string, expiresAt time.Time) error
+	// IsSessionRevoked reports whether the session with the given ID has been revoked
+	IsSessionRevoked(sessionID string) (bool, error)
+	// SetTokensValidAfter invalidates every token issued to the user before validAfter
+	SetTokensValidAfter(userID string, validAfter time.Time) error
+	// GetTokensValidAfter returns the user's cut-off time, or the zero time if none is set
+	GetTokensValidAfter(userID string) (time.Time, error)
+}
+
+// MemoryRevocationStore is an in-process RevocationStore.
+// It is suitable for a single backend instance and for tests.
+type MemoryRevocationStore struct {
+	mu         sync.RWMutex
+	revoked    map[string]time.Time
+	sessions   map[string]time.Time
+	validAfter map[string]time.Time
+}
+
+// NewMemoryRevocationStore creates a new in-memory revocation store
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked:    make(map[string]time.Time),
+		sessions:   make(map[string]time.Time),
+		validAfter: make(map[string]time.Time),
+	}
+}
+
+// RevokeToken revokes a single token until it would have expired anyway
+func (s *MemoryRevocationStore) RevokeToken(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop entries for tokens that have expired on their own
+	now := time.Now()
+	for id, exp := range s.revoked {
+		if now.After(exp) {
+			delete(s.revoked, id)
+		}
+	}
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+// IsTokenRevoked reports whether the token with the given jti has been revoked
+func (s *MemoryRevocationStore) IsTokenRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, revoked := s.revoked[jti]
+	return revoked, nil
+}
+
+// RevokeSession revokes every token carrying the session ID until the last of them would have expired
+func (s *MemoryRevocationStore) RevokeSession(sessionID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.sessions {
+		if now.After(exp) {
+			delete(s.sessions, id)
+		}
+	}
+
+	s.sessions[sessionID] = expiresAt
+	return nil
+}
+
+// IsSessionRevoked reports whether the session with the given ID has been revoked
+func (s *MemoryRevocationStore) IsSessionRevoked(sessionID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, revoked := s.sessions[sessionID]
+	return revoked, nil
+}
+
+// SetTokensValidAfter invalidates every token issued to the user before validAfter
+func (s *MemoryRevocationStore) SetTokensValidAfter(userID string, validAfter time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.validAfter[userID] = validAfter
+	return nil
+}
+
+// GetTokensValidAfter returns the user's cut-off time, or the zero time if none is set
+func (s *MemoryRevocationStore) GetTokensValidAfter(userID string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.validAfter[userID], nil
+}
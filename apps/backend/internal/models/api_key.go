@@ -0,0 +1,110 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// APIScope is a permission that can be granted to an API key
+type APIScope string
+
+const (
+	// APIScopeResidentsRead allows checking whether a resident is registered with the municipality
+	APIScopeResidentsRead APIScope = "residents:read"
+	// APIScopePaymentsRead allows reading the status of the municipality's payments
+	APIScopePaymentsRead APIScope = "payments:read"
+	// APIScopePaymentsWrite allows reporting payment status changes, e.g. from a bank or kiosk
+	APIScopePaymentsWrite APIScope = "payments:write"
+)
+
+// APIScopes lists every scope that can be granted
+var APIScopes = []APIScope{
+	APIScopeResidentsRead,
+	APIScopePaymentsRead,
+	APIScopePaymentsWrite,
+}
+
+// IsValidAPIScope reports whether scope is a known API scope
+func IsValidAPIScope(scope string) bool {
+	for _, s := range APIScopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyScopes is the list of scopes granted to an API key, stored as JSON
+type APIKeyScopes []string
+
+// Value implements the driver.Valuer interface for GORM
+func (s APIKeyScopes) Value() (driver.Value, error) {
+	if s == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface for GORM
+func (s *APIKeyScopes) Scan(value interface{}) error {
+	if value == nil {
+		*s = APIKeyScopes{}
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, s)
+}
+
+// APIKey is a machine credential used by integration partners such as banks,
+// utilities and payment kiosks. Only a SHA-256 hash of the key is stored; the
+// prefix is kept in clear text so a key can be identified in logs and listings.
+type APIKey struct {
+	ID             string       `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name           string       `json:"name" gorm:"not null;size:100"`
+	Prefix         string       `json:"prefix" gorm:"not null;size:16;uniqueIndex:idx_api_keys_prefix"`
+	KeyHash        string       `json:"-" gorm:"column:key_hash;not null;size:64"`
+	Scopes         APIKeyScopes `json:"scopes" gorm:"type:jsonb;not null"`
+	MunicipalityID string       `json:"municipalityId" gorm:"column:municipality_id;not null;type:uuid;index:idx_api_keys_municipality_id"`
+	CreatedBy      *string      `json:"createdBy,omitempty" gorm:"column:created_by;type:uuid"`
+	LastUsedAt     *time.Time   `json:"lastUsedAt,omitempty" gorm:"column:last_used_at"`
+	LastUsedIP     *string      `json:"lastUsedIp,omitempty" gorm:"column:last_used_ip;size:45"`
+	ExpiresAt      *time.Time   `json:"expiresAt,omitempty" gorm:"column:expires_at"`
+	RevokedAt      *time.Time   `json:"revokedAt,omitempty" gorm:"column:revoked_at"`
+	CreatedAt      time.Time    `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time    `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+
+	// Relationships
+	Municipality Municipality `json:"municipality,omitempty" gorm:"foreignKey:MunicipalityID"`
+}
+
+// IsRevoked reports whether the key has been revoked
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// IsExpired reports whether the key has passed its expiry time
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// IsActive reports whether the key can still be used
+func (k *APIKey) IsActive() bool {
+	return !k.IsRevoked() && !k.IsExpired()
+}
+
+// TableName returns the table name for the APIKey model
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditAction names an action recorded in the audit log
+type AuditAction string
+
+// Admin user management actions
+const (
+	AuditActionUserRoleChanged         AuditAction = "user.role_changed"
+	AuditActionUserDisabled            AuditAction = "user.disabled"
+	AuditActionUserEnabled             AuditAction = "user.enabled"
+	AuditActionUserPasswordResetForced AuditAction = "user.password_reset_forced"
+	AuditActionUserSignedOut           AuditAction = "user.signed_out"
+	AuditActionUserUnlocked            AuditAction = "user.unlocked"
+	AuditActionUserSessionRevoked      AuditAction = "user.session_revoked"
+	AuditActionUserErased              AuditAction = "user.erased"
+	AuditActionUserNationalIDSet       AuditAction = "user.national_id_set"
+)
+
+// Staff invitation actions
+const (
+	AuditActionInvitationCreated  AuditAction = "invitation.created"
+	AuditActionInvitationResent   AuditAction = "invitation.resent"
+	AuditActionInvitationRevoked  AuditAction = "invitation.revoked"
+	AuditActionInvitationAccepted AuditAction = "invitation.accepted"
+)
+
+// Impersonation actions
+const (
+	AuditActionImpersonationStarted AuditAction = "impersonation.started"
+	AuditActionImpersonationRequest AuditAction = "impersonation.request"
+	AuditActionImpersonationEnded   AuditAction = "impersonation.ended"
+)
+
+// Sign-in actions
+const (
+	AuditActionAuthLogin       AuditAction = "auth.login"
+	AuditActionAuthLoginFailed AuditAction = "auth.login_failed"
+)
+
+// Municipality and municipal role actions
+const (
+	AuditActionMunicipalityCreated   AuditAction = "municipality.created"
+	AuditActionMunicipalityUpdated   AuditAction = "municipality.updated"
+	AuditActionMunicipalityDeleted   AuditAction = "municipality.deleted"
+	AuditActionMembershipRoleChanged AuditAction = "membership.role_changed"
+	AuditActionMunicipalRoleCreated  AuditAction = "municipal_role.created"
+	AuditActionMunicipalRoleUpdated  AuditAction = "municipal_role.updated"
+	AuditActionMunicipalRoleDeleted  AuditAction = "municipal_role.deleted"
+)
+
+// Payment actions
+const (
+	AuditActionPaymentStatusChanged AuditAction = "payment.status_changed"
+)
+
+// Audit log actions
+const (
+	AuditActionAuditLogExported AuditAction = "audit_log.exported"
+)
+
+// Data subject request actions
+const (
+	AuditActionDataRequestCreated   AuditAction = "data_request.created"
+	AuditActionDataRequestApproved  AuditAction = "data_request.approved"
+	AuditActionDataRequestRejected  AuditAction = "data_request.rejected"
+	AuditActionDataRequestCompleted AuditAction = "data_request.completed"
+	AuditActionDataRequestFailed    AuditAction = "data_request.failed"
+)
+
+// Household registration actions
+const (
+	AuditActionHouseholdCreated       AuditAction = "household.created"
+	AuditActionHouseholdMemberAdded   AuditAction = "household.member_added"
+	AuditActionHouseholdMemberRemoved AuditAction = "household.member_removed"
+	AuditActionResidentLookedUp       AuditAction = "resident.looked_up"
+)
+
+// Audited resource types
+const (
+	AuditResourceUser            = "user"
+	AuditResourceStaffInvitation = "staff_invitation"
+	AuditResourceMunicipality    = "municipality"
+	AuditResourceMembership      = "membership"
+	AuditResourceMunicipalRole   = "municipal_role"
+	AuditResourcePayment         = "payment"
+	AuditResourceAuditLog        = "audit_log"
+	AuditResourceDataRequest     = "data_request"
+	AuditResourceHousehold       = "household"
+)
+
+// AuditDetails holds action-specific details of an audit log entry
+type AuditDetails map[string]interface{}
+
+// Value implements the driver.Valuer interface for GORM
+func (d AuditDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return json.Marshal(d)
+}
+
+// Scan implements the sql.Scanner interface for GORM
+func (d *AuditDetails) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, d)
+}
+
+// AuditChange is the value of a field before and after an audited change
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditChanges maps each changed field to its values before and after an audited change
+type AuditChanges map[string]AuditChange
+
+// Value implements the driver.Valuer interface for GORM
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface for GORM
+func (c *AuditChanges) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, c)
+}
+
+// Audit log hash versions
+const (
+	// AuditHashV1 entries hash the client IP address with the rest of the entry
+	AuditHashV1 = 1
+	// AuditHashV2 entries leave the client IP address out of the hash, so it can be erased
+	AuditHashV2 = 2
+)
+
+// AuditLog records who did what to which resource. Entries are only ever inserted, and
+// each entry's hash covers its content and the previous entry's hash, so changing or
+// removing an entry breaks the chain from that point on. The only change allowed is
+// erasing the client IP address, which IPErasedAt records.
+type AuditLog struct {
+	ID             string       `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Sequence       int64        `json:"sequence" gorm:"not null;uniqueIndex:idx_audit_logs_sequence"`
+	ActorID        *string      `json:"actorId,omitempty" gorm:"column:actor_id;type:uuid;index:idx_audit_logs_actor_id"`
+	ImpersonatorID *string      `json:"impersonatorId,omitempty" gorm:"column:impersonator_id;type:uuid;index:idx_audit_logs_impersonator_id"`
+	Action         AuditAction  `json:"action" gorm:"not null;size:100;index:idx_audit_logs_action"`
+	ResourceType   string       `json:"resourceType" gorm:"column:resource_type;not null;size:50;index:idx_audit_logs_resource,priority:1"`
+	ResourceID     string       `json:"resourceId" gorm:"column:resource_id;not null;size:100;index:idx_audit_logs_resource,priority:2"`
+	Changes        AuditChanges `json:"changes,omitempty" gorm:"type:jsonb"`
+	Details        AuditDetails `json:"details,omitempty" gorm:"type:jsonb"`
+	IPAddress      string       `json:"ipAddress,omitempty" gorm:"column:ip_address;size:45"`
+	RequestID      string       `json:"requestId,omitempty" gorm:"column:request_id;size:100;index:idx_audit_logs_request_id"`
+	PrevHash       string       `json:"prevHash" gorm:"column:prev_hash;size:64"`
+	Hash           string       `json:"hash" gorm:"size:64"`
+	HashVersion    int          `json:"hashVersion" gorm:"column:hash_version;not null;default:1"`
+	IPErasedAt     *time.Time   `json:"ipErasedAt,omitempty" gorm:"column:ip_erased_at"`
+	CreatedAt      time.Time    `json:"createdAt" gorm:"column:created_at;index:idx_audit_logs_created_at"`
+}
+
+// auditLogContent is the hashed content of an AuditHashV1 entry, in a fixed field order
+type auditLogContent struct {
+	Sequence       int64        `json:"sequence"`
+	ActorID        *string      `json:"actorId"`
+	ImpersonatorID *string      `json:"impersonatorId"`
+	Action         AuditAction  `json:"action"`
+	ResourceType   string       `json:"resourceType"`
+	ResourceID     string       `json:"resourceId"`
+	Changes        AuditChanges `json:"changes"`
+	Details        AuditDetails `json:"details"`
+	IPAddress      string       `json:"ipAddress"`
+	RequestID      string       `json:"requestId"`
+	PrevHash       string       `json:"prevHash"`
+	CreatedAt      string       `json:"createdAt"`
+}
+
+// auditLogContentV2 is the hashed content of an AuditHashV2 entry, in a fixed field order
+type auditLogContentV2 struct {
+	HashVersion    int          `json:"hashVersion"`
+	Sequence       int64        `json:"sequence"`
+	ActorID        *string      `json:"actorId"`
+	ImpersonatorID *string      `json:"impersonatorId"`
+	Action         AuditAction  `json:"action"`
+	ResourceType   string       `json:"resourceType"`
+	ResourceID     string       `json:"resourceId"`
+	Changes        AuditChanges `json:"changes"`
+	Details        AuditDetails `json:"details"`
+	RequestID      string       `json:"requestId"`
+	PrevHash       string       `json:"prevHash"`
+	CreatedAt      string       `json:"createdAt"`
+}
+
+// ComputeHash returns the SHA-256 hash chaining the entry to the one before it.
+// CreatedAt is hashed at microsecond precision, as stored by the database.
+func (l *AuditLog) ComputeHash() (string, error) {
+	createdAt := l.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+
+	var content []byte
+	var err error
+	if l.HashVersion == AuditHashV2 {
+		content, err = json.Marshal(auditLogContentV2{
+			HashVersion:    l.HashVersion,
+			Sequence:       l.Sequence,
+			ActorID:        l.ActorID,
+			ImpersonatorID: l.ImpersonatorID,
+			Action:         l.Action,
+			ResourceType:   l.ResourceType,
+			ResourceID:     l.ResourceID,
+			Changes:        l.Changes,
+			Details:        l.Details,
+			RequestID:      l.RequestID,
+			PrevHash:       l.PrevHash,
+			CreatedAt:      createdAt,
+		})
+	} else {
+		content, err = json.Marshal(auditLogContent{
+			Sequence:       l.Sequence,
+			ActorID:        l.ActorID,
+			ImpersonatorID: l.ImpersonatorID,
+			Action:         l.Action,
+			ResourceType:   l.ResourceType,
+			ResourceID:     l.ResourceID,
+			Changes:        l.Changes,
+			Details:        l.Details,
+			IPAddress:      l.IPAddress,
+			RequestID:      l.RequestID,
+			PrevHash:       l.PrevHash,
+			CreatedAt:      createdAt,
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// IsHashVerifiable reports whether the entry still holds everything its hash covers. An
+// AuditHashV1 entry whose IP address was erased can only be checked by its place in the chain.
+func (l *AuditLog) IsHashVerifiable() bool {
+	return l.HashVersion == AuditHashV2 || l.IPErasedAt == nil
+}
+
+// TableName returns the table name for the AuditLog model
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -28,6 +28,30 @@ func (Auth) TableName() string {
 	return "auth"
 }
 
+// PasswordResetToken represents a single-use password reset token.
+// Only a SHA-256 hash of the token is stored; the raw token is sent to the user.
+type PasswordResetToken struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string     `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_password_reset_tokens_user_id"`
+	TokenHash string     `json:"-" gorm:"column:token_hash;not null;size:64;uniqueIndex:idx_password_reset_tokens_token_hash"`
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" gorm:"column:used_at"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID;references:ID"`
+}
+
+// IsUsable reports whether the token is unused and unexpired
+func (t *PasswordResetToken) IsUsable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// TableName returns the table name for the PasswordResetToken model
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
 // AuthTokens represents authentication tokens (not stored in database)
 type AuthTokens struct {
 	AccessToken  string    `json:"accessToken" validate:"required"`
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+)
+
+// ConsentPurpose is what a user consents to
+type ConsentPurpose string
+
+// Documents are accepted in a given version; messages are consented to per channel
+const (
+	ConsentPurposeTermsOfService   ConsentPurpose = "terms_of_service"
+	ConsentPurposePrivacyPolicy    ConsentPurpose = "privacy_policy"
+	ConsentPurposePaymentReminders ConsentPurpose = "payment_reminders"
+	ConsentPurposeMarketing        ConsentPurpose = "marketing"
+)
+
+// ConsentChannel is a channel messages can be sent through
+type ConsentChannel string
+
+const (
+	// ConsentChannelNone is the channel of consent to a document
+	ConsentChannelNone  ConsentChannel = ""
+	ConsentChannelEmail ConsentChannel = "email"
+	ConsentChannelSMS   ConsentChannel = "sms"
+	ConsentChannelInApp ConsentChannel = "in_app"
+)
+
+// ConsentSource records how a consent choice was made
+type ConsentSource string
+
+const (
+	ConsentSourceRegistration ConsentSource = "registration"
+	ConsentSourceSettings     ConsentSource = "settings"
+)
+
+// ConsentDocuments lists the documents every user must accept
+var ConsentDocuments = []ConsentPurpose{
+	ConsentPurposeTermsOfService,
+	ConsentPurposePrivacyPolicy,
+}
+
+// MessagePurposes lists the kinds of messages that are only sent with the user's consent
+var MessagePurposes = []ConsentPurpose{
+	ConsentPurposePaymentReminders,
+	ConsentPurposeMarketing,
+}
+
+// MessageChannels lists the channels messages can be consented to on
+var MessageChannels = []ConsentChannel{
+	ConsentChannelEmail,
+	ConsentChannelSMS,
+	ConsentChannelInApp,
+}
+
+// ConsentRecord is one consent choice a user made. Records are only ever added:
+// the latest record for a purpose and channel is the user's current choice, and
+// the earlier ones are the history of what they agreed to and when.
+type ConsentRecord struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string         `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_consent_records_user_purpose,priority:1"`
+	Purpose   ConsentPurpose `json:"purpose" gorm:"not null;type:varchar(50);index:idx_consent_records_user_purpose,priority:2"`
+	Channel   ConsentChannel `json:"channel,omitempty" gorm:"not null;type:varchar(20);default:'';index:idx_consent_records_user_purpose,priority:3"`
+	Version   string         `json:"version" gorm:"not null;size:50"`
+	Granted   bool           `json:"granted" gorm:"not null"`
+	Source    ConsentSource  `json:"source" gorm:"not null;type:varchar(20)"`
+	IPAddress string         `json:"ipAddress,omitempty" gorm:"column:ip_address;size:45"`
+	UserAgent string         `json:"userAgent,omitempty" gorm:"column:user_agent;size:512"`
+	CreatedAt time.Time      `json:"createdAt" gorm:"column:created_at;autoCreateTime;index:idx_consent_records_user_purpose,priority:4,sort:desc"`
+}
+
+// IsConsentDocument reports whether purpose is a document accepted by version
+func IsConsentDocument(purpose ConsentPurpose) bool {
+	for _, document := range ConsentDocuments {
+		if document == purpose {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMessagePurpose reports whether purpose is a kind of message sent only with consent
+func IsMessagePurpose(purpose ConsentPurpose) bool {
+	for _, p := range MessagePurposes {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMessageChannel reports whether channel is a channel messages can be consented to on
+func IsMessageChannel(channel ConsentChannel) bool {
+	for _, c := range MessageChannels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationConsentPurpose returns the consent a notification of the given type needs.
+// Notifications about the user's own payments and account security need none.
+func NotificationConsentPurpose(notificationType NotificationType) (ConsentPurpose, bool) {
+	switch notificationType {
+	case NotificationTypePaymentReminder:
+		return ConsentPurposePaymentReminders, true
+	case NotificationTypeSystemUpdate:
+		return ConsentPurposeMarketing, true
+	}
+	return "", false
+}
+
+// TableName returns the table name for the ConsentRecord model
+func (ConsentRecord) TableName() string {
+	return "consent_records"
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"sort"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+const (
+	CurrencyTHB Currency = "THB"
+	CurrencyUSD Currency = "USD"
+	CurrencyEUR Currency = "EUR"
+	CurrencyGBP Currency = "GBP"
+)
+
+// DefaultCurrency is the currency of municipalities and payments that do not set one
+const DefaultCurrency = CurrencyTHB
+
+// CurrencyInfo is the ISO 4217 metadata of a supported currency
+type CurrencyInfo struct {
+	Code Currency `json:"code"`
+	// Numeric is the ISO 4217 numeric code
+	Numeric string `json:"numeric"`
+	// MinorUnits is the number of decimal places of the minor unit, such as satang or cents
+	MinorUnits int    `json:"minorUnits"`
+	Symbol     string `json:"symbol"`
+	Name       string `json:"name"`
+	NameTH     string `json:"nameTh"`
+}
+
+// currencies is the registry of supported currencies. Adding one also needs a migration that
+// extends the chk_payments_currency and chk_municipalities_default_currency constraints.
+var currencies = map[Currency]CurrencyInfo{
+	CurrencyTHB: {Code: CurrencyTHB, Numeric: "764", MinorUnits: 2, Symbol: "฿", Name: "Thai Baht", NameTH: "บาท"},
+	CurrencyUSD: {Code: CurrencyUSD, Numeric: "840", MinorUnits: 2, Symbol: "$", Name: "US Dollar", NameTH: "ดอลลาร์สหรัฐ"},
+	CurrencyEUR: {Code: CurrencyEUR, Numeric: "978", MinorUnits: 2, Symbol: "€", Name: "Euro", NameTH: "ยูโร"},
+	CurrencyGBP: {Code: CurrencyGBP, Numeric: "826", MinorUnits: 2, Symbol: "£", Name: "Pound Sterling", NameTH: "ปอนด์สเตอร์ลิง"},
+}
+
+// LookupCurrency returns the metadata of a supported currency
+func LookupCurrency(code Currency) (CurrencyInfo, bool) {
+	info, ok := currencies[code]
+	return info, ok
+}
+
+// Currencies returns every supported currency, ordered by code
+func Currencies() []CurrencyInfo {
+	list := make([]CurrencyInfo, 0, len(currencies))
+	for _, info := range currencies {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// MinorUnits returns the number of decimal places of the currency's minor unit, or two for
+// a currency that is not supported
+func (c Currency) MinorUnits() int {
+	if info, ok := currencies[c]; ok {
+		return info.MinorUnits
+	}
+	return defaultMinorUnits
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DataSubjectRequestType is what a resident asks for under the PDPA
+type DataSubjectRequestType string
+
+const (
+	// DataSubjectRequestExport asks for a copy of all personal data held about the resident
+	DataSubjectRequestExport DataSubjectRequestType = "export"
+	// DataSubjectRequestErasure asks for the resident's personal data to be erased
+	DataSubjectRequestErasure DataSubjectRequestType = "erasure"
+)
+
+// DataSubjectRequestStatus tracks a data subject request through review and processing
+type DataSubjectRequestStatus string
+
+const (
+	DataSubjectRequestPending   DataSubjectRequestStatus = "pending"
+	DataSubjectRequestApproved  DataSubjectRequestStatus = "approved"
+	DataSubjectRequestRejected  DataSubjectRequestStatus = "rejected"
+	DataSubjectRequestCompleted DataSubjectRequestStatus = "completed"
+	DataSubjectRequestFailed    DataSubjectRequestStatus = "failed"
+)
+
+// DataSubjectRequest is a resident's request to export or erase their personal data.
+// An admin approves or rejects it; approved requests are carried out in the background.
+// The export archive is kept until ArchiveExpiresAt and never serialised with the request.
+type DataSubjectRequest struct {
+	ID               string                   `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID           string                   `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_data_subject_requests_user_id"`
+	Type             DataSubjectRequestType   `json:"type" gorm:"not null;type:varchar(20)"`
+	Status           DataSubjectRequestStatus `json:"status" gorm:"not null;type:varchar(20);default:pending;index:idx_data_subject_requests_status"`
+	Reason           string                   `json:"reason,omitempty" gorm:"size:500"`
+	ReviewedByID     *string                  `json:"reviewedById,omitempty" gorm:"column:reviewed_by_id;type:uuid"`
+	ReviewedAt       *time.Time               `json:"reviewedAt,omitempty" gorm:"column:reviewed_at"`
+	ReviewNote       string                   `json:"reviewNote,omitempty" gorm:"column:review_note;size:500"`
+	Error            string                   `json:"error,omitempty" gorm:"size:500"`
+	Archive          []byte                   `json:"-" gorm:"type:bytea"`
+	ArchiveExpiresAt *time.Time               `json:"archiveExpiresAt,omitempty" gorm:"column:archive_expires_at"`
+	CompletedAt      *time.Time               `json:"completedAt,omitempty" gorm:"column:completed_at"`
+	CreatedAt        time.Time                `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time                `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+
+	// Relationships
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// IsOpen reports whether the request is still awaiting review or processing
+func (r *DataSubjectRequest) IsOpen() bool {
+	return r.Status == DataSubjectRequestPending || r.Status == DataSubjectRequestApproved
+}
+
+// ArchiveAvailable reports whether the export archive can still be downloaded
+func (r *DataSubjectRequest) ArchiveAvailable(now time.Time) bool {
+	return r.Type == DataSubjectRequestExport && r.Status == DataSubjectRequestCompleted &&
+		len(r.Archive) > 0 && r.ArchiveExpiresAt != nil && now.Before(*r.ArchiveExpiresAt)
+}
+
+// ValidateDataSubjectRequestType validates a data subject request type
+func ValidateDataSubjectRequestType(requestType DataSubjectRequestType) error {
+	switch requestType {
+	case DataSubjectRequestExport, DataSubjectRequestErasure:
+		return nil
+	}
+	return fmt.Errorf("invalid data subject request type: %s", requestType)
+}
+
+// TableName returns the table name for the DataSubjectRequest model
+func (DataSubjectRequest) TableName() string {
+	return "data_subject_requests"
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// Household is a house on a municipality's house registration, identified by its house
+// number. Residents are bound to it by their national ID, and each user belongs to at
+// most one household.
+type Household struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	MunicipalityID string    `json:"municipalityId" gorm:"column:municipality_id;not null;type:uuid;uniqueIndex:idx_households_municipality_house_number,priority:1"`
+	HouseNumber    string    `json:"houseNumber" gorm:"column:house_number;not null;size:20;uniqueIndex:idx_households_municipality_house_number,priority:2" validate:"required,max=20"`
+	Address        string    `json:"address" gorm:"not null;size:500" validate:"required,max=500"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+
+	// Relationships
+	Members []HouseholdMember `json:"members,omitempty" gorm:"foreignKey:HouseholdID"`
+}
+
+// HouseholdMember binds a user to a household
+type HouseholdMember struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	HouseholdID string    `json:"householdId" gorm:"column:household_id;not null;type:uuid;index:idx_household_members_household_id"`
+	UserID      string    `json:"userId" gorm:"column:user_id;not null;type:uuid;uniqueIndex:idx_household_members_user_id"`
+	IsHead      bool      `json:"isHead" gorm:"column:is_head;not null;default:false"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+
+	// Relationships
+	User      *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Household *Household `json:"household,omitempty" gorm:"foreignKey:HouseholdID"`
+}
+
+// TableName returns the table name for the Household model
+func (Household) TableName() string {
+	return "households"
+}
+
+// TableName returns the table name for the HouseholdMember model
+func (HouseholdMember) TableName() string {
+	return "household_members"
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey records a request made with an Idempotency-Key header, so a retry of the
+// same request gets the stored response instead of repeating its effect. Keys are unique per
+// user and scope, such as payment initiation, and are forgotten once they expire.
+type IdempotencyKey struct {
+	ID          string `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID      string `json:"userId" gorm:"column:user_id;not null;type:uuid;uniqueIndex:idx_idempotency_keys_user_scope_key,priority:1"`
+	Scope       string `json:"scope" gorm:"not null;size:50;uniqueIndex:idx_idempotency_keys_user_scope_key,priority:2"`
+	Key         string `json:"key" gorm:"column:idempotency_key;not null;size:255;uniqueIndex:idx_idempotency_keys_user_scope_key,priority:3"`
+	Fingerprint string `json:"-" gorm:"not null;size:64"`
+	// The stored response is empty until the first request completes
+	ResponseStatus      *int       `json:"responseStatus,omitempty" gorm:"column:response_status"`
+	ResponseContentType string     `json:"-" gorm:"column:response_content_type;size:100"`
+	ResponseBody        *string    `json:"-" gorm:"column:response_body;type:text;serializer:encrypted"`
+	CompletedAt         *time.Time `json:"completedAt,omitempty" gorm:"column:completed_at"`
+	ExpiresAt           time.Time  `json:"expiresAt" gorm:"column:expires_at;not null;index:idx_idempotency_keys_expires_at"`
+	CreatedAt           time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// IsCompleted reports whether the first request made with the key has a stored response
+func (k *IdempotencyKey) IsCompleted() bool {
+	return k.CompletedAt != nil
+}
+
+// TableName returns the table name for the IdempotencyKey model
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
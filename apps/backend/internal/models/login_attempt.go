@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt tracks failed logins for one throttling key, such as an account or a client IP.
+// Keeping the counters in the database lets every backend replica see the same state.
+type LoginAttempt struct {
+	Key           string     `json:"key" gorm:"primaryKey;column:throttle_key;size:320"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"lastFailureAt" gorm:"column:last_failure_at;not null"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty" gorm:"column:locked_until"`
+	UpdatedAt     time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime;index:idx_login_attempts_updated_at"`
+}
+
+// TableName returns the table name for the LoginAttempt model
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// UserMFA represents a user's TOTP second factor. The secret is encrypted, since anyone
+// who reads it can generate the user's codes.
+// The factor only protects logins once EnabledAt is set by a confirmed enrolment.
+type UserMFA struct {
+	UserID       string     `json:"userId" gorm:"primaryKey;column:user_id;type:uuid"`
+	Secret       string     `json:"-" gorm:"not null;type:text;serializer:encrypted"`
+	EnabledAt    *time.Time `json:"enabledAt,omitempty" gorm:"column:enabled_at"`
+	LastUsedStep int64      `json:"-" gorm:"column:last_used_step;not null;default:0"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID;references:ID"`
+}
+
+// IsEnabled reports whether enrolment has been confirmed
+func (m *UserMFA) IsEnabled() bool {
+	return m.EnabledAt != nil
+}
+
+// TableName returns the table name for the UserMFA model
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// MFARecoveryCode represents a single-use recovery code for a user's second factor.
+// Only a SHA-256 hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string     `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_mfa_recovery_codes_user_id"`
+	CodeHash  string     `json:"-" gorm:"column:code_hash;not null;size:64"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" gorm:"column:used_at"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID;references:ID"`
+}
+
+// TableName returns the table name for the MFARecoveryCode model
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
@@ -73,10 +73,12 @@ func ValidateStructWithDB(db *gorm.DB, s interface{}) error {
 
 // validateUser performs database-specific validation for User
 func validateUser(db *gorm.DB, user *User) error {
-	// Check if email is unique (for new users or email changes)
-	if user.ID == "" || db.Where("email = ? AND id != ?", user.Email, user.ID).First(&User{}).Error == nil {
+	// Check if email is unique (for new users or email changes). Addresses are
+	// encrypted, so they are compared by their blind index.
+	emailIndex := EmailIndex(user.Email)
+	if user.ID == "" || db.Where("email_index = ? AND id != ?", emailIndex, user.ID).First(&User{}).Error == nil {
 		var existingUser User
-		if db.Where("email = ?", user.Email).First(&existingUser).Error == nil {
+		if db.Where("email_index = ?", emailIndex).First(&existingUser).Error == nil {
 			if user.ID == "" || existingUser.ID != user.ID {
 				return fmt.Errorf("email already exists")
 			}
@@ -212,5 +214,25 @@ func AutoMigrate(db *gorm.DB) error {
 		&Payment{},
 		&PaymentTransaction{},
 		&Notification{},
+		&RefreshToken{},
+		&RevokedToken{},
+		&UserTokenCutoff{},
+		&PasswordResetToken{},
+		&VerificationCode{},
+		&UserMFA{},
+		&MFARecoveryCode{},
+		&LoginAttempt{},
+		&APIKey{},
+		&UserIdentity{},
+		&Session{},
+		&MunicipalRole{},
+		&AuditLog{},
+		&StaffInvitation{},
+		&DataSubjectRequest{},
+		&ConsentRecord{},
+		&Household{},
+		&HouseholdMember{},
+		&IdempotencyKey{},
+		&WebhookEvent{},
 	)
 }
\ No newline at end of file
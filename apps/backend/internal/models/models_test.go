@@ -41,15 +41,15 @@ func TestModelStructures(t *testing.T) {
 		MunicipalityID: municipality.ID,
 		UserID:         user.ID,
 		ServiceType:    ServiceTypeWasteManagement,
-		Amount:         100.50,
+		Amount:         NewMoney(10050, CurrencyUSD),
 		Currency:       CurrencyUSD,
 		Status:         PaymentStatusPending,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}
 
-	if payment.Amount != 100.50 {
-		t.Errorf("Expected amount to be 100.50, got %f", payment.Amount)
+	if payment.Amount.Decimal() != "100.50" {
+		t.Errorf("Expected amount to be 100.50, got %s", payment.Amount)
 	}
 
 	// Test Notification model
@@ -122,19 +122,38 @@ func TestValidationFunctions(t *testing.T) {
 	}
 
 	// Test amount validation
-	validAmounts := []float64{0.01, 100.50, 999999.99}
+	validAmounts := []Money{NewMoney(1, CurrencyUSD), NewMoney(10050, CurrencyUSD), NewMoney(99999999, CurrencyUSD)}
 	for _, amount := range validAmounts {
 		if err := ValidateAmount(amount); err != nil {
-			t.Errorf("Expected amount %f to be valid, got error: %v", amount, err)
+			t.Errorf("Expected amount %s to be valid, got error: %v", amount, err)
 		}
 	}
 
-	invalidAmounts := []float64{0, -10.50, 1000000.00}
+	invalidAmounts := []Money{NewMoney(0, CurrencyUSD), NewMoney(-1050, CurrencyUSD), NewMoney(100000000, CurrencyUSD)}
 	for _, amount := range invalidAmounts {
 		if err := ValidateAmount(amount); err == nil {
-			t.Errorf("Expected amount %f to be invalid, but validation passed", amount)
+			t.Errorf("Expected amount %s to be invalid, but validation passed", amount)
 		}
 	}
+
+	// Test Thai national ID validation
+	validNationalIDs := []string{"1101700230708", "3-1001-00045-67-1", "1 2345 67890 12 1"}
+	for _, id := range validNationalIDs {
+		if err := ValidateThaiNationalID(id); err != nil {
+			t.Errorf("Expected national ID %s to be valid, got error: %v", id, err)
+		}
+	}
+
+	invalidNationalIDs := []string{"1101700230709", "110170023070", "0101700230700", "11017002307a8"}
+	for _, id := range invalidNationalIDs {
+		if err := ValidateThaiNationalID(id); err == nil {
+			t.Errorf("Expected national ID %s to be invalid, but validation passed", id)
+		}
+	}
+
+	if masked := MaskThaiNationalID("1-1017-00230-70-8"); masked != "X-XXXX-XXXX0-70-8" {
+		t.Errorf("Expected masked national ID X-XXXX-XXXX0-70-8, got %s", masked)
+	}
 }
 
 // TestEnumValidation tests enum validation functions
@@ -164,7 +183,7 @@ func TestEnumValidation(t *testing.T) {
 	}
 
 	// Test Currency validation
-	validCurrencies := []Currency{CurrencyUSD, CurrencyEUR, CurrencyGBP}
+	validCurrencies := []Currency{CurrencyTHB, CurrencyUSD, CurrencyEUR, CurrencyGBP}
 	for _, currency := range validCurrencies {
 		if err := ValidateCurrency(currency); err != nil {
 			t.Errorf("Expected currency %s to be valid, got error: %v", currency, err)
@@ -220,4 +239,70 @@ func TestPaymentConfigValidation(t *testing.T) {
 	if err := ValidatePaymentConfig(invalidConfig3); err == nil {
 		t.Error("Expected invalid expiration time to fail validation")
 	}
-}
\ No newline at end of file
+}
+
+// TestMunicipalityDefaultCurrency tests that a municipality's currency and payment configuration agree
+func TestMunicipalityDefaultCurrency(t *testing.T) {
+	// Defaults to THB and gives the payment configuration the same currency
+	municipality := &Municipality{Name: "Chiang Mai", Code: "CNX", PaymentConfig: &PaymentConfig{}}
+	if err := municipality.BeforeSave(nil); err != nil {
+		t.Fatalf("Expected municipality without a currency to be valid, got error: %v", err)
+	}
+	if municipality.DefaultCurrency != CurrencyTHB || municipality.PaymentConfig.Currency != "THB" {
+		t.Errorf("Expected THB, got %s and %s", municipality.DefaultCurrency, municipality.PaymentConfig.Currency)
+	}
+
+	// Takes the currency of the payment configuration
+	municipality = &Municipality{Name: "Springfield", Code: "SPRING", PaymentConfig: &PaymentConfig{Currency: "USD"}}
+	if err := municipality.BeforeSave(nil); err != nil {
+		t.Fatalf("Expected municipality with a configured currency to be valid, got error: %v", err)
+	}
+	if municipality.DefaultCurrency != CurrencyUSD {
+		t.Errorf("Expected USD, got %s", municipality.DefaultCurrency)
+	}
+
+	// Rejects a payment configuration in another currency
+	municipality = &Municipality{Name: "Chiang Mai", Code: "CNX", DefaultCurrency: CurrencyTHB, PaymentConfig: &PaymentConfig{Currency: "USD"}}
+	if err := municipality.BeforeSave(nil); err == nil {
+		t.Error("Expected mismatched payment configuration currency to fail validation")
+	}
+
+	// Rejects an unsupported currency
+	municipality = &Municipality{Name: "Tokyo", Code: "TYO", DefaultCurrency: "JPY"}
+	if err := municipality.BeforeSave(nil); err == nil {
+		t.Error("Expected JPY to fail validation")
+	}
+}
+
+// TestPaymentConfigMissingVerifications tests the per-municipality verification policy
+func TestPaymentConfigMissingVerifications(t *testing.T) {
+	required := true
+	phone := "+66812345678"
+	verifiedAt := time.Now()
+
+	unverified := &User{ID: "user-1", Email: "test@example.com", Phone: &phone}
+	verified := &User{ID: "user-2", Email: "test@example.com", Phone: &phone, EmailVerifiedAt: &verifiedAt, PhoneVerifiedAt: &verifiedAt}
+
+	// No policy
+	var noConfig *PaymentConfig
+	if missing := noConfig.MissingVerifications(unverified); len(missing) != 0 {
+		t.Errorf("Expected no missing verifications without a config, got %v", missing)
+	}
+
+	policy := &PaymentConfig{RequireVerifiedEmail: &required, RequireVerifiedPhone: &required}
+
+	missing := policy.MissingVerifications(unverified)
+	if len(missing) != 2 || missing[0] != VerificationChannelEmail || missing[1] != VerificationChannelPhone {
+		t.Errorf("Expected email and phone to be missing, got %v", missing)
+	}
+
+	if missing := policy.MissingVerifications(verified); len(missing) != 0 {
+		t.Errorf("Expected verified user to pass, got %v", missing)
+	}
+
+	// A verified phone timestamp does not count once the number is removed
+	noPhone := &User{ID: "user-3", Email: "test@example.com", EmailVerifiedAt: &verifiedAt, PhoneVerifiedAt: &verifiedAt}
+	if missing := policy.MissingVerifications(noPhone); len(missing) != 1 || missing[0] != VerificationChannelPhone {
+		t.Errorf("Expected phone to be missing, got %v", missing)
+	}
+}
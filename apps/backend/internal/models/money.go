@@ -0,0 +1,299 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultMinorUnits is the number of decimal places of amounts whose currency is not
+// known yet, such as an amount decoded from JSON before the currency next to it
+const defaultMinorUnits = 2
+
+// decimalAmount matches a decimal amount without an exponent, capturing its sign, integer
+// digits and decimal places
+var decimalAmount = regexp.MustCompile(`^(-?)([0-9]+)(?:\.([0-9]+))?$`)
+
+// Money errors
+var (
+	ErrInvalidMoney     = errors.New("invalid amount")
+	ErrMoneyPrecision   = errors.New("amount has more decimal places than its currency allows")
+	ErrMoneyOverflow    = errors.New("amount is out of range")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+)
+
+// Money is an exact amount of money, counted in the minor units of its currency: satang for
+// Thai baht, cents for US dollars. It is stored in the database as the integer number of
+// minor units, and in JSON as a decimal number with the currency's decimal places, so
+// 100.50 THB is stored as 10050 and sent as 100.50.
+//
+// The currency is held in its own column or field next to the amount, so amounts decoded
+// from the database or JSON have no currency until In gives them one. Until then they
+// have two decimal places.
+type Money struct {
+	Minor    int64
+	Currency Currency
+}
+
+// NewMoney returns an amount of minor units of the currency
+func NewMoney(minor int64, currency Currency) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "100.50" in the currency. The amount must not
+// have more decimal places than the currency's minor unit.
+func ParseMoney(amount string, currency Currency) (Money, error) {
+	minor, err := parseMinorUnits(amount, currency.MinorUnits())
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// minorUnits returns the number of decimal places the amount is counted in
+func (m Money) minorUnits() int {
+	if m.Currency == "" {
+		return defaultMinorUnits
+	}
+	return m.Currency.MinorUnits()
+}
+
+// In returns the amount in the currency. An amount without a currency is rescaled to the
+// currency's minor unit, and must be exactly representable in it.
+func (m Money) In(currency Currency) (Money, error) {
+	if m.Currency == currency || currency == "" {
+		return m, nil
+	}
+	if m.Currency != "" {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, currency)
+	}
+
+	from, to := m.minorUnits(), currency.MinorUnits()
+	minor := new(big.Int).SetInt64(m.Minor)
+	if to > from {
+		minor.Mul(minor, pow10(to-from))
+	} else if to < from {
+		quotient, remainder := new(big.Int).QuoRem(minor, pow10(from-to), new(big.Int))
+		if remainder.Sign() != 0 {
+			return Money{}, ErrMoneyPrecision
+		}
+		minor = quotient
+	}
+	if !minor.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Minor: minor.Int64(), Currency: currency}, nil
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+// Add returns the sum of two amounts of the same currency
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.sameCurrency(other)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := m.Minor + other.Minor
+	if (other.Minor > 0 && sum < m.Minor) || (other.Minor < 0 && sum > m.Minor) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Minor: sum, Currency: currency}, nil
+}
+
+// Sub returns the difference of two amounts of the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Minor == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(Money{Minor: -other.Minor, Currency: other.Currency})
+}
+
+// Mul returns the amount multiplied by n
+func (m Money) Mul(n int64) (Money, error) {
+	return m.MulRatio(n, 1)
+}
+
+// MulRatio returns the amount multiplied by num/den, rounded half away from zero to the
+// currency's minor unit. A 7% fee on 100.25 THB is MulRatio(7, 100), or 7.02 THB.
+func (m Money) MulRatio(num, den int64) (Money, error) {
+	if den == 0 {
+		return Money{}, fmt.Errorf("%w: division by zero", ErrInvalidMoney)
+	}
+
+	product := new(big.Int).Mul(big.NewInt(m.Minor), big.NewInt(num))
+	result := roundQuotient(product, big.NewInt(den))
+	if !result.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Minor: result.Int64(), Currency: m.Currency}, nil
+}
+
+// Split divides the amount into n parts that add up to it exactly. The minor units that
+// cannot be divided evenly go to the first parts, so 100.00 split three ways is
+// 33.34, 33.33 and 33.33.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: cannot split into %d parts", ErrInvalidMoney, n)
+	}
+
+	share, remainder := m.Minor/int64(n), m.Minor%int64(n)
+	parts := make([]Money, n)
+	for i := range parts {
+		parts[i] = Money{Minor: share, Currency: m.Currency}
+		if remainder > 0 {
+			parts[i].Minor++
+			remainder--
+		} else if remainder < 0 {
+			parts[i].Minor--
+			remainder++
+		}
+	}
+	return parts, nil
+}
+
+// Decimal returns the amount as a decimal number with the currency's decimal places, such as "100.50"
+func (m Money) Decimal() string {
+	places := m.minorUnits()
+	digits := new(big.Int).Abs(big.NewInt(m.Minor)).String()
+
+	sign := ""
+	if m.Minor < 0 {
+		sign = "-"
+	}
+	if places == 0 {
+		return sign + digits
+	}
+	if len(digits) <= places {
+		digits = strings.Repeat("0", places-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-places] + "." + digits[len(digits)-places:]
+}
+
+// String returns the amount with its currency, such as "100.50 THB"
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+// MarshalJSON writes the amount as a decimal number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON reads a decimal number or string exactly, without going through a float.
+// Exponents such as 1e2 are not accepted.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	minor, err := parseMinorUnits(text, m.minorUnits())
+	if err != nil {
+		return err
+	}
+	m.Minor = minor
+	return nil
+}
+
+// Value implements the driver.Valuer interface for GORM, storing the minor units
+func (m Money) Value() (driver.Value, error) {
+	return m.Minor, nil
+}
+
+// Scan implements the sql.Scanner interface for GORM. The currency is left as it is.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		m.Minor = 0
+	case int64:
+		m.Minor = v
+	case []byte:
+		return m.scanText(string(v))
+	case string:
+		return m.scanText(v)
+	default:
+		return fmt.Errorf("unsupported amount type %T", value)
+	}
+	return nil
+}
+
+// scanText reads minor units returned as text
+func (m *Money) scanText(text string) error {
+	minor, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMoney, text)
+	}
+	m.Minor = minor
+	return nil
+}
+
+// sameCurrency returns the currency shared by both amounts. An amount without a currency
+// takes the other one's.
+func (m Money) sameCurrency(other Money) (Currency, error) {
+	switch {
+	case m.Currency == other.Currency, other.Currency == "":
+		return m.Currency, nil
+	case m.Currency == "":
+		return other.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+// parseMinorUnits parses a decimal amount such as "-100.5" into minor units with the given
+// number of decimal places. Trailing zeros beyond them are allowed.
+func parseMinorUnits(text string, places int) (int64, error) {
+	match := decimalAmount.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, text)
+	}
+
+	fraction := strings.TrimRight(match[3], "0")
+	if len(fraction) > places {
+		return 0, ErrMoneyPrecision
+	}
+	fraction += strings.Repeat("0", places-len(fraction))
+
+	minor, err := strconv.ParseInt(match[1]+match[2]+fraction, 10, 64)
+	if err != nil {
+		return 0, ErrMoneyOverflow
+	}
+	return minor, nil
+}
+
+// roundQuotient returns num/den rounded half away from zero
+func roundQuotient(num, den *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient
+}
+
+// pow10 returns 10 to the power of n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
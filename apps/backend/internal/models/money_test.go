@@ -0,0 +1,145 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// TestMoneyParsing tests exact parsing and formatting of amounts
+func TestMoneyParsing(t *testing.T) {
+	cases := map[string]int64{
+		"100.50": 10050,
+		"100.5":  10050,
+		"0.01":   1,
+		"-12":    -1200,
+		"7.000":  700,
+	}
+	for text, minor := range cases {
+		amount, err := ParseMoney(text, CurrencyUSD)
+		if err != nil {
+			t.Errorf("Expected %s to parse, got error: %v", text, err)
+			continue
+		}
+		if amount.Minor != minor {
+			t.Errorf("Expected %s to be %d minor units, got %d", text, minor, amount.Minor)
+		}
+	}
+
+	for _, text := range []string{"", "abc", "1e2", "1/2", "0x10", "1.", ".5"} {
+		if _, err := ParseMoney(text, CurrencyUSD); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("Expected %q to be invalid, got %v", text, err)
+		}
+	}
+	if _, err := ParseMoney("0.001", CurrencyUSD); !errors.Is(err, ErrMoneyPrecision) {
+		t.Errorf("Expected 0.001 to have too many decimal places, got %v", err)
+	}
+	if _, err := ParseMoney("99999999999999999999", CurrencyUSD); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("Expected a huge amount to overflow, got %v", err)
+	}
+
+	for minor, text := range map[int64]string{10050: "100.50", 5: "0.05", -5: "-0.05", 0: "0.00"} {
+		if decimal := NewMoney(minor, CurrencyUSD).Decimal(); decimal != text {
+			t.Errorf("Expected %d minor units to format as %s, got %s", minor, text, decimal)
+		}
+	}
+}
+
+// TestMoneyJSON tests that amounts round-trip through JSON as exact decimal numbers
+func TestMoneyJSON(t *testing.T) {
+	encoded, err := json.Marshal(map[string]Money{"amount": NewMoney(10050, CurrencyUSD)})
+	if err != nil {
+		t.Fatalf("Failed to encode amount: %v", err)
+	}
+	if string(encoded) != `{"amount":100.50}` {
+		t.Errorf("Expected amount to encode as 100.50, got %s", encoded)
+	}
+
+	// 0.1 + 0.2 in floating point is not 0.3, but the amounts are decoded exactly
+	var decoded struct {
+		A Money `json:"a"`
+		B Money `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": 0.1, "b": "0.2"}`), &decoded); err != nil {
+		t.Fatalf("Failed to decode amounts: %v", err)
+	}
+	sum, err := decoded.A.Add(decoded.B)
+	if err != nil || sum.Minor != 30 {
+		t.Errorf("Expected 0.1 + 0.2 to be 30 minor units, got %d (%v)", sum.Minor, err)
+	}
+
+	var data QRCodeData
+	if err := json.Unmarshal([]byte(`{"amount": 25.00, "currency": "USD"}`), &data); err != nil {
+		t.Fatalf("Failed to decode QR code data: %v", err)
+	}
+	if data.Amount != NewMoney(2500, CurrencyUSD) {
+		t.Errorf("Expected QR code amount 25.00 USD, got %s", data.Amount)
+	}
+
+	var config PaymentConfig
+	if err := json.Unmarshal([]byte(`{"wasteManagementFee": 150, "currency": "EUR"}`), &config); err != nil {
+		t.Fatalf("Failed to decode payment config: %v", err)
+	}
+	if config.WasteManagementFee == nil || *config.WasteManagementFee != NewMoney(15000, CurrencyEUR) {
+		t.Errorf("Expected waste management fee 150.00 EUR, got %v", config.WasteManagementFee)
+	}
+}
+
+// TestMoneyArithmetic tests arithmetic and rounding
+func TestMoneyArithmetic(t *testing.T) {
+	if _, err := NewMoney(100, CurrencyUSD).Add(NewMoney(100, CurrencyEUR)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected adding USD and EUR to fail, got %v", err)
+	}
+
+	difference, err := NewMoney(100, CurrencyUSD).Sub(NewMoney(250, CurrencyUSD))
+	if err != nil || difference.Minor != -150 {
+		t.Errorf("Expected 1.00 - 2.50 to be -1.50, got %s (%v)", difference, err)
+	}
+
+	// A 7% fee on 100.25 is 7.0175, rounded half away from zero to 7.02
+	fee, err := NewMoney(10025, CurrencyUSD).MulRatio(7, 100)
+	if err != nil || fee.Minor != 702 {
+		t.Errorf("Expected a 7%% fee on 100.25 to be 7.02, got %s (%v)", fee, err)
+	}
+	half, err := NewMoney(-5, CurrencyUSD).MulRatio(1, 2)
+	if err != nil || half.Minor != -3 {
+		t.Errorf("Expected half of -0.05 to round to -0.03, got %s (%v)", half, err)
+	}
+
+	parts, err := NewMoney(10000, CurrencyUSD).Split(3)
+	if err != nil {
+		t.Fatalf("Failed to split amount: %v", err)
+	}
+	if parts[0].Minor != 3334 || parts[1].Minor != 3333 || parts[2].Minor != 3333 {
+		t.Errorf("Expected 100.00 to split into 33.34, 33.33 and 33.33, got %v", parts)
+	}
+
+	if _, err := NewMoney(1<<62, CurrencyUSD).Mul(4); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("Expected multiplying a huge amount to overflow, got %v", err)
+	}
+}
+
+// TestCurrencyRegistry tests the ISO 4217 metadata of supported currencies
+func TestCurrencyRegistry(t *testing.T) {
+	info, ok := LookupCurrency(CurrencyTHB)
+	if !ok {
+		t.Fatal("Expected THB to be supported")
+	}
+	if info.Numeric != "764" || info.MinorUnits != 2 || info.Symbol != "฿" || info.NameTH != "บาท" {
+		t.Errorf("Unexpected THB metadata: %+v", info)
+	}
+
+	amount, err := ParseMoney("1250.75", CurrencyTHB)
+	if err != nil || amount.Minor != 125075 || amount.String() != "1250.75 THB" {
+		t.Errorf("Expected 1250.75 THB to be 125075 satang, got %v (%v)", amount, err)
+	}
+
+	if _, ok := LookupCurrency("JPY"); ok {
+		t.Error("Expected JPY to be unsupported")
+	}
+
+	currencies := Currencies()
+	if len(currencies) != 4 || currencies[0].Code != CurrencyEUR {
+		t.Errorf("Expected four currencies ordered by code, got %+v", currencies)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,11 +12,13 @@ import (
 
 // PaymentConfig represents the payment configuration for a municipality
 type PaymentConfig struct {
-	WasteManagementFee      *float64 `json:"wasteManagementFee,omitempty"`
+	WasteManagementFee      *Money   `json:"wasteManagementFee,omitempty"`
 	WaterBillEnabled        *bool    `json:"waterBillEnabled,omitempty"`
 	Currency                string   `json:"currency" validate:"required,currency"`
 	PaymentMethods          []string `json:"paymentMethods" validate:"required,min=1"`
 	QRCodeExpirationMinutes int      `json:"qrCodeExpirationMinutes" validate:"required,min=1,max=1440"`
+	RequireVerifiedEmail    *bool    `json:"requireVerifiedEmail,omitempty"`
+	RequireVerifiedPhone    *bool    `json:"requireVerifiedPhone,omitempty"`
 }
 
 // Value implements the driver.Valuer interface for GORM
@@ -37,30 +40,92 @@ func (pc *PaymentConfig) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, pc)
 }
 
-// Municipality represents a municipality in the system
+// UnmarshalJSON decodes a payment configuration and gives the waste management fee its currency
+func (pc *PaymentConfig) UnmarshalJSON(data []byte) error {
+	type paymentConfig PaymentConfig
+	var decoded paymentConfig
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*pc = PaymentConfig(decoded)
+	return pc.applyCurrency()
+}
+
+// applyCurrency counts the waste management fee in the minor units of the configured currency
+func (pc *PaymentConfig) applyCurrency() error {
+	if pc.WasteManagementFee == nil {
+		return nil
+	}
+
+	fee, err := pc.WasteManagementFee.In(Currency(pc.Currency))
+	if err != nil {
+		return fmt.Errorf("invalid waste management fee: %w", err)
+	}
+	pc.WasteManagementFee = &fee
+	return nil
+}
+
+// MissingVerifications returns the contact verifications this configuration requires
+// that the user has not completed. An empty result means the user may pay.
+func (pc *PaymentConfig) MissingVerifications(user *User) []VerificationChannel {
+	var missing []VerificationChannel
+	if pc == nil {
+		return missing
+	}
+
+	if pc.RequireVerifiedEmail != nil && *pc.RequireVerifiedEmail && !user.IsEmailVerified() {
+		missing = append(missing, VerificationChannelEmail)
+	}
+	if pc.RequireVerifiedPhone != nil && *pc.RequireVerifiedPhone && !user.IsPhoneVerified() {
+		missing = append(missing, VerificationChannelPhone)
+	}
+
+	return missing
+}
+
+// Municipality represents a municipality in the system. Its payment configuration and its
+// payments are in its default currency.
 type Municipality struct {
-	ID            string         `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()" validate:"required"`
-	Name          string         `json:"name" gorm:"not null;size:255;index:idx_municipalities_name" validate:"required,min=1,max=255"`
-	Code          string         `json:"code" gorm:"uniqueIndex:idx_municipalities_code;not null;size:10" validate:"required,municipality_code"`
-	ContactEmail  *string        `json:"contactEmail,omitempty" gorm:"size:255;index:idx_municipalities_contact_email" validate:"omitempty,email"`
-	ContactPhone  *string        `json:"contactPhone,omitempty" gorm:"size:20" validate:"omitempty,phone"`
-	PaymentConfig *PaymentConfig `json:"paymentConfig,omitempty" gorm:"type:jsonb"`
-	CreatedAt     time.Time      `json:"createdAt" gorm:"column:created_at;autoCreateTime;index:idx_municipalities_created_at"`
-	UpdatedAt     time.Time      `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	ID              string         `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()" validate:"required"`
+	Name            string         `json:"name" gorm:"not null;size:255;index:idx_municipalities_name" validate:"required,min=1,max=255"`
+	Code            string         `json:"code" gorm:"uniqueIndex:idx_municipalities_code;not null;size:10" validate:"required,municipality_code"`
+	ContactEmail    *string        `json:"contactEmail,omitempty" gorm:"size:255;index:idx_municipalities_contact_email" validate:"omitempty,email"`
+	ContactPhone    *string        `json:"contactPhone,omitempty" gorm:"type:text;serializer:encrypted" validate:"omitempty,phone"`
+	DefaultCurrency Currency       `json:"defaultCurrency" gorm:"column:default_currency;type:varchar(3);not null;default:THB" validate:"omitempty,currency"`
+	PaymentConfig   *PaymentConfig `json:"paymentConfig,omitempty" gorm:"type:jsonb"`
+	CreatedAt       time.Time      `json:"createdAt" gorm:"column:created_at;autoCreateTime;index:idx_municipalities_created_at"`
+	UpdatedAt       time.Time      `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
 
 	// Relationships
 	Payments []Payment `json:"payments,omitempty" gorm:"foreignKey:MunicipalityID"`
 }
 
-// BeforeCreate hook to validate data before creation
-func (m *Municipality) BeforeCreate(tx *gorm.DB) error {
+// BeforeSave hook to default and check the currency before creation and updates
+func (m *Municipality) BeforeSave(tx *gorm.DB) error {
+	return m.applyDefaultCurrency()
+}
+
+// applyDefaultCurrency defaults the municipality's currency to that of its payment
+// configuration, or to DefaultCurrency, gives the payment configuration the municipality's
+// currency when it has none, and checks that the two agree
+func (m *Municipality) applyDefaultCurrency() error {
+	if m.DefaultCurrency == "" {
+		m.DefaultCurrency = DefaultCurrency
+		if m.PaymentConfig != nil && m.PaymentConfig.Currency != "" {
+			m.DefaultCurrency = Currency(m.PaymentConfig.Currency)
+		}
+	}
 	if m.PaymentConfig != nil && m.PaymentConfig.Currency == "" {
-		m.PaymentConfig.Currency = "USD"
+		m.PaymentConfig.Currency = string(m.DefaultCurrency)
+		if err := m.PaymentConfig.applyCurrency(); err != nil {
+			return err
+		}
 	}
-	return nil
+	return ValidateMunicipalityCurrency(m)
 }
 
 // TableName returns the table name for the Municipality model
 func (Municipality) TableName() string {
 	return "municipalities"
-}
\ No newline at end of file
+}
@@ -17,6 +17,7 @@ const (
 	NotificationTypePaymentConfirmation NotificationType = "payment_confirmation"
 	NotificationTypePaymentFailed      NotificationType = "payment_failed"
 	NotificationTypeSystemUpdate       NotificationType = "system_update"
+	NotificationTypeSecurityAlert      NotificationType = "security_alert"
 )
 
 // NotificationStatus represents the status of a notification
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -22,15 +23,7 @@ const (
 	PaymentStatusCompleted PaymentStatus = "completed"
 	PaymentStatusFailed    PaymentStatus = "failed"
 	PaymentStatusExpired   PaymentStatus = "expired"
-)
-
-// Currency represents supported currencies
-type Currency string
-
-const (
-	CurrencyUSD Currency = "USD"
-	CurrencyEUR Currency = "EUR"
-	CurrencyGBP Currency = "GBP"
+	PaymentStatusRefunded  PaymentStatus = "refunded"
 )
 
 // Payment represents a payment in the system
@@ -39,12 +32,16 @@ type Payment struct {
 	MunicipalityID string        `json:"municipalityId" gorm:"column:municipality_id;not null;type:uuid;index:idx_payments_municipality_user,priority:1;index:idx_payments_municipality_service,priority:1" validate:"required,uuid"`
 	UserID         string        `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_payments_user;index:idx_payments_municipality_user,priority:2" validate:"required,uuid"`
 	ServiceType    ServiceType   `json:"serviceType" gorm:"column:service_type;not null;type:varchar(50);index:idx_payments_service_type;index:idx_payments_municipality_service,priority:2" validate:"required,service_type"`
-	Amount         float64       `json:"amount" gorm:"not null;type:decimal(10,2);index:idx_payments_amount" validate:"required,amount"`
-	Currency       Currency      `json:"currency" gorm:"type:varchar(3);default:USD" validate:"required,currency"`
+	Amount         Money         `json:"amount" gorm:"column:amount_minor;not null;type:bigint;index:idx_payments_amount" validate:"required,amount"`
+	Currency       Currency      `json:"currency" gorm:"type:varchar(3);default:THB" validate:"required,currency"`
 	Status         PaymentStatus `json:"status" gorm:"type:varchar(20);default:pending;index:idx_payments_status" validate:"required,payment_status"`
+	PaymentMethod  *string       `json:"paymentMethod,omitempty" gorm:"column:payment_method;size:50"`
+	Provider       *string       `json:"provider,omitempty" gorm:"size:50;uniqueIndex:idx_payments_provider_reference,priority:1"`
+	ProviderRef    *string       `json:"providerReference,omitempty" gorm:"column:provider_reference;size:255;uniqueIndex:idx_payments_provider_reference,priority:2"`
 	QRCode         *string       `json:"qrCode,omitempty" gorm:"column:qr_code;uniqueIndex:idx_payments_qr_code;size:255"`
 	DueDate        *time.Time    `json:"dueDate,omitempty" gorm:"column:due_date;index:idx_payments_due_date"`
 	PaidAt         *time.Time    `json:"paidAt,omitempty" gorm:"column:paid_at;index:idx_payments_paid_at"`
+	RefundStarted  *time.Time    `json:"refundStartedAt,omitempty" gorm:"column:refund_started_at"`
 	CreatedAt      time.Time     `json:"createdAt" gorm:"column:created_at;autoCreateTime;index:idx_payments_created_at"`
 	UpdatedAt      time.Time     `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
 
@@ -59,7 +56,7 @@ type PaymentTransaction struct {
 	ID              string                 `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()" validate:"required"`
 	PaymentID       string                 `json:"paymentId" gorm:"column:payment_id;not null;type:uuid;index:idx_payment_transactions_payment_id" validate:"required,uuid"`
 	Status          PaymentStatus          `json:"status" gorm:"not null;type:varchar(20);index:idx_payment_transactions_status" validate:"required,payment_status"`
-	TransactionData map[string]interface{} `json:"transactionData,omitempty" gorm:"column:transaction_data;type:jsonb"`
+	TransactionData map[string]interface{} `json:"transactionData,omitempty" gorm:"column:transaction_data;type:text;serializer:encrypted"`
 	CreatedAt       time.Time              `json:"createdAt" gorm:"column:created_at;autoCreateTime;index:idx_payment_transactions_created_at"`
 
 	// Relationships
@@ -72,11 +69,36 @@ func (p *Payment) BeforeCreate(tx *gorm.DB) error {
 		p.Status = PaymentStatusPending
 	}
 	if p.Currency == "" {
-		p.Currency = CurrencyUSD
+		p.Currency = p.Amount.Currency
+	}
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
 	}
 	return nil
 }
 
+// BeforeSave makes sure the amount is counted in the minor units of the payment's currency
+func (p *Payment) BeforeSave(tx *gorm.DB) error {
+	amount, err := p.Amount.In(p.Currency)
+	if err != nil {
+		return fmt.Errorf("invalid payment amount: %w", err)
+	}
+	p.Amount = amount
+	return nil
+}
+
+// AfterFind gives the amount the payment's currency
+func (p *Payment) AfterFind(tx *gorm.DB) error {
+	p.Amount.Currency = p.Currency
+	return nil
+}
+
+// IsProviderManaged reports whether a payment provider collects the payment, so that the
+// provider's results rather than staff decide its status
+func (p *Payment) IsProviderManaged() bool {
+	return p.Provider != nil && *p.Provider != ""
+}
+
 // TableName returns the table name for the Payment model
 func (Payment) TableName() string {
 	return "payments"
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Permission is a single action a user may be allowed to perform
+type Permission string
+
+// Municipality permissions are granted per municipality through the member's role
+const (
+	// PermissionPaymentsRead allows viewing the municipality's payments
+	PermissionPaymentsRead Permission = "payments.read"
+	// PermissionPaymentsUpdateStatus allows marking payments as completed, failed or expired,
+	// and refunding completed ones
+	PermissionPaymentsUpdateStatus Permission = "payments.update_status"
+	// PermissionResidentsRead allows viewing the municipality's residents
+	PermissionResidentsRead Permission = "residents.read"
+	// PermissionResidentsWrite allows registering and editing residents
+	PermissionResidentsWrite Permission = "residents.write"
+	// PermissionRoutesRead allows viewing waste collection routes
+	PermissionRoutesRead Permission = "routes.read"
+	// PermissionRoutesManage allows planning and assigning waste collection routes
+	PermissionRoutesManage Permission = "routes.manage"
+	// PermissionFacilitiesManage allows managing rentals, markets and parking
+	PermissionFacilitiesManage Permission = "facilities.manage"
+	// PermissionChatUse allows using staff chat
+	PermissionChatUse Permission = "chat.use"
+	// PermissionReportsExport allows exporting financial and service reports
+	PermissionReportsExport Permission = "reports.export"
+	// PermissionMunicipalityManage allows changing the municipality's settings
+	PermissionMunicipalityManage Permission = "municipality.manage"
+	// PermissionStaffManage allows managing the municipality's members and roles
+	PermissionStaffManage Permission = "staff.manage"
+)
+
+// Platform permissions apply across municipalities and are only granted by the global role
+const (
+	// PermissionMunicipalitiesManage allows creating and deleting municipalities
+	PermissionMunicipalitiesManage Permission = "municipalities.manage"
+	// PermissionUsersManage allows managing any user's role, sessions and lockouts
+	PermissionUsersManage Permission = "users.manage"
+	// PermissionAPIKeysManage allows issuing and revoking partner API keys
+	PermissionAPIKeysManage Permission = "api_keys.manage"
+	// PermissionAuditLogsRead allows searching, exporting and verifying the audit log
+	PermissionAuditLogsRead Permission = "audit_logs.read"
+	// PermissionDataRequestsManage allows reviewing residents' data export and erasure requests
+	PermissionDataRequestsManage Permission = "data_requests.manage"
+)
+
+// MunicipalPermissions lists every permission that can be granted within a municipality
+var MunicipalPermissions = []Permission{
+	PermissionPaymentsRead,
+	PermissionPaymentsUpdateStatus,
+	PermissionResidentsRead,
+	PermissionResidentsWrite,
+	PermissionRoutesRead,
+	PermissionRoutesManage,
+	PermissionFacilitiesManage,
+	PermissionChatUse,
+	PermissionReportsExport,
+	PermissionMunicipalityManage,
+	PermissionStaffManage,
+}
+
+// PlatformPermissions lists every permission that applies across municipalities
+var PlatformPermissions = []Permission{
+	PermissionMunicipalitiesManage,
+	PermissionUsersManage,
+	PermissionAPIKeysManage,
+	PermissionAuditLogsRead,
+	PermissionDataRequestsManage,
+}
+
+// IsMunicipalPermission reports whether permission can be granted within a municipality
+func IsMunicipalPermission(permission string) bool {
+	for _, p := range MunicipalPermissions {
+		if string(p) == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GlobalRolePermissions returns the permissions a global role grants everywhere.
+// Global admins hold every permission; other users only get permissions through
+// their municipality memberships.
+func GlobalRolePermissions(role UserRole) []Permission {
+	if role != UserRoleAdmin {
+		return nil
+	}
+
+	permissions := make([]Permission, 0, len(MunicipalPermissions)+len(PlatformPermissions))
+	permissions = append(permissions, MunicipalPermissions...)
+	return append(permissions, PlatformPermissions...)
+}
+
+// Built-in municipal role keys, matching the staff roles of the municipal dashboard
+const (
+	MunicipalRoleResident  = "resident"
+	MunicipalRoleAdmin     = "admin"
+	MunicipalRoleFinance   = "finance"
+	MunicipalRoleServices  = "services"
+	MunicipalRoleGeneral   = "general"
+	MunicipalRoleCollector = "collector"
+)
+
+// BuiltInMunicipalRoles are available in every municipality and cannot be changed
+var BuiltInMunicipalRoles = []MunicipalRole{
+	{
+		Key:         MunicipalRoleResident,
+		Name:        "Resident",
+		Description: "Member of the municipality without staff access",
+		Permissions: PermissionSet{},
+	},
+	{
+		Key:         MunicipalRoleAdmin,
+		Name:        "Admin",
+		Description: "Full access to the municipality, including settings and staff",
+		Permissions: permissionSet(MunicipalPermissions...),
+	},
+	{
+		Key:         MunicipalRoleFinance,
+		Name:        "Finance",
+		Description: "Payments, receivables and financial reports",
+		Permissions: permissionSet(PermissionPaymentsRead, PermissionPaymentsUpdateStatus, PermissionResidentsRead, PermissionReportsExport),
+	},
+	{
+		Key:         MunicipalRoleServices,
+		Name:        "Services",
+		Description: "Residents, collection routes and municipal facilities",
+		Permissions: permissionSet(PermissionResidentsRead, PermissionResidentsWrite, PermissionRoutesRead, PermissionRoutesManage, PermissionFacilitiesManage),
+	},
+	{
+		Key:         MunicipalRoleGeneral,
+		Name:        "General",
+		Description: "Read-only access to payments and residents",
+		Permissions: permissionSet(PermissionPaymentsRead, PermissionResidentsRead),
+	},
+	{
+		Key:         MunicipalRoleCollector,
+		Name:        "Collector",
+		Description: "Waste collection crews: routes and staff chat",
+		Permissions: permissionSet(PermissionRoutesRead, PermissionChatUse),
+	},
+}
+
+// BuiltInMunicipalRole returns the built-in role with the given key
+func BuiltInMunicipalRole(key string) (*MunicipalRole, bool) {
+	for i := range BuiltInMunicipalRoles {
+		if BuiltInMunicipalRoles[i].Key == key {
+			role := BuiltInMunicipalRoles[i]
+			role.BuiltIn = true
+			return &role, true
+		}
+	}
+	return nil, false
+}
+
+// municipalRoleKeyPattern restricts role keys to lowercase slugs
+var municipalRoleKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// IsValidMunicipalRoleKey reports whether key can be used for a custom role
+func IsValidMunicipalRoleKey(key string) bool {
+	return municipalRoleKeyPattern.MatchString(key)
+}
+
+// PermissionSet is the list of permissions granted by a role, stored as JSON
+type PermissionSet []string
+
+// permissionSet builds a PermissionSet from permissions
+func permissionSet(permissions ...Permission) PermissionSet {
+	set := make(PermissionSet, len(permissions))
+	for i, p := range permissions {
+		set[i] = string(p)
+	}
+	return set
+}
+
+// Has reports whether the set contains the permission
+func (s PermissionSet) Has(permission Permission) bool {
+	for _, p := range s {
+		if p == string(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements the driver.Valuer interface for GORM
+func (s PermissionSet) Value() (driver.Value, error) {
+	if s == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface for GORM
+func (s *PermissionSet) Scan(value interface{}) error {
+	if value == nil {
+		*s = PermissionSet{}
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, s)
+}
+
+// MunicipalRole is a named set of permissions that can be given to members of a municipality.
+// Built-in roles exist in every municipality; municipalities may define their own as well.
+type MunicipalRole struct {
+	ID             string        `json:"id,omitempty" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	MunicipalityID string        `json:"municipalityId,omitempty" gorm:"column:municipality_id;not null;type:uuid;uniqueIndex:idx_municipal_roles_municipality_key"`
+	Key            string        `json:"key" gorm:"not null;size:50;uniqueIndex:idx_municipal_roles_municipality_key"`
+	Name           string        `json:"name" gorm:"not null;size:100"`
+	Description    string        `json:"description" gorm:"size:255"`
+	Permissions    PermissionSet `json:"permissions" gorm:"type:jsonb;not null"`
+	BuiltIn        bool          `json:"builtIn" gorm:"-"`
+	CreatedAt      time.Time     `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time     `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName returns the table name for the MunicipalRole model
+func (MunicipalRole) TableName() string {
+	return "municipal_roles"
+}
@@ -11,7 +11,7 @@ import (
 type QRCodeData struct {
 	PaymentID      string      `json:"paymentId" validate:"required,uuid"`
 	MunicipalityID string      `json:"municipalityId" validate:"required,uuid"`
-	Amount         float64     `json:"amount" validate:"required,amount"`
+	Amount         Money       `json:"amount" validate:"required,amount"`
 	Currency       Currency    `json:"currency" validate:"required,currency"`
 	ServiceType    ServiceType `json:"serviceType" validate:"required,service_type"`
 	ExpiresAt      time.Time   `json:"expiresAt" validate:"required"`
@@ -36,6 +36,24 @@ func (qd *QRCodeData) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, qd)
 }
 
+// UnmarshalJSON decodes QR code data and gives the amount its currency
+func (qd *QRCodeData) UnmarshalJSON(data []byte) error {
+	type qrCodeData QRCodeData
+	var decoded qrCodeData
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	amount, err := decoded.Amount.In(decoded.Currency)
+	if err != nil {
+		return err
+	}
+	decoded.Amount = amount
+
+	*qd = QRCodeData(decoded)
+	return nil
+}
+
 // QRCode represents a QR code in the system
 type QRCode struct {
 	Code     string     `json:"code" validate:"required"`
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshTokenRevocationReason describes why a refresh token stopped being usable
+type RefreshTokenRevocationReason string
+
+const (
+	RefreshTokenRevokedRotated        RefreshTokenRevocationReason = "rotated"
+	RefreshTokenRevokedLogout         RefreshTokenRevocationReason = "logout"
+	RefreshTokenRevokedReuseDetected  RefreshTokenRevocationReason = "reuse_detected"
+	RefreshTokenRevokedPasswordChange RefreshTokenRevocationReason = "password_changed"
+	RefreshTokenRevokedSignOutAll     RefreshTokenRevocationReason = "sign_out_all"
+	RefreshTokenRevokedSessionEnded   RefreshTokenRevocationReason = "session_ended"
+)
+
+// RefreshToken represents an issued refresh token stored server-side.
+// Only a SHA-256 hash of the token is kept. Every token belongs to a family
+// that starts at login; each refresh rotates the token within the same family.
+type RefreshToken struct {
+	ID            string                        `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID        string                        `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_refresh_tokens_user_id"`
+	FamilyID      string                        `json:"familyId" gorm:"column:family_id;not null;type:uuid;index:idx_refresh_tokens_family_id"`
+	TokenHash     string                        `json:"-" gorm:"column:token_hash;not null;size:64;uniqueIndex:idx_refresh_tokens_token_hash"`
+	ExpiresAt     time.Time                     `json:"expiresAt" gorm:"column:expires_at;not null;index:idx_refresh_tokens_expires_at"`
+	RevokedAt     *time.Time                    `json:"revokedAt,omitempty" gorm:"column:revoked_at"`
+	RevokedReason *RefreshTokenRevocationReason `json:"revokedReason,omitempty" gorm:"column:revoked_reason;type:varchar(20)"`
+	CreatedAt     time.Time                     `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// IsRevoked reports whether the token has been revoked for any reason
+func (rt *RefreshToken) IsRevoked() bool {
+	return rt.RevokedAt != nil
+}
+
+// IsExpired reports whether the token has passed its expiry time
+func (rt *RefreshToken) IsExpired() bool {
+	return time.Now().After(rt.ExpiresAt)
+}
+
+// TableName returns the table name for the RefreshToken model
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
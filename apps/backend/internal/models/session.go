@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a server-side record of a login on one device. Its ID is the family ID of
+// the refresh tokens issued for the login and the "sid" claim of its access tokens, so
+// revoking a session ends both. Last-seen details are updated on refresh and on use.
+type Session struct {
+	ID            string                        `json:"id" gorm:"primaryKey;type:uuid"`
+	UserID        string                        `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_sessions_user_id"`
+	Device        string                        `json:"device" gorm:"size:100"`
+	UserAgent     string                        `json:"userAgent" gorm:"column:user_agent;size:512"`
+	IPAddress     string                        `json:"ipAddress" gorm:"column:ip_address;size:45"`
+	LastSeenAt    time.Time                     `json:"lastSeenAt" gorm:"column:last_seen_at;not null"`
+	ExpiresAt     time.Time                     `json:"expiresAt" gorm:"column:expires_at;not null"`
+	RevokedAt     *time.Time                    `json:"revokedAt,omitempty" gorm:"column:revoked_at"`
+	RevokedReason *RefreshTokenRevocationReason `json:"revokedReason,omitempty" gorm:"column:revoked_reason;type:varchar(20)"`
+	CreatedAt     time.Time                     `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt     time.Time                     `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// IsActive reports whether the session can still be refreshed
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// TableName returns the table name for the Session model
+func (Session) TableName() string {
+	return "sessions"
+}
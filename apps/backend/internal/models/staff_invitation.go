@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// StaffInvitation invites someone by email to join a municipality with a given role.
+// Only a SHA-256 hash of the token is stored; the raw token is emailed to the invitee.
+type StaffInvitation struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	MunicipalityID string     `json:"municipalityId" gorm:"column:municipality_id;not null;type:uuid;index:idx_staff_invitations_municipality_id"`
+	Email          string     `json:"email" gorm:"not null;size:255;index:idx_staff_invitations_email"`
+	Role           string     `json:"role" gorm:"not null;size:50"`
+	TokenHash      string     `json:"-" gorm:"column:token_hash;not null;size:64;uniqueIndex:idx_staff_invitations_token_hash"`
+	InvitedByID    *string    `json:"invitedById,omitempty" gorm:"column:invited_by_id;type:uuid"`
+	ExpiresAt      time.Time  `json:"expiresAt" gorm:"column:expires_at;not null"`
+	AcceptedAt     *time.Time `json:"acceptedAt,omitempty" gorm:"column:accepted_at"`
+	AcceptedUserID *string    `json:"acceptedUserId,omitempty" gorm:"column:accepted_user_id;type:uuid"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty" gorm:"column:revoked_at"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+
+	// Relationships
+	Municipality Municipality `json:"municipality,omitempty" gorm:"foreignKey:MunicipalityID"`
+}
+
+// IsOpen reports whether the invitation has been neither accepted nor revoked
+func (i *StaffInvitation) IsOpen() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil
+}
+
+// IsExpired reports whether the invitation link has expired
+func (i *StaffInvitation) IsExpired() bool {
+	return !time.Now().Before(i.ExpiresAt)
+}
+
+// IsUsable reports whether the invitation can still be accepted
+func (i *StaffInvitation) IsUsable() bool {
+	return i.IsOpen() && !i.IsExpired()
+}
+
+// TableName returns the table name for the StaffInvitation model
+func (StaffInvitation) TableName() string {
+	return "staff_invitations"
+}
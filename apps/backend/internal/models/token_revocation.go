@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken represents an access token revoked before its expiry, keyed by its jti claim
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey;column:jti;size:64"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"column:expires_at;not null;index:idx_revoked_tokens_expires_at"`
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName returns the table name for the RevokedToken model
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// UserTokenCutoff holds the per-user "tokens valid after" timestamp.
+// Tokens issued to the user before ValidAfter are rejected.
+type UserTokenCutoff struct {
+	UserID     string    `json:"userId" gorm:"primaryKey;column:user_id;type:uuid"`
+	ValidAfter time.Time `json:"validAfter" gorm:"column:valid_after;not null"`
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// TableName returns the table name for the UserTokenCutoff model
+func (UserTokenCutoff) TableName() string {
+	return "user_token_cutoffs"
+}
@@ -1,9 +1,11 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"municollect/internal/encryption"
 )
 
 // UserRole represents the role of a user in the system
@@ -18,11 +20,21 @@ const (
 // User represents a user in the system
 type User struct {
 	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()" validate:"required"`
-	Email     string    `json:"email" gorm:"uniqueIndex:idx_users_email;not null;size:255" validate:"required,email"`
+	Email     string    `json:"email" gorm:"not null;type:text;serializer:encrypted" validate:"required,email"`
+	EmailIndex string   `json:"-" gorm:"column:email_index;size:64;uniqueIndex:idx_users_email_index"`
 	FirstName string    `json:"firstName" gorm:"column:first_name;not null;size:100;index:idx_users_name" validate:"required,min=1,max=100"`
 	LastName  string    `json:"lastName" gorm:"column:last_name;not null;size:100;index:idx_users_name" validate:"required,min=1,max=100"`
-	Phone     *string   `json:"phone,omitempty" gorm:"size:20;index:idx_users_phone" validate:"omitempty,phone"`
+	Phone     *string   `json:"phone,omitempty" gorm:"type:text;serializer:encrypted" validate:"omitempty,phone"`
+	PhoneIndex *string  `json:"-" gorm:"column:phone_index;size:64;index:idx_users_phone_index"`
+	NationalID *string  `json:"-" gorm:"column:national_id;type:text;serializer:encrypted" validate:"omitempty,thai_national_id"`
+	NationalIDIndex *string `json:"-" gorm:"column:national_id_index;size:64;uniqueIndex:idx_users_national_id_index"`
+	MaskedNationalID string `json:"maskedNationalId,omitempty" gorm:"-"`
 	Role      UserRole  `json:"role" gorm:"type:varchar(20);default:resident;index:idx_users_role" validate:"required,user_role"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty" gorm:"column:email_verified_at"`
+	PhoneVerifiedAt *time.Time `json:"phoneVerifiedAt,omitempty" gorm:"column:phone_verified_at"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty" gorm:"column:disabled_at;index:idx_users_disabled_at"`
+	PasswordResetRequired bool `json:"passwordResetRequired" gorm:"column:password_reset_required;not null;default:false"`
+	ErasedAt *time.Time `json:"erasedAt,omitempty" gorm:"column:erased_at"`
 	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime;index:idx_users_created_at"`
 	UpdatedAt time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
 
@@ -47,6 +59,79 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// BeforeSave keeps the blind indexes in step with the encrypted email address, phone number
+// and national ID
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	u.SetBlindIndexes()
+	return nil
+}
+
+// AfterFind fills in the masked national ID shown in place of the full one
+func (u *User) AfterFind(tx *gorm.DB) error {
+	u.setMaskedNationalID()
+	return nil
+}
+
+// SetBlindIndexes computes the blind indexes of the user's email address, phone number and national ID
+func (u *User) SetBlindIndexes() {
+	u.EmailIndex = EmailIndex(u.Email)
+	u.PhoneIndex = nil
+	if u.Phone != nil {
+		index := PhoneIndex(*u.Phone)
+		u.PhoneIndex = &index
+	}
+	u.NationalIDIndex = nil
+	if u.NationalID != nil {
+		index := NationalIDIndex(*u.NationalID)
+		u.NationalIDIndex = &index
+	}
+	u.setMaskedNationalID()
+}
+
+// setMaskedNationalID sets MaskedNationalID from the national ID
+func (u *User) setMaskedNationalID() {
+	u.MaskedNationalID = ""
+	if u.NationalID != nil {
+		u.MaskedNationalID = MaskThaiNationalID(*u.NationalID)
+	}
+}
+
+// EmailIndex returns the blind index users are looked up by email address with.
+// Addresses are compared without regard to case or surrounding spaces.
+func EmailIndex(email string) string {
+	return encryption.MustDefault().BlindIndex("users.email", strings.ToLower(strings.TrimSpace(email)))
+}
+
+// PhoneIndex returns the blind index users are looked up by phone number with
+func PhoneIndex(phone string) string {
+	return encryption.MustDefault().BlindIndex("users.phone", strings.TrimSpace(phone))
+}
+
+// NationalIDIndex returns the blind index users are looked up by national ID with
+func NationalIDIndex(nationalID string) string {
+	return encryption.MustDefault().BlindIndex("users.national_id", NormalizeThaiNationalID(nationalID))
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsPhoneVerified reports whether the user has confirmed their phone number
+func (u *User) IsPhoneVerified() bool {
+	return u.Phone != nil && u.PhoneVerifiedAt != nil
+}
+
+// IsDisabled reports whether an admin has disabled the account
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// IsErased reports whether the user's personal data was erased on their request
+func (u *User) IsErased() bool {
+	return u.ErasedAt != nil
+}
+
 // TableName returns the table name for the User model
 func (User) TableName() string {
 	return "users"
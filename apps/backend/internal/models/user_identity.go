@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect identity provider.
+// The (issuer, subject) pair identifies the account; the email is kept for reference only.
+type UserIdentity struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID      string     `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_user_identities_user_id"`
+	Issuer      string     `json:"issuer" gorm:"not null;size:255;uniqueIndex:idx_user_identities_issuer_subject"`
+	Subject     string     `json:"subject" gorm:"not null;size:255;uniqueIndex:idx_user_identities_issuer_subject"`
+	Email       string     `json:"email" gorm:"size:255"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty" gorm:"column:last_login_at"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// TableName returns the table name for the UserIdentity model
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
@@ -6,11 +6,14 @@ import (
 	"gorm.io/gorm"
 )
 
-// UserMunicipality represents the association between users and municipalities
+// UserMunicipality represents the association between users and municipalities.
+// Role is the key of the user's MunicipalRole within that municipality; residents
+// who associate themselves are members with the resident role.
 type UserMunicipality struct {
 	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	UserID         string    `json:"userId" gorm:"not null;type:uuid;index:idx_user_municipality_user"`
 	MunicipalityID string    `json:"municipalityId" gorm:"not null;type:uuid;index:idx_user_municipality_municipality"`
+	Role           string    `json:"role" gorm:"size:50;not null;default:resident"`
 	CreatedAt      time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt      time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
 
@@ -21,6 +24,10 @@ type UserMunicipality struct {
 
 // BeforeCreate hook to validate the association
 func (um *UserMunicipality) BeforeCreate(tx *gorm.DB) error {
+	if um.Role == "" {
+		um.Role = MunicipalRoleResident
+	}
+	
 	// Check if association already exists
 	var existing UserMunicipality
 	if err := tx.Where("user_id = ? AND municipality_id = ?", um.UserID, um.MunicipalityID).First(&existing).Error; err == nil {
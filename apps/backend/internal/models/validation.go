@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
 	"time"
@@ -18,13 +19,8 @@ func ValidatePaymentConfig(config *PaymentConfig) error {
 	}
 
 	// Validate currency
-	validCurrencies := map[string]bool{
-		"USD": true,
-		"EUR": true,
-		"GBP": true,
-	}
-	if !validCurrencies[config.Currency] {
-		return fmt.Errorf("invalid currency: %s", config.Currency)
+	if err := ValidateCurrency(Currency(config.Currency)); err != nil {
+		return err
 	}
 
 	// Validate payment methods
@@ -52,13 +48,28 @@ func ValidatePaymentConfig(config *PaymentConfig) error {
 	}
 
 	// Validate waste management fee if provided
-	if config.WasteManagementFee != nil && *config.WasteManagementFee < 0 {
+	if config.WasteManagementFee != nil && config.WasteManagementFee.Minor < 0 {
 		return fmt.Errorf("waste management fee cannot be negative")
 	}
 
 	return nil
 }
 
+// ValidateMunicipalityCurrency validates the municipality's default currency and checks that
+// its payment configuration uses the same one
+func ValidateMunicipalityCurrency(municipality *Municipality) error {
+	if err := ValidateCurrency(municipality.DefaultCurrency); err != nil {
+		return err
+	}
+
+	if municipality.PaymentConfig != nil && Currency(municipality.PaymentConfig.Currency) != municipality.DefaultCurrency {
+		return fmt.Errorf("payment configuration currency %s does not match municipality currency %s",
+			municipality.PaymentConfig.Currency, municipality.DefaultCurrency)
+	}
+
+	return nil
+}
+
 // ValidateUserRole validates user role
 func ValidateUserRole(role UserRole) error {
 	validRoles := map[UserRole]bool{
@@ -95,6 +106,7 @@ func ValidatePaymentStatus(status PaymentStatus) error {
 		PaymentStatusCompleted: true,
 		PaymentStatusFailed:    true,
 		PaymentStatusExpired:   true,
+		PaymentStatusRefunded:  true,
 	}
 
 	if !validStatuses[status] {
@@ -104,15 +116,9 @@ func ValidatePaymentStatus(status PaymentStatus) error {
 	return nil
 }
 
-// ValidateCurrency validates currency
+// ValidateCurrency validates that the currency is in the currency registry
 func ValidateCurrency(currency Currency) error {
-	validCurrencies := map[Currency]bool{
-		CurrencyUSD: true,
-		CurrencyEUR: true,
-		CurrencyGBP: true,
-	}
-
-	if !validCurrencies[currency] {
+	if _, ok := LookupCurrency(currency); !ok {
 		return fmt.Errorf("invalid currency: %s", currency)
 	}
 
@@ -126,6 +132,7 @@ func ValidateNotificationType(notificationType NotificationType) error {
 		NotificationTypePaymentConfirmation: true,
 		NotificationTypePaymentFailed:      true,
 		NotificationTypeSystemUpdate:       true,
+		NotificationTypeSecurityAlert:      true,
 	}
 
 	if !validTypes[notificationType] {
@@ -169,6 +176,49 @@ func ValidatePhoneNumber(phone string) error {
 	return nil
 }
 
+// NormalizeThaiNationalID removes the spaces and dashes of the printed 1-2345-67890-12-1 format
+func NormalizeThaiNationalID(id string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(id))
+}
+
+// ValidateThaiNationalID validates a 13-digit Thai citizen ID. The last digit is a mod-11
+// check digit: the first 12 digits are weighted 13 down to 2, and the check digit is
+// (11 - sum mod 11) mod 10.
+func ValidateThaiNationalID(id string) error {
+	id = NormalizeThaiNationalID(id)
+	if len(id) != 13 {
+		return fmt.Errorf("national ID must have 13 digits")
+	}
+
+	sum := 0
+	for i, r := range id {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("national ID must only contain digits")
+		}
+		if i < 12 {
+			sum += int(r-'0') * (13 - i)
+		}
+	}
+	if id[0] == '0' {
+		return fmt.Errorf("national ID cannot start with 0")
+	}
+	if (11-sum%11)%10 != int(id[12]-'0') {
+		return fmt.Errorf("invalid national ID check digit")
+	}
+
+	return nil
+}
+
+// MaskThaiNationalID returns the national ID with all but its last four digits hidden,
+// in the printed format: X-XXXX-XXXX0-12-1
+func MaskThaiNationalID(id string) string {
+	id = NormalizeThaiNationalID(id)
+	if len(id) != 13 {
+		return "X-XXXX-XXXXX-XX-X"
+	}
+	return "X-XXXX-XXXX" + id[9:10] + "-" + id[10:12] + "-" + id[12:]
+}
+
 // ValidateEmail validates email format
 func ValidateEmail(email string) error {
 	if email == "" {
@@ -198,14 +248,19 @@ func ValidateMunicipalityCode(code string) error {
 	return nil
 }
 
-// ValidateAmount validates payment amount
-func ValidateAmount(amount float64) error {
-	if amount <= 0 {
+// maxAmountUnits is the number of whole currency units payment amounts must stay below
+const maxAmountUnits = 1000000
+
+// ValidateAmount validates payment amount. It must be positive and less than a million units
+// of its currency, or 999,999.99 THB.
+func ValidateAmount(amount Money) error {
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be greater than 0")
 	}
 
-	if amount > 999999.99 {
-		return fmt.Errorf("amount cannot exceed 999,999.99")
+	limit := maxAmountUnits * pow10(amount.minorUnits()).Int64()
+	if amount.Minor >= limit {
+		return fmt.Errorf("amount cannot exceed %s", NewMoney(limit-1, amount.Currency).Decimal())
 	}
 
 	return nil
@@ -257,12 +312,21 @@ func RegisterCustomValidators(v *validator.Validate) {
 		return ValidatePhoneNumber(fl.Field().String()) == nil
 	})
 
+	v.RegisterValidation("thai_national_id", func(fl validator.FieldLevel) bool {
+		return ValidateThaiNationalID(fl.Field().String()) == nil
+	})
+
 	v.RegisterValidation("municipality_code", func(fl validator.FieldLevel) bool {
 		return ValidateMunicipalityCode(fl.Field().String()) == nil
 	})
 
+	// Money is validated as its minor units, so required and comparisons such as gt=0 work
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(Money).Minor
+	}, Money{})
+
 	v.RegisterValidation("amount", func(fl validator.FieldLevel) bool {
-		return ValidateAmount(fl.Field().Float()) == nil
+		return ValidateAmount(NewMoney(fl.Field().Int(), "")) == nil
 	})
 
 	v.RegisterValidation("user_role", func(fl validator.FieldLevel) bool {
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// VerificationChannel represents how a verification code is delivered
+type VerificationChannel string
+
+const (
+	VerificationChannelEmail VerificationChannel = "email"
+	VerificationChannelPhone VerificationChannel = "phone"
+)
+
+// VerificationCode represents a one-time code sent to prove ownership of an email address or phone number.
+// Only a SHA-256 hash of the code is stored.
+type VerificationCode struct {
+	ID         string              `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID     string              `json:"userId" gorm:"column:user_id;not null;type:uuid;index:idx_verification_codes_user_channel"`
+	Channel    VerificationChannel `json:"channel" gorm:"type:varchar(10);not null;index:idx_verification_codes_user_channel"`
+	Target     string              `json:"target" gorm:"not null;size:255"`
+	CodeHash   string              `json:"-" gorm:"column:code_hash;not null;size:64"`
+	Attempts   int                 `json:"attempts" gorm:"not null;default:0"`
+	ExpiresAt  time.Time           `json:"expiresAt" gorm:"column:expires_at;not null"`
+	ConsumedAt *time.Time          `json:"consumedAt,omitempty" gorm:"column:consumed_at"`
+	CreatedAt  time.Time           `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID;references:ID"`
+}
+
+// IsUsable reports whether the code is unconsumed and unexpired
+func (v *VerificationCode) IsUsable() bool {
+	return v.ConsumedAt == nil && time.Now().Before(v.ExpiresAt)
+}
+
+// TableName returns the table name for the VerificationCode model
+func (VerificationCode) TableName() string {
+	return "verification_codes"
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// WebhookEventStatus tracks an inbound webhook through the retryable inbox
+type WebhookEventStatus string
+
+const (
+	// WebhookEventPending events are waiting to be processed, or to be retried after a failure
+	WebhookEventPending WebhookEventStatus = "pending"
+	// WebhookEventProcessed events have been applied to their payment
+	WebhookEventProcessed WebhookEventStatus = "processed"
+	// WebhookEventIgnored events could not change their payment, such as a failure reported
+	// for a payment that has already completed
+	WebhookEventIgnored WebhookEventStatus = "ignored"
+	// WebhookEventDead events failed too many times and are kept for review
+	WebhookEventDead WebhookEventStatus = "dead"
+)
+
+// WebhookEvent is a payment notification received from a payment provider or bank. The raw,
+// signature-checked payload is stored before it is processed, so an event that cannot be
+// applied right away is retried instead of lost. Events are unique per provider and event ID.
+type WebhookEvent struct {
+	ID            string             `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Provider      string             `json:"provider" gorm:"not null;size:50;uniqueIndex:idx_webhook_events_provider_event,priority:1"`
+	EventID       string             `json:"eventId" gorm:"column:event_id;not null;size:255;uniqueIndex:idx_webhook_events_provider_event,priority:2"`
+	Reference     string             `json:"reference" gorm:"not null;size:255"`
+	Signature     string             `json:"-" gorm:"not null;size:255"`
+	Payload       string             `json:"-" gorm:"not null;type:text;serializer:encrypted"`
+	Status        WebhookEventStatus `json:"status" gorm:"not null;type:varchar(20);default:pending;index:idx_webhook_events_status_next_attempt,priority:1"`
+	Attempts      int                `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time          `json:"nextAttemptAt" gorm:"column:next_attempt_at;not null;index:idx_webhook_events_status_next_attempt,priority:2"`
+	LastError     string             `json:"lastError,omitempty" gorm:"column:last_error;size:500"`
+	PaymentID     *string            `json:"paymentId,omitempty" gorm:"column:payment_id;type:uuid;index:idx_webhook_events_payment_id"`
+	ProcessedAt   *time.Time         `json:"processedAt,omitempty" gorm:"column:processed_at"`
+	ReceivedAt    time.Time          `json:"receivedAt" gorm:"column:received_at;autoCreateTime"`
+}
+
+// TableName returns the table name for the WebhookEvent model
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
@@ -0,0 +1,347 @@
+// Package oidcmock implements a minimal OpenID Connect identity provider for local
+// development and tests. It supports discovery, the authorization code flow with PKCE
+// (S256 only) and RS256-signed ID tokens. Users sign in without a password: the
+// authorize endpoint picks the account named by login_hint or lists the accounts.
+package oidcmock
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// codeLifetime is how long an authorization code can be redeemed
+const codeLifetime = time.Minute
+
+// User is an account at the mock identity provider
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Groups        []string
+}
+
+// authCode is an issued, not yet redeemed authorization code
+type authCode struct {
+	user        User
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+	expiresAt   time.Time
+}
+
+// Provider is the mock identity provider
+type Provider struct {
+	clientID string
+	key      *rsa.PrivateKey
+	kid      string
+
+	mu     sync.Mutex
+	issuer string
+	users  []User
+	codes  map[string]*authCode
+}
+
+// New creates a mock identity provider that accepts the given client ID
+func New(clientID string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	return &Provider{
+		clientID: clientID,
+		key:      key,
+		kid:      "mock-" + randomString(6),
+		codes:    make(map[string]*authCode),
+	}, nil
+}
+
+// SetIssuer sets the issuer URL, which must be the base URL the handler is served at
+func (p *Provider) SetIssuer(issuer string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.issuer = strings.TrimSuffix(issuer, "/")
+}
+
+// Issuer returns the issuer URL
+func (p *Provider) Issuer() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.issuer
+}
+
+// AddUser adds an account
+func (p *Provider) AddUser(user User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.users = append(p.users, user)
+}
+
+// Handler returns the HTTP handler serving the provider endpoints
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	return mux
+}
+
+// SignIDToken signs arbitrary claims with the provider key, for tests that need malformed tokens
+func (p *Provider) SignIDToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+	return token.SignedString(p.key)
+}
+
+// discovery serves the provider metadata
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.Issuer()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile", "groups"},
+	})
+}
+
+// jwks serves the public signing key
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": p.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// accountsPage lists the accounts when no login_hint is given
+var accountsPage = template.Must(template.New("accounts").Parse(`<!DOCTYPE html>
+<html><head><title>Mock identity provider</title></head><body>
+<h1>Sign in as</h1>
+<ul>{{range .}}<li><a href="{{.URL}}">{{.Email}}</a> {{.Groups}}</li>{{end}}</ul>
+</body></html>`))
+
+// authorize issues an authorization code for the chosen account
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("response_type") != "code" || query.Get("client_id") != p.clientID {
+		http.Error(w, "unsupported response_type or unknown client_id", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	hint := query.Get("login_hint")
+	if hint == "" {
+		p.listAccounts(w, r)
+		return
+	}
+
+	user, ok := p.findUser(hint)
+	if !ok {
+		http.Error(w, "unknown account", http.StatusNotFound)
+		return
+	}
+
+	code := randomString(24)
+	p.mu.Lock()
+	p.codes[code] = &authCode{
+		user:        user,
+		clientID:    p.clientID,
+		redirectURI: redirectURI.String(),
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+		expiresAt:   time.Now().Add(codeLifetime),
+	}
+	p.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	if state := query.Get("state"); state != "" {
+		params.Set("state", state)
+	}
+	redirectURI.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// listAccounts renders a page with one sign-in link per account
+func (p *Provider) listAccounts(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	users := append([]User(nil), p.users...)
+	p.mu.Unlock()
+
+	type entry struct {
+		Email  string
+		Groups string
+		URL    string
+	}
+	entries := make([]entry, 0, len(users))
+	for _, user := range users {
+		query := r.URL.Query()
+		query.Set("login_hint", user.Email)
+		entries = append(entries, entry{
+			Email:  user.Email,
+			Groups: strings.Join(user.Groups, ", "),
+			URL:    p.Issuer() + "/authorize?" + query.Encode(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := accountsPage.Execute(w, entries); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// token redeems an authorization code for an ID token
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", "malformed form")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "")
+		return
+	}
+
+	clientID := r.PostForm.Get("client_id")
+	if basicID, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(basicID)
+	}
+
+	// Codes are single use, even when redemption fails
+	p.mu.Lock()
+	code, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	switch {
+	case !ok || time.Now().After(code.expiresAt):
+		tokenError(w, "invalid_grant", "unknown or expired code")
+		return
+	case clientID != code.clientID:
+		tokenError(w, "invalid_client", "")
+		return
+	case r.PostForm.Get("redirect_uri") != code.redirectURI:
+		tokenError(w, "invalid_grant", "redirect_uri mismatch")
+		return
+	case pkceChallenge(r.PostForm.Get("code_verifier")) != code.challenge:
+		tokenError(w, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.Issuer(),
+		"sub":            code.user.Subject,
+		"aud":            code.clientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"email":          code.user.Email,
+		"email_verified": code.user.EmailVerified,
+		"given_name":     code.user.GivenName,
+		"family_name":    code.user.FamilyName,
+		"name":           strings.TrimSpace(code.user.GivenName + " " + code.user.FamilyName),
+		"groups":         code.user.Groups,
+	}
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+
+	idToken, err := p.SignIDToken(claims)
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(24),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// findUser returns the account with the given email or subject
+func (p *Provider) findUser(hint string) (User, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, user := range p.users {
+		if strings.EqualFold(user.Email, hint) || user.Subject == hint {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+// pkceChallenge returns the S256 code challenge for a verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// tokenError writes an OAuth 2.0 error response
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// randomString returns a URL-safe random string built from n random bytes
+func randomString(n int) string {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
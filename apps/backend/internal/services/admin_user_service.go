@@ -0,0 +1,253 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/models"
+)
+
+// Admin user management errors
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrUserAlreadyDisabled  = errors.New("user is already disabled")
+	ErrUserNotDisabled      = errors.New("user is not disabled")
+	ErrCannotModifySelf     = errors.New("admins cannot disable or demote themselves")
+	ErrAccountDisabled      = errors.New("account is disabled")
+	ErrPasswordResetPending = errors.New("password reset required")
+	ErrUserErased           = errors.New("user's personal data has been erased")
+)
+
+// AdminUserService carries out admin actions on user accounts. Every change is written
+// to the audit log in the same transaction as the change itself.
+type AdminUserService struct {
+	db                   *gorm.DB
+	audit                *AuditService
+	revocationService    *TokenRevocationService
+	passwordResetService *PasswordResetService
+	loginProtection      *LoginProtectionService
+	now                  func() time.Time
+}
+
+// NewAdminUserService creates a new admin user service
+func NewAdminUserService(db *gorm.DB, audit *AuditService, revocationService *TokenRevocationService, passwordResetService *PasswordResetService, loginProtection *LoginProtectionService) *AdminUserService {
+	return &AdminUserService{
+		db:                   db,
+		audit:                audit,
+		revocationService:    revocationService,
+		passwordResetService: passwordResetService,
+		loginProtection:      loginProtection,
+		now:                  time.Now,
+	}
+}
+
+// CheckCanSignIn reports why a user may not be issued tokens, if anything
+func CheckCanSignIn(user *models.User) error {
+	if user.IsDisabled() {
+		return ErrAccountDisabled
+	}
+	if user.PasswordResetRequired {
+		return ErrPasswordResetPending
+	}
+	return nil
+}
+
+// ChangeRole changes a user's global role and invalidates the access tokens carrying the old role
+func (s *AdminUserService) ChangeRole(actor AuditActor, userID string, role models.UserRole) (*models.User, error) {
+	if err := models.ValidateUserRole(role); err != nil {
+		return nil, err
+	}
+	if actor.UserID == userID && role != models.UserRoleAdmin {
+		return nil, ErrCannotModifySelf
+	}
+
+	user, err := s.update(actor, userID, func(tx *gorm.DB, user *models.User) (*AuditEvent, error) {
+		previous := user.Role
+		if err := tx.Model(user).Update("role", role).Error; err != nil {
+			return nil, fmt.Errorf("failed to update user role: %w", err)
+		}
+		user.Role = role
+
+		return &AuditEvent{
+			Action:  models.AuditActionUserRoleChanged,
+			Details: models.AuditDetails{"from": previous, "to": role},
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued before the change still carry the old role
+	if err := s.revocationService.InvalidateAccessTokens(user.ID); err != nil {
+		return nil, fmt.Errorf("failed to revoke existing tokens: %w", err)
+	}
+
+	return user, nil
+}
+
+// Disable blocks the user from signing in and signs them out of every device
+func (s *AdminUserService) Disable(actor AuditActor, userID, reason string) (*models.User, error) {
+	if actor.UserID == userID {
+		return nil, ErrCannotModifySelf
+	}
+
+	user, err := s.update(actor, userID, func(tx *gorm.DB, user *models.User) (*AuditEvent, error) {
+		if user.IsDisabled() {
+			return nil, ErrUserAlreadyDisabled
+		}
+
+		disabledAt := s.now()
+		if err := tx.Model(user).Update("disabled_at", disabledAt).Error; err != nil {
+			return nil, fmt.Errorf("failed to disable user: %w", err)
+		}
+		user.DisabledAt = &disabledAt
+
+		event := &AuditEvent{Action: models.AuditActionUserDisabled}
+		if reason != "" {
+			event.Details = models.AuditDetails{"reason": reason}
+		}
+		return event, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revocationService.SignOutEverywhere(user.ID, models.RefreshTokenRevokedSignOutAll); err != nil {
+		return nil, fmt.Errorf("failed to revoke existing sessions: %w", err)
+	}
+
+	return user, nil
+}
+
+// Enable lets a disabled user sign in again
+func (s *AdminUserService) Enable(actor AuditActor, userID string) (*models.User, error) {
+	return s.update(actor, userID, func(tx *gorm.DB, user *models.User) (*AuditEvent, error) {
+		if !user.IsDisabled() {
+			return nil, ErrUserNotDisabled
+		}
+		if user.IsErased() {
+			return nil, ErrUserErased
+		}
+
+		if err := tx.Model(user).Update("disabled_at", nil).Error; err != nil {
+			return nil, fmt.Errorf("failed to enable user: %w", err)
+		}
+		user.DisabledAt = nil
+
+		return &AuditEvent{Action: models.AuditActionUserEnabled}, nil
+	})
+}
+
+// ForcePasswordReset signs the user out everywhere and emails them a reset link.
+// They cannot sign in again until they have chosen a new password.
+func (s *AdminUserService) ForcePasswordReset(actor AuditActor, userID string) (*models.User, error) {
+	user, err := s.update(actor, userID, func(tx *gorm.DB, user *models.User) (*AuditEvent, error) {
+		if err := tx.Model(user).Update("password_reset_required", true).Error; err != nil {
+			return nil, fmt.Errorf("failed to require password reset: %w", err)
+		}
+		user.PasswordResetRequired = true
+
+		return &AuditEvent{Action: models.AuditActionUserPasswordResetForced}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revocationService.SignOutEverywhere(user.ID, models.RefreshTokenRevokedPasswordChange); err != nil {
+		return nil, fmt.Errorf("failed to revoke existing sessions: %w", err)
+	}
+	if err := s.passwordResetService.RequestReset(user.Email); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SignOut signs the user out of every device
+func (s *AdminUserService) SignOut(actor AuditActor, userID string) error {
+	user, err := s.get(s.db, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.revocationService.SignOutEverywhere(user.ID, models.RefreshTokenRevokedSignOutAll); err != nil {
+		return fmt.Errorf("failed to sign user out: %w", err)
+	}
+
+	return s.audit.Record(actor, AuditEvent{
+		Action:       models.AuditActionUserSignedOut,
+		ResourceType: models.AuditResourceUser,
+		ResourceID:   user.ID,
+	})
+}
+
+// Unlock clears the user's failed login counter and any temporary lockout
+func (s *AdminUserService) Unlock(actor AuditActor, userID string) error {
+	user, err := s.get(s.db, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.loginProtection.Unlock(user.Email); err != nil {
+		return fmt.Errorf("failed to unlock user: %w", err)
+	}
+
+	return s.audit.Record(actor, AuditEvent{
+		Action:       models.AuditActionUserUnlocked,
+		ResourceType: models.AuditResourceUser,
+		ResourceID:   user.ID,
+	})
+}
+
+// update loads the user, applies change and records the audit event it returns,
+// all in one transaction
+func (s *AdminUserService) update(actor AuditActor, userID string, change func(tx *gorm.DB, user *models.User) (*AuditEvent, error)) (*models.User, error) {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	user, err := s.get(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	event, err := change(tx, user)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	event.ResourceType = models.AuditResourceUser
+	event.ResourceID = user.ID
+	if err := s.audit.RecordTx(tx, actor, *event); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit user update: %w", err)
+	}
+
+	return user, nil
+}
+
+// get loads a user by ID
+func (s *AdminUserService) get(db *gorm.DB, userID string) (*models.User, error) {
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"municollect/internal/models"
+)
+
+func TestCheckCanSignIn(t *testing.T) {
+	disabledAt := time.Now()
+
+	assert.NoError(t, CheckCanSignIn(&models.User{}))
+	assert.ErrorIs(t, CheckCanSignIn(&models.User{DisabledAt: &disabledAt}), ErrAccountDisabled)
+	assert.ErrorIs(t, CheckCanSignIn(&models.User{PasswordResetRequired: true}), ErrPasswordResetPending)
+
+	// Disabling takes precedence over a pending reset
+	assert.ErrorIs(t, CheckCanSignIn(&models.User{DisabledAt: &disabledAt, PasswordResetRequired: true}), ErrAccountDisabled)
+}
+
+func TestAdminUserService_RefusesSelfModification(t *testing.T) {
+	service := NewAdminUserService(nil, nil, nil, nil, nil)
+	actor := AuditActor{UserID: "admin-1", IPAddress: "192.0.2.1"}
+
+	_, err := service.Disable(actor, "admin-1", "")
+	assert.ErrorIs(t, err, ErrCannotModifySelf)
+
+	_, err = service.ChangeRole(actor, "admin-1", models.UserRoleResident)
+	assert.ErrorIs(t, err, ErrCannotModifySelf)
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, "somchai", escapeLike("somchai"))
+	assert.Equal(t, `100\%`, escapeLike("100%"))
+	assert.Equal(t, `first\_last`, escapeLike("first_last"))
+	assert.Equal(t, `a\\b`, escapeLike(`a\b`))
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"municollect/internal/middleware"
+	"municollect/internal/models"
+)
+
+// APIKeyPrefix starts every API key so that leaked keys are easy to recognise
+const APIKeyPrefix = "mck_"
+
+// apiKeyPrefixLength is the length of the identifying part after APIKeyPrefix
+const apiKeyPrefixLength = 8
+
+// apiKeyLastUsedInterval limits how often last-used tracking writes to the database
+const apiKeyLastUsedInterval = time.Minute
+
+var (
+	// ErrAPIKeyNotFound is returned when an API key does not exist
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrInvalidAPIScope is returned when an unknown scope is requested
+	ErrInvalidAPIScope = errors.New("invalid API scope")
+	// ErrInvalidAPIKeyExpiry is returned when a new key would already be expired
+	ErrInvalidAPIKeyExpiry = errors.New("API key expiry must be in the future")
+)
+
+// CreateAPIKeyRequest represents the data needed to issue an API key
+type CreateAPIKeyRequest struct {
+	Name           string     `json:"name" validate:"required,min=1,max=100"`
+	MunicipalityID string     `json:"municipalityId" validate:"required,uuid"`
+	Scopes         []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+}
+
+// APIKeyService manages API keys for machine-to-machine integrations
+type APIKeyService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// CreateAPIKey issues a new API key. The plain key is returned only here; it cannot be recovered later.
+func (s *APIKeyService) CreateAPIKey(req *CreateAPIKeyRequest, createdBy string) (*models.APIKey, string, error) {
+	for _, scope := range req.Scopes {
+		if !models.IsValidAPIScope(scope) {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidAPIScope, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(s.now()) {
+		return nil, "", ErrInvalidAPIKeyExpiry
+	}
+
+	// Verify municipality exists
+	var municipality models.Municipality
+	if err := s.db.Where("id = ?", req.MunicipalityID).First(&municipality).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", fmt.Errorf("municipality not found")
+		}
+		return nil, "", fmt.Errorf("failed to get municipality: %w", err)
+	}
+
+	prefix, key, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	apiKey := &models.APIKey{
+		Name:           req.Name,
+		Prefix:         prefix,
+		KeyHash:        hashToken(key),
+		Scopes:         models.APIKeyScopes(req.Scopes),
+		MunicipalityID: req.MunicipalityID,
+		ExpiresAt:      req.ExpiresAt,
+	}
+	if createdBy != "" {
+		apiKey.CreatedBy = &createdBy
+	}
+
+	if err := s.db.Create(apiKey).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return apiKey, key, nil
+}
+
+// ListAPIKeys returns API keys, newest first, optionally limited to one municipality
+func (s *APIKeyService) ListAPIKeys(municipalityID *string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+
+	query := s.db.Model(&models.APIKey{})
+	if municipalityID != nil {
+		query = query.Where("municipality_id = ?", *municipalityID)
+	}
+
+	if err := query.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key. Revoking an already revoked key is a no-op.
+func (s *APIKeyService) RevokeAPIKey(id string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := s.db.Where("id = ?", id).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if apiKey.IsRevoked() {
+		return &apiKey, nil
+	}
+
+	now := s.now()
+	if err := s.db.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	apiKey.RevokedAt = &now
+
+	return &apiKey, nil
+}
+
+// AuthenticateAPIKey resolves a raw API key to its principal and records its use.
+// It implements middleware.APIKeyAuthenticator.
+func (s *APIKeyService) AuthenticateAPIKey(key, ip string) (*middleware.APIKeyPrincipal, error) {
+	prefix, ok := parseAPIKeyPrefix(key)
+	if !ok {
+		return nil, middleware.ErrInvalidAPIKey
+	}
+
+	var apiKey models.APIKey
+	if err := s.db.Where("prefix = ?", prefix).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, middleware.ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(apiKey.KeyHash)) != 1 {
+		return nil, middleware.ErrInvalidAPIKey
+	}
+	if !apiKey.IsActive() {
+		return nil, middleware.ErrInvalidAPIKey
+	}
+
+	// Tracking is best effort and throttled so busy integrations do not write on every request
+	now := s.now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyLastUsedInterval {
+		if err := s.db.Model(&apiKey).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error; err != nil {
+			log.Printf("Failed to record use of API key %s: %v", apiKey.Prefix, err)
+		}
+	}
+
+	return &middleware.APIKeyPrincipal{
+		KeyID:          apiKey.ID,
+		Name:           apiKey.Name,
+		MunicipalityID: apiKey.MunicipalityID,
+		Scopes:         apiKey.Scopes,
+	}, nil
+}
+
+// generateAPIKey returns a new key of the form mck_<8 hex>_<secret> and its identifying prefix mck_<8 hex>
+func generateAPIKey() (prefix, key string, err error) {
+	id := make([]byte, apiKeyPrefixLength/2)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+
+	secret, err := generateSecureToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = APIKeyPrefix + hex.EncodeToString(id)
+	return prefix, prefix + "_" + secret, nil
+}
+
+// parseAPIKeyPrefix extracts the identifying prefix from a raw key
+func parseAPIKeyPrefix(key string) (string, bool) {
+	prefixLength := len(APIKeyPrefix) + apiKeyPrefixLength
+	if !strings.HasPrefix(key, APIKeyPrefix) || len(key) <= prefixLength+1 || key[prefixLength] != '_' {
+		return "", false
+	}
+
+	return key[:prefixLength], true
+}
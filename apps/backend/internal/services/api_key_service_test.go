@@ -0,0 +1,40 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	prefix, key, err := generateAPIKey()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(key, prefix+"_"))
+	assert.Len(t, prefix, len(APIKeyPrefix)+apiKeyPrefixLength)
+	assert.LessOrEqual(t, len(prefix), 16)
+
+	parsed, ok := parseAPIKeyPrefix(key)
+	require.True(t, ok)
+	assert.Equal(t, prefix, parsed)
+
+	_, otherKey, err := generateAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, otherKey)
+}
+
+func TestParseAPIKeyPrefix_Rejects(t *testing.T) {
+	for _, key := range []string{
+		"",
+		"mck_",
+		"mck_1234abcd",
+		"mck_1234abcd_",
+		"mck_1234abcdXsecret",
+		"sk_1234abcd_secret",
+	} {
+		_, ok := parseAPIKeyPrefix(key)
+		assert.False(t, ok, key)
+	}
+}
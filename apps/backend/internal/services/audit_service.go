@@ -0,0 +1,513 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// auditChainLock is the advisory lock key serialising writes to the audit log hash chain
+const auditChainLock = 7301160016
+
+// maxAuditRequestIDLength matches the audit_logs.request_id column; request IDs can come from clients
+const maxAuditRequestIDLength = 100
+
+// auditBatchSize is how many entries are read at a time when exporting or verifying the audit log
+const auditBatchSize = 500
+
+// ErrInvalidAuditExportFormat is returned for an export format other than csv or json
+var ErrInvalidAuditExportFormat = errors.New("audit log export format must be csv or json")
+
+// errAuditChainBroken stops Verify at the first entry that breaks the chain
+var errAuditChainBroken = errors.New("audit log chain broken")
+
+// AuditActor identifies who performed an audited action, from where and in which request.
+// ImpersonatorID is set when an admin performed it while impersonating UserID.
+type AuditActor struct {
+	UserID         string
+	ImpersonatorID string
+	IPAddress      string
+	RequestID      string
+}
+
+// AuditEvent describes an audited action. Before and After are the state of the resource
+// around the change; the fields that differ are recorded as the entry's changes.
+type AuditEvent struct {
+	Action       models.AuditAction
+	ResourceType string
+	ResourceID   string
+	Details      models.AuditDetails
+	Before       interface{}
+	After        interface{}
+}
+
+// AuditLogFilter represents filters for audit log queries
+type AuditLogFilter struct {
+	ActorID        *string
+	ImpersonatorID *string
+	Action         *models.AuditAction
+	ResourceType   *string
+	ResourceID     *string
+	RequestID      *string
+	From           *time.Time
+	To             *time.Time
+}
+
+// AuditChainReport is the result of verifying the audit log hash chain
+type AuditChainReport struct {
+	Valid   bool  `json:"valid"`
+	Entries int64 `json:"entries"`
+	// BrokenAt is the sequence number of the first entry that does not fit the chain
+	BrokenAt *int64 `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Unverified counts entries whose hashed IP address was erased, so only their
+	// place in the chain could be checked
+	Unverified int64 `json:"unverified,omitempty"`
+}
+
+// AuditService writes, queries and verifies the audit log
+type AuditService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// Record writes an audit log entry
+func (s *AuditService) Record(actor AuditActor, event AuditEvent) error {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.RecordTx(tx, actor, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit audit log entry: %w", err)
+	}
+
+	return nil
+}
+
+// RecordTx writes an audit log entry within tx, so the entry is only kept
+// if the audited change is committed. tx must be a transaction: the head of
+// the chain stays locked against other writers until it ends.
+func (s *AuditService) RecordTx(tx *gorm.DB, actor AuditActor, event AuditEvent) error {
+	changes, err := auditDiff(event.Before, event.After)
+	if err != nil {
+		return fmt.Errorf("failed to diff audited change: %w", err)
+	}
+	details, err := normalizeAuditDetails(event.Details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	entry := &models.AuditLog{
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		Changes:      changes,
+		Details:      details,
+		IPAddress:    actor.IPAddress,
+		RequestID:    actor.RequestID,
+		HashVersion:  models.AuditHashV2,
+		CreatedAt:    s.now().UTC().Truncate(time.Microsecond),
+	}
+	if actor.UserID != "" {
+		entry.ActorID = &actor.UserID
+	}
+	if actor.ImpersonatorID != "" {
+		entry.ImpersonatorID = &actor.ImpersonatorID
+	}
+	if len(entry.RequestID) > maxAuditRequestIDLength {
+		entry.RequestID = entry.RequestID[:maxAuditRequestIDLength]
+	}
+
+	// Entries are chained one at a time, so concurrent writers wait for each other
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+
+	var head models.AuditLog
+	if err := tx.Select("sequence", "hash").Order("sequence DESC").Limit(1).Find(&head).Error; err != nil {
+		return fmt.Errorf("failed to read audit log head: %w", err)
+	}
+
+	entry.Sequence = head.Sequence + 1
+	entry.PrevHash = head.Hash
+	if entry.Hash, err = entry.ComputeHash(); err != nil {
+		return fmt.Errorf("failed to hash audit log entry: %w", err)
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the audit log entries matching the filter, newest first
+func (s *AuditService) List(filter *AuditLogFilter, limit, offset int) ([]models.AuditLog, int64, error) {
+	var entries []models.AuditLog
+	var total int64
+
+	// Get total count
+	if err := s.query(filter).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit log entries: %w", err)
+	}
+
+	// Get entries with pagination
+	if err := s.query(filter).Order("sequence DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit log entries: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// Export writes every audit log entry matching the filter to w, oldest first, as CSV or
+// as one JSON object per line. The export itself is recorded in the audit log.
+func (s *AuditService) Export(actor AuditActor, filter *AuditLogFilter, format string, w io.Writer) error {
+	var write func(entry *models.AuditLog) error
+	var flush func() error
+
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(auditCSVHeader); err != nil {
+			return fmt.Errorf("failed to write audit log export: %w", err)
+		}
+		write = func(entry *models.AuditLog) error {
+			record, err := auditCSVRecord(entry)
+			if err != nil {
+				return err
+			}
+			return writer.Write(record)
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case "json":
+		encoder := json.NewEncoder(w)
+		write = func(entry *models.AuditLog) error {
+			return encoder.Encode(entry)
+		}
+		flush = func() error { return nil }
+	default:
+		return ErrInvalidAuditExportFormat
+	}
+
+	exported := 0
+	err := s.each(filter, func(entry *models.AuditLog) error {
+		exported++
+		return write(entry)
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export audit log: %w", err)
+	}
+
+	return s.Record(actor, AuditEvent{
+		Action:       models.AuditActionAuditLogExported,
+		ResourceType: models.AuditResourceAuditLog,
+		ResourceID:   format,
+		Details:      models.AuditDetails{"entries": exported, "filter": auditFilterDetails(filter)},
+	})
+}
+
+// Verify walks the whole audit log in sequence order and checks that every entry's hash
+// matches its content and the entry before it. Entries written before the log was
+// hash-chained carry no hash and are only checked for gaps, and older entries whose
+// hashed IP address was erased are only checked against the entry before them.
+func (s *AuditService) Verify() (*AuditChainReport, error) {
+	report := &AuditChainReport{Valid: true}
+	var previous models.AuditLog
+	chained := false
+
+	broken := func(entry *models.AuditLog, reason string) error {
+		sequence := entry.Sequence
+		report.Valid = false
+		report.BrokenAt = &sequence
+		report.Reason = reason
+		return errAuditChainBroken
+	}
+
+	err := s.each(nil, func(entry *models.AuditLog) error {
+		report.Entries++
+
+		// Sequences start at 1 and have no gaps
+		if entry.Sequence != previous.Sequence+1 {
+			return broken(entry, "entries are missing before this one")
+		}
+		if entry.Hash == "" {
+			if chained {
+				return broken(entry, "entry is not hashed")
+			}
+		} else {
+			if entry.PrevHash != previous.Hash {
+				return broken(entry, "entry does not follow the previous entry")
+			}
+			if entry.IsHashVerifiable() {
+				hash, err := entry.ComputeHash()
+				if err != nil {
+					return fmt.Errorf("failed to hash audit log entry: %w", err)
+				}
+				if hash != entry.Hash {
+					return broken(entry, "entry content does not match its hash")
+				}
+			} else {
+				report.Unverified++
+			}
+			chained = true
+		}
+
+		previous = *entry
+		return nil
+	})
+	if err != nil && !errors.Is(err, errAuditChainBroken) {
+		return nil, fmt.Errorf("failed to verify audit log: %w", err)
+	}
+
+	return report, nil
+}
+
+// each calls fn for every entry matching the filter in sequence order, reading the
+// log in batches
+func (s *AuditService) each(filter *AuditLogFilter, fn func(entry *models.AuditLog) error) error {
+	var after int64
+	for {
+		var entries []models.AuditLog
+		if err := s.query(filter).Where("sequence > ?", after).Order("sequence ASC").Limit(auditBatchSize).Find(&entries).Error; err != nil {
+			return err
+		}
+
+		for i := range entries {
+			if err := fn(&entries[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(entries) < auditBatchSize {
+			return nil
+		}
+		after = entries[len(entries)-1].Sequence
+	}
+}
+
+// query builds the audit log query for filter
+func (s *AuditService) query(filter *AuditLogFilter) *gorm.DB {
+	query := s.db.Model(&models.AuditLog{})
+	if filter == nil {
+		return query
+	}
+
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.ImpersonatorID != nil {
+		query = query.Where("impersonator_id = ?", *filter.ImpersonatorID)
+	}
+	if filter.Action != nil {
+		query = query.Where("action = ?", *filter.Action)
+	}
+	if filter.ResourceType != nil {
+		query = query.Where("resource_type = ?", *filter.ResourceType)
+	}
+	if filter.ResourceID != nil {
+		query = query.Where("resource_id = ?", *filter.ResourceID)
+	}
+	if filter.RequestID != nil {
+		query = query.Where("request_id = ?", *filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	return query
+}
+
+// auditCSVHeader names the columns of a CSV audit log export
+var auditCSVHeader = []string{
+	"sequence", "created_at", "actor_id", "impersonator_id", "action", "resource_type", "resource_id",
+	"changes", "details", "ip_address", "request_id", "prev_hash", "hash",
+}
+
+// auditCSVRecord formats an entry as a row of a CSV audit log export
+func auditCSVRecord(entry *models.AuditLog) ([]string, error) {
+	var changes, details string
+	if entry.Changes != nil {
+		encoded, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return nil, err
+		}
+		changes = string(encoded)
+	}
+	if entry.Details != nil {
+		encoded, err := json.Marshal(entry.Details)
+		if err != nil {
+			return nil, err
+		}
+		details = string(encoded)
+	}
+
+	optional := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+
+	return []string{
+		strconv.FormatInt(entry.Sequence, 10),
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		optional(entry.ActorID),
+		optional(entry.ImpersonatorID),
+		string(entry.Action),
+		entry.ResourceType,
+		entry.ResourceID,
+		changes,
+		details,
+		entry.IPAddress,
+		entry.RequestID,
+		entry.PrevHash,
+		entry.Hash,
+	}, nil
+}
+
+// auditFilterDetails describes an export's filter in the audit log
+func auditFilterDetails(filter *AuditLogFilter) models.AuditDetails {
+	details := models.AuditDetails{}
+	if filter == nil {
+		return details
+	}
+
+	set := func(key string, value *string) {
+		if value != nil {
+			details[key] = *value
+		}
+	}
+	set("actorId", filter.ActorID)
+	set("impersonatorId", filter.ImpersonatorID)
+	set("resourceType", filter.ResourceType)
+	set("resourceId", filter.ResourceID)
+	set("requestId", filter.RequestID)
+	if filter.Action != nil {
+		details["action"] = string(*filter.Action)
+	}
+	if filter.From != nil {
+		details["from"] = filter.From.UTC().Format(time.RFC3339)
+	}
+	if filter.To != nil {
+		details["to"] = filter.To.UTC().Format(time.RFC3339)
+	}
+
+	return details
+}
+
+// auditIgnoredFields are bookkeeping fields left out of recorded changes
+var auditIgnoredFields = map[string]bool{
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// auditDiff returns the top-level fields that differ between the JSON forms of before
+// and after. Either may be nil, for resources that were created or deleted. Fields
+// hidden from JSON, such as password hashes, never reach the audit log.
+func auditDiff(before, after interface{}) (models.AuditChanges, error) {
+	if before == nil && after == nil {
+		return nil, nil
+	}
+
+	from, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := models.AuditChanges{}
+	for field, value := range from {
+		if auditIgnoredFields[field] {
+			continue
+		}
+		if other, ok := to[field]; !ok || !reflect.DeepEqual(value, other) {
+			changes[field] = models.AuditChange{From: value, To: to[field]}
+		}
+	}
+	for field, value := range to {
+		if _, ok := from[field]; !ok && !auditIgnoredFields[field] {
+			changes[field] = models.AuditChange{To: value}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, nil
+}
+
+// normalizeAuditDetails gives details the form they have when read back from the
+// database, so the hash computed on write matches the one computed on verification
+func normalizeAuditDetails(details models.AuditDetails) (models.AuditDetails, error) {
+	if details == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized models.AuditDetails
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// auditFields returns the top-level fields of value's JSON form
+func auditFields(value interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if value == nil {
+		return fields, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	// A nil pointer encodes as null and leaves fields empty
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
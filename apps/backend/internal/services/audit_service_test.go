@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"municollect/internal/models"
+)
+
+func TestAuditDiff(t *testing.T) {
+	email := "office@example.com"
+	before := &models.Municipality{ID: "m-1", Name: "Old Town", Code: "OLD", UpdatedAt: time.Now()}
+	after := &models.Municipality{ID: "m-1", Name: "New Town", Code: "OLD", ContactEmail: &email, UpdatedAt: time.Now().Add(time.Minute)}
+
+	changes, err := auditDiff(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuditChanges{
+		"name":         {From: "Old Town", To: "New Town"},
+		"contactEmail": {To: "office@example.com"},
+	}, changes)
+
+	// Created and deleted resources have only one side
+	changes, err = auditDiff(nil, map[string]interface{}{"role": "finance"})
+	require.NoError(t, err)
+	assert.Equal(t, models.AuditChanges{"role": {To: "finance"}}, changes)
+
+	var deleted *models.MunicipalRole
+	changes, err = auditDiff(&models.MunicipalRole{Key: "clerk"}, deleted)
+	require.NoError(t, err)
+	assert.Equal(t, "clerk", changes["key"].From)
+	assert.Nil(t, changes["key"].To)
+
+	// Nothing changed
+	changes, err = auditDiff(before, before)
+	require.NoError(t, err)
+	assert.Nil(t, changes)
+}
+
+func TestAuditLog_HashChain(t *testing.T) {
+	actorID := "7f9c7c1e-52b4-4a34-9a55-52c2f1e2a001"
+	createdAt := time.Date(2026, 10, 16, 9, 30, 0, 123456789, time.UTC)
+
+	details, err := normalizeAuditDetails(models.AuditDetails{"count": 3, "at": createdAt})
+	require.NoError(t, err)
+
+	first := &models.AuditLog{
+		Sequence:     1,
+		ActorID:      &actorID,
+		Action:       models.AuditActionMunicipalityUpdated,
+		ResourceType: models.AuditResourceMunicipality,
+		ResourceID:   "m-1",
+		Changes:      models.AuditChanges{"name": {From: "Old Town", To: "New Town"}},
+		Details:      details,
+		CreatedAt:    createdAt,
+	}
+	first.Hash, err = first.ComputeHash()
+	require.NoError(t, err)
+	assert.Len(t, first.Hash, 64)
+
+	// The hash survives the database round trip of the JSON columns and timestamp
+	encoded, err := json.Marshal(first)
+	require.NoError(t, err)
+	var stored models.AuditLog
+	require.NoError(t, json.Unmarshal(encoded, &stored))
+	stored.CreatedAt = createdAt.Truncate(time.Microsecond)
+	hash, err := stored.ComputeHash()
+	require.NoError(t, err)
+	assert.Equal(t, first.Hash, hash)
+
+	// Each entry is bound to the one before it
+	second := &models.AuditLog{Sequence: 2, Action: models.AuditActionAuthLogin, ResourceType: models.AuditResourceUser, PrevHash: first.Hash, CreatedAt: createdAt}
+	secondHash, err := second.ComputeHash()
+	require.NoError(t, err)
+	second.PrevHash = "0000"
+	otherHash, err := second.ComputeHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, secondHash, otherHash)
+
+	// Any change to the content changes the hash
+	stored.ResourceID = "m-2"
+	tampered, err := stored.ComputeHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Hash, tampered)
+}
+
+func TestAuditLog_HashVersions(t *testing.T) {
+	actorID := "7f9c7c1e-52b4-4a34-9a55-52c2f1e2a001"
+	createdAt := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	erasedAt := createdAt.Add(time.Hour)
+
+	entry := func(version int) *models.AuditLog {
+		return &models.AuditLog{
+			Sequence:     1,
+			ActorID:      &actorID,
+			Action:       models.AuditActionAuthLogin,
+			ResourceType: models.AuditResourceUser,
+			ResourceID:   actorID,
+			IPAddress:    "203.0.113.7",
+			HashVersion:  version,
+			CreatedAt:    createdAt,
+		}
+	}
+
+	// Version 2 leaves the IP address out, so erasing it keeps the entry verifiable
+	current := entry(models.AuditHashV2)
+	hash, err := current.ComputeHash()
+	require.NoError(t, err)
+	current.IPAddress = ""
+	current.IPErasedAt = &erasedAt
+	erased, err := current.ComputeHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, erased)
+	assert.True(t, current.IsHashVerifiable())
+
+	// The version is hashed, so an entry can't be moved to another version
+	legacy := entry(models.AuditHashV1)
+	legacyHash, err := legacy.ComputeHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, legacyHash)
+	legacy.HashVersion = 0
+	unversioned, err := legacy.ComputeHash()
+	require.NoError(t, err)
+	assert.Equal(t, legacyHash, unversioned)
+
+	// Version 1 covers the IP address, so an erased entry can't be rehashed
+	legacy.IPAddress = ""
+	assert.True(t, legacy.IsHashVerifiable())
+	legacy.IPErasedAt = &erasedAt
+	assert.False(t, legacy.IsHashVerifiable())
+	blanked, err := legacy.ComputeHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, legacyHash, blanked)
+}
+
+func TestAuditCSVRecord(t *testing.T) {
+	actorID := "7f9c7c1e-52b4-4a34-9a55-52c2f1e2a001"
+	record, err := auditCSVRecord(&models.AuditLog{
+		Sequence:     42,
+		ActorID:      &actorID,
+		Action:       models.AuditActionPaymentStatusChanged,
+		ResourceType: models.AuditResourcePayment,
+		ResourceID:   "p-1",
+		Changes:      models.AuditChanges{"status": {From: "pending", To: "completed"}},
+		RequestID:    "req-1",
+		Hash:         "abc",
+		CreatedAt:    time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, record, len(auditCSVHeader))
+	assert.Equal(t, []string{
+		"42", "2026-10-16T09:30:00Z", actorID, "", "payment.status_changed", "payment", "p-1",
+		`{"status":{"from":"pending","to":"completed"}}`, "", "", "req-1", "", "abc",
+	}, record)
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ExpiredRecordCleanupInterval is how often the background worker deletes expired records
+const ExpiredRecordCleanupInterval = time.Hour
+
+// ExpiredRecordDeleter is a store whose records expire, such as refresh tokens or idempotency keys
+type ExpiredRecordDeleter interface {
+	DeleteExpired() error
+}
+
+// RunExpiredRecordCleanup deletes the expired records of every store each interval until ctx
+// is cancelled, so their tables do not grow without bound
+func RunExpiredRecordCleanup(ctx context.Context, interval time.Duration, stores ...ExpiredRecordDeleter) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, store := range stores {
+			if err := store.DeleteExpired(); err != nil {
+				log.Printf("Failed to delete expired records: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// Consent errors
+var (
+	ErrConsentRequired        = errors.New("the terms of service and privacy policy must be accepted")
+	ErrConsentVersionOutdated = errors.New("consent was given to a version that is no longer current")
+	ErrInvalidConsent         = errors.New("unknown consent purpose or channel")
+	ErrNoConsent              = errors.New("user has not consented to this notification")
+)
+
+// ConsentVersions are the current versions of the documents users accept and of the
+// notice they consent to messages under
+type ConsentVersions struct {
+	TermsOfService string `json:"termsOfService"`
+	PrivacyPolicy  string `json:"privacyPolicy"`
+	Messages       string `json:"messages"`
+}
+
+// ConsentVersionsFromEnv reads the current versions from CONSENT_TERMS_VERSION,
+// CONSENT_PRIVACY_VERSION and CONSENT_MESSAGES_VERSION, each defaulting to "1"
+func ConsentVersionsFromEnv() ConsentVersions {
+	version := func(name string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return "1"
+	}
+
+	return ConsentVersions{
+		TermsOfService: version("CONSENT_TERMS_VERSION"),
+		PrivacyPolicy:  version("CONSENT_PRIVACY_VERSION"),
+		Messages:       version("CONSENT_MESSAGES_VERSION"),
+	}
+}
+
+// For returns the current version of what purpose is consented under
+func (v ConsentVersions) For(purpose models.ConsentPurpose) string {
+	switch purpose {
+	case models.ConsentPurposeTermsOfService:
+		return v.TermsOfService
+	case models.ConsentPurposePrivacyPolicy:
+		return v.PrivacyPolicy
+	}
+	return v.Messages
+}
+
+// ConsentChoices are the consent choices a user makes at once. Documents are accepted by
+// naming the version the user was shown; message consent is given or withdrawn per channel.
+// Purposes and channels that are left out keep their current choice.
+type ConsentChoices struct {
+	TermsOfServiceVersion string                                                   `json:"termsOfServiceVersion,omitempty"`
+	PrivacyPolicyVersion  string                                                   `json:"privacyPolicyVersion,omitempty"`
+	Messages              map[models.ConsentPurpose]map[models.ConsentChannel]bool `json:"messages,omitempty"`
+}
+
+// DocumentConsent describes the user's acceptance of a document
+type DocumentConsent struct {
+	AcceptedVersion string     `json:"acceptedVersion,omitempty"`
+	AcceptedAt      *time.Time `json:"acceptedAt,omitempty"`
+	CurrentVersion  string     `json:"currentVersion"`
+	UpToDate        bool       `json:"upToDate"`
+}
+
+// ConsentState is a user's current consent to each document and each kind of message
+type ConsentState struct {
+	Documents       map[models.ConsentPurpose]DocumentConsent                `json:"documents"`
+	Messages        map[models.ConsentPurpose]map[models.ConsentChannel]bool `json:"messages"`
+	MessagesVersion string                                                   `json:"messagesVersion"`
+}
+
+// ConsentService records users' consent and checks it before messages are sent
+type ConsentService struct {
+	db       *gorm.DB
+	versions ConsentVersions
+}
+
+// NewConsentService creates a new consent service
+func NewConsentService(db *gorm.DB, versions ConsentVersions) *ConsentService {
+	return &ConsentService{
+		db:       db,
+		versions: versions,
+	}
+}
+
+// Versions returns the current document and message notice versions
+func (s *ConsentService) Versions() ConsentVersions {
+	return s.versions
+}
+
+// Validate checks that choices only accept current document versions and only name known
+// purposes and channels. At registration both documents must be accepted.
+func (s *ConsentService) Validate(choices *ConsentChoices, registering bool) error {
+	for purpose, version := range map[models.ConsentPurpose]string{
+		models.ConsentPurposeTermsOfService: choices.TermsOfServiceVersion,
+		models.ConsentPurposePrivacyPolicy:  choices.PrivacyPolicyVersion,
+	} {
+		if version == "" {
+			if registering {
+				return ErrConsentRequired
+			}
+			continue
+		}
+		if version != s.versions.For(purpose) {
+			return fmt.Errorf("%w: %s", ErrConsentVersionOutdated, purpose)
+		}
+	}
+
+	for purpose, channels := range choices.Messages {
+		if !models.IsMessagePurpose(purpose) {
+			return fmt.Errorf("%w: %s", ErrInvalidConsent, purpose)
+		}
+		for channel := range channels {
+			if !models.IsMessageChannel(channel) {
+				return fmt.Errorf("%w: %s", ErrInvalidConsent, channel)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RecordTx validates choices and records those that differ from the user's current consent within tx
+func (s *ConsentService) RecordTx(tx *gorm.DB, userID string, choices *ConsentChoices, source models.ConsentSource, client ClientInfo) error {
+	if err := s.Validate(choices, source == models.ConsentSourceRegistration); err != nil {
+		return err
+	}
+
+	current, err := s.latest(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	var records []models.ConsentRecord
+	add := func(purpose models.ConsentPurpose, channel models.ConsentChannel, granted bool) {
+		version := s.versions.For(purpose)
+		if previous, ok := current[consentKey{purpose, channel}]; ok && previous.Granted == granted && previous.Version == version {
+			return
+		}
+		records = append(records, models.ConsentRecord{
+			UserID:    userID,
+			Purpose:   purpose,
+			Channel:   channel,
+			Version:   version,
+			Granted:   granted,
+			Source:    source,
+			IPAddress: truncate(client.IPAddress, 45),
+			UserAgent: truncate(client.UserAgent, 512),
+		})
+	}
+
+	if choices.TermsOfServiceVersion != "" {
+		add(models.ConsentPurposeTermsOfService, models.ConsentChannelNone, true)
+	}
+	if choices.PrivacyPolicyVersion != "" {
+		add(models.ConsentPurposePrivacyPolicy, models.ConsentChannelNone, true)
+	}
+	for _, purpose := range models.MessagePurposes {
+		for _, channel := range models.MessageChannels {
+			if granted, ok := choices.Messages[purpose][channel]; ok {
+				add(purpose, channel, granted)
+			}
+		}
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return fmt.Errorf("failed to record consent: %w", err)
+	}
+
+	return nil
+}
+
+// Update records the user's changed consent choices and returns their consent state
+func (s *ConsentService) Update(userID string, choices *ConsentChoices, client ClientInfo) (*ConsentState, error) {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.RecordTx(tx, userID, choices, models.ConsentSourceSettings, client); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit consent: %w", err)
+	}
+
+	return s.State(userID)
+}
+
+// State returns the user's current consent. Messages the user never chose about are not consented to.
+func (s *ConsentService) State(userID string) (*ConsentState, error) {
+	current, err := s.latest(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ConsentState{
+		Documents:       map[models.ConsentPurpose]DocumentConsent{},
+		Messages:        map[models.ConsentPurpose]map[models.ConsentChannel]bool{},
+		MessagesVersion: s.versions.Messages,
+	}
+	for _, purpose := range models.ConsentDocuments {
+		document := DocumentConsent{CurrentVersion: s.versions.For(purpose)}
+		if record, ok := current[consentKey{purpose, models.ConsentChannelNone}]; ok && record.Granted {
+			acceptedAt := record.CreatedAt
+			document.AcceptedVersion = record.Version
+			document.AcceptedAt = &acceptedAt
+			document.UpToDate = record.Version == document.CurrentVersion
+		}
+		state.Documents[purpose] = document
+	}
+	for _, purpose := range models.MessagePurposes {
+		state.Messages[purpose] = map[models.ConsentChannel]bool{}
+		for _, channel := range models.MessageChannels {
+			state.Messages[purpose][channel] = current[consentKey{purpose, channel}].Granted
+		}
+	}
+
+	return state, nil
+}
+
+// History returns every consent choice the user has made, newest first
+func (s *ConsentService) History(userID string) ([]models.ConsentRecord, error) {
+	var records []models.ConsentRecord
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get consent history: %w", err)
+	}
+	return records, nil
+}
+
+// Allows reports whether the user currently consents to messages for purpose on channel
+func (s *ConsentService) Allows(userID string, purpose models.ConsentPurpose, channel models.ConsentChannel) (bool, error) {
+	return s.allows(s.db, userID, purpose, channel)
+}
+
+// allows reads the user's latest choice for purpose on channel
+func (s *ConsentService) allows(db *gorm.DB, userID string, purpose models.ConsentPurpose, channel models.ConsentChannel) (bool, error) {
+	var records []models.ConsentRecord
+	if err := db.Where("user_id = ? AND purpose = ? AND channel = ?", userID, purpose, channel).
+		Order("created_at DESC").Limit(1).Find(&records).Error; err != nil {
+		return false, fmt.Errorf("failed to check consent: %w", err)
+	}
+	return len(records) > 0 && records[0].Granted, nil
+}
+
+// RegisterHooks makes the database refuse to store in-app notifications the recipient has
+// not consented to, whichever code path creates them. Creating one fails with ErrNoConsent.
+func (s *ConsentService) RegisterHooks(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("consent:notifications", s.enforceNotificationConsent)
+}
+
+// enforceNotificationConsent is the create callback registered by RegisterHooks
+func (s *ConsentService) enforceNotificationConsent(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	var notifications []*models.Notification
+	switch dest := db.Statement.Dest.(type) {
+	case *models.Notification:
+		notifications = append(notifications, dest)
+	case []models.Notification:
+		for i := range dest {
+			notifications = append(notifications, &dest[i])
+		}
+	case *[]models.Notification:
+		for i := range *dest {
+			notifications = append(notifications, &(*dest)[i])
+		}
+	default:
+		return
+	}
+
+	// Check within the statement's own connection, which may be a transaction
+	check := db.Session(&gorm.Session{NewDB: true})
+	for _, notification := range notifications {
+		purpose, needed := models.NotificationConsentPurpose(notification.Type)
+		if !needed {
+			continue
+		}
+		allowed, err := s.allows(check, notification.UserID, purpose, models.ConsentChannelInApp)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		if !allowed {
+			db.AddError(fmt.Errorf("%w: %s", ErrNoConsent, notification.Type))
+			return
+		}
+	}
+}
+
+// consentKey identifies a purpose on a channel
+type consentKey struct {
+	purpose models.ConsentPurpose
+	channel models.ConsentChannel
+}
+
+// latest returns the user's most recent choice for each purpose and channel
+func (s *ConsentService) latest(db *gorm.DB, userID string) (map[consentKey]models.ConsentRecord, error) {
+	var records []models.ConsentRecord
+	if err := db.Raw(`SELECT DISTINCT ON (purpose, channel) * FROM consent_records
+		WHERE user_id = ? ORDER BY purpose, channel, created_at DESC`, userID).Scan(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get consent: %w", err)
+	}
+
+	current := make(map[consentKey]models.ConsentRecord, len(records))
+	for _, record := range records {
+		current[consentKey{record.Purpose, record.Channel}] = record
+	}
+	return current, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"municollect/internal/models"
+)
+
+func testConsentVersions() ConsentVersions {
+	return ConsentVersions{TermsOfService: "2024-06", PrivacyPolicy: "2024-05", Messages: "3"}
+}
+
+func TestConsentVersions_For(t *testing.T) {
+	versions := testConsentVersions()
+
+	assert.Equal(t, "2024-06", versions.For(models.ConsentPurposeTermsOfService))
+	assert.Equal(t, "2024-05", versions.For(models.ConsentPurposePrivacyPolicy))
+	assert.Equal(t, "3", versions.For(models.ConsentPurposePaymentReminders))
+	assert.Equal(t, "3", versions.For(models.ConsentPurposeMarketing))
+}
+
+func TestConsentService_Validate(t *testing.T) {
+	service := NewConsentService(nil, testConsentVersions())
+
+	accepted := &ConsentChoices{TermsOfServiceVersion: "2024-06", PrivacyPolicyVersion: "2024-05"}
+	assert.NoError(t, service.Validate(accepted, true))
+
+	// Registration requires both documents; later updates may leave them out
+	assert.ErrorIs(t, service.Validate(&ConsentChoices{TermsOfServiceVersion: "2024-06"}, true), ErrConsentRequired)
+	assert.NoError(t, service.Validate(&ConsentChoices{}, false))
+
+	// Users can only accept the version that is current
+	assert.ErrorIs(t, service.Validate(&ConsentChoices{TermsOfServiceVersion: "2023-01", PrivacyPolicyVersion: "2024-05"}, true), ErrConsentVersionOutdated)
+
+	withMessages := &ConsentChoices{Messages: map[models.ConsentPurpose]map[models.ConsentChannel]bool{
+		models.ConsentPurposePaymentReminders: {models.ConsentChannelEmail: true, models.ConsentChannelSMS: false},
+		models.ConsentPurposeMarketing:        {models.ConsentChannelInApp: false},
+	}}
+	assert.NoError(t, service.Validate(withMessages, false))
+
+	unknownPurpose := &ConsentChoices{Messages: map[models.ConsentPurpose]map[models.ConsentChannel]bool{
+		models.ConsentPurposeTermsOfService: {models.ConsentChannelEmail: true},
+	}}
+	assert.ErrorIs(t, service.Validate(unknownPurpose, false), ErrInvalidConsent)
+
+	unknownChannel := &ConsentChoices{Messages: map[models.ConsentPurpose]map[models.ConsentChannel]bool{
+		models.ConsentPurposeMarketing: {"fax": true},
+	}}
+	assert.ErrorIs(t, service.Validate(unknownChannel, false), ErrInvalidConsent)
+}
+
+func TestNotificationConsentPurpose(t *testing.T) {
+	purpose, needed := models.NotificationConsentPurpose(models.NotificationTypePaymentReminder)
+	assert.True(t, needed)
+	assert.Equal(t, models.ConsentPurposePaymentReminders, purpose)
+
+	purpose, needed = models.NotificationConsentPurpose(models.NotificationTypeSystemUpdate)
+	assert.True(t, needed)
+	assert.Equal(t, models.ConsentPurposeMarketing, purpose)
+
+	for _, notificationType := range []models.NotificationType{
+		models.NotificationTypePaymentConfirmation,
+		models.NotificationTypePaymentFailed,
+		models.NotificationTypeSecurityAlert,
+	} {
+		_, needed := models.NotificationConsentPurpose(notificationType)
+		assert.False(t, needed, notificationType)
+	}
+}
+
+func TestNotificationService_Channels(t *testing.T) {
+	service := NewNotificationService(nil, nil, nil, nil)
+
+	// Notifications that need no consent go out in the app and by email
+	channels, err := service.channels(&models.User{ID: "user-1"}, models.NotificationTypeSecurityAlert)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ConsentChannel{models.ConsentChannelInApp, models.ConsentChannelEmail}, channels)
+
+	// Nothing goes out to erased users
+	erasedAt := time.Now()
+	channels, err = service.channels(&models.User{ID: "user-1", ErasedAt: &erasedAt}, models.NotificationTypeSecurityAlert)
+	require.NoError(t, err)
+	assert.Empty(t, channels)
+}
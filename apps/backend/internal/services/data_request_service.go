@@ -0,0 +1,702 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/models"
+)
+
+// DataExportLifetime is how long a completed export archive can be downloaded
+const DataExportLifetime = 7 * 24 * time.Hour
+
+// DataRequestPollInterval is how often the background worker looks for approved requests
+const DataRequestPollInterval = time.Minute
+
+// dataExportFormatVersion is bumped whenever the layout of the export archive changes
+const dataExportFormatVersion = 2
+
+// Data subject request errors
+var (
+	ErrDataRequestNotFound       = errors.New("data request not found")
+	ErrDataRequestOpen           = errors.New("a request of this type is already open")
+	ErrDataRequestNotReviewable  = errors.New("data request is not awaiting review")
+	ErrDataExportUnavailable     = errors.New("data export is not available")
+	ErrDataRequestSubjectMissing = errors.New("data request subject no longer exists")
+	ErrDataRequestOwn            = errors.New("admins cannot review their own data requests")
+)
+
+// personalDataKeys are the payment transaction data fields that identify the payer.
+// They are removed on erasure; the rest of the transaction is kept for accounting.
+var personalDataKeys = []string{
+	"firstName", "lastName", "name", "fullName", "email", "phone", "address", "nationalId",
+}
+
+// DataRequestService handles residents' PDPA requests to export or erase their personal data.
+// Requests wait in a queue for an admin's approval and are then carried out by a background
+// worker. Erasure pseudonymises the user and deletes their personal records, but keeps their
+// payments, stripped of identity, because they must be retained for accounting.
+type DataRequestService struct {
+	db                *gorm.DB
+	audit             *AuditService
+	revocationService *TokenRevocationService
+	now               func() time.Time
+	wake              chan struct{}
+}
+
+// NewDataRequestService creates a new data request service
+func NewDataRequestService(db *gorm.DB, audit *AuditService, revocationService *TokenRevocationService) *DataRequestService {
+	return &DataRequestService{
+		db:                db,
+		audit:             audit,
+		revocationService: revocationService,
+		now:               time.Now,
+		wake:              make(chan struct{}, 1),
+	}
+}
+
+// Create files a request by the signed-in user about their own data.
+// Each user may have one open request of each type.
+func (s *DataRequestService) Create(actor AuditActor, requestType models.DataSubjectRequestType, reason string) (*models.DataSubjectRequest, error) {
+	if err := models.ValidateDataSubjectRequestType(requestType); err != nil {
+		return nil, err
+	}
+
+	request := &models.DataSubjectRequest{
+		UserID: actor.UserID,
+		Type:   requestType,
+		Status: models.DataSubjectRequestPending,
+		Reason: reason,
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the user so concurrent requests of the same type cannot both be filed
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", actor.UserID).First(&user).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var open int64
+	if err := tx.Model(&models.DataSubjectRequest{}).
+		Where("user_id = ? AND type = ? AND status IN ?", actor.UserID, requestType,
+			[]models.DataSubjectRequestStatus{models.DataSubjectRequestPending, models.DataSubjectRequestApproved}).
+		Count(&open).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to check open data requests: %w", err)
+	}
+	if open > 0 {
+		tx.Rollback()
+		return nil, ErrDataRequestOpen
+	}
+
+	if err := tx.Create(request).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create data request: %w", err)
+	}
+
+	if err := s.audit.RecordTx(tx, actor, AuditEvent{
+		Action:       models.AuditActionDataRequestCreated,
+		ResourceType: models.AuditResourceDataRequest,
+		ResourceID:   request.ID,
+		Details:      models.AuditDetails{"type": requestType},
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit data request: %w", err)
+	}
+
+	return request, nil
+}
+
+// ListForUser returns the user's own requests, newest first
+func (s *DataRequestService) ListForUser(userID string) ([]models.DataSubjectRequest, error) {
+	var requests []models.DataSubjectRequest
+	if err := s.db.Omit("archive").Where("user_id = ?", userID).Order("created_at DESC").Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("failed to list data requests: %w", err)
+	}
+	return requests, nil
+}
+
+// Archive returns the export archive of one of the user's completed export requests
+func (s *DataRequestService) Archive(userID, requestID string) (*models.DataSubjectRequest, error) {
+	var request models.DataSubjectRequest
+	if err := s.db.Where("id = ? AND user_id = ?", requestID, userID).First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataRequestNotFound
+		}
+		return nil, fmt.Errorf("failed to get data request: %w", err)
+	}
+
+	if !request.ArchiveAvailable(s.now()) {
+		return nil, ErrDataExportUnavailable
+	}
+
+	return &request, nil
+}
+
+// List returns the review queue: requests with the given status, or every request
+// if status is nil, oldest first
+func (s *DataRequestService) List(status *models.DataSubjectRequestStatus, limit, offset int) ([]models.DataSubjectRequest, int64, error) {
+	var requests []models.DataSubjectRequest
+	var total int64
+
+	query := s.db.Model(&models.DataSubjectRequest{})
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	// Get total count
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count data requests: %w", err)
+	}
+
+	// Get requests with pagination
+	if err := query.Omit("archive").Preload("User").Order("created_at ASC").Limit(limit).Offset(offset).Find(&requests).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list data requests: %w", err)
+	}
+
+	return requests, total, nil
+}
+
+// Approve queues a pending request for processing. A failed request can be approved again to retry it.
+func (s *DataRequestService) Approve(actor AuditActor, requestID, note string) (*models.DataSubjectRequest, error) {
+	request, err := s.review(actor, requestID, models.DataSubjectRequestApproved, note, models.AuditActionDataRequestApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the worker pick the request up without waiting for its next poll
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return request, nil
+}
+
+// Reject closes a pending request without carrying it out
+func (s *DataRequestService) Reject(actor AuditActor, requestID, note string) (*models.DataSubjectRequest, error) {
+	return s.review(actor, requestID, models.DataSubjectRequestRejected, note, models.AuditActionDataRequestRejected)
+}
+
+// review records an admin's decision on a request awaiting review
+func (s *DataRequestService) review(actor AuditActor, requestID string, status models.DataSubjectRequestStatus, note string, action models.AuditAction) (*models.DataSubjectRequest, error) {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var request models.DataSubjectRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Omit("archive").Where("id = ?", requestID).First(&request).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataRequestNotFound
+		}
+		return nil, fmt.Errorf("failed to get data request: %w", err)
+	}
+
+	if request.UserID == actor.UserID {
+		tx.Rollback()
+		return nil, ErrDataRequestOwn
+	}
+
+	reviewable := request.Status == models.DataSubjectRequestPending ||
+		(request.Status == models.DataSubjectRequestFailed && status == models.DataSubjectRequestApproved)
+	if !reviewable {
+		tx.Rollback()
+		return nil, ErrDataRequestNotReviewable
+	}
+
+	before := request
+	reviewedAt := s.now()
+	updates := map[string]interface{}{
+		"status":         status,
+		"reviewed_by_id": actor.UserID,
+		"reviewed_at":    reviewedAt,
+		"review_note":    note,
+		"error":          "",
+	}
+	if err := tx.Model(&request).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update data request: %w", err)
+	}
+	request.Status = status
+	request.ReviewedByID = &actor.UserID
+	request.ReviewedAt = &reviewedAt
+	request.ReviewNote = note
+	request.Error = ""
+
+	if err := s.audit.RecordTx(tx, actor, AuditEvent{
+		Action:       action,
+		ResourceType: models.AuditResourceDataRequest,
+		ResourceID:   request.ID,
+		Details:      models.AuditDetails{"type": request.Type, "userId": request.UserID},
+		Before:       &before,
+		After:        &request,
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit data request review: %w", err)
+	}
+
+	return &request, nil
+}
+
+// Run carries out approved requests until ctx is cancelled, polling every interval and
+// whenever a request is approved. It also clears export archives once they expire.
+func (s *DataRequestService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := s.ProcessNext()
+			if err != nil {
+				log.Printf("Failed to process data request: %v", err)
+			}
+			if !processed {
+				break
+			}
+		}
+		if err := s.PurgeExpiredArchives(); err != nil {
+			log.Printf("Failed to purge expired data exports: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// ProcessNext carries out the oldest approved request, if any, and reports whether there was one.
+// The request stays locked while it is processed, so several workers can run side by side;
+// if processing is interrupted, the request is left approved and picked up again.
+func (s *DataRequestService) ProcessNext() (bool, error) {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var request models.DataSubjectRequest
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).Omit("archive").
+		Where("status = ?", models.DataSubjectRequestApproved).
+		Order("reviewed_at ASC").First(&request).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim data request: %w", err)
+	}
+
+	if err := s.process(tx, &request); err != nil {
+		tx.Rollback()
+		return true, s.fail(&request, err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return true, s.fail(&request, fmt.Errorf("failed to commit data request: %w", err))
+	}
+
+	return true, nil
+}
+
+// process carries out the request within tx and marks it completed
+func (s *DataRequestService) process(tx *gorm.DB, request *models.DataSubjectRequest) error {
+	completedAt := s.now()
+	updates := map[string]interface{}{
+		"status":       models.DataSubjectRequestCompleted,
+		"completed_at": completedAt,
+	}
+
+	switch request.Type {
+	case models.DataSubjectRequestExport:
+		archive, err := s.buildExport(tx, request, completedAt)
+		if err != nil {
+			return err
+		}
+		updates["archive"] = archive
+		updates["archive_expires_at"] = completedAt.Add(DataExportLifetime)
+	case models.DataSubjectRequestErasure:
+		if err := s.erase(tx, request, completedAt); err != nil {
+			return err
+		}
+	default:
+		return models.ValidateDataSubjectRequestType(request.Type)
+	}
+
+	if err := tx.Model(request).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to complete data request: %w", err)
+	}
+
+	return s.audit.RecordTx(tx, AuditActor{}, AuditEvent{
+		Action:       models.AuditActionDataRequestCompleted,
+		ResourceType: models.AuditResourceDataRequest,
+		ResourceID:   request.ID,
+		Details:      models.AuditDetails{"type": request.Type, "userId": request.UserID},
+	})
+}
+
+// fail marks a request as failed so an admin can look into it and approve it again
+func (s *DataRequestService) fail(request *models.DataSubjectRequest, cause error) error {
+	message := truncate(cause.Error(), 500)
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(request).Updates(map[string]interface{}{
+		"status": models.DataSubjectRequestFailed,
+		"error":  message,
+	}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%v; failed to mark data request as failed: %w", cause, err)
+	}
+
+	if err := s.audit.RecordTx(tx, AuditActor{}, AuditEvent{
+		Action:       models.AuditActionDataRequestFailed,
+		ResourceType: models.AuditResourceDataRequest,
+		ResourceID:   request.ID,
+		Details:      models.AuditDetails{"type": request.Type, "userId": request.UserID, "error": message},
+	}); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%v; %w", cause, err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("%v; failed to commit data request failure: %w", cause, err)
+	}
+
+	return cause
+}
+
+// PurgeExpiredArchives deletes export archives that can no longer be downloaded
+func (s *DataRequestService) PurgeExpiredArchives() error {
+	return s.db.Model(&models.DataSubjectRequest{}).
+		Where("archive IS NOT NULL AND archive_expires_at <= ?", s.now()).
+		Update("archive", nil).Error
+}
+
+// exportRecords returns the JSON form of each record without the omitted fields, which
+// hold related records that are either exported separately or not the subject's data
+func exportRecords(records interface{}, omit ...string) ([]map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+
+	var exported []map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &exported); err != nil {
+		return nil, err
+	}
+	if exported == nil {
+		exported = []map[string]json.RawMessage{}
+	}
+	for _, record := range exported {
+		for _, field := range omit {
+			delete(record, field)
+		}
+	}
+
+	return exported, nil
+}
+
+// buildExport collects everything held about the request's subject into a ZIP archive of JSON files
+func (s *DataRequestService) buildExport(tx *gorm.DB, request *models.DataSubjectRequest, generatedAt time.Time) ([]byte, error) {
+	var user models.User
+	if err := tx.Where("id = ?", request.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataRequestSubjectMissing
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// The national ID is left out of API responses, but it is the subject's own data
+	userRecords, err := exportRecords([]models.User{user})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode user.json: %w", err)
+	}
+	if user.NationalID != nil {
+		if userRecords[0]["nationalId"], err = json.Marshal(*user.NationalID); err != nil {
+			return nil, fmt.Errorf("failed to encode user.json: %w", err)
+		}
+	}
+
+	files := map[string]interface{}{
+		"manifest.json": map[string]interface{}{
+			"formatVersion": dataExportFormatVersion,
+			"requestId":     request.ID,
+			"userId":        user.ID,
+			"generatedAt":   generatedAt.UTC(),
+		},
+		"user.json": userRecords[0],
+	}
+
+	// Each related table is exported as its own file
+	var payments []models.Payment
+	var transactions []models.PaymentTransaction
+	var notifications []models.Notification
+	var memberships []models.UserMunicipality
+	var households []models.HouseholdMember
+	var identities []models.UserIdentity
+	var sessions []models.Session
+	var requests []models.DataSubjectRequest
+	var consents []models.ConsentRecord
+	for _, export := range []struct {
+		file    string
+		records interface{}
+		query   *gorm.DB
+		omit    []string
+	}{
+		{"payments.json", &payments, tx.Where("user_id = ?", user.ID), []string{"user", "municipality", "transactions"}},
+		{"payment_transactions.json", &transactions, tx.Joins("JOIN payments ON payments.id = payment_transactions.payment_id").
+			Where("payments.user_id = ?", user.ID), []string{"payment"}},
+		{"notifications.json", &notifications, tx.Where("user_id = ?", user.ID), []string{"user"}},
+		{"municipalities.json", &memberships, tx.Preload("Municipality").Where("user_id = ?", user.ID), []string{"user"}},
+		{"households.json", &households, tx.Preload("Household").Where("user_id = ?", user.ID), []string{"user"}},
+		{"identities.json", &identities, tx.Where("user_id = ?", user.ID), []string{"user"}},
+		{"sessions.json", &sessions, tx.Where("user_id = ?", user.ID), []string{"user"}},
+		{"data_requests.json", &requests, tx.Omit("archive").Where("user_id = ?", user.ID), nil},
+		{"consents.json", &consents, tx.Where("user_id = ?", user.ID), nil},
+	} {
+		if err := export.query.Order("created_at ASC").Find(export.records).Error; err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", export.file, err)
+		}
+		records, err := exportRecords(export.records, export.omit...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", export.file, err)
+		}
+		files[export.file] = records
+	}
+
+	return dataExportArchive(files)
+}
+
+// dataExportArchive writes each entry as an indented JSON file of a ZIP archive, in name order
+func dataExportArchive(files map[string]interface{}) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := archive.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(files[name]); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write data export: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// erase pseudonymises the request's subject and deletes their personal records within tx.
+// Payments and their transactions are kept with identifying fields removed; the audit log
+// is append-only and keeps the user's ID, but loses the client IP addresses of their actions.
+func (s *DataRequestService) erase(tx *gorm.DB, request *models.DataSubjectRequest, erasedAt time.Time) error {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", request.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDataRequestSubjectMissing
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.IsErased() {
+		return nil
+	}
+
+	// Access tokens already issued must stop working; refresh tokens are deleted below.
+	// Doing this first is harmless if the erasure then fails.
+	if err := s.revocationService.InvalidateAccessTokens(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	for _, record := range []interface{}{
+		&models.Auth{},
+		&models.PasswordResetToken{},
+		&models.VerificationCode{},
+		&models.UserMFA{},
+		&models.MFARecoveryCode{},
+		&models.UserIdentity{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.Notification{},
+		&models.UserMunicipality{},
+		&models.HouseholdMember{},
+	} {
+		if err := tx.Where("user_id = ?", user.ID).Delete(record).Error; err != nil {
+			return fmt.Errorf("failed to erase %T: %w", record, err)
+		}
+	}
+
+	if err := tx.Where("throttle_key = ?", accountKey(user.Email)).Delete(&models.LoginAttempt{}).Error; err != nil {
+		return fmt.Errorf("failed to erase login attempts: %w", err)
+	}
+
+	pseudonym := ErasedEmail(user.ID)
+	if err := tx.Model(&models.StaffInvitation{}).
+		Where("accepted_user_id = ? OR LOWER(email) = LOWER(?)", user.ID, user.Email).
+		Update("email", pseudonym).Error; err != nil {
+		return fmt.Errorf("failed to erase staff invitations: %w", err)
+	}
+
+	if err := s.stripPaymentIdentity(tx, user.ID); err != nil {
+		return err
+	}
+
+	// Consent records are kept as evidence of what was agreed, without the client details
+	if err := tx.Model(&models.ConsentRecord{}).Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"ip_address": "", "user_agent": ""}).Error; err != nil {
+		return fmt.Errorf("failed to erase consent client details: %w", err)
+	}
+
+	// The only change the audit log allows; newer entries leave the IP address out of their hash
+	if err := tx.Model(&models.AuditLog{}).
+		Where("(actor_id = ? OR impersonator_id = ?) AND ip_erased_at IS NULL", user.ID, user.ID).
+		Updates(map[string]interface{}{"ip_address": "", "ip_erased_at": erasedAt}).Error; err != nil {
+		return fmt.Errorf("failed to erase audit log client addresses: %w", err)
+	}
+
+	// Earlier exports hold the same data
+	if err := tx.Model(&models.DataSubjectRequest{}).Where("user_id = ? AND archive IS NOT NULL", user.ID).
+		Update("archive", nil).Error; err != nil {
+		return fmt.Errorf("failed to erase data exports: %w", err)
+	}
+
+	user.Email = pseudonym
+	user.FirstName = "Erased"
+	user.LastName = "User"
+	user.Phone = nil
+	user.NationalID = nil
+	user.EmailVerifiedAt = nil
+	user.PhoneVerifiedAt = nil
+	user.PasswordResetRequired = false
+	user.ErasedAt = &erasedAt
+	if !user.IsDisabled() {
+		user.DisabledAt = &erasedAt
+	}
+
+	// Updated from the struct so the email address is encrypted and its blind index recomputed
+	if err := tx.Model(&user).Select(
+		"email", "email_index", "first_name", "last_name", "phone", "phone_index", "national_id", "national_id_index",
+		"email_verified_at", "phone_verified_at", "password_reset_required", "erased_at", "disabled_at",
+	).Updates(&user).Error; err != nil {
+		return fmt.Errorf("failed to pseudonymise user: %w", err)
+	}
+
+	// No before/after state: the audit log must not keep the erased details
+	return s.audit.RecordTx(tx, AuditActor{}, AuditEvent{
+		Action:       models.AuditActionUserErased,
+		ResourceType: models.AuditResourceUser,
+		ResourceID:   user.ID,
+		Details:      models.AuditDetails{"requestId": request.ID},
+	})
+}
+
+// stripPaymentIdentity removes the payer's identifying fields from the transactions of
+// the user's payments. The payments themselves only refer to the pseudonymised user.
+func (s *DataRequestService) stripPaymentIdentity(tx *gorm.DB, userID string) error {
+	var transactions []models.PaymentTransaction
+	if err := tx.Joins("JOIN payments ON payments.id = payment_transactions.payment_id").
+		Where("payments.user_id = ? AND payment_transactions.transaction_data IS NOT NULL", userID).
+		Find(&transactions).Error; err != nil {
+		return fmt.Errorf("failed to load payment transactions: %w", err)
+	}
+
+	for i := range transactions {
+		data, changed := stripPersonalData(transactions[i].TransactionData)
+		if !changed {
+			continue
+		}
+		transactions[i].TransactionData = data
+		if err := tx.Model(&transactions[i]).Select("transaction_data").Updates(&transactions[i]).Error; err != nil {
+			return fmt.Errorf("failed to strip payment transaction: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// stripPersonalData returns data without the fields that identify the payer, and whether any were removed
+func stripPersonalData(data map[string]interface{}) (map[string]interface{}, bool) {
+	stripped := make(map[string]interface{}, len(data))
+	changed := false
+	for key, value := range data {
+		if isPersonalDataKey(key) {
+			changed = true
+			continue
+		}
+		stripped[key] = value
+	}
+	return stripped, changed
+}
+
+// isPersonalDataKey reports whether a transaction data field identifies the payer
+func isPersonalDataKey(key string) bool {
+	for _, personal := range personalDataKeys {
+		if strings.EqualFold(key, personal) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErasedEmail is the placeholder address given to an erased user, unique per user
+// so the users' email index still holds
+func ErasedEmail(userID string) string {
+	return "erased-" + userID + "@erased.invalid"
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"municollect/internal/models"
+)
+
+func TestStripPersonalData(t *testing.T) {
+	data, changed := stripPersonalData(map[string]interface{}{
+		"firstName": "Somchai",
+		"LastName":  "Jaidee",
+		"email":     "somchai@example.com",
+		"phone":     "0812345678",
+		"reference": "INV-2024-001",
+		"apiKeyId":  "key-1",
+	})
+	assert.True(t, changed)
+	assert.Equal(t, map[string]interface{}{"reference": "INV-2024-001", "apiKeyId": "key-1"}, data)
+
+	// Transactions without identifying fields are left alone
+	_, changed = stripPersonalData(map[string]interface{}{"reference": "INV-2024-001"})
+	assert.False(t, changed)
+}
+
+func TestExportRecords(t *testing.T) {
+	records, err := exportRecords([]models.Notification{
+		{ID: "notification-1", UserID: "user-1", Title: "Payment received"},
+	}, "user")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	assert.NotContains(t, records[0], "user")
+	assert.JSONEq(t, `"notification-1"`, string(records[0]["id"]))
+	assert.JSONEq(t, `"Payment received"`, string(records[0]["title"]))
+
+	// No records export as an empty list rather than null
+	records, err = exportRecords([]models.Session(nil), "user")
+	require.NoError(t, err)
+	encoded, err := json.Marshal(records)
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(encoded))
+}
+
+func TestDataExportArchive(t *testing.T) {
+	archive, err := dataExportArchive(map[string]interface{}{
+		"user.json":     map[string]string{"id": "user-1"},
+		"manifest.json": map[string]int{"formatVersion": dataExportFormatVersion},
+	})
+	require.NoError(t, err)
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	require.Len(t, reader.File, 2)
+	assert.Equal(t, "manifest.json", reader.File[0].Name)
+	assert.Equal(t, "user.json", reader.File[1].Name)
+
+	file, err := reader.File[1].Open()
+	require.NoError(t, err)
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"user-1"}`, string(content))
+}
+
+func TestDataSubjectRequest_ArchiveAvailable(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	request := &models.DataSubjectRequest{
+		Type:             models.DataSubjectRequestExport,
+		Status:           models.DataSubjectRequestCompleted,
+		Archive:          []byte("PK"),
+		ArchiveExpiresAt: &expiresAt,
+	}
+	assert.True(t, request.ArchiveAvailable(now))
+	assert.False(t, request.ArchiveAvailable(expiresAt))
+
+	request.Archive = nil
+	assert.False(t, request.ArchiveAvailable(now), "purged archives cannot be downloaded")
+}
+
+func TestErasedEmail(t *testing.T) {
+	email := ErasedEmail("5f0c7a4e-8d3b-4c1e-9a2f-1b6d8e3c4a7f")
+	assert.Equal(t, "erased-5f0c7a4e-8d3b-4c1e-9a2f-1b6d8e3c4a7f@erased.invalid", email)
+	assert.NoError(t, models.ValidateStruct(&struct {
+		Email string `validate:"email"`
+	}{email}))
+}
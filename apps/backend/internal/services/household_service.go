@@ -0,0 +1,424 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/models"
+)
+
+// Household registration errors
+var (
+	ErrInvalidNationalID        = errors.New("national ID is not a valid 13-digit Thai citizen ID")
+	ErrNationalIDInUse          = errors.New("national ID is registered to another user")
+	ErrNationalIDLocked         = errors.New("national ID cannot be changed while the user is registered to a household")
+	ErrResidentNotFound         = errors.New("no resident of this municipality has that national ID")
+	ErrHouseholdNotFound        = errors.New("household not found")
+	ErrHouseholdExists          = errors.New("a household with this house number already exists")
+	ErrHouseholdMemberExists    = errors.New("user is already registered to a household")
+	ErrHouseholdMemberNotFound  = errors.New("user is not a member of this household")
+	ErrHouseholdNationalIDUnset = errors.New("user has not registered a national ID")
+)
+
+// ResidentRecord is a resident found by national ID, with the household they are registered to
+type ResidentRecord struct {
+	User      *models.User      `json:"user"`
+	Household *models.Household `json:"household,omitempty"`
+}
+
+// HouseholdService manages residents' national IDs and the municipalities' house registrations.
+// Residents are bound to households by their national ID, which only staff of the municipality
+// can look up; every lookup is recorded in the audit log.
+type HouseholdService struct {
+	db    *gorm.DB
+	audit *AuditService
+}
+
+// NewHouseholdService creates a new household service
+func NewHouseholdService(db *gorm.DB, audit *AuditService) *HouseholdService {
+	return &HouseholdService{
+		db:    db,
+		audit: audit,
+	}
+}
+
+// SetNationalID registers the user's national ID. It cannot be changed once the user is
+// bound to a household, since the household registration is keyed by it.
+func (s *HouseholdService) SetNationalID(actor AuditActor, userID, nationalID string) (*models.User, error) {
+	nationalID = models.NormalizeThaiNationalID(nationalID)
+	if err := models.ValidateThaiNationalID(nationalID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNationalID, err)
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.NationalID != nil && *user.NationalID == nationalID {
+		tx.Rollback()
+		return &user, nil
+	}
+
+	var taken int64
+	if err := tx.Model(&models.User{}).
+		Where("national_id_index = ? AND id <> ?", models.NationalIDIndex(nationalID), userID).
+		Count(&taken).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to check national ID: %w", err)
+	}
+	if taken > 0 {
+		tx.Rollback()
+		return nil, ErrNationalIDInUse
+	}
+
+	if user.NationalID != nil {
+		var members int64
+		if err := tx.Model(&models.HouseholdMember{}).Where("user_id = ?", userID).Count(&members).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to check household membership: %w", err)
+		}
+		if members > 0 {
+			tx.Rollback()
+			return nil, ErrNationalIDLocked
+		}
+	}
+
+	// The national ID is encrypted, so it must be written from the struct
+	user.NationalID = &nationalID
+	user.SetBlindIndexes()
+	if err := tx.Model(&user).Select("national_id", "national_id_index").Updates(&user).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update national ID: %w", err)
+	}
+
+	// The ID itself is never written to the audit log
+	if err := s.audit.RecordTx(tx, actor, AuditEvent{
+		Action:       models.AuditActionUserNationalIDSet,
+		ResourceType: models.AuditResourceUser,
+		ResourceID:   user.ID,
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit national ID: %w", err)
+	}
+
+	return &user, nil
+}
+
+// FindResident looks up a resident of the municipality by national ID. Only users who are
+// members of the municipality are found. The lookup is audited whether or not it matches.
+func (s *HouseholdService) FindResident(actor AuditActor, municipalityID, nationalID string) (*ResidentRecord, error) {
+	nationalID = models.NormalizeThaiNationalID(nationalID)
+	if err := models.ValidateThaiNationalID(nationalID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNationalID, err)
+	}
+
+	var user models.User
+	err := s.db.Where("national_id_index = ?", models.NationalIDIndex(nationalID)).
+		Where("id IN (?)", s.db.Model(&models.UserMunicipality{}).Select("user_id").Where("municipality_id = ?", municipalityID)).
+		First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to look up resident: %w", err)
+	}
+	found := err == nil
+
+	event := AuditEvent{
+		Action:       models.AuditActionResidentLookedUp,
+		ResourceType: models.AuditResourceMunicipality,
+		ResourceID:   municipalityID,
+		Details:      models.AuditDetails{"found": found},
+	}
+	if found {
+		event.ResourceType = models.AuditResourceUser
+		event.ResourceID = user.ID
+		event.Details["municipalityId"] = municipalityID
+	}
+	if err := s.audit.Record(actor, event); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrResidentNotFound
+	}
+
+	record := &ResidentRecord{User: &user}
+	household, err := s.UserHousehold(user.ID)
+	if err != nil && !errors.Is(err, ErrHouseholdNotFound) {
+		return nil, err
+	}
+	if household != nil && household.MunicipalityID == municipalityID {
+		record.Household = household
+	}
+
+	return record, nil
+}
+
+// ListHouseholds returns the municipality's households ordered by house number
+func (s *HouseholdService) ListHouseholds(municipalityID string, limit, offset int) ([]models.Household, int64, error) {
+	query := s.db.Model(&models.Household{}).Where("municipality_id = ?", municipalityID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count households: %w", err)
+	}
+
+	var households []models.Household
+	if err := query.Order("house_number ASC").Limit(limit).Offset(offset).Find(&households).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list households: %w", err)
+	}
+
+	return households, total, nil
+}
+
+// CreateHousehold registers a house in the municipality
+func (s *HouseholdService) CreateHousehold(actor AuditActor, municipalityID, houseNumber, address string) (*models.Household, error) {
+	household := &models.Household{
+		MunicipalityID: municipalityID,
+		HouseNumber:    houseNumber,
+		Address:        address,
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var existing int64
+	if err := tx.Model(&models.Household{}).
+		Where("municipality_id = ? AND house_number = ?", municipalityID, houseNumber).
+		Count(&existing).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to check house number: %w", err)
+	}
+	if existing > 0 {
+		tx.Rollback()
+		return nil, ErrHouseholdExists
+	}
+
+	if err := tx.Create(household).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create household: %w", err)
+	}
+
+	if err := s.audit.RecordTx(tx, actor, AuditEvent{
+		Action:       models.AuditActionHouseholdCreated,
+		ResourceType: models.AuditResourceHousehold,
+		ResourceID:   household.ID,
+		Details:      models.AuditDetails{"municipalityId": municipalityID, "houseNumber": houseNumber},
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit household: %w", err)
+	}
+
+	return household, nil
+}
+
+// GetHousehold returns one of the municipality's households with its members
+func (s *HouseholdService) GetHousehold(municipalityID, householdID string) (*models.Household, error) {
+	var household models.Household
+	err := s.db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("is_head DESC, created_at ASC")
+	}).Preload("Members.User").
+		Where("id = ? AND municipality_id = ?", householdID, municipalityID).
+		First(&household).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHouseholdNotFound
+		}
+		return nil, fmt.Errorf("failed to get household: %w", err)
+	}
+
+	return &household, nil
+}
+
+// UserHousehold returns the household the user is registered to, with its members
+func (s *HouseholdService) UserHousehold(userID string) (*models.Household, error) {
+	var member models.HouseholdMember
+	if err := s.db.Where("user_id = ?", userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHouseholdNotFound
+		}
+		return nil, fmt.Errorf("failed to get household membership: %w", err)
+	}
+
+	var household models.Household
+	if err := s.db.Where("id = ?", member.HouseholdID).First(&household).Error; err != nil {
+		return nil, fmt.Errorf("failed to get household: %w", err)
+	}
+	return s.GetHousehold(household.MunicipalityID, household.ID)
+}
+
+// AddMember binds the resident with the given national ID to one of the municipality's
+// households, making them a resident of the municipality if they are not a member yet.
+// A user belongs to at most one household; a new head replaces the previous one.
+func (s *HouseholdService) AddMember(actor AuditActor, municipalityID, householdID, nationalID string, isHead bool) (*models.HouseholdMember, error) {
+	nationalID = models.NormalizeThaiNationalID(nationalID)
+	if err := models.ValidateThaiNationalID(nationalID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNationalID, err)
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the household so concurrent changes to its head are serialised
+	var household models.Household
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND municipality_id = ?", householdID, municipalityID).
+		First(&household).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHouseholdNotFound
+		}
+		return nil, fmt.Errorf("failed to get household: %w", err)
+	}
+
+	var user models.User
+	if err := tx.Where("national_id_index = ?", models.NationalIDIndex(nationalID)).First(&user).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHouseholdNationalIDUnset
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var existing int64
+	if err := tx.Model(&models.HouseholdMember{}).Where("user_id = ?", user.ID).Count(&existing).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to check household membership: %w", err)
+	}
+	if existing > 0 {
+		tx.Rollback()
+		return nil, ErrHouseholdMemberExists
+	}
+
+	if isHead {
+		if err := tx.Model(&models.HouseholdMember{}).
+			Where("household_id = ? AND is_head = ?", household.ID, true).
+			Update("is_head", false).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to replace household head: %w", err)
+		}
+	}
+
+	member := &models.HouseholdMember{
+		HouseholdID: household.ID,
+		UserID:      user.ID,
+		IsHead:      isHead,
+	}
+	if err := tx.Create(member).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to add household member: %w", err)
+	}
+
+	var memberships int64
+	if err := tx.Model(&models.UserMunicipality{}).
+		Where("user_id = ? AND municipality_id = ?", user.ID, municipalityID).
+		Count(&memberships).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to check municipality membership: %w", err)
+	}
+	if memberships == 0 {
+		if err := tx.Create(&models.UserMunicipality{UserID: user.ID, MunicipalityID: municipalityID}).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to create municipality membership: %w", err)
+		}
+	}
+
+	if err := s.audit.RecordTx(tx, actor, AuditEvent{
+		Action:       models.AuditActionHouseholdMemberAdded,
+		ResourceType: models.AuditResourceHousehold,
+		ResourceID:   household.ID,
+		Details:      models.AuditDetails{"userId": user.ID, "isHead": isHead},
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit household member: %w", err)
+	}
+
+	member.User = &user
+	return member, nil
+}
+
+// RemoveMember unbinds a user from one of the municipality's households. Their municipality
+// membership is left as it is.
+func (s *HouseholdService) RemoveMember(actor AuditActor, municipalityID, householdID, userID string) error {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var household models.Household
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND municipality_id = ?", householdID, municipalityID).
+		First(&household).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrHouseholdNotFound
+		}
+		return fmt.Errorf("failed to get household: %w", err)
+	}
+
+	result := tx.Where("household_id = ? AND user_id = ?", household.ID, userID).Delete(&models.HouseholdMember{})
+	if result.Error != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to remove household member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrHouseholdMemberNotFound
+	}
+
+	if err := s.audit.RecordTx(tx, actor, AuditEvent{
+		Action:       models.AuditActionHouseholdMemberRemoved,
+		ResourceType: models.AuditResourceHousehold,
+		ResourceID:   household.ID,
+		Details:      models.AuditDetails{"userId": userID},
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit household member removal: %w", err)
+	}
+
+	return nil
+}
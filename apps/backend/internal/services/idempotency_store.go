@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/middleware"
+	"municollect/internal/models"
+)
+
+// DBIdempotencyStore is a database-backed middleware.IdempotencyStore shared by every
+// backend replica. Stored responses are encrypted like other personal data.
+type DBIdempotencyStore struct {
+	db *gorm.DB
+}
+
+// NewDBIdempotencyStore creates a new database-backed idempotency store
+func NewDBIdempotencyStore(db *gorm.DB) *DBIdempotencyStore {
+	return &DBIdempotencyStore{
+		db: db,
+	}
+}
+
+// Begin claims the key for a request with the given fingerprint. The unique index on
+// user, scope and key lets exactly one of several concurrent requests claim it.
+func (s *DBIdempotencyStore) Begin(userID, scope, key, fingerprint string, expiresAt time.Time) (*middleware.IdempotentResponse, error) {
+	// An expired key can be claimed again
+	if err := s.keyQuery(userID, scope, key).Where("expires_at < ?", time.Now()).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete expired idempotency key: %w", err)
+	}
+
+	record := &models.IdempotencyKey{
+		UserID:      userID,
+		Scope:       scope,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   expiresAt,
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := s.keyQuery(userID, scope, key).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released between the insert and the lookup; the client may simply retry
+			return nil, middleware.ErrIdempotencyKeyInProgress
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if existing.Fingerprint != fingerprint {
+		return nil, middleware.ErrIdempotencyKeyMismatch
+	}
+	if !existing.IsCompleted() || existing.ResponseStatus == nil {
+		return nil, middleware.ErrIdempotencyKeyInProgress
+	}
+
+	response := &middleware.IdempotentResponse{
+		Status:      *existing.ResponseStatus,
+		ContentType: existing.ResponseContentType,
+	}
+	if existing.ResponseBody != nil {
+		response.Body = []byte(*existing.ResponseBody)
+	}
+	return response, nil
+}
+
+// Complete stores the response of the request holding the key. The update is made from
+// the struct so the serializer encrypts the body.
+func (s *DBIdempotencyStore) Complete(userID, scope, key string, response *middleware.IdempotentResponse) error {
+	now := time.Now()
+	body := string(response.Body)
+	record := &models.IdempotencyKey{
+		ResponseStatus:      &response.Status,
+		ResponseContentType: response.ContentType,
+		ResponseBody:        &body,
+		CompletedAt:         &now,
+	}
+
+	if err := s.keyQuery(userID, scope, key).Model(&models.IdempotencyKey{}).
+		Select("response_status", "response_content_type", "response_body", "completed_at").
+		Updates(record).Error; err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+
+	return nil
+}
+
+// Release forgets the key without a response
+func (s *DBIdempotencyStore) Release(userID, scope, key string) error {
+	if err := s.keyQuery(userID, scope, key).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes idempotency keys that have expired
+func (s *DBIdempotencyStore) DeleteExpired() error {
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	return nil
+}
+
+// keyQuery selects a user's idempotency key in a scope
+func (s *DBIdempotencyStore) keyQuery(userID, scope, key string) *gorm.DB {
+	return s.db.Where("user_id = ? AND scope = ? AND idempotency_key = ?", userID, scope, key)
+}
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/models"
+)
+
+// InvitationLifetime is how long a staff invitation link stays valid
+const InvitationLifetime = 7 * 24 * time.Hour
+
+// Staff invitation errors
+var (
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationInvalid    = errors.New("invitation is invalid or has expired")
+	ErrInvitationClosed     = errors.New("invitation has already been accepted or revoked")
+	ErrInvitationPending    = errors.New("an invitation for this email is already pending")
+	ErrInvitationEmailInUse = errors.New("a user with this email already exists")
+)
+
+// AcceptInvitationDetails are the details the invitee fills in when accepting
+type AcceptInvitationDetails struct {
+	FirstName    string
+	LastName     string
+	Phone        *string
+	PasswordHash string
+}
+
+// InvitationService handles inviting staff to a municipality and onboarding them
+type InvitationService struct {
+	db          *gorm.DB
+	mailer      MailSender
+	permissions *PermissionService
+	audit       *AuditService
+	acceptURL   string
+	now         func() time.Time
+}
+
+// NewInvitationService creates a new invitation service.
+// The link sent to invitees points at INVITATION_ACCEPT_URL.
+func NewInvitationService(db *gorm.DB, mailer MailSender, permissions *PermissionService, audit *AuditService) *InvitationService {
+	acceptURL := os.Getenv("INVITATION_ACCEPT_URL")
+	if acceptURL == "" {
+		acceptURL = "http://localhost:3000/accept-invitation"
+	}
+
+	return &InvitationService{
+		db:          db,
+		mailer:      mailer,
+		permissions: permissions,
+		audit:       audit,
+		acceptURL:   acceptURL,
+		now:         time.Now,
+	}
+}
+
+// GlobalRoleForMunicipalRole returns the global role given to a user who joins through an
+// invitation. Municipal admins are staff globally; platform admins are never created this way.
+func GlobalRoleForMunicipalRole(key string) models.UserRole {
+	if key == models.MunicipalRoleResident {
+		return models.UserRoleResident
+	}
+	return models.UserRoleMunicipalStaff
+}
+
+// Invite creates an invitation to join the municipality with the given role and emails the link
+func (s *InvitationService) Invite(actor AuditActor, municipalityID, email, role string) (*models.StaffInvitation, error) {
+	email = strings.TrimSpace(email)
+
+	var municipality models.Municipality
+	if err := s.db.Where("id = ?", municipalityID).First(&municipality).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMembershipMunicipalityNotFound
+		}
+		return nil, fmt.Errorf("failed to get municipality: %w", err)
+	}
+	if _, err := s.permissions.GetRole(municipalityID, role); err != nil {
+		return nil, err
+	}
+
+	// Existing users are given a role through their membership instead
+	var users int64
+	if err := s.db.Model(&models.User{}).Where("email_index = ?", models.EmailIndex(email)).Count(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to check existing users: %w", err)
+	}
+	if users > 0 {
+		return nil, ErrInvitationEmailInUse
+	}
+
+	var open int64
+	if err := s.db.Model(&models.StaffInvitation{}).
+		Where("municipality_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", municipalityID, email).
+		Count(&open).Error; err != nil {
+		return nil, fmt.Errorf("failed to check pending invitations: %w", err)
+	}
+	if open > 0 {
+		return nil, ErrInvitationPending
+	}
+
+	token, err := generateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &models.StaffInvitation{
+		MunicipalityID: municipalityID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      hashToken(token),
+		ExpiresAt:      s.now().Add(InvitationLifetime),
+	}
+	if actor.UserID != "" {
+		invitation.InvitedByID = &actor.UserID
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(invitation).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+	if err := s.audit.RecordTx(tx, actor, invitationEvent(models.AuditActionInvitationCreated, invitation)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+
+	invitation.Municipality = municipality
+	if err := s.send(invitation, token); err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// Resend issues a fresh link for an open invitation, restarting its expiry, and emails it again.
+// The previous link stops working.
+func (s *InvitationService) Resend(actor AuditActor, municipalityID, invitationID string) (*models.StaffInvitation, error) {
+	invitation, err := s.getOpen(municipalityID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	invitation.TokenHash = hashToken(token)
+	invitation.ExpiresAt = s.now().Add(InvitationLifetime)
+	if err := tx.Model(invitation).Updates(map[string]interface{}{
+		"token_hash": invitation.TokenHash,
+		"expires_at": invitation.ExpiresAt,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to renew invitation: %w", err)
+	}
+	if err := s.audit.RecordTx(tx, actor, invitationEvent(models.AuditActionInvitationResent, invitation)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+
+	if err := s.send(invitation, token); err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// Revoke cancels an open invitation so its link can no longer be used
+func (s *InvitationService) Revoke(actor AuditActor, municipalityID, invitationID string) error {
+	invitation, err := s.getOpen(municipalityID, invitationID)
+	if err != nil {
+		return err
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result := tx.Model(&models.StaffInvitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+		Update("revoked_at", s.now())
+	if result.Error != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to revoke invitation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrInvitationClosed
+	}
+	if err := s.audit.RecordTx(tx, actor, invitationEvent(models.AuditActionInvitationRevoked, invitation)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit invitation revocation: %w", err)
+	}
+
+	return nil
+}
+
+// ListPending returns the municipality's invitations that have been neither accepted nor
+// revoked, newest first. Expired invitations are included so they can be resent.
+func (s *InvitationService) ListPending(municipalityID string) ([]models.StaffInvitation, error) {
+	var invitations []models.StaffInvitation
+	err := s.db.Where("municipality_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", municipalityID).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// GetByToken returns the usable invitation with the given token, with its municipality
+func (s *InvitationService) GetByToken(token string) (*models.StaffInvitation, error) {
+	var invitation models.StaffInvitation
+	if err := s.db.Preload("Municipality").Where("token_hash = ?", hashToken(token)).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	if !invitation.IsUsable() {
+		return nil, ErrInvitationInvalid
+	}
+
+	return &invitation, nil
+}
+
+// Accept consumes the invitation and creates the invitee's user, credentials and
+// membership in one transaction. The email address counts as verified, since the
+// invitee received the link there.
+func (s *InvitationService) Accept(actor AuditActor, token string, details AcceptInvitationDetails) (*models.User, error) {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the invitation so it can only be accepted once
+	var invitation models.StaffInvitation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", hashToken(token)).First(&invitation).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	if !invitation.IsUsable() {
+		tx.Rollback()
+		return nil, ErrInvitationInvalid
+	}
+
+	var users int64
+	if err := tx.Model(&models.User{}).Where("email_index = ?", models.EmailIndex(invitation.Email)).Count(&users).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to check existing users: %w", err)
+	}
+	if users > 0 {
+		tx.Rollback()
+		return nil, ErrInvitationEmailInUse
+	}
+
+	now := s.now()
+	user := &models.User{
+		Email:           invitation.Email,
+		FirstName:       details.FirstName,
+		LastName:        details.LastName,
+		Phone:           details.Phone,
+		Role:            GlobalRoleForMunicipalRole(invitation.Role),
+		EmailVerifiedAt: &now,
+	}
+	if err := tx.Create(user).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	authRecord := &models.Auth{
+		UserID:       user.ID,
+		PasswordHash: details.PasswordHash,
+	}
+	if err := tx.Create(authRecord).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create authentication record: %w", err)
+	}
+
+	membership := &models.UserMunicipality{
+		UserID:         user.ID,
+		MunicipalityID: invitation.MunicipalityID,
+		Role:           invitation.Role,
+	}
+	if err := tx.Create(membership).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create municipality membership: %w", err)
+	}
+
+	if err := tx.Model(&invitation).Updates(map[string]interface{}{
+		"accepted_at":      now,
+		"accepted_user_id": user.ID,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	actor.UserID = user.ID
+	if err := s.audit.RecordTx(tx, actor, invitationEvent(models.AuditActionInvitationAccepted, &invitation)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit invitation acceptance: %w", err)
+	}
+
+	return user, nil
+}
+
+// getOpen returns one of the municipality's invitations that has been neither accepted nor revoked
+func (s *InvitationService) getOpen(municipalityID, invitationID string) (*models.StaffInvitation, error) {
+	var invitation models.StaffInvitation
+	err := s.db.Preload("Municipality").
+		Where("id = ? AND municipality_id = ?", invitationID, municipalityID).
+		First(&invitation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	if !invitation.IsOpen() {
+		return nil, ErrInvitationClosed
+	}
+
+	return &invitation, nil
+}
+
+// send emails the invitation link
+func (s *InvitationService) send(invitation *models.StaffInvitation, token string) error {
+	link := fmt.Sprintf("%s?token=%s", s.acceptURL, url.QueryEscape(token))
+	msg := MailMessage{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You're invited to join %s on MuniCollect", invitation.Municipality.Name),
+		Body: fmt.Sprintf("Hello,\n\nYou have been invited to join %s on MuniCollect as %s. "+
+			"Open the link below within %d days to set your password and activate your account:\n\n%s\n\n"+
+			"If you were not expecting this invitation, you can ignore this email.",
+			invitation.Municipality.Name, invitation.Role, int(InvitationLifetime.Hours()/24), link),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		return fmt.Errorf("failed to send invitation email: %w", err)
+	}
+
+	return nil
+}
+
+// invitationEvent builds the audit event for an invitation action. The invitee's email is
+// left out: the audit log cannot be erased, while the invitation itself can be.
+func invitationEvent(action models.AuditAction, invitation *models.StaffInvitation) AuditEvent {
+	return AuditEvent{
+		Action:       action,
+		ResourceType: models.AuditResourceStaffInvitation,
+		ResourceID:   invitation.ID,
+		Details: models.AuditDetails{
+			"municipalityId": invitation.MunicipalityID,
+			"role":           invitation.Role,
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"municollect/internal/models"
+)
+
+// recordingMailSender keeps the messages it is asked to send
+type recordingMailSender struct {
+	sent []MailMessage
+}
+
+func (r *recordingMailSender) Send(msg MailMessage) error {
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func TestGlobalRoleForMunicipalRole(t *testing.T) {
+	assert.Equal(t, models.UserRoleResident, GlobalRoleForMunicipalRole(models.MunicipalRoleResident))
+	assert.Equal(t, models.UserRoleMunicipalStaff, GlobalRoleForMunicipalRole(models.MunicipalRoleFinance))
+	assert.Equal(t, models.UserRoleMunicipalStaff, GlobalRoleForMunicipalRole(models.MunicipalRoleCollector))
+	assert.Equal(t, models.UserRoleMunicipalStaff, GlobalRoleForMunicipalRole("night_shift"))
+
+	// Municipal admins never become platform admins
+	assert.Equal(t, models.UserRoleMunicipalStaff, GlobalRoleForMunicipalRole(models.MunicipalRoleAdmin))
+}
+
+func TestInvitationService_SendLinksToToken(t *testing.T) {
+	mailer := &recordingMailSender{}
+	service := &InvitationService{mailer: mailer, acceptURL: "https://app.example.com/accept-invitation"}
+
+	invitation := &models.StaffInvitation{
+		Email:        "somchai@example.com",
+		Role:         models.MunicipalRoleFinance,
+		Municipality: models.Municipality{Name: "Chiang Mai"},
+	}
+	require.NoError(t, service.send(invitation, "tok+en/1"))
+	require.Len(t, mailer.sent, 1)
+
+	msg := mailer.sent[0]
+	assert.Equal(t, "somchai@example.com", msg.To)
+	assert.Contains(t, msg.Subject, "Chiang Mai")
+	assert.Contains(t, msg.Body, "as finance")
+	assert.Contains(t, msg.Body, "https://app.example.com/accept-invitation?token="+url.QueryEscape("tok+en/1"))
+	assert.True(t, strings.Contains(msg.Body, "7 days"))
+}
+
+func TestStaffInvitation_IsUsable(t *testing.T) {
+	now := time.Now()
+
+	open := models.StaffInvitation{ExpiresAt: now.Add(time.Hour)}
+	assert.True(t, open.IsUsable())
+
+	expired := models.StaffInvitation{ExpiresAt: now.Add(-time.Minute)}
+	assert.True(t, expired.IsOpen())
+	assert.False(t, expired.IsUsable())
+
+	accepted := models.StaffInvitation{ExpiresAt: now.Add(time.Hour), AcceptedAt: &now}
+	assert.False(t, accepted.IsOpen())
+	assert.False(t, accepted.IsUsable())
+
+	revoked := models.StaffInvitation{ExpiresAt: now.Add(time.Hour), RevokedAt: &now}
+	assert.False(t, revoked.IsUsable())
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/models"
+)
+
+// LoginAttemptState is the failed login state of one throttling key
+type LoginAttemptState struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// LoginAttemptStore keeps failed login counters. Replicas must share a store for the
+// limits to hold across the whole deployment.
+type LoginAttemptStore interface {
+	// Get returns the state for key, or nil if there is none
+	Get(key string) (*LoginAttemptState, error)
+	// RecordFailure increments the failure counter for key and returns the new state.
+	// Counters whose last failure is older than window start again from one.
+	RecordFailure(key string, now time.Time, window time.Duration) (*LoginAttemptState, error)
+	// Lock blocks key until the given time
+	Lock(key string, until time.Time) error
+	// Reset clears the counters and any lock for key
+	Reset(key string) error
+}
+
+// MemoryLoginAttemptStore is an in-process login attempt store.
+// It is only suitable for a single backend instance.
+type MemoryLoginAttemptStore struct {
+	mu      sync.Mutex
+	entries map[string]*LoginAttemptState
+}
+
+// NewMemoryLoginAttemptStore creates a new in-memory login attempt store
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		entries: make(map[string]*LoginAttemptState),
+	}
+}
+
+// Get returns a copy of the state for key
+func (s *MemoryLoginAttemptStore) Get(key string) (*LoginAttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+
+	copied := *state
+	return &copied, nil
+}
+
+// RecordFailure increments the failure counter for key
+func (s *MemoryLoginAttemptStore) RecordFailure(key string, now time.Time, window time.Duration) (*LoginAttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.entries[key]
+	if !ok || now.Sub(state.LastFailureAt) > window {
+		state = &LoginAttemptState{LockedUntil: lockedUntilOf(state)}
+		s.entries[key] = state
+	}
+
+	state.Failures++
+	state.LastFailureAt = now
+
+	copied := *state
+	return &copied, nil
+}
+
+// Lock blocks key until the given time
+func (s *MemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.entries[key]
+	if !ok {
+		state = &LoginAttemptState{}
+		s.entries[key] = state
+	}
+	state.LockedUntil = until
+
+	return nil
+}
+
+// Reset clears the counters and any lock for key
+func (s *MemoryLoginAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// lockedUntilOf keeps an existing lock when a stale counter is restarted
+func lockedUntilOf(state *LoginAttemptState) time.Time {
+	if state == nil {
+		return time.Time{}
+	}
+	return state.LockedUntil
+}
+
+// DBLoginAttemptStore is a database-backed login attempt store shared by every backend replica
+type DBLoginAttemptStore struct {
+	db *gorm.DB
+}
+
+// NewDBLoginAttemptStore creates a new database-backed login attempt store
+func NewDBLoginAttemptStore(db *gorm.DB) *DBLoginAttemptStore {
+	return &DBLoginAttemptStore{
+		db: db,
+	}
+}
+
+// Get returns the state for key
+func (s *DBLoginAttemptStore) Get(key string) (*LoginAttemptState, error) {
+	var record models.LoginAttempt
+	if err := s.db.Where("throttle_key = ?", key).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get login attempts: %w", err)
+	}
+
+	return loginAttemptStateFromRecord(&record), nil
+}
+
+// RecordFailure increments the failure counter for key in a single upsert,
+// so concurrent failures on different replicas are all counted
+func (s *DBLoginAttemptStore) RecordFailure(key string, now time.Time, window time.Duration) (*LoginAttemptState, error) {
+	record := &models.LoginAttempt{
+		Key:           key,
+		Failures:      1,
+		LastFailureAt: now,
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "throttle_key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":        gorm.Expr("CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END", now.Add(-window)),
+			"last_failure_at": now,
+			"updated_at":      now,
+		}),
+	}).Create(record).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return s.Get(key)
+}
+
+// Lock blocks key until the given time
+func (s *DBLoginAttemptStore) Lock(key string, until time.Time) error {
+	result := s.db.Model(&models.LoginAttempt{}).Where("throttle_key = ?", key).Update("locked_until", until)
+	if result.Error != nil {
+		return fmt.Errorf("failed to lock login key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		record := &models.LoginAttempt{
+			Key:           key,
+			LastFailureAt: time.Now(),
+			LockedUntil:   &until,
+		}
+		if err := s.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "throttle_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"locked_until"}),
+		}).Create(record).Error; err != nil {
+			return fmt.Errorf("failed to lock login key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Reset clears the counters and any lock for key
+func (s *DBLoginAttemptStore) Reset(key string) error {
+	if err := s.db.Where("throttle_key = ?", key).Delete(&models.LoginAttempt{}).Error; err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStale removes counters that have not changed since before the given time
+func (s *DBLoginAttemptStore) DeleteStale(before time.Time) error {
+	if err := s.db.Where("updated_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, time.Now()).
+		Delete(&models.LoginAttempt{}).Error; err != nil {
+		return fmt.Errorf("failed to delete stale login attempts: %w", err)
+	}
+
+	return nil
+}
+
+// loginAttemptStateFromRecord converts a database record to a store state
+func loginAttemptStateFromRecord(record *models.LoginAttempt) *LoginAttemptState {
+	state := &LoginAttemptState{
+		Failures:      record.Failures,
+		LastFailureAt: record.LastFailureAt,
+	}
+	if record.LockedUntil != nil {
+		state.LockedUntil = *record.LockedUntil
+	}
+
+	return state
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// ErrLoginBlocked is wrapped by LoginBlockedError
+var ErrLoginBlocked = errors.New("too many failed login attempts")
+
+// LoginBlockedError is returned when a login is refused because of earlier failures
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+// Error implements the error interface
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account temporarily locked, try again in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Unwrap lets callers match the error with errors.Is(err, ErrLoginBlocked)
+func (e *LoginBlockedError) Unwrap() error {
+	return ErrLoginBlocked
+}
+
+// LoginProtectionConfig holds the brute-force protection limits
+type LoginProtectionConfig struct {
+	// MaxAccountFailures locks an account after this many consecutive failures
+	MaxAccountFailures int
+	// MaxIPFailures blocks a client IP after this many failures across all accounts
+	MaxIPFailures int
+	// LockoutDuration is how long a locked account or IP stays blocked
+	LockoutDuration time.Duration
+	// BackoffBase is the delay after the first failure; it doubles with each further failure
+	BackoffBase time.Duration
+	// BackoffMax caps the backoff delay
+	BackoffMax time.Duration
+	// FailureWindow is how long failures are remembered without a new one
+	FailureWindow time.Duration
+}
+
+// DefaultLoginProtectionConfig returns the default limits, overridden by the
+// LOGIN_MAX_FAILURES, LOGIN_MAX_IP_FAILURES and LOGIN_LOCKOUT_MINUTES settings
+func DefaultLoginProtectionConfig() LoginProtectionConfig {
+	config := LoginProtectionConfig{
+		MaxAccountFailures: 5,
+		MaxIPFailures:      50,
+		LockoutDuration:    15 * time.Minute,
+		BackoffBase:        time.Second,
+		BackoffMax:         time.Minute,
+		FailureWindow:      24 * time.Hour,
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES")); err == nil && n > 0 {
+		config.MaxAccountFailures = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_IP_FAILURES")); err == nil && n > 0 {
+		config.MaxIPFailures = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_MINUTES")); err == nil && n > 0 {
+		config.LockoutDuration = time.Duration(n) * time.Minute
+	}
+
+	return config
+}
+
+// LoginProtectionService limits password guessing per account and per client IP
+type LoginProtectionService struct {
+	db            *gorm.DB
+	store         LoginAttemptStore
+	notifications *NotificationService
+	config        LoginProtectionConfig
+	now           func() time.Time
+	notify        func(email, ip string, lockedUntil time.Time)
+}
+
+// NewLoginProtectionService creates a new login protection service
+func NewLoginProtectionService(db *gorm.DB, store LoginAttemptStore, notifications *NotificationService, config LoginProtectionConfig) *LoginProtectionService {
+	s := &LoginProtectionService{
+		db:            db,
+		store:         store,
+		notifications: notifications,
+		config:        config,
+		now:           time.Now,
+	}
+	s.notify = s.notifyLockout
+
+	return s
+}
+
+// accountKey returns the throttling key for an account. It holds the email's blind index
+// rather than the email, since attempts are kept for addresses without an account too.
+func accountKey(email string) string {
+	return "account:" + models.EmailIndex(email)
+}
+
+// ipKey returns the throttling key for a client IP
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a *LoginBlockedError if a login for the account from the IP must be refused now
+func (s *LoginProtectionService) Check(email, ip string) error {
+	now := s.now()
+
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		state, err := s.store.Get(key)
+		if err != nil {
+			return err
+		}
+		if state == nil {
+			continue
+		}
+
+		if now.Before(state.LockedUntil) {
+			return &LoginBlockedError{RetryAfter: state.LockedUntil.Sub(now), Locked: true}
+		}
+
+		// Exponential backoff only applies to accounts; IPs are limited by the lockout alone
+		if key == accountKey(email) && state.Failures > 0 && now.Sub(state.LastFailureAt) <= s.config.FailureWindow {
+			retryAt := state.LastFailureAt.Add(s.backoff(state.Failures))
+			if now.Before(retryAt) {
+				return &LoginBlockedError{RetryAfter: retryAt.Sub(now)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// backoff returns the delay required after the given number of consecutive failures
+func (s *LoginProtectionService) backoff(failures int) time.Duration {
+	delay := s.config.BackoffBase
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= s.config.BackoffMax {
+			return s.config.BackoffMax
+		}
+	}
+
+	return delay
+}
+
+// RecordFailure counts a failed login. When the account reaches the failure limit it is
+// locked and its owner is notified.
+func (s *LoginProtectionService) RecordFailure(email, ip string) error {
+	now := s.now()
+
+	ipState, err := s.store.RecordFailure(ipKey(ip), now, s.config.FailureWindow)
+	if err != nil {
+		return err
+	}
+	if ipState.Failures >= s.config.MaxIPFailures && !now.Before(ipState.LockedUntil) {
+		if err := s.store.Lock(ipKey(ip), now.Add(s.config.LockoutDuration)); err != nil {
+			return err
+		}
+		log.Printf("SECURITY: blocked IP %s after %d failed logins", ip, ipState.Failures)
+	}
+
+	accountState, err := s.store.RecordFailure(accountKey(email), now, s.config.FailureWindow)
+	if err != nil {
+		return err
+	}
+	if accountState.Failures >= s.config.MaxAccountFailures && !now.Before(accountState.LockedUntil) {
+		lockedUntil := now.Add(s.config.LockoutDuration)
+		if err := s.store.Lock(accountKey(email), lockedUntil); err != nil {
+			return err
+		}
+		s.notify(email, ip, lockedUntil)
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the account's failure counter after a successful login.
+// The IP counter is kept so that one valid account cannot reset it.
+func (s *LoginProtectionService) RecordSuccess(email string) error {
+	return s.store.Reset(accountKey(email))
+}
+
+// Unlock clears the lock and failure counter of an account
+func (s *LoginProtectionService) Unlock(email string) error {
+	return s.store.Reset(accountKey(email))
+}
+
+// notifyLockout tells the account owner about the lockout by email and in-app notification.
+// Failures are logged rather than returned so they never change the login response.
+func (s *LoginProtectionService) notifyLockout(email, ip string, lockedUntil time.Time) {
+	var user models.User
+	if err := s.db.Where("email_index = ?", models.EmailIndex(email)).First(&user).Error; err != nil {
+		// Unknown accounts are throttled too, but there is nobody to notify
+		log.Printf("SECURITY: locked sign-in to an unknown account after %d failed logins (last from %s)", s.config.MaxAccountFailures, ip)
+		return
+	}
+	log.Printf("SECURITY: locked account of user %s after %d failed logins (last from %s)", user.ID, s.config.MaxAccountFailures, ip)
+
+	message := fmt.Sprintf("Your account was locked until %s after %d failed sign-in attempts (last from %s). "+
+		"If this was not you, reset your password once the lock expires.",
+		lockedUntil.UTC().Format(time.RFC1123), s.config.MaxAccountFailures, ip)
+
+	notification := &models.Notification{
+		UserID:  user.ID,
+		Type:    models.NotificationTypeSecurityAlert,
+		Title:   "Your MuniCollect account was temporarily locked",
+		Message: message,
+		Data: models.NotificationData{
+			"ip":          ip,
+			"lockedUntil": lockedUntil,
+		},
+	}
+	if _, err := s.notifications.Notify(notification); err != nil {
+		log.Printf("Failed to send lockout notification to user %s: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLoginProtection(config LoginProtectionConfig) (*LoginProtectionService, *time.Time, *[]string) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	notified := []string{}
+
+	s := NewLoginProtectionService(nil, NewMemoryLoginAttemptStore(), nil, config)
+	s.now = func() time.Time { return now }
+	s.notify = func(email, ip string, lockedUntil time.Time) {
+		notified = append(notified, email)
+	}
+
+	return s, &now, &notified
+}
+
+func testLoginProtectionConfig() LoginProtectionConfig {
+	return LoginProtectionConfig{
+		MaxAccountFailures: 3,
+		MaxIPFailures:      5,
+		LockoutDuration:    15 * time.Minute,
+		BackoffBase:        time.Second,
+		BackoffMax:         4 * time.Second,
+		FailureWindow:      time.Hour,
+	}
+}
+
+func TestLoginProtection_Backoff(t *testing.T) {
+	s, now, _ := newTestLoginProtection(testLoginProtectionConfig())
+
+	require.NoError(t, s.Check("user@example.com", "10.0.0.1"))
+	require.NoError(t, s.RecordFailure("user@example.com", "10.0.0.1"))
+
+	// One failure requires a one second wait
+	err := s.Check("user@example.com", "10.0.0.1")
+	var blocked *LoginBlockedError
+	require.True(t, errors.As(err, &blocked))
+	assert.False(t, blocked.Locked)
+	assert.Equal(t, time.Second, blocked.RetryAfter)
+	assert.True(t, errors.Is(err, ErrLoginBlocked))
+
+	*now = now.Add(time.Second)
+	require.NoError(t, s.Check("user@example.com", "10.0.0.1"))
+
+	// The delay doubles with the next failure, and the email is matched case-insensitively
+	require.NoError(t, s.RecordFailure("User@Example.com", "10.0.0.1"))
+	err = s.Check("user@example.com", "10.0.0.1")
+	require.True(t, errors.As(err, &blocked))
+	assert.Equal(t, 2*time.Second, blocked.RetryAfter)
+
+	// Other accounts are not affected
+	assert.NoError(t, s.Check("other@example.com", "10.0.0.2"))
+
+	// A successful login clears the counter
+	require.NoError(t, s.RecordSuccess("user@example.com"))
+	assert.NoError(t, s.Check("user@example.com", "10.0.0.1"))
+}
+
+func TestLoginProtection_AccountLockout(t *testing.T) {
+	s, now, notified := newTestLoginProtection(testLoginProtectionConfig())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.RecordFailure("user@example.com", "10.0.0.1"))
+		*now = now.Add(10 * time.Second)
+	}
+	assert.Equal(t, []string{"user@example.com"}, *notified)
+
+	err := s.Check("user@example.com", "10.0.0.9")
+	var blocked *LoginBlockedError
+	require.True(t, errors.As(err, &blocked))
+	assert.True(t, blocked.Locked)
+
+	// The lock expires on its own
+	*now = now.Add(15 * time.Minute)
+	assert.NoError(t, s.Check("user@example.com", "10.0.0.9"))
+
+	// An admin unlock clears it immediately
+	require.NoError(t, s.RecordFailure("user@example.com", "10.0.0.1"))
+	require.Error(t, s.Check("user@example.com", "10.0.0.1"))
+	require.NoError(t, s.Unlock("user@example.com"))
+	assert.NoError(t, s.Check("user@example.com", "10.0.0.1"))
+}
+
+func TestLoginProtection_IPBlock(t *testing.T) {
+	s, now, notified := newTestLoginProtection(testLoginProtectionConfig())
+
+	// Spread failures over many accounts from one IP
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.RecordFailure(string(rune('a'+i))+"@example.com", "10.0.0.1"))
+	}
+	assert.Empty(t, *notified)
+
+	*now = now.Add(time.Minute)
+	err := s.Check("fresh@example.com", "10.0.0.1")
+	var blocked *LoginBlockedError
+	require.True(t, errors.As(err, &blocked))
+	assert.True(t, blocked.Locked)
+
+	assert.NoError(t, s.Check("fresh@example.com", "10.0.0.2"))
+}
+
+func TestLoginProtection_FailureWindow(t *testing.T) {
+	s, now, _ := newTestLoginProtection(testLoginProtectionConfig())
+
+	require.NoError(t, s.RecordFailure("user@example.com", "10.0.0.1"))
+	require.NoError(t, s.RecordFailure("user@example.com", "10.0.0.1"))
+
+	// Failures older than the window are forgotten
+	*now = now.Add(2 * time.Hour)
+	require.NoError(t, s.RecordFailure("user@example.com", "10.0.0.1"))
+
+	state, err := s.store.Get(accountKey("user@example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, state.Failures)
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds the whole conversation with the SMTP server, from dialling to QUIT
+const smtpTimeout = 30 * time.Second
+
+// MailMessage represents an outgoing email
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender delivers outgoing email. Implementations can be swapped per environment.
+type MailSender interface {
+	Send(msg MailMessage) error
+}
+
+// LogMailSender writes outgoing email to the application log instead of sending it.
+// The log then holds reset links and verification codes, so it is refused in production.
+type LogMailSender struct{}
+
+// NewLogMailSender creates a new log mail sender
+func NewLogMailSender() *LogMailSender {
+	return &LogMailSender{}
+}
+
+// Send logs the message
+func (s *LogMailSender) Send(msg MailMessage) error {
+	log.Printf("MAIL: to=%s subject=%q body=%q", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailSender writes each outgoing email to its own file in a directory.
+// It is intended for development and tests, where the files act as an outbox.
+type FileMailSender struct {
+	dir string
+}
+
+// NewFileMailSender creates a new file mail sender writing into dir
+func NewFileMailSender(dir string) *FileMailSender {
+	return &FileMailSender{
+		dir: dir,
+	}
+}
+
+// Send writes the message to a new .eml file in the outbox directory
+func (s *FileMailSender) Send(msg MailMessage) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail outbox: %w", err)
+	}
+
+	suffix, err := generateSecureToken(6)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), suffix)
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		msg.To, msg.Subject, time.Now().UTC().Format(time.RFC1123Z), strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	if err := os.WriteFile(filepath.Join(s.dir, filename), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write mail message: %w", err)
+	}
+
+	return nil
+}
+
+// SMTPConfig holds the settings of the SMTP server outgoing email is relayed through
+type SMTPConfig struct {
+	Host string
+	// Port is the submission port; the connection is upgraded with STARTTLS
+	Port     string
+	Username string
+	Password string
+	// From is the sender address of every message
+	From string
+}
+
+// SMTPMailSender delivers outgoing email through an SMTP server. Connections other than to
+// localhost must support STARTTLS, so credentials and message contents are never sent in clear.
+type SMTPMailSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPMailSender creates a new SMTP mail sender
+func NewSMTPMailSender(config SMTPConfig) *SMTPMailSender {
+	return &SMTPMailSender{
+		config: config,
+	}
+}
+
+// Send delivers the message to the SMTP server
+func (s *SMTPMailSender) Send(msg MailMessage) error {
+	content, err := s.message(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(s.config.Host, s.config.Port), smtpTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS with SMTP server: %w", err)
+		}
+	} else if !isLocalhost(s.config.Host) {
+		return errors.New("SMTP server does not support STARTTLS")
+	}
+
+	if s.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.config.From); err != nil {
+		return fmt.Errorf("SMTP server refused sender: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("SMTP server refused recipient: %w", err)
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send mail message: %w", err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to send mail message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to send mail message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// message formats the message with its headers, quoted-printable UTF-8 text and CRLF line endings
+func (s *SMTPMailSender) message(msg MailMessage, now time.Time) ([]byte, error) {
+	// Line breaks in header values would let them add headers of their own
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("mail recipient and subject must be a single line")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.config.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.UTC().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, fmt.Errorf("failed to encode mail message: %w", err)
+	}
+	if err := body.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode mail message: %w", err)
+	}
+	buf.WriteString("\r\n")
+
+	return buf.Bytes(), nil
+}
+
+// isLocalhost reports whether host refers to the local machine
+func isLocalhost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// NewMailSenderFromEnv returns the mail sender selected by MAIL_SENDER ("log", "file" or "smtp").
+// The SMTP sender relays through SMTP_HOST and SMTP_PORT (default 587), signing in with
+// SMTP_USERNAME and SMTP_PASSWORD when set, and sends from MAIL_FROM.
+func NewMailSenderFromEnv() (MailSender, error) {
+	switch os.Getenv("MAIL_SENDER") {
+	case "", "log":
+		return NewLogMailSender(), nil
+	case "file":
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			dir = "mail-outbox"
+		}
+		return NewFileMailSender(dir), nil
+	case "smtp":
+		config := SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+		if config.Host == "" || config.From == "" {
+			return nil, errors.New("SMTP_HOST and MAIL_FROM are required when MAIL_SENDER is smtp")
+		}
+		if config.Port == "" {
+			config.Port = "587"
+		}
+		return NewSMTPMailSender(config), nil
+	default:
+		return nil, fmt.Errorf("unknown mail sender %q", os.Getenv("MAIL_SENDER"))
+	}
+}
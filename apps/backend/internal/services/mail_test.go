@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailSender_Send(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewFileMailSender(dir)
+
+	require.NoError(t, sender.Send(MailMessage{
+		To:      "resident@example.com",
+		Subject: "Reset your MuniCollect password",
+		Body:    "line one\nline two",
+	}))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, strings.HasSuffix(entries[0].Name(), ".eml"))
+
+	content, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: resident@example.com\r\n")
+	assert.Contains(t, string(content), "Subject: Reset your MuniCollect password\r\n")
+	assert.Contains(t, string(content), "line one\r\nline two")
+}
+
+// startTestSMTPServer accepts one SMTP session without STARTTLS and returns the commands
+// and message data it received once the session ends
+func startTestSMTPServer(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		var lines []string
+		defer func() { received <- lines }()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+
+			switch {
+			case inData && line == ".":
+				inData = false
+				reply("250 queued")
+			case inData:
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(line, "AUTH"):
+				reply("235 authenticated")
+			case line == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailSender_Send(t *testing.T) {
+	addr, received := startTestSMTPServer(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	sender := NewSMTPMailSender(SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: "mailer",
+		Password: "secret",
+		From:     "no-reply@municollect.example",
+	})
+	require.NoError(t, sender.Send(MailMessage{
+		To:      "resident@example.com",
+		Subject: "Reset your MuniCollect password",
+		Body:    "line one\nline two",
+	}))
+
+	session := strings.Join(<-received, "\n")
+	assert.Contains(t, session, "AUTH PLAIN")
+	assert.Contains(t, session, "MAIL FROM:<no-reply@municollect.example>")
+	assert.Contains(t, session, "RCPT TO:<resident@example.com>")
+	assert.Contains(t, session, "Subject: Reset your MuniCollect password")
+	assert.Contains(t, session, "line one\nline two")
+}
+
+func TestSMTPMailSender_Message(t *testing.T) {
+	sender := NewSMTPMailSender(SMTPConfig{Host: "smtp.example.com", Port: "587", From: "no-reply@municollect.example"})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	content, err := sender.message(MailMessage{To: "resident@example.com", Subject: "รหัสยืนยัน", Body: "รหัสของคุณคือ 123456"}, now)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "From: no-reply@municollect.example\r\n")
+	assert.Contains(t, string(content), "Subject: =?utf-8?q?")
+	assert.Contains(t, string(content), "Content-Transfer-Encoding: quoted-printable\r\n")
+
+	// Line breaks cannot smuggle extra headers into the message
+	_, err = sender.message(MailMessage{To: "resident@example.com\r\nBcc: other@example.com", Subject: "Hi"}, now)
+	assert.Error(t, err)
+	_, err = sender.message(MailMessage{To: "resident@example.com", Subject: "Hi\nBcc: other@example.com"}, now)
+	assert.Error(t, err)
+}
+
+func TestIsLocalhost(t *testing.T) {
+	assert.True(t, isLocalhost("localhost"))
+	assert.True(t, isLocalhost("127.0.0.1"))
+	assert.True(t, isLocalhost("::1"))
+	assert.False(t, isLocalhost("smtp.example.com"))
+}
+
+func TestNewMailSenderFromEnv(t *testing.T) {
+	t.Setenv("MAIL_SENDER", "")
+	sender, err := NewMailSenderFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &LogMailSender{}, sender)
+
+	t.Setenv("MAIL_SENDER", "smtp")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_PORT", "")
+	t.Setenv("MAIL_FROM", "")
+	_, err = NewMailSenderFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("MAIL_FROM", "no-reply@municollect.example")
+	sender, err = NewMailSenderFromEnv()
+	require.NoError(t, err)
+	require.IsType(t, &SMTPMailSender{}, sender)
+	assert.Equal(t, "587", sender.(*SMTPMailSender).config.Port)
+
+	t.Setenv("MAIL_SENDER", "carrier-pigeon")
+	_, err = NewMailSenderFromEnv()
+	assert.Error(t, err)
+}
+
+func TestSecureTokenHelpers(t *testing.T) {
+	first, err := generateSecureToken(32)
+	require.NoError(t, err)
+	second, err := generateSecureToken(32)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+	assert.Len(t, hashToken(first), 64)
+	assert.Equal(t, hashToken(first), hashToken(first))
+	assert.NotEqual(t, hashToken(first), hashToken(second))
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"municollect/internal/middleware"
+	"municollect/internal/models"
+)
+
+// Membership errors
+var (
+	ErrMembershipUserNotFound         = errors.New("user not found")
+	ErrMembershipMunicipalityNotFound = errors.New("municipality not found")
+)
+
+// MembershipService manages the roles users hold in individual municipalities
+type MembershipService struct {
+	db    *gorm.DB
+	roles *PermissionService
+	audit *AuditService
+}
+
+// NewMembershipService creates a new membership service
+func NewMembershipService(db *gorm.DB, audit *AuditService) *MembershipService {
+	return &MembershipService{
+		db:    db,
+		roles: NewPermissionService(db, audit),
+		audit: audit,
+	}
+}
+
+// ListMembers returns the memberships of a municipality with their users
+func (s *MembershipService) ListMembers(municipalityID string, role *string) ([]models.UserMunicipality, error) {
+	query := s.db.Preload("User").Where("municipality_id = ?", municipalityID)
+	if role != nil {
+		query = query.Where("role = ?", *role)
+	}
+
+	var memberships []models.UserMunicipality
+	if err := query.Order("created_at ASC").Find(&memberships).Error; err != nil {
+		return nil, fmt.Errorf("failed to list municipality members: %w", err)
+	}
+
+	return memberships, nil
+}
+
+// SetMembershipRole gives the user a built-in or municipality-defined role in the municipality,
+// creating the membership if needed. The change is recorded in the audit log.
+func (s *MembershipService) SetMembershipRole(actor AuditActor, userID, municipalityID, role string) (*models.UserMunicipality, error) {
+	if err := s.db.Select("id").Where("id = ?", userID).First(&models.User{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMembershipUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if err := s.db.Select("id").Where("id = ?", municipalityID).First(&models.Municipality{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMembershipMunicipalityNotFound
+		}
+		return nil, fmt.Errorf("failed to get municipality: %w", err)
+	}
+	if _, err := s.roles.GetRole(municipalityID, role); err != nil {
+		return nil, err
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var membership models.UserMunicipality
+	var before interface{}
+	err := tx.Where("user_id = ? AND municipality_id = ?", userID, municipalityID).First(&membership).Error
+	switch {
+	case err == nil:
+		before = map[string]interface{}{"role": membership.Role}
+		if err := tx.Model(&membership).Update("role", role).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to update municipality role: %w", err)
+		}
+		membership.Role = role
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		membership = models.UserMunicipality{
+			UserID:         userID,
+			MunicipalityID: municipalityID,
+			Role:           role,
+		}
+		if err := tx.Create(&membership).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to create municipality membership: %w", err)
+		}
+	default:
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to get municipality membership: %w", err)
+	}
+
+	event := AuditEvent{
+		Action:       models.AuditActionMembershipRoleChanged,
+		ResourceType: models.AuditResourceMembership,
+		ResourceID:   membership.ID,
+		Details:      models.AuditDetails{"userId": userID, "municipalityId": municipalityID},
+		Before:       before,
+		After:        map[string]interface{}{"role": role},
+	}
+	if err := s.audit.RecordTx(tx, actor, event); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit municipality role change: %w", err)
+	}
+
+	return &membership, nil
+}
+
+// PaymentMunicipality resolves the municipality of the payment named by a path parameter
+func (s *MembershipService) PaymentMunicipality(paramName string) middleware.MunicipalityResolver {
+	return func(c *fiber.Ctx) (string, error) {
+		var payment models.Payment
+		err := s.db.Select("municipality_id").Where("id = ?", c.Params(paramName)).First(&payment).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", middleware.ErrMunicipalityNotResolved
+			}
+			return "", fmt.Errorf("failed to get payment: %w", err)
+		}
+
+		return payment.MunicipalityID, nil
+	}
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/models"
+)
+
+// MFARecoveryCodeCount is the number of recovery codes issued at a time
+const MFARecoveryCodeCount = 10
+
+// MFA errors
+var (
+	ErrMFANotEnrolled     = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrMFAInvalidCode     = errors.New("invalid two-factor authentication code")
+	ErrMFARequiredForRole = errors.New("two-factor authentication is required for this role")
+)
+
+// MFAEnrollment holds what a user needs to add the secret to an authenticator app
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+	QRCode          string `json:"qrCode"`
+}
+
+// MFAService handles TOTP enrolment, verification and recovery codes
+type MFAService struct {
+	db            *gorm.DB
+	issuer        string
+	requiredRoles map[models.UserRole]bool
+}
+
+// NewMFAService creates a new MFA service.
+// MFA_REQUIRED_ROLES lists the roles that must use a second factor (comma-separated,
+// "none" for no roles); it defaults to admin and municipal_staff. MFA_ISSUER sets the
+// name shown in authenticator apps.
+func NewMFAService(db *gorm.DB) *MFAService {
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "MuniCollect"
+	}
+
+	return &MFAService{
+		db:            db,
+		issuer:        issuer,
+		requiredRoles: parseMFARequiredRoles(os.Getenv("MFA_REQUIRED_ROLES")),
+	}
+}
+
+// parseMFARequiredRoles parses the MFA_REQUIRED_ROLES setting
+func parseMFARequiredRoles(value string) map[models.UserRole]bool {
+	roles := make(map[models.UserRole]bool)
+	value = strings.TrimSpace(value)
+
+	switch value {
+	case "":
+		roles[models.UserRoleAdmin] = true
+		roles[models.UserRoleMunicipalStaff] = true
+	case "none":
+	default:
+		for _, role := range strings.Split(value, ",") {
+			role = strings.TrimSpace(role)
+			if role != "" {
+				roles[models.UserRole(role)] = true
+			}
+		}
+	}
+
+	return roles
+}
+
+// IsRequired reports whether users with the given role must use a second factor
+func (s *MFAService) IsRequired(role models.UserRole) bool {
+	return s.requiredRoles[role]
+}
+
+// IsEnabled reports whether the user has a confirmed second factor
+func (s *MFAService) IsEnabled(userID string) (bool, error) {
+	mfa, err := s.getMFA(userID)
+	if err != nil {
+		if errors.Is(err, ErrMFANotEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return mfa.IsEnabled(), nil
+}
+
+// BeginEnrollment generates a new secret for the user. The factor is not enforced until
+// ConfirmEnrollment succeeds with a code from the authenticator app.
+func (s *MFAService) BeginEnrollment(user *models.User) (*MFAEnrollment, error) {
+	enabled, err := s.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.UserMFA{
+		UserID: user.ID,
+		Secret: secret,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		// Take the new row's values, so the secret is stored as encrypted by the serializer
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "enabled_at", "last_used_step", "updated_at"}),
+	}).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store MFA secret: %w", err)
+	}
+
+	uri := TOTPProvisioningURI(secret, user.Email, s.issuer)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+
+	return &MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRCode:          fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(png)),
+	}, nil
+}
+
+// ConfirmEnrollment enables the second factor once the user proves their app produces
+// valid codes, and returns a fresh set of recovery codes.
+func (s *MFAService) ConfirmEnrollment(userID, code string) ([]string, error) {
+	mfa, err := s.getMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.IsEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := ValidateTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(&models.UserMFA{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"enabled_at":     time.Now(),
+		"last_used_step": step,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to enable MFA: %w", err)
+	}
+
+	codes, err := s.replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit MFA enrolment: %w", err)
+	}
+
+	return codes, nil
+}
+
+// Verify checks a TOTP code or an unused recovery code for the user.
+// Each TOTP code is accepted once and each recovery code can only be used once.
+func (s *MFAService) Verify(userID, code string) error {
+	mfa, err := s.getMFA(userID)
+	if err != nil {
+		return err
+	}
+	if !mfa.IsEnabled() {
+		return ErrMFANotEnrolled
+	}
+
+	if step, ok := ValidateTOTP(mfa.Secret, code, time.Now()); ok {
+		// The step guard stops a code from being replayed within its validity window
+		result := s.db.Model(&models.UserMFA{}).
+			Where("user_id = ? AND last_used_step < ?", userID, step).
+			Update("last_used_step", step)
+		if result.Error != nil {
+			return fmt.Errorf("failed to record MFA code use: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFAInvalidCode
+		}
+		return nil
+	}
+
+	return s.useRecoveryCode(userID, code)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a current code
+func (s *MFAService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable removes the user's second factor after checking a current code.
+// Users whose role requires MFA cannot disable it.
+func (s *MFAService) Disable(userID string, role models.UserRole, code string) error {
+	if s.IsRequired(role) {
+		return ErrMFARequiredForRole
+	}
+
+	if err := s.Verify(userID, code); err != nil {
+		return err
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserMFA{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to disable MFA: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit MFA removal: %w", err)
+	}
+
+	return nil
+}
+
+// getMFA loads the user's MFA record
+func (s *MFAService) getMFA(userID string) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	if err := s.db.Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("failed to get MFA settings: %w", err)
+	}
+
+	return &mfa, nil
+}
+
+// useRecoveryCode consumes a matching unused recovery code
+func (s *MFAService) useRecoveryCode(userID, code string) error {
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return ErrMFAInvalidCode
+	}
+
+	result := s.db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalized)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to use recovery code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAInvalidCode
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores a new set
+func (s *MFAService) replaceRecoveryCodes(db *gorm.DB, userID string) ([]string, error) {
+	if err := db.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, 0, MFARecoveryCodeCount)
+	records := make([]models.MFARecoveryCode, 0, MFARecoveryCodeCount)
+	for i := 0; i < MFARecoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.MFARecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := db.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code formatted as two groups of five characters
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	encoded := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// normalizeRecoveryCode strips formatting so codes can be typed with or without the dash
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"municollect/internal/models"
+)
+
+// MockPaymentProviderName is the name of the mock payment provider
+const MockPaymentProviderName = "mock"
+
+// MockWebhookSecret signs mock provider webhooks when PAYMENT_WEBHOOK_SECRET_MOCK is not set
+const MockWebhookSecret = "mock-webhook-secret"
+
+// Amounts whose minor units end in these values make the mock provider fail in a set way,
+// so every outcome can be tried by hand: 100.13 THB is declined, 100.03 THB finds the
+// provider unavailable.
+const (
+	MockDeclinedMinorUnits    = 13
+	MockUnavailableMinorUnits = 3
+)
+
+// MockPaymentMethods are the payment methods the mock provider collects when enabled.
+// Cash is always settled by staff.
+var MockPaymentMethods = []string{"qr_code", "online", "credit_card", "debit_card", "bank_transfer", "mobile_payment"}
+
+// MockProviderConfig controls how the mock payment provider behaves
+type MockProviderConfig struct {
+	// Latency is how long every call to the provider takes
+	Latency time.Duration
+	// SettleAfter is how long a charge stays pending before it completes or fails.
+	// Charges settle as soon as they are created when it is zero.
+	SettleAfter time.Duration
+	// FailureRate is the share of charges, between 0 and 1, that fail at random
+	FailureRate float64
+}
+
+// DefaultMockProviderConfig returns a configuration in which charges complete after a few seconds
+func DefaultMockProviderConfig() MockProviderConfig {
+	return MockProviderConfig{
+		Latency:     200 * time.Millisecond,
+		SettleAfter: 5 * time.Second,
+	}
+}
+
+// MockProviderConfigFromEnv reads MOCK_PAYMENT_LATENCY and MOCK_PAYMENT_SETTLE_AFTER (Go
+// durations) and MOCK_PAYMENT_FAILURE_RATE, keeping the defaults for unset values
+func MockProviderConfigFromEnv() MockProviderConfig {
+	config := DefaultMockProviderConfig()
+	if latency, err := time.ParseDuration(os.Getenv("MOCK_PAYMENT_LATENCY")); err == nil && latency >= 0 {
+		config.Latency = latency
+	}
+	if settleAfter, err := time.ParseDuration(os.Getenv("MOCK_PAYMENT_SETTLE_AFTER")); err == nil && settleAfter >= 0 {
+		config.SettleAfter = settleAfter
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv("MOCK_PAYMENT_FAILURE_RATE"), 64); err == nil && rate >= 0 && rate <= 1 {
+		config.FailureRate = rate
+	}
+	return config
+}
+
+// mockCharge is a charge held by the mock provider
+type mockCharge struct {
+	reference string
+	paymentID string
+	amount    models.Money
+	createdAt time.Time
+	// outcome is the status the charge settles in
+	outcome  models.PaymentStatus
+	refunded models.Money
+}
+
+// MockPaymentProvider is a payment provider that runs entirely in process. It simulates
+// network latency, charges that settle after a delay, declined charges and outages, and
+// accepts webhooks in its own JSON format. It is meant for development and tests.
+type MockPaymentProvider struct {
+	config MockProviderConfig
+
+	mu      sync.Mutex
+	charges map[string]*mockCharge
+	// now and random are replaced in tests
+	now    func() time.Time
+	random func() float64
+}
+
+// NewMockPaymentProvider creates a new mock payment provider
+func NewMockPaymentProvider(config MockProviderConfig) *MockPaymentProvider {
+	return &MockPaymentProvider{
+		config:  config,
+		charges: make(map[string]*mockCharge),
+		now:     time.Now,
+		random:  rand.Float64,
+	}
+}
+
+// Name returns "mock"
+func (p *MockPaymentProvider) Name() string {
+	return MockPaymentProviderName
+}
+
+// CreateCharge records a charge that settles after the configured delay
+func (p *MockPaymentProvider) CreateCharge(ctx context.Context, charge ChargeRequest) (*ChargeResult, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	if minorUnitsEnding(charge.Amount) == MockUnavailableMinorUnits {
+		return nil, fmt.Errorf("%w: mock provider unavailable", ErrPaymentProviderFailed)
+	}
+
+	reference, err := generateSecureToken(12)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	record := &mockCharge{
+		reference: "mock_ch_" + reference,
+		paymentID: charge.PaymentID,
+		amount:    charge.Amount,
+		createdAt: p.now(),
+		outcome:   models.PaymentStatusCompleted,
+	}
+	if minorUnitsEnding(charge.Amount) == MockDeclinedMinorUnits || p.random() < p.config.FailureRate {
+		record.outcome = models.PaymentStatusFailed
+	}
+	p.charges[record.reference] = record
+
+	return p.result(record), nil
+}
+
+// QueryStatus returns the state of a charge
+func (p *MockPaymentProvider) QueryStatus(ctx context.Context, reference string) (*ChargeResult, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	record, ok := p.charges[reference]
+	if !ok {
+		return nil, ErrChargeNotFound
+	}
+	return p.result(record), nil
+}
+
+// Refund refunds part or all of a completed charge
+func (p *MockPaymentProvider) Refund(ctx context.Context, reference string, amount models.Money) (*RefundResult, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	record, ok := p.charges[reference]
+	if !ok {
+		return nil, ErrChargeNotFound
+	}
+	if p.status(record) != models.PaymentStatusCompleted {
+		return nil, fmt.Errorf("%w: charge is not completed", ErrRefundNotAllowed)
+	}
+
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: refund must be positive", ErrRefundNotAllowed)
+	}
+	refunded, err := record.refunded.Add(amount)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRefundNotAllowed, err)
+	}
+	remaining, err := record.amount.Sub(refunded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRefundNotAllowed, err)
+	}
+	if remaining.Minor < 0 {
+		return nil, fmt.Errorf("%w: refund exceeds the charged amount", ErrRefundNotAllowed)
+	}
+	record.refunded = refunded
+
+	refundReference, err := generateSecureToken(12)
+	if err != nil {
+		return nil, err
+	}
+	return &RefundResult{
+		Reference: "mock_rf_" + refundReference,
+		Amount:    amount,
+		Data: map[string]interface{}{
+			"provider":  MockPaymentProviderName,
+			"chargeId":  reference,
+			"refundId":  "mock_rf_" + refundReference,
+			"amount":    amount.Decimal(),
+			"refunded":  refunded.Decimal(),
+			"remaining": remaining.Decimal(),
+		},
+	}, nil
+}
+
+// mockWebhook is the JSON body of a mock provider webhook
+type mockWebhook struct {
+	ID       string                 `json:"id"`
+	ChargeID string                 `json:"chargeId"`
+	Status   string                 `json:"status"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// ParseWebhook decodes a webhook such as {"id": "evt_1", "chargeId": "mock_ch_...", "status": "completed"}
+func (p *MockPaymentProvider) ParseWebhook(payload []byte) (*ProviderEvent, error) {
+	var webhook mockWebhook
+	if err := json.Unmarshal(payload, &webhook); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProviderEvent, err)
+	}
+	if webhook.ID == "" || webhook.ChargeID == "" {
+		return nil, fmt.Errorf("%w: id and chargeId are required", ErrInvalidProviderEvent)
+	}
+
+	status := models.PaymentStatus(webhook.Status)
+	switch status {
+	case models.PaymentStatusPending, models.PaymentStatusCompleted, models.PaymentStatusFailed, models.PaymentStatusRefunded:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidProviderEvent, webhook.Status)
+	}
+
+	data := map[string]interface{}{"provider": MockPaymentProviderName, "eventId": webhook.ID, "chargeId": webhook.ChargeID}
+	for key, value := range webhook.Data {
+		data[key] = value
+	}
+	return &ProviderEvent{ID: webhook.ID, Reference: webhook.ChargeID, Status: status, Data: data}, nil
+}
+
+// wait simulates the provider's latency
+func (p *MockPaymentProvider) wait(ctx context.Context) error {
+	if p.config.Latency <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(p.config.Latency)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrPaymentProviderFailed, ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// status returns the status of a charge at the current time
+func (p *MockPaymentProvider) status(record *mockCharge) models.PaymentStatus {
+	if p.now().Sub(record.createdAt) < p.config.SettleAfter {
+		return models.PaymentStatusPending
+	}
+	return record.outcome
+}
+
+// result describes a charge at the current time
+func (p *MockPaymentProvider) result(record *mockCharge) *ChargeResult {
+	result := &ChargeResult{
+		Reference: record.reference,
+		Status:    p.status(record),
+		Data: map[string]interface{}{
+			"provider": MockPaymentProviderName,
+			"chargeId": record.reference,
+		},
+	}
+	if result.Status == models.PaymentStatusFailed {
+		result.FailureReason = "card_declined"
+		result.Data["failureReason"] = result.FailureReason
+	}
+	return result
+}
+
+// minorUnitsEnding returns the last two digits of an amount's minor units
+func minorUnitsEnding(amount models.Money) int64 {
+	ending := amount.Minor % 100
+	if ending < 0 {
+		ending = -ending
+	}
+	return ending
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"municollect/internal/models"
+)
+
+func newTestMockProvider(config MockProviderConfig) (*MockPaymentProvider, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	p := NewMockPaymentProvider(config)
+	p.now = func() time.Time { return now }
+	p.random = func() float64 { return 0.5 }
+
+	return p, &now
+}
+
+func TestMockPaymentProvider_SettlesAfterDelay(t *testing.T) {
+	p, now := newTestMockProvider(MockProviderConfig{SettleAfter: 5 * time.Second})
+	ctx := context.Background()
+
+	charge, err := p.CreateCharge(ctx, ChargeRequest{PaymentID: "payment-1", Amount: models.NewMoney(10050, models.CurrencyTHB)})
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPending, charge.Status)
+	assert.NotEmpty(t, charge.Reference)
+
+	*now = now.Add(5 * time.Second)
+	result, err := p.QueryStatus(ctx, charge.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusCompleted, result.Status)
+
+	_, err = p.QueryStatus(ctx, "mock_ch_unknown")
+	assert.ErrorIs(t, err, ErrChargeNotFound)
+}
+
+func TestMockPaymentProvider_Failures(t *testing.T) {
+	p, _ := newTestMockProvider(MockProviderConfig{})
+	ctx := context.Background()
+
+	// Amounts ending in 13 satang are declined
+	declined, err := p.CreateCharge(ctx, ChargeRequest{Amount: models.NewMoney(10013, models.CurrencyTHB)})
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusFailed, declined.Status)
+	assert.Equal(t, "card_declined", declined.FailureReason)
+
+	// Amounts ending in 03 satang find the provider unavailable
+	_, err = p.CreateCharge(ctx, ChargeRequest{Amount: models.NewMoney(10003, models.CurrencyTHB)})
+	assert.ErrorIs(t, err, ErrPaymentProviderFailed)
+
+	// Random failures follow the failure rate
+	p.config.FailureRate = 0.6
+	random, err := p.CreateCharge(ctx, ChargeRequest{Amount: models.NewMoney(10000, models.CurrencyTHB)})
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusFailed, random.Status)
+}
+
+func TestMockPaymentProvider_Latency(t *testing.T) {
+	p, _ := newTestMockProvider(MockProviderConfig{Latency: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := p.CreateCharge(ctx, ChargeRequest{Amount: models.NewMoney(10000, models.CurrencyTHB)})
+	assert.True(t, errors.Is(err, ErrPaymentProviderFailed))
+}
+
+func TestMockPaymentProvider_Refund(t *testing.T) {
+	p, now := newTestMockProvider(MockProviderConfig{SettleAfter: time.Second})
+	ctx := context.Background()
+	amount := models.NewMoney(10000, models.CurrencyTHB)
+
+	charge, err := p.CreateCharge(ctx, ChargeRequest{Amount: amount})
+	require.NoError(t, err)
+
+	// Pending charges cannot be refunded
+	_, err = p.Refund(ctx, charge.Reference, amount)
+	assert.ErrorIs(t, err, ErrRefundNotAllowed)
+
+	*now = now.Add(time.Second)
+	refund, err := p.Refund(ctx, charge.Reference, models.NewMoney(4000, models.CurrencyTHB))
+	require.NoError(t, err)
+	assert.Equal(t, "60.00", refund.Data["remaining"])
+
+	// The refunds cannot add up to more than the charge
+	_, err = p.Refund(ctx, charge.Reference, models.NewMoney(6001, models.CurrencyTHB))
+	assert.ErrorIs(t, err, ErrRefundNotAllowed)
+	_, err = p.Refund(ctx, charge.Reference, models.NewMoney(6000, models.CurrencyTHB))
+	assert.NoError(t, err)
+}
+
+func TestMockPaymentProvider_ParseWebhook(t *testing.T) {
+	p, _ := newTestMockProvider(MockProviderConfig{})
+
+	event, err := p.ParseWebhook([]byte(`{"id": "evt_1", "chargeId": "mock_ch_1", "status": "completed", "data": {"bank": "KBANK"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "evt_1", event.ID)
+	assert.Equal(t, "mock_ch_1", event.Reference)
+	assert.Equal(t, models.PaymentStatusCompleted, event.Status)
+	assert.Equal(t, "KBANK", event.Data["bank"])
+
+	for _, payload := range []string{`not json`, `{"chargeId": "mock_ch_1", "status": "completed"}`, `{"id": "evt_2", "chargeId": "mock_ch_1", "status": "paid"}`} {
+		_, err := p.ParseWebhook([]byte(payload))
+		assert.ErrorIs(t, err, ErrInvalidProviderEvent, payload)
+	}
+}
+
+func TestPaymentProviders_ForConfig(t *testing.T) {
+	mock := NewMockPaymentProvider(MockProviderConfig{})
+	providers := NewPaymentProviders()
+	providers.Register(mock, "qr_code", "online")
+
+	// The first configured method with a provider is used
+	provider, method, ok := providers.ForConfig(&models.PaymentConfig{PaymentMethods: []string{"cash", "online", "qr_code"}})
+	require.True(t, ok)
+	assert.Equal(t, "online", method)
+	assert.Equal(t, MockPaymentProviderName, provider.Name())
+
+	// Cash only payments are settled by staff
+	_, _, ok = providers.ForConfig(&models.PaymentConfig{PaymentMethods: []string{"cash"}})
+	assert.False(t, ok)
+	_, _, ok = providers.ForConfig(nil)
+	assert.False(t, ok)
+
+	var none *PaymentProviders
+	_, _, ok = none.ForConfig(&models.PaymentConfig{PaymentMethods: []string{"online"}})
+	assert.False(t, ok)
+
+	named, ok := providers.Get(MockPaymentProviderName)
+	require.True(t, ok)
+	assert.Equal(t, mock, named)
+}
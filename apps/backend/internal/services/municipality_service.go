@@ -10,18 +10,20 @@ import (
 
 // MunicipalityService handles municipality-related business logic
 type MunicipalityService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	audit *AuditService
 }
 
 // NewMunicipalityService creates a new municipality service
-func NewMunicipalityService(db *gorm.DB) *MunicipalityService {
+func NewMunicipalityService(db *gorm.DB, audit *AuditService) *MunicipalityService {
 	return &MunicipalityService{
-		db: db,
+		db:    db,
+		audit: audit,
 	}
 }
 
 // CreateMunicipality creates a new municipality
-func (s *MunicipalityService) CreateMunicipality(municipality *models.Municipality) error {
+func (s *MunicipalityService) CreateMunicipality(actor AuditActor, municipality *models.Municipality) error {
 	// Validate required fields
 	if municipality.Name == "" {
 		return errors.New("municipality name is required")
@@ -38,19 +40,44 @@ func (s *MunicipalityService) CreateMunicipality(municipality *models.Municipali
 		return fmt.Errorf("error checking municipality code: %w", err)
 	}
 
-	// Set default payment config if not provided
+	// Set default payment config if not provided, in the municipality's currency
 	if municipality.PaymentConfig == nil {
 		municipality.PaymentConfig = &models.PaymentConfig{
-			Currency:                "USD",
+			Currency:                string(municipality.DefaultCurrency),
 			PaymentMethods:          []string{"qr_code"},
 			QRCodeExpirationMinutes: 60,
 		}
 	}
 
-	if err := s.db.Create(municipality).Error; err != nil {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(municipality).Error; err != nil {
+		tx.Rollback()
 		return fmt.Errorf("failed to create municipality: %w", err)
 	}
 
+	event := AuditEvent{
+		Action:       models.AuditActionMunicipalityCreated,
+		ResourceType: models.AuditResourceMunicipality,
+		ResourceID:   municipality.ID,
+		After:        municipalityAuditState(municipality),
+	}
+	if err := s.audit.RecordTx(tx, actor, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit municipality creation: %w", err)
+	}
+
 	return nil
 }
 
@@ -105,12 +132,13 @@ func (s *MunicipalityService) GetAllMunicipalities(limit, offset int) ([]models.
 }
 
 // UpdateMunicipality updates an existing municipality
-func (s *MunicipalityService) UpdateMunicipality(id string, updates *models.Municipality) (*models.Municipality, error) {
+func (s *MunicipalityService) UpdateMunicipality(actor AuditActor, id string, updates *models.Municipality) (*models.Municipality, error) {
 	// Check if municipality exists
 	municipality, err := s.GetMunicipalityByID(id)
 	if err != nil {
 		return nil, err
 	}
+	before := municipalityAuditState(municipality)
 
 	// If code is being updated, check for conflicts
 	if updates.Code != "" && updates.Code != municipality.Code {
@@ -135,21 +163,50 @@ func (s *MunicipalityService) UpdateMunicipality(id string, updates *models.Muni
 	if updates.ContactPhone != nil {
 		municipality.ContactPhone = updates.ContactPhone
 	}
+	if updates.DefaultCurrency != "" {
+		municipality.DefaultCurrency = updates.DefaultCurrency
+	}
 	if updates.PaymentConfig != nil {
 		municipality.PaymentConfig = updates.PaymentConfig
 	}
 
-	if err := s.db.Save(municipality).Error; err != nil {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(municipality).Error; err != nil {
+		tx.Rollback()
 		return nil, fmt.Errorf("failed to update municipality: %w", err)
 	}
 
+	event := AuditEvent{
+		Action:       models.AuditActionMunicipalityUpdated,
+		ResourceType: models.AuditResourceMunicipality,
+		ResourceID:   municipality.ID,
+		Before:       before,
+		After:        municipalityAuditState(municipality),
+	}
+	if err := s.audit.RecordTx(tx, actor, event); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit municipality update: %w", err)
+	}
+
 	return municipality, nil
 }
 
 // DeleteMunicipality deletes a municipality by ID
-func (s *MunicipalityService) DeleteMunicipality(id string) error {
+func (s *MunicipalityService) DeleteMunicipality(actor AuditActor, id string) error {
 	// Check if municipality exists
-	_, err := s.GetMunicipalityByID(id)
+	municipality, err := s.GetMunicipalityByID(id)
 	if err != nil {
 		return err
 	}
@@ -164,10 +221,35 @@ func (s *MunicipalityService) DeleteMunicipality(id string) error {
 		return fmt.Errorf("cannot delete municipality with existing payments")
 	}
 
-	if err := s.db.Delete(&models.Municipality{}, "id = ?", id).Error; err != nil {
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Delete(&models.Municipality{}, "id = ?", id).Error; err != nil {
+		tx.Rollback()
 		return fmt.Errorf("failed to delete municipality: %w", err)
 	}
 
+	event := AuditEvent{
+		Action:       models.AuditActionMunicipalityDeleted,
+		ResourceType: models.AuditResourceMunicipality,
+		ResourceID:   id,
+		Before:       municipalityAuditState(municipality),
+	}
+	if err := s.audit.RecordTx(tx, actor, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit municipality deletion: %w", err)
+	}
+
 	return nil
 }
 
@@ -197,4 +279,23 @@ func (s *MunicipalityService) SearchMunicipalities(query string, limit, offset i
 	}
 
 	return municipalities, total, nil
-}
\ No newline at end of file
+}
+// municipalityAuditState returns the fields of a municipality recorded in the audit log. The
+// audit log cannot be erased, so the encrypted contact phone is left out.
+func municipalityAuditState(municipality *models.Municipality) map[string]interface{} {
+	state := map[string]interface{}{
+		"name":            municipality.Name,
+		"code":            municipality.Code,
+		"contactEmail":    nil,
+		"defaultCurrency": municipality.DefaultCurrency,
+		"paymentConfig":   nil,
+	}
+	// Copy the pointed-to values, so later changes to the municipality do not alter the state
+	if municipality.ContactEmail != nil {
+		state["contactEmail"] = *municipality.ContactEmail
+	}
+	if municipality.PaymentConfig != nil {
+		state["paymentConfig"] = *municipality.PaymentConfig
+	}
+	return state
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// NotificationService delivers notifications to users in the app, by email and by text message.
+// Notifications that need consent, such as payment reminders, only go out on the channels the
+// user has consented to; the rest go out in the app and by email.
+type NotificationService struct {
+	db       *gorm.DB
+	consents *ConsentService
+	mailer   MailSender
+	sms      SMSSender
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(db *gorm.DB, consents *ConsentService, mailer MailSender, sms SMSSender) *NotificationService {
+	return &NotificationService{
+		db:       db,
+		consents: consents,
+		mailer:   mailer,
+		sms:      sms,
+	}
+}
+
+// Notify delivers the notification to its user and returns the channels it went out on.
+// Delivery failures on one channel are logged and do not stop the others.
+func (s *NotificationService) Notify(notification *models.Notification) ([]models.ConsentChannel, error) {
+	var user models.User
+	if err := s.db.Where("id = ?", notification.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	channels, err := s.channels(&user, notification.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var delivered []models.ConsentChannel
+	for _, channel := range channels {
+		var err error
+		switch channel {
+		case models.ConsentChannelInApp:
+			err = s.db.Create(notification).Error
+		case models.ConsentChannelEmail:
+			err = s.mailer.Send(MailMessage{
+				To:      user.Email,
+				Subject: notification.Title,
+				Body:    fmt.Sprintf("Hello %s,\n\n%s", user.FirstName, notification.Message),
+			})
+		case models.ConsentChannelSMS:
+			err = s.sms.Send(SMSMessage{
+				To:   *user.Phone,
+				Body: notification.Title + ": " + notification.Message,
+			})
+		}
+		if err != nil {
+			log.Printf("Failed to deliver %s notification to user %s by %s: %v", notification.Type, user.ID, channel, err)
+			continue
+		}
+		delivered = append(delivered, channel)
+	}
+
+	return delivered, nil
+}
+
+// channels returns the channels a notification of the given type may go out on to the user
+func (s *NotificationService) channels(user *models.User, notificationType models.NotificationType) ([]models.ConsentChannel, error) {
+	// Erased users have no contact details left to send to
+	if user.IsErased() {
+		return nil, nil
+	}
+
+	purpose, needed := models.NotificationConsentPurpose(notificationType)
+	if !needed {
+		return []models.ConsentChannel{models.ConsentChannelInApp, models.ConsentChannelEmail}, nil
+	}
+
+	var channels []models.ConsentChannel
+	for _, channel := range models.MessageChannels {
+		// Text messages only go to a confirmed phone number
+		if channel == models.ConsentChannelSMS && !user.IsPhoneVerified() {
+			continue
+		}
+		allowed, err := s.consents.Allows(user.ID, purpose, channel)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			channels = append(channels, channel)
+		}
+	}
+
+	return channels, nil
+}
@@ -0,0 +1,524 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"municollect/internal/models"
+)
+
+// oidcKeyRefreshInterval limits how often an unknown kid triggers a JWKS refetch
+const oidcKeyRefreshInterval = time.Minute
+
+var (
+	// ErrOIDCInvalidToken is returned when the identity provider's ID token fails verification
+	ErrOIDCInvalidToken = errors.New("invalid OIDC ID token")
+	// ErrOIDCExchangeFailed is returned when the authorization code cannot be redeemed
+	ErrOIDCExchangeFailed = errors.New("OIDC code exchange failed")
+)
+
+// OIDCConfig holds the identity provider settings for staff single sign-on
+type OIDCConfig struct {
+	// Issuer is the identity provider's issuer URL; discovery is read from
+	// <Issuer>/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// GroupsClaim is the ID token claim listing the user's IdP groups
+	GroupsClaim string
+	// RoleMapping maps IdP group names to roles. When a user is in several
+	// mapped groups the highest role wins.
+	RoleMapping map[string]models.UserRole
+	// DefaultRole is used when none of the user's groups are mapped. When empty,
+	// such users are refused.
+	DefaultRole models.UserRole
+	// JITProvisioning creates a user on first login when no account matches
+	JITProvisioning bool
+	// PostLoginURL, when set, receives the browser after the callback with the
+	// tokens (or an error) in the URL fragment
+	PostLoginURL string
+	HTTPClient   *http.Client
+}
+
+// OIDCConfigFromEnv reads the OIDC settings. It returns nil when OIDC_ISSUER is not set.
+func OIDCConfigFromEnv() (*OIDCConfig, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	config := &OIDCConfig{
+		Issuer:          issuer,
+		ClientID:        os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:    os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:     os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:          []string{"openid", "email", "profile"},
+		GroupsClaim:     "groups",
+		DefaultRole:     models.UserRole(os.Getenv("OIDC_DEFAULT_ROLE")),
+		JITProvisioning: os.Getenv("OIDC_JIT_PROVISIONING") != "false",
+		PostLoginURL:    os.Getenv("OIDC_POST_LOGIN_URL"),
+	}
+
+	if config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+	}
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		config.Scopes = strings.Fields(scopes)
+	}
+	if claim := os.Getenv("OIDC_GROUPS_CLAIM"); claim != "" {
+		config.GroupsClaim = claim
+	}
+	if config.DefaultRole != "" && !isValidUserRole(config.DefaultRole) {
+		return nil, fmt.Errorf("invalid OIDC_DEFAULT_ROLE %q", config.DefaultRole)
+	}
+
+	mapping, err := ParseOIDCRoleMapping(os.Getenv("OIDC_ROLE_MAPPING"))
+	if err != nil {
+		return nil, err
+	}
+	config.RoleMapping = mapping
+
+	return config, nil
+}
+
+// ParseOIDCRoleMapping parses a mapping of the form "group=role,other-group=role"
+func ParseOIDCRoleMapping(value string) (map[string]models.UserRole, error) {
+	mapping := make(map[string]models.UserRole)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		group, role, ok := strings.Cut(entry, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || !isValidUserRole(models.UserRole(role)) {
+			return nil, fmt.Errorf("invalid OIDC role mapping entry %q", entry)
+		}
+		mapping[group] = models.UserRole(role)
+	}
+
+	return mapping, nil
+}
+
+// isValidUserRole reports whether role is one of the known user roles
+func isValidUserRole(role models.UserRole) bool {
+	switch role {
+	case models.UserRoleResident, models.UserRoleMunicipalStaff, models.UserRoleAdmin:
+		return true
+	}
+	return false
+}
+
+// oidcRoleRank orders roles so that the most privileged mapped group wins
+var oidcRoleRank = map[models.UserRole]int{
+	models.UserRoleResident:       1,
+	models.UserRoleMunicipalStaff: 2,
+	models.UserRoleAdmin:          3,
+}
+
+// MapRole returns the role for a user in the given IdP groups
+func (c *OIDCConfig) MapRole(groups []string) (models.UserRole, bool) {
+	var role models.UserRole
+	for _, group := range groups {
+		if mapped, ok := c.RoleMapping[group]; ok && oidcRoleRank[mapped] > oidcRoleRank[role] {
+			role = mapped
+		}
+	}
+
+	if role == "" {
+		return c.DefaultRole, c.DefaultRole != ""
+	}
+	return role, true
+}
+
+// OIDCIdentity is the verified identity asserted by the identity provider
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+	Groups        []string
+}
+
+// oidcDiscovery holds the parts of the provider metadata used by the client
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider is an OpenID Connect relying party using the authorization code flow with PKCE
+type OIDCProvider struct {
+	config *OIDCConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewOIDCProvider creates a new OIDC provider client. Provider metadata is fetched on first use.
+func NewOIDCProvider(config *OIDCConfig) *OIDCProvider {
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &OIDCProvider{
+		config: config,
+		client: client,
+	}
+}
+
+// Config returns the provider configuration
+func (p *OIDCProvider) Config() *OIDCConfig {
+	return p.config
+}
+
+// NewPKCEVerifier returns a random PKCE code verifier (RFC 7636)
+func NewPKCEVerifier() (string, error) {
+	return generateSecureToken(32)
+}
+
+// NewOIDCState returns a random value for the OIDC state and nonce parameters
+func NewOIDCState() (string, error) {
+	return generateSecureToken(16)
+}
+
+// PKCEChallenge returns the S256 code challenge for a verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the identity provider URL that starts a login
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {PKCEChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified identity from the ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCExchangeFailed, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: invalid token response: %v", ErrOIDCExchangeFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrOIDCExchangeFailed, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in response", ErrOIDCExchangeFailed)
+	}
+
+	return p.VerifyIDToken(ctx, body.IDToken, nonce)
+}
+
+// VerifyIDToken checks the ID token's signature, issuer, audience, expiry and nonce
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(rawToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.getKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCInvalidToken, err)
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCInvalidToken)
+	}
+
+	// With several audiences the token must have been issued to this client
+	if audiences, _ := claims.GetAudience(); len(audiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.config.ClientID {
+			return nil, fmt.Errorf("%w: unexpected authorized party", ErrOIDCInvalidToken)
+		}
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrOIDCInvalidToken)
+	}
+
+	identity := &OIDCIdentity{
+		Issuer:  discovery.Issuer,
+		Subject: subject,
+		Groups:  stringListClaim(claims[p.config.GroupsClaim]),
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.GivenName, _ = claims["given_name"].(string)
+	identity.FamilyName, _ = claims["family_name"].(string)
+	identity.Name, _ = claims["name"].(string)
+
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	return identity, nil
+}
+
+// stringListClaim reads a claim holding a list of strings, or a single string
+func stringListClaim(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// getDiscovery returns the provider metadata, fetching it on first use
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC discovery document: %w", err)
+	}
+	if discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", discovery.Issuer, p.config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// getKey returns the provider's signing key with the given kid, refetching the
+// key set when the kid is unknown so that IdP key rotation is picked up
+func (p *OIDCProvider) getKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcKeyRefreshInterval && p.keys != nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, raw := range set.Keys {
+		keyID, key, err := parseJWK(raw)
+		if err != nil {
+			// Skip keys we cannot use, such as encryption keys
+			continue
+		}
+		keys[keyID] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		// A key set with a single key may be used without kid headers
+		if kid == "" && len(p.keys) == 1 {
+			for _, only := range p.keys {
+				return only, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// getJSON fetches a JSON document
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(target)
+}
+
+// parseJWK converts a signing JSON Web Key (RSA, EC P-256 or Ed25519) to a public key
+func parseJWK(raw json.RawMessage) (string, crypto.PublicKey, error) {
+	var jwk struct {
+		KTY string `json:"kty"`
+		KID string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return "", nil, errors.New("not a signing key")
+	}
+
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch jwk.KTY {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.KID, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := key.ECDH(); err != nil {
+			return "", nil, fmt.Errorf("invalid EC key: %w", err)
+		}
+		return jwk.KID, key, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return "", nil, errors.New("invalid Ed25519 key")
+		}
+		return jwk.KID, ed25519.PublicKey(x), nil
+	default:
+		return "", nil, fmt.Errorf("unsupported key type %q", jwk.KTY)
+	}
+}
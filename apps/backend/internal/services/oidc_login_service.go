@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+var (
+	// ErrOIDCEmailNotVerified is returned when the identity provider has not verified the user's email
+	ErrOIDCEmailNotVerified = errors.New("identity provider did not verify the email address")
+	// ErrOIDCNoRole is returned when none of the user's IdP groups map to a role
+	ErrOIDCNoRole = errors.New("identity provider groups do not grant access")
+	// ErrOIDCUnknownUser is returned when no account matches and provisioning is disabled
+	ErrOIDCUnknownUser = errors.New("no account exists for this identity")
+	// ErrOIDCLinkRefused is returned when the identity's email belongs to an account that may not be linked
+	ErrOIDCLinkRefused = errors.New("identity cannot be linked to an administrator account")
+)
+
+// OIDCLoginService maps identities verified by an OIDC identity provider to users
+type OIDCLoginService struct {
+	db       *gorm.DB
+	provider *OIDCProvider
+}
+
+// NewOIDCLoginService creates a new OIDC login service
+func NewOIDCLoginService(db *gorm.DB, provider *OIDCProvider) *OIDCLoginService {
+	return &OIDCLoginService{
+		db:       db,
+		provider: provider,
+	}
+}
+
+// Provider returns the identity provider client
+func (s *OIDCLoginService) Provider() *OIDCProvider {
+	return s.provider
+}
+
+// ResolveUser returns the user for a verified identity. Known identities map to their
+// linked user; otherwise the identity is linked to the user with the same verified email,
+// or a new user is provisioned. Administrator accounts are never linked by email. The user's role follows their IdP groups on every login.
+// roleChanged reports whether an existing user's role was updated.
+func (s *OIDCLoginService) ResolveUser(identity *OIDCIdentity) (user *models.User, roleChanged bool, err error) {
+	role, ok := s.provider.Config().MapRole(identity.Groups)
+	if !ok {
+		return nil, false, ErrOIDCNoRole
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+
+	var link models.UserIdentity
+	err = tx.Where("issuer = ? AND subject = ?", identity.Issuer, identity.Subject).First(&link).Error
+	switch {
+	case err == nil:
+		user = &models.User{}
+		if err := tx.Where("id = ?", link.UserID).First(user).Error; err != nil {
+			tx.Rollback()
+			return nil, false, fmt.Errorf("failed to get linked user: %w", err)
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Linking and provisioning rely on the email, so it must be verified by the IdP
+		if identity.Email == "" || !identity.EmailVerified {
+			tx.Rollback()
+			return nil, false, ErrOIDCEmailNotVerified
+		}
+
+		user, err = s.findOrProvisionUser(tx, identity, role, now)
+		if err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+
+		link = models.UserIdentity{
+			UserID:  user.ID,
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			tx.Rollback()
+			return nil, false, fmt.Errorf("failed to link identity: %w", err)
+		}
+	default:
+		tx.Rollback()
+		return nil, false, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	if err := tx.Model(&link).Updates(map[string]interface{}{
+		"email":         identity.Email,
+		"last_login_at": now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, false, fmt.Errorf("failed to update identity: %w", err)
+	}
+
+	if user.Role != role {
+		if err := tx.Model(user).Update("role", role).Error; err != nil {
+			tx.Rollback()
+			return nil, false, fmt.Errorf("failed to update user role: %w", err)
+		}
+		user.Role = role
+		roleChanged = true
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, false, fmt.Errorf("failed to commit OIDC login: %w", err)
+	}
+
+	return user, roleChanged, nil
+}
+
+// findOrProvisionUser returns the user with the identity's email, creating one when
+// just-in-time provisioning is enabled
+func (s *OIDCLoginService) findOrProvisionUser(tx *gorm.DB, identity *OIDCIdentity, role models.UserRole, now time.Time) (*models.User, error) {
+	var user models.User
+	err := tx.Where("email_index = ?", models.EmailIndex(identity.Email)).First(&user).Error
+	if err == nil {
+		// Whoever controls the email at the IdP would take over the account
+		if user.Role == models.UserRoleAdmin {
+			return nil, ErrOIDCLinkRefused
+		}
+		if user.EmailVerifiedAt == nil {
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return nil, fmt.Errorf("failed to mark email verified: %w", err)
+			}
+			user.EmailVerifiedAt = &now
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !s.provider.Config().JITProvisioning {
+		return nil, ErrOIDCUnknownUser
+	}
+
+	firstName, lastName := oidcNames(identity)
+	user = models.User{
+		Email:           identity.Email,
+		FirstName:       firstName,
+		LastName:        lastName,
+		Role:            role,
+		EmailVerifiedAt: &now,
+	}
+	// Provisioned users have no password; they can only sign in through the IdP
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to provision user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// oidcNames derives first and last names from the identity's profile claims
+func oidcNames(identity *OIDCIdentity) (string, string) {
+	firstName, lastName := identity.GivenName, identity.FamilyName
+
+	if parts := strings.Fields(identity.Name); firstName == "" && len(parts) > 0 {
+		firstName = parts[0]
+		if lastName == "" && len(parts) > 1 {
+			lastName = strings.Join(parts[1:], " ")
+		}
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if lastName == "" {
+		lastName = "-"
+	}
+
+	return truncateName(firstName), truncateName(lastName)
+}
+
+// truncateName keeps names within the 100 character column limit
+func truncateName(name string) string {
+	runes := []rune(name)
+	if len(runes) > 100 {
+		return string(runes[:100])
+	}
+	return name
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"municollect/internal/models"
+	"municollect/internal/oidcmock"
+)
+
+// startMockIdP runs a mock identity provider with one staff account
+func startMockIdP(t *testing.T) (*oidcmock.Provider, *OIDCProvider) {
+	t.Helper()
+
+	idp, err := oidcmock.New("municollect")
+	require.NoError(t, err)
+	server := httptest.NewServer(idp.Handler())
+	t.Cleanup(server.Close)
+	idp.SetIssuer(server.URL)
+
+	idp.AddUser(oidcmock.User{
+		Subject:       "staff-1",
+		Email:         "staff@example.com",
+		EmailVerified: true,
+		GivenName:     "Somchai",
+		FamilyName:    "Staff",
+		Groups:        []string{"muni-staff"},
+	})
+
+	provider := NewOIDCProvider(&OIDCConfig{
+		Issuer:      server.URL,
+		ClientID:    "municollect",
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+		GroupsClaim: "groups",
+		RoleMapping: map[string]models.UserRole{"muni-staff": models.UserRoleMunicipalStaff},
+		HTTPClient:  server.Client(),
+	})
+
+	return idp, provider
+}
+
+// authorize follows the authorization URL as a browser would and returns the callback query
+func authorize(t *testing.T, authURL, loginHint string) url.Values {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Get(authURL + "&login_hint=" + url.QueryEscape(loginHint))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query()
+}
+
+func TestOIDCProvider_AuthorizationCodeFlow(t *testing.T) {
+	_, provider := startMockIdP(t)
+	ctx := context.Background()
+
+	verifier, err := NewPKCEVerifier()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+	assert.Contains(t, authURL, "code_challenge="+PKCEChallenge(verifier))
+	assert.NotContains(t, authURL, verifier)
+
+	callback := authorize(t, authURL, "staff@example.com")
+	assert.Equal(t, "state-1", callback.Get("state"))
+
+	identity, err := provider.Exchange(ctx, callback.Get("code"), verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "staff-1", identity.Subject)
+	assert.Equal(t, "staff@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, []string{"muni-staff"}, identity.Groups)
+
+	role, ok := provider.Config().MapRole(identity.Groups)
+	assert.True(t, ok)
+	assert.Equal(t, models.UserRoleMunicipalStaff, role)
+
+	// Codes are single use
+	_, err = provider.Exchange(ctx, callback.Get("code"), verifier, "nonce-1")
+	assert.True(t, errors.Is(err, ErrOIDCExchangeFailed))
+}
+
+func TestOIDCProvider_RejectsWrongVerifierAndNonce(t *testing.T) {
+	_, provider := startMockIdP(t)
+	ctx := context.Background()
+
+	verifier, err := NewPKCEVerifier()
+	require.NoError(t, err)
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+		require.NoError(t, err)
+		callback := authorize(t, authURL, "staff@example.com")
+
+		_, err = provider.Exchange(ctx, callback.Get("code"), verifier+"x", "nonce")
+		assert.True(t, errors.Is(err, ErrOIDCExchangeFailed))
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+		require.NoError(t, err)
+		callback := authorize(t, authURL, "staff@example.com")
+
+		_, err = provider.Exchange(ctx, callback.Get("code"), verifier, "other-nonce")
+		assert.True(t, errors.Is(err, ErrOIDCInvalidToken))
+	})
+}
+
+func TestOIDCProvider_VerifyIDToken(t *testing.T) {
+	idp, provider := startMockIdP(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   idp.Issuer(),
+			"sub":   "staff-1",
+			"aud":   "municollect",
+			"exp":   now.Add(time.Minute).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "nonce",
+		}
+	}
+
+	token, err := idp.SignIDToken(valid())
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, token, "nonce")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+	}{
+		{"other audience", func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{"other issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }},
+		{"missing subject", func(c jwt.MapClaims) { delete(c, "sub") }},
+		{"several audiences without azp", func(c jwt.MapClaims) { c["aud"] = []string{"municollect", "other"} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+			token, err := idp.SignIDToken(claims)
+			require.NoError(t, err)
+
+			_, err = provider.VerifyIDToken(ctx, token, "nonce")
+			assert.True(t, errors.Is(err, ErrOIDCInvalidToken))
+		})
+	}
+}
+
+func TestParseOIDCRoleMapping(t *testing.T) {
+	mapping, err := ParseOIDCRoleMapping("staff=municipal_staff, admins = admin ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.UserRole{
+		"staff":  models.UserRoleMunicipalStaff,
+		"admins": models.UserRoleAdmin,
+	}, mapping)
+
+	_, err = ParseOIDCRoleMapping("staff=superuser")
+	assert.Error(t, err)
+	_, err = ParseOIDCRoleMapping("staff")
+	assert.Error(t, err)
+}
+
+func TestOIDCConfig_MapRole(t *testing.T) {
+	config := &OIDCConfig{
+		RoleMapping: map[string]models.UserRole{
+			"staff":  models.UserRoleMunicipalStaff,
+			"admins": models.UserRoleAdmin,
+		},
+	}
+
+	role, ok := config.MapRole([]string{"staff", "admins", "other"})
+	assert.True(t, ok)
+	assert.Equal(t, models.UserRoleAdmin, role)
+
+	_, ok = config.MapRole([]string{"other"})
+	assert.False(t, ok)
+
+	config.DefaultRole = models.UserRoleResident
+	role, ok = config.MapRole(nil)
+	assert.True(t, ok)
+	assert.Equal(t, models.UserRoleResident, role)
+}
+
+func TestOIDCNames(t *testing.T) {
+	first, last := oidcNames(&OIDCIdentity{GivenName: "Somchai", FamilyName: "Jaidee"})
+	assert.Equal(t, "Somchai", first)
+	assert.Equal(t, "Jaidee", last)
+
+	first, last = oidcNames(&OIDCIdentity{Name: "Suda Mali Rak"})
+	assert.Equal(t, "Suda", first)
+	assert.Equal(t, "Mali Rak", last)
+
+	first, last = oidcNames(&OIDCIdentity{Name: "  ", Email: "staff@example.com"})
+	assert.Equal(t, "staff", first)
+	assert.Equal(t, "-", last)
+}
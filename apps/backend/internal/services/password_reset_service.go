@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// PasswordResetTokenLifetime is how long a password reset link stays valid
+const PasswordResetTokenLifetime = time.Hour
+
+// ErrPasswordResetTokenInvalid is returned for unknown, used or expired reset tokens
+var ErrPasswordResetTokenInvalid = errors.New("password reset token is invalid or has expired")
+
+// PasswordResetService handles the forgotten password flow
+type PasswordResetService struct {
+	db                *gorm.DB
+	mailer            MailSender
+	revocationService *TokenRevocationService
+	resetURL          string
+}
+
+// NewPasswordResetService creates a new password reset service.
+// The link sent to users points at PASSWORD_RESET_URL.
+func NewPasswordResetService(db *gorm.DB, mailer MailSender, revocationService *TokenRevocationService) *PasswordResetService {
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		resetURL = "http://localhost:3000/reset-password"
+	}
+
+	return &PasswordResetService{
+		db:                db,
+		mailer:            mailer,
+		revocationService: revocationService,
+		resetURL:          resetURL,
+	}
+}
+
+// RequestReset emails a reset link to the user with the given email address.
+// Unknown addresses are ignored so that callers cannot probe for accounts.
+func (s *PasswordResetService) RequestReset(email string) error {
+	var user models.User
+	if err := s.db.Where("email_index = ?", models.EmailIndex(email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Only the most recent link should work
+	if err := s.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to invalidate previous reset tokens: %w", err)
+	}
+
+	token, err := generateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	record := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(PasswordResetTokenLifetime),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+
+	link := fmt.Sprintf("%s?token=%s", s.resetURL, url.QueryEscape(token))
+	msg := MailMessage{
+		To:      user.Email,
+		Subject: "Reset your MuniCollect password",
+		Body: fmt.Sprintf("Hello %s,\n\nWe received a request to reset your password. "+
+			"Open the link below within %d minutes to choose a new one:\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email.",
+			user.FirstName, int(PasswordResetTokenLifetime.Minutes()), link),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword consumes a reset token, stores the new password hash and signs the
+// user out of every existing session. It returns the ID of the affected user.
+func (s *PasswordResetService) ResetPassword(token, passwordHash string) (string, error) {
+	var record models.PasswordResetToken
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrPasswordResetTokenInvalid
+		}
+		return "", fmt.Errorf("failed to get password reset token: %w", err)
+	}
+
+	if !record.IsUsable() {
+		return "", ErrPasswordResetTokenInvalid
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Mark the token as used; the used_at guard makes it single-use under concurrency
+	result := tx.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("failed to consume password reset token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return "", ErrPasswordResetTokenInvalid
+	}
+
+	result = tx.Model(&models.Auth{}).Where("user_id = ?", record.UserID).Update("password_hash", passwordHash)
+	if result.Error != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("failed to update password: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return "", fmt.Errorf("authentication record not found")
+	}
+
+	// A password reset forced by an admin is complete
+	if err := tx.Model(&models.User{}).Where("id = ?", record.UserID).Update("password_reset_required", false).Error; err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("failed to clear password reset requirement: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return "", fmt.Errorf("failed to commit password reset: %w", err)
+	}
+
+	if err := s.revocationService.SignOutEverywhere(record.UserID, models.RefreshTokenRevokedPasswordChange); err != nil {
+		return "", fmt.Errorf("failed to revoke existing sessions: %w", err)
+	}
+
+	return record.UserID, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"municollect/internal/models"
+)
+
+// paymentProviderTimeout bounds each call to a payment provider
+const paymentProviderTimeout = 30 * time.Second
+
+// PaymentProviderSyncInterval is how often the background worker asks providers about pending payments
+const PaymentProviderSyncInterval = time.Minute
+
+// paymentRefundLease is how long a refund keeps its claim on a payment. It outlasts the
+// provider call, so an older claim was left by a request that never finished.
+const paymentRefundLease = 10 * time.Minute
+
+// Payment provider errors
+var (
+	ErrPaymentProviderFailed  = errors.New("payment provider failed")
+	ErrChargeNotFound         = errors.New("charge not found")
+	ErrRefundNotAllowed       = errors.New("charge cannot be refunded")
+	ErrRefundInProgress       = errors.New("a refund of the payment is already in progress")
+	ErrInvalidProviderEvent   = errors.New("invalid payment provider event")
+	ErrProviderManagedPayment = errors.New("payment status is set by its payment provider")
+)
+
+// PaymentProvider is a payment gateway that collects payments on a municipality's behalf.
+// PaymentService moves payments between statuses from the provider's results.
+type PaymentProvider interface {
+	// Name identifies the provider, such as "mock". It is stored with the payments it collects.
+	Name() string
+	// CreateCharge asks the provider to collect a payment
+	CreateCharge(ctx context.Context, charge ChargeRequest) (*ChargeResult, error)
+	// QueryStatus returns the current state of a charge, or ErrChargeNotFound
+	QueryStatus(ctx context.Context, reference string) (*ChargeResult, error)
+	// Refund returns the amount of a completed charge to the payer
+	Refund(ctx context.Context, reference string, amount models.Money) (*RefundResult, error)
+	// ParseWebhook decodes a notification the provider sent about one of its charges, or
+	// returns ErrInvalidProviderEvent
+	ParseWebhook(payload []byte) (*ProviderEvent, error)
+}
+
+// ChargeRequest describes a payment for a provider to collect
+type ChargeRequest struct {
+	PaymentID   string
+	Method      string
+	Amount      models.Money
+	Description string
+}
+
+// ChargeResult is the state of a charge at a provider. Status is pending, completed or failed.
+type ChargeResult struct {
+	Reference     string
+	Status        models.PaymentStatus
+	FailureReason string
+	// Data is recorded as the transaction data of the status change
+	Data map[string]interface{}
+}
+
+// RefundResult is a refund made by a provider
+type RefundResult struct {
+	Reference string
+	Amount    models.Money
+	Data      map[string]interface{}
+}
+
+// ProviderEvent is a notification from a provider that a charge changed status
+type ProviderEvent struct {
+	// ID identifies the event at the provider, so repeated deliveries can be recognised
+	ID        string
+	Reference string
+	Status    models.PaymentStatus
+	Data      map[string]interface{}
+}
+
+// PaymentProviders selects the provider that collects payments made with each payment method
+type PaymentProviders struct {
+	byName   map[string]PaymentProvider
+	byMethod map[string]PaymentProvider
+}
+
+// NewPaymentProviders creates an empty provider registry. Payments made with a method that
+// has no provider, such as cash, are settled by staff.
+func NewPaymentProviders() *PaymentProviders {
+	return &PaymentProviders{
+		byName:   make(map[string]PaymentProvider),
+		byMethod: make(map[string]PaymentProvider),
+	}
+}
+
+// Register makes the provider collect payments made with the given methods
+func (r *PaymentProviders) Register(provider PaymentProvider, methods ...string) {
+	r.byName[provider.Name()] = provider
+	for _, method := range methods {
+		r.byMethod[method] = provider
+	}
+}
+
+// Get returns the provider with the given name
+func (r *PaymentProviders) Get(name string) (PaymentProvider, bool) {
+	if r == nil {
+		return nil, false
+	}
+	provider, ok := r.byName[name]
+	return provider, ok
+}
+
+// ForConfig returns the provider for the first of the configuration's payment methods that
+// has one, together with that method
+func (r *PaymentProviders) ForConfig(config *models.PaymentConfig) (PaymentProvider, string, bool) {
+	if r == nil || config == nil {
+		return nil, "", false
+	}
+	for _, method := range config.PaymentMethods {
+		if provider, ok := r.byMethod[method]; ok {
+			return provider, method, true
+		}
+	}
+	return nil, "", false
+}
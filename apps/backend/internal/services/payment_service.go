@@ -1,31 +1,47 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"municollect/internal/models"
 )
 
+// ErrInvalidStatusTransition is returned for a status change the payment's current status does not allow
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
 // PaymentService handles payment-related business logic
 type PaymentService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	audit     *AuditService
+	providers *PaymentProviders
 }
 
 // NewPaymentService creates a new payment service
-func NewPaymentService(db *gorm.DB) *PaymentService {
+func NewPaymentService(db *gorm.DB, audit *AuditService) *PaymentService {
 	return &PaymentService{
-		db: db,
+		db:    db,
+		audit: audit,
 	}
 }
 
+// SetProviders makes the payment providers collect payments made with their methods. Their
+// results then move those payments between statuses. Without providers every payment is
+// settled by staff.
+func (s *PaymentService) SetProviders(providers *PaymentProviders) {
+	s.providers = providers
+}
+
 // PaymentRequest represents a payment creation request
 type PaymentRequest struct {
 	MunicipalityID string                `json:"municipalityId" validate:"required,uuid"`
 	ServiceType    models.ServiceType    `json:"serviceType" validate:"required"`
-	Amount         float64               `json:"amount" validate:"required,gt=0"`
+	Amount         models.Money          `json:"amount" validate:"required,gt=0"`
 	Currency       models.Currency       `json:"currency,omitempty"`
 	DueDate        *time.Time            `json:"dueDate,omitempty"`
 	UserDetails    map[string]interface{} `json:"userDetails,omitempty"`
@@ -41,7 +57,8 @@ type PaymentFilter struct {
 	DateTo         *time.Time             `json:"dateTo,omitempty"`
 }
 
-// CreatePayment creates a new payment
+// CreatePayment creates a new payment. When the payment was stored but its provider could
+// not take it, the payment is returned together with the error.
 func (s *PaymentService) CreatePayment(userID string, req *PaymentRequest) (*models.Payment, error) {
 	// Validate municipality exists
 	var municipality models.Municipality
@@ -61,26 +78,45 @@ func (s *PaymentService) CreatePayment(userID string, req *PaymentRequest) (*mod
 		return nil, fmt.Errorf("failed to validate user: %w", err)
 	}
 
-	// Set default currency if not provided
-	currency := req.Currency
+	// Enforce the municipality's verification policy
+	if missing := municipality.PaymentConfig.MissingVerifications(&user); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing %v verification", ErrUserNotVerified, missing)
+	}
+
+	// Bill in the municipality's currency
+	currency := municipality.DefaultCurrency
 	if currency == "" {
-		if municipality.PaymentConfig != nil && municipality.PaymentConfig.Currency != "" {
-			currency = models.Currency(municipality.PaymentConfig.Currency)
-		} else {
-			currency = models.CurrencyUSD
-		}
+		currency = models.DefaultCurrency
+	}
+	if req.Currency != "" && req.Currency != currency {
+		return nil, fmt.Errorf("%w: municipality bills in %s, not %s", models.ErrCurrencyMismatch, currency, req.Currency)
+	}
+
+	// Count the amount in the currency's minor units
+	amount, err := req.Amount.In(currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %w", err)
+	}
+	if err := models.ValidateAmount(amount); err != nil {
+		return nil, err
 	}
 
-	// Create payment
+	// Create payment, collected by the provider of the municipality's payment method if it has one
 	payment := &models.Payment{
 		MunicipalityID: req.MunicipalityID,
 		UserID:         userID,
 		ServiceType:    req.ServiceType,
-		Amount:         req.Amount,
+		Amount:         amount,
 		Currency:       currency,
 		Status:         models.PaymentStatusPending,
 		DueDate:        req.DueDate,
 	}
+	provider, method, hasProvider := s.providers.ForConfig(municipality.PaymentConfig)
+	if hasProvider {
+		name := provider.Name()
+		payment.Provider = &name
+		payment.PaymentMethod = &method
+	}
 
 	// Start transaction
 	tx := s.db.Begin()
@@ -113,9 +149,17 @@ func (s *PaymentService) CreatePayment(userID string, req *PaymentRequest) (*mod
 		return nil, fmt.Errorf("failed to commit payment creation: %w", err)
 	}
 
-	// Load relationships
+	// Ask the provider to collect the payment once it is stored. The payment is returned
+	// with the error, since it exists whether or not the provider took it.
+	if hasProvider {
+		if err := s.createCharge(provider, payment); err != nil {
+			return payment, err
+		}
+	}
+
+	// Load relationships; the payment is stored either way, so a failure is only logged
 	if err := s.db.Preload("Municipality").Preload("User").First(payment, "id = ?", payment.ID).Error; err != nil {
-		return nil, fmt.Errorf("failed to load payment relationships: %w", err)
+		log.Printf("Failed to load relationships of payment %s: %v", payment.ID, err)
 	}
 
 	return payment, nil
@@ -141,6 +185,19 @@ func (s *PaymentService) GetPaymentByID(paymentID string, userID *string) (*mode
 	return &payment, nil
 }
 
+// GetPaymentByProviderReference retrieves the payment a provider knows by the given charge reference
+func (s *PaymentService) GetPaymentByProviderReference(provider, reference string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := s.db.Where("provider = ? AND provider_reference = ?", provider, reference).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("payment with %s reference '%s' not found", provider, reference)
+		}
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return &payment, nil
+}
+
 // GetPaymentHistory retrieves payment history with filtering and pagination
 func (s *PaymentService) GetPaymentHistory(filter *PaymentFilter, limit, offset int) ([]models.Payment, int64, error) {
 	var payments []models.Payment
@@ -188,19 +245,8 @@ func (s *PaymentService) GetPaymentHistory(filter *PaymentFilter, limit, offset
 	return payments, total, nil
 }
 
-// UpdatePaymentStatus updates the status of a payment
-func (s *PaymentService) UpdatePaymentStatus(paymentID string, status models.PaymentStatus, transactionData map[string]interface{}) (*models.Payment, error) {
-	// Get payment
-	payment, err := s.GetPaymentByID(paymentID, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// Validate status transition
-	if !s.isValidStatusTransition(payment.Status, status) {
-		return nil, fmt.Errorf("invalid status transition from %s to %s", payment.Status, status)
-	}
-
+// UpdatePaymentStatus updates the status of a payment and records who changed it in the audit log
+func (s *PaymentService) UpdatePaymentStatus(actor AuditActor, paymentID string, status models.PaymentStatus, transactionData map[string]interface{}) (*models.Payment, error) {
 	// Start transaction
 	tx := s.db.Begin()
 	defer func() {
@@ -209,6 +255,48 @@ func (s *PaymentService) UpdatePaymentStatus(paymentID string, status models.Pay
 		}
 	}()
 
+	// Lock the payment so concurrent status changes, such as a webhook and the provider sync,
+	// are applied one after the other and each sees the status the previous one left
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", paymentID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("payment with ID '%s' not found", paymentID)
+		}
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	if err := s.updatePaymentStatusTx(tx, actor, &payment, status, transactionData); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit payment status update: %w", err)
+	}
+
+	// The update is committed, so a failed reload returns the payment as updated
+	updated, err := s.reloadPayment(paymentID)
+	if err != nil {
+		log.Printf("Failed to reload payment %s: %v", paymentID, err)
+		return &payment, nil
+	}
+
+	return updated, nil
+}
+
+// updatePaymentStatusTx moves a payment locked by tx to the given status, recording the
+// transaction and the audit entry in tx
+func (s *PaymentService) updatePaymentStatusTx(tx *gorm.DB, actor AuditActor, payment *models.Payment, status models.PaymentStatus, transactionData map[string]interface{}) error {
+	// Validate status transition
+	if !s.isValidStatusTransition(payment.Status, status) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidStatusTransition, payment.Status, status)
+	}
+
+	// Keep the audited fields as they were before the update
+	before := map[string]interface{}{"status": payment.Status, "paidAt": payment.PaidAt}
+
 	// Update payment status
 	updates := map[string]interface{}{
 		"status": status,
@@ -220,34 +308,301 @@ func (s *PaymentService) UpdatePaymentStatus(paymentID string, status models.Pay
 		updates["paid_at"] = &now
 	}
 
-	if err := tx.Model(payment).Updates(updates).Error; err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("failed to update payment status: %w", err)
+	// Only move the payment from the status it was read with
+	result := tx.Model(&models.Payment{}).Where("id = ? AND status = ?", payment.ID, payment.Status).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update payment status: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w from %s to %s: payment status changed concurrently", ErrInvalidStatusTransition, payment.Status, status)
 	}
 
 	// Create transaction record
 	transaction := &models.PaymentTransaction{
-		PaymentID:       paymentID,
+		PaymentID:       payment.ID,
 		Status:          status,
 		TransactionData: transactionData,
 	}
 
 	if err := tx.Create(transaction).Error; err != nil {
+		return fmt.Errorf("failed to create payment transaction: %w", err)
+	}
+
+	// Record the change with its actor in the same transaction
+	after := map[string]interface{}{"status": status, "paidAt": before["paidAt"]}
+	if paidAt, ok := updates["paid_at"]; ok {
+		after["paidAt"] = paidAt
+	}
+	event := AuditEvent{
+		Action:       models.AuditActionPaymentStatusChanged,
+		ResourceType: models.AuditResourcePayment,
+		ResourceID:   payment.ID,
+		Details:      models.AuditDetails{"transactionId": transaction.ID},
+		Before:       before,
+		After:        after,
+	}
+	return s.audit.RecordTx(tx, actor, event)
+}
+
+// reloadPayment loads a payment with its relationships after an update
+func (s *PaymentService) reloadPayment(paymentID string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := s.db.Preload("Municipality").Preload("User").Preload("Transactions").First(&payment, "id = ?", paymentID).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload payment: %w", err)
+	}
+
+	return &payment, nil
+}
+
+// UpdatePaymentStatusManually records a status change made by staff. Payments collected by
+// a payment provider get their status from the provider instead, except that a payment the
+// provider never took can be moved back to pending, which asks the provider for a new charge.
+// If that charge fails, the payment is returned together with the error.
+func (s *PaymentService) UpdatePaymentStatusManually(actor AuditActor, paymentID string, status models.PaymentStatus, transactionData map[string]interface{}) (*models.Payment, error) {
+	payment, err := s.GetPaymentByID(paymentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !payment.IsProviderManaged() {
+		return s.UpdatePaymentStatus(actor, paymentID, status, transactionData)
+	}
+	if payment.ProviderRef != nil || status != models.PaymentStatusPending {
+		return nil, fmt.Errorf("%w: %s", ErrProviderManagedPayment, *payment.Provider)
+	}
+
+	provider, ok := s.providers.Get(*payment.Provider)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider %s is not configured", ErrPaymentProviderFailed, *payment.Provider)
+	}
+
+	payment, err = s.UpdatePaymentStatus(actor, paymentID, status, transactionData)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.createCharge(provider, payment); err != nil {
+		return payment, err
+	}
+
+	return s.reloadPayment(paymentID)
+}
+
+// SyncPendingPayments asks the providers about every pending payment they collect, and
+// returns how many payments changed status
+func (s *PaymentService) SyncPendingPayments() (int, error) {
+	var paymentIDs []string
+	if err := s.db.Model(&models.Payment{}).
+		Where("status = ? AND provider IS NOT NULL AND provider_reference IS NOT NULL", models.PaymentStatusPending).
+		Order("created_at").Pluck("id", &paymentIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to list pending provider payments: %w", err)
+	}
+
+	changed := 0
+	for _, paymentID := range paymentIDs {
+		updated, err := s.syncPendingPayment(paymentID)
+		if err != nil {
+			log.Printf("Failed to sync payment %s with its provider: %v", paymentID, err)
+			continue
+		}
+		if updated {
+			changed++
+		}
+	}
+
+	return changed, nil
+}
+
+// syncPendingPayment asks the provider of a pending payment for the state of its charge and
+// moves the payment to the status the provider reports. The provider is asked before the
+// payment is locked; a payment that another replica is updating, or that is no longer pending
+// by then, is skipped.
+func (s *PaymentService) syncPendingPayment(paymentID string) (bool, error) {
+	var payment models.Payment
+	err := s.db.Where("id = ? AND status = ? AND provider IS NOT NULL AND provider_reference IS NOT NULL", paymentID, models.PaymentStatusPending).
+		First(&payment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	provider, ok := s.providers.Get(*payment.Provider)
+	if !ok {
+		return false, fmt.Errorf("%w: provider %s is not configured", ErrPaymentProviderFailed, *payment.Provider)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), paymentProviderTimeout)
+	defer cancel()
+	result, err := provider.QueryStatus(ctx, *payment.ProviderRef)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrPaymentProviderFailed, err)
+	}
+	if result.Status == "" || result.Status == payment.Status {
+		return false, nil
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the payment again and apply the result only if it is still the charge the provider was asked about
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("id = ? AND status = ? AND provider_reference = ?", paymentID, models.PaymentStatusPending, *payment.ProviderRef).
+		First(&payment).Error
+	if err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to create payment transaction: %w", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to lock payment: %w", err)
+	}
+
+	if err := s.updatePaymentStatusTx(tx, AuditActor{}, &payment, result.Status, chargeTransactionData(result)); err != nil {
+		tx.Rollback()
+		return false, err
 	}
 
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
-		return nil, fmt.Errorf("failed to commit payment status update: %w", err)
+		return false, fmt.Errorf("failed to commit payment status update: %w", err)
 	}
 
-	// Reload payment with updated data
-	if err := s.db.Preload("Municipality").Preload("User").Preload("Transactions").First(payment, "id = ?", paymentID).Error; err != nil {
-		return nil, fmt.Errorf("failed to reload payment: %w", err)
+	return true, nil
+}
+
+// RunProviderSync syncs pending payments with their providers every interval until ctx is
+// cancelled, so payments settle even when a provider's webhook never arrives
+func (s *PaymentService) RunProviderSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SyncPendingPayments(); err != nil {
+			log.Printf("Failed to sync pending payments: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	return payment, nil
+// RefundPayment refunds a completed payment in full through its provider and marks it refunded.
+// The payment is claimed before the provider is asked, so a concurrent refund fails with
+// ErrRefundInProgress instead of refunding it again. When the refund was made but could not
+// be recorded, the payment is returned together with the error.
+func (s *PaymentService) RefundPayment(actor AuditActor, paymentID, reason string) (*models.Payment, error) {
+	payment, err := s.GetPaymentByID(paymentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isValidStatusTransition(payment.Status, models.PaymentStatusRefunded) {
+		return nil, fmt.Errorf("%w from %s to %s", ErrInvalidStatusTransition, payment.Status, models.PaymentStatusRefunded)
+	}
+	if !payment.IsProviderManaged() || payment.ProviderRef == nil {
+		return nil, fmt.Errorf("%w: payment was not collected by a payment provider", ErrRefundNotAllowed)
+	}
+
+	provider, ok := s.providers.Get(*payment.Provider)
+	if !ok {
+		return nil, fmt.Errorf("%w: provider %s is not configured", ErrPaymentProviderFailed, *payment.Provider)
+	}
+
+	// Claim the payment, committed before the provider call
+	now := time.Now()
+	claim := s.db.Model(&models.Payment{}).
+		Where("id = ? AND status = ? AND (refund_started_at IS NULL OR refund_started_at < ?)", paymentID, payment.Status, now.Add(-paymentRefundLease)).
+		Update("refund_started_at", now)
+	if claim.Error != nil {
+		return nil, fmt.Errorf("failed to claim payment for refund: %w", claim.Error)
+	}
+	if claim.RowsAffected == 0 {
+		return nil, ErrRefundInProgress
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), paymentProviderTimeout)
+	defer cancel()
+	refund, err := provider.Refund(ctx, *payment.ProviderRef, payment.Amount)
+	if err != nil {
+		// Nothing was refunded, so the payment can be refunded again
+		if releaseErr := s.db.Model(&models.Payment{}).Where("id = ?", paymentID).Update("refund_started_at", nil).Error; releaseErr != nil {
+			log.Printf("Failed to release refund claim on payment %s: %v", paymentID, releaseErr)
+		}
+		if errors.Is(err, ErrRefundNotAllowed) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrPaymentProviderFailed, err)
+	}
+
+	transactionData := map[string]interface{}{"refundReference": refund.Reference, "reason": reason}
+	for key, value := range refund.Data {
+		transactionData[key] = value
+	}
+
+	// The provider has refunded the payment, so it is returned with any error recording that
+	refunded, err := s.UpdatePaymentStatus(actor, paymentID, models.PaymentStatusRefunded, transactionData)
+	if err != nil {
+		return payment, fmt.Errorf("refund %s was made but not recorded: %w", refund.Reference, err)
+	}
+
+	return refunded, nil
+}
+
+// createCharge asks the provider to collect a pending payment and records the charge. A
+// payment the provider cannot take is marked failed without a charge reference; staff can
+// retry it by moving it back to pending (see UpdatePaymentStatusManually).
+func (s *PaymentService) createCharge(provider PaymentProvider, payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), paymentProviderTimeout)
+	defer cancel()
+
+	result, err := provider.CreateCharge(ctx, ChargeRequest{
+		PaymentID:   payment.ID,
+		Method:      *payment.PaymentMethod,
+		Amount:      payment.Amount,
+		Description: string(payment.ServiceType),
+	})
+	if err != nil {
+		failure := map[string]interface{}{"provider": provider.Name(), "error": err.Error()}
+		if _, updateErr := s.UpdatePaymentStatus(AuditActor{}, payment.ID, models.PaymentStatusFailed, failure); updateErr != nil {
+			log.Printf("Failed to mark payment %s failed: %v", payment.ID, updateErr)
+		}
+		return fmt.Errorf("%w: %v", ErrPaymentProviderFailed, err)
+	}
+
+	if err := s.db.Model(payment).Update("provider_reference", result.Reference).Error; err != nil {
+		return fmt.Errorf("failed to record payment provider charge: %w", err)
+	}
+	payment.ProviderRef = &result.Reference
+
+	_, err = s.applyChargeResult(payment, result)
+	return err
+}
+
+// applyChargeResult moves a payment to the status its provider reports, if that differs
+func (s *PaymentService) applyChargeResult(payment *models.Payment, result *ChargeResult) (*models.Payment, error) {
+	if result.Status == "" || result.Status == payment.Status {
+		return payment, nil
+	}
+
+	return s.UpdatePaymentStatus(AuditActor{}, payment.ID, result.Status, chargeTransactionData(result))
+}
+
+// chargeTransactionData returns the transaction data recorded when a charge result changes a payment's status
+func chargeTransactionData(result *ChargeResult) map[string]interface{} {
+	transactionData := map[string]interface{}{"providerReference": result.Reference}
+	if result.FailureReason != "" {
+		transactionData["failureReason"] = result.FailureReason
+	}
+	for key, value := range result.Data {
+		transactionData[key] = value
+	}
+	return transactionData
 }
 
 // GetPaymentsByUser retrieves all payments for a specific user
@@ -295,7 +650,10 @@ func (s *PaymentService) isValidStatusTransition(from, to models.PaymentStatus)
 			models.PaymentStatusPending,
 			models.PaymentStatusExpired,
 		},
-		models.PaymentStatusCompleted: {}, // No transitions from completed
+		models.PaymentStatusCompleted: {
+			models.PaymentStatusRefunded,
+		},
+		models.PaymentStatusRefunded: {}, // No transitions from refunded
 		models.PaymentStatusExpired: {
 			models.PaymentStatusPending,
 		},
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// Municipal role errors
+var (
+	ErrMunicipalRoleNotFound = errors.New("role not found")
+	ErrMunicipalRoleExists   = errors.New("role already exists")
+	ErrMunicipalRoleInUse    = errors.New("role is assigned to members")
+	ErrBuiltInMunicipalRole  = errors.New("built-in roles cannot be changed")
+	ErrInvalidMunicipalRole  = errors.New("invalid role key")
+	ErrInvalidPermission     = errors.New("invalid permission")
+)
+
+// PermissionService resolves permissions from global roles and municipality memberships,
+// and manages the roles each municipality defines
+type PermissionService struct {
+	db    *gorm.DB
+	audit *AuditService
+}
+
+// NewPermissionService creates a new permission service
+func NewPermissionService(db *gorm.DB, audit *AuditService) *PermissionService {
+	return &PermissionService{
+		db:    db,
+		audit: audit,
+	}
+}
+
+// HasPermission reports whether the user's global role grants the permission
+func (s *PermissionService) HasPermission(userID, role, permission string) (bool, error) {
+	for _, p := range models.GlobalRolePermissions(models.UserRole(role)) {
+		if string(p) == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasMunicipalityPermission reports whether the user holds the permission in the municipality,
+// either through their global role or through the role of their membership there
+func (s *PermissionService) HasMunicipalityPermission(userID, role, municipalityID, permission string) (bool, error) {
+	if allowed, err := s.HasPermission(userID, role, permission); err != nil || allowed {
+		return allowed, err
+	}
+
+	var membership models.UserMunicipality
+	err := s.db.Select("role").Where("user_id = ? AND municipality_id = ?", userID, municipalityID).First(&membership).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get municipality membership: %w", err)
+	}
+
+	municipalRole, err := s.GetRole(municipalityID, membership.Role)
+	if err != nil {
+		if errors.Is(err, ErrMunicipalRoleNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return municipalRole.Permissions.Has(models.Permission(permission)), nil
+}
+
+// ListRoles returns the built-in roles followed by the municipality's own roles
+func (s *PermissionService) ListRoles(municipalityID string) ([]models.MunicipalRole, error) {
+	var custom []models.MunicipalRole
+	if err := s.db.Where("municipality_id = ?", municipalityID).Order("key ASC").Find(&custom).Error; err != nil {
+		return nil, fmt.Errorf("failed to list municipal roles: %w", err)
+	}
+
+	roles := make([]models.MunicipalRole, 0, len(models.BuiltInMunicipalRoles)+len(custom))
+	for _, builtIn := range models.BuiltInMunicipalRoles {
+		builtIn.BuiltIn = true
+		roles = append(roles, builtIn)
+	}
+	return append(roles, custom...), nil
+}
+
+// GetRole returns the built-in or municipality-defined role with the given key
+func (s *PermissionService) GetRole(municipalityID, key string) (*models.MunicipalRole, error) {
+	if builtIn, ok := models.BuiltInMunicipalRole(key); ok {
+		return builtIn, nil
+	}
+
+	var role models.MunicipalRole
+	if err := s.db.Where("municipality_id = ? AND key = ?", municipalityID, key).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMunicipalRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get municipal role: %w", err)
+	}
+
+	return &role, nil
+}
+
+// CreateRole defines a new role in the municipality
+func (s *PermissionService) CreateRole(actor AuditActor, municipalityID string, role *models.MunicipalRole) error {
+	if !models.IsValidMunicipalRoleKey(role.Key) {
+		return ErrInvalidMunicipalRole
+	}
+	if _, ok := models.BuiltInMunicipalRole(role.Key); ok {
+		return ErrMunicipalRoleExists
+	}
+	permissions, err := normalizePermissions(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.MunicipalRole{}).Where("municipality_id = ? AND key = ?", municipalityID, role.Key).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check municipal role: %w", err)
+	}
+	if count > 0 {
+		return ErrMunicipalRoleExists
+	}
+
+	role.MunicipalityID = municipalityID
+	role.Permissions = permissions
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(role).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create municipal role: %w", err)
+	}
+
+	if err := s.audit.RecordTx(tx, actor, roleEvent(models.AuditActionMunicipalRoleCreated, role, nil, role)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit municipal role creation: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateRole changes the name, description and permissions of a municipality-defined role
+func (s *PermissionService) UpdateRole(actor AuditActor, municipalityID, key, name, description string, permissions models.PermissionSet) (*models.MunicipalRole, error) {
+	if _, ok := models.BuiltInMunicipalRole(key); ok {
+		return nil, ErrBuiltInMunicipalRole
+	}
+	permissions, err := normalizePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.GetRole(municipalityID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *role
+	role.Name = name
+	role.Description = description
+	role.Permissions = permissions
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(role).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update municipal role: %w", err)
+	}
+
+	if err := s.audit.RecordTx(tx, actor, roleEvent(models.AuditActionMunicipalRoleUpdated, role, &before, role)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit municipal role update: %w", err)
+	}
+
+	return role, nil
+}
+
+// DeleteRole removes a municipality-defined role that no member holds
+func (s *PermissionService) DeleteRole(actor AuditActor, municipalityID, key string) error {
+	if _, ok := models.BuiltInMunicipalRole(key); ok {
+		return ErrBuiltInMunicipalRole
+	}
+
+	role, err := s.GetRole(municipalityID, key)
+	if err != nil {
+		return err
+	}
+
+	var members int64
+	if err := s.db.Model(&models.UserMunicipality{}).Where("municipality_id = ? AND role = ?", municipalityID, key).Count(&members).Error; err != nil {
+		return fmt.Errorf("failed to count role members: %w", err)
+	}
+	if members > 0 {
+		return ErrMunicipalRoleInUse
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Delete(role).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete municipal role: %w", err)
+	}
+
+	if err := s.audit.RecordTx(tx, actor, roleEvent(models.AuditActionMunicipalRoleDeleted, role, role, nil)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit municipal role deletion: %w", err)
+	}
+
+	return nil
+}
+
+// roleEvent describes a change to a municipality-defined role for the audit log
+func roleEvent(action models.AuditAction, role *models.MunicipalRole, before, after interface{}) AuditEvent {
+	return AuditEvent{
+		Action:       action,
+		ResourceType: models.AuditResourceMunicipalRole,
+		ResourceID:   role.ID,
+		Details:      models.AuditDetails{"municipalityId": role.MunicipalityID, "key": role.Key},
+		Before:       before,
+		After:        after,
+	}
+}
+
+// normalizePermissions checks that every permission can be granted within a municipality
+// and removes duplicates
+func normalizePermissions(permissions models.PermissionSet) (models.PermissionSet, error) {
+	normalized := make(models.PermissionSet, 0, len(permissions))
+	seen := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		p = strings.TrimSpace(p)
+		if !models.IsMunicipalPermission(p) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPermission, p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			normalized = append(normalized, p)
+		}
+	}
+	return normalized, nil
+}
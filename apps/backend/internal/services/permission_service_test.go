@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"municollect/internal/models"
+)
+
+func TestPermissionService_HasPermission(t *testing.T) {
+	service := NewPermissionService(nil, nil)
+
+	tests := []struct {
+		role       models.UserRole
+		permission models.Permission
+		expected   bool
+	}{
+		{models.UserRoleAdmin, models.PermissionMunicipalitiesManage, true},
+		{models.UserRoleAdmin, models.PermissionPaymentsUpdateStatus, true},
+		{models.UserRoleMunicipalStaff, models.PermissionPaymentsRead, false},
+		{models.UserRoleMunicipalStaff, models.PermissionUsersManage, false},
+		{models.UserRoleResident, models.PermissionPaymentsRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role)+" "+string(tt.permission), func(t *testing.T) {
+			allowed, err := service.HasPermission("user-1", string(tt.role), string(tt.permission))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, allowed)
+		})
+	}
+}
+
+func TestBuiltInMunicipalRoles(t *testing.T) {
+	finance, ok := models.BuiltInMunicipalRole(models.MunicipalRoleFinance)
+	require.True(t, ok)
+	assert.True(t, finance.BuiltIn)
+	assert.True(t, finance.Permissions.Has(models.PermissionPaymentsUpdateStatus))
+	assert.False(t, finance.Permissions.Has(models.PermissionRoutesManage))
+
+	collector, ok := models.BuiltInMunicipalRole(models.MunicipalRoleCollector)
+	require.True(t, ok)
+	assert.False(t, collector.Permissions.Has(models.PermissionPaymentsRead))
+
+	admin, ok := models.BuiltInMunicipalRole(models.MunicipalRoleAdmin)
+	require.True(t, ok)
+	for _, p := range models.MunicipalPermissions {
+		assert.True(t, admin.Permissions.Has(p), p)
+	}
+	assert.False(t, admin.Permissions.Has(models.PermissionMunicipalitiesManage))
+
+	// Built-in roles may only grant municipal permissions
+	for _, role := range models.BuiltInMunicipalRoles {
+		for _, p := range role.Permissions {
+			assert.True(t, models.IsMunicipalPermission(p), "%s grants %s", role.Key, p)
+		}
+	}
+
+	_, ok = models.BuiltInMunicipalRole("cashier")
+	assert.False(t, ok)
+}
+
+func TestNormalizePermissions(t *testing.T) {
+	permissions, err := normalizePermissions(models.PermissionSet{"payments.read", " payments.read", "reports.export"})
+	require.NoError(t, err)
+	assert.Equal(t, models.PermissionSet{"payments.read", "reports.export"}, permissions)
+
+	_, err = normalizePermissions(models.PermissionSet{"payments.read", "users.manage"})
+	assert.ErrorIs(t, err, ErrInvalidPermission)
+
+	_, err = normalizePermissions(models.PermissionSet{"payments.delete"})
+	assert.ErrorIs(t, err, ErrInvalidPermission)
+}
+
+func TestIsValidMunicipalRoleKey(t *testing.T) {
+	assert.True(t, models.IsValidMunicipalRoleKey("cashier"))
+	assert.True(t, models.IsValidMunicipalRoleKey("night_shift_2"))
+	assert.False(t, models.IsValidMunicipalRoleKey("Cashier"))
+	assert.False(t, models.IsValidMunicipalRoleKey("c"))
+	assert.False(t, models.IsValidMunicipalRoleKey("2nd_shift"))
+	assert.False(t, models.IsValidMunicipalRoleKey("payments.read"))
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// AccessTokenLifetime is how long an issued access token stays valid
+const AccessTokenLifetime = 24 * time.Hour
+
+// RefreshTokenLifetime is how long an issued refresh token stays valid
+const RefreshTokenLifetime = 7 * 24 * time.Hour
+
+// Refresh token errors
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenRevoked  = errors.New("refresh token has been revoked")
+	ErrRefreshTokenExpired  = errors.New("refresh token has expired")
+	ErrRefreshTokenReused   = errors.New("refresh token reuse detected")
+)
+
+// RefreshTokenService handles the server-side refresh token store
+type RefreshTokenService struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenService creates a new refresh token service
+func NewRefreshTokenService(db *gorm.DB) *RefreshTokenService {
+	return &RefreshTokenService{
+		db: db,
+	}
+}
+
+// HashRefreshToken returns the hex-encoded SHA-256 hash under which a refresh token is stored
+func HashRefreshToken(token string) string {
+	return hashToken(token)
+}
+
+// Store records a newly issued refresh token. An empty familyID starts a new token family.
+func (s *RefreshTokenService) Store(userID, familyID, token string, expiresAt time.Time) (*models.RefreshToken, error) {
+	if familyID == "" {
+		familyID = uuid.NewString()
+	}
+
+	record := &models.RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashRefreshToken(token),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return record, nil
+}
+
+// Rotate consumes a presented refresh token so that it cannot be used again and returns
+// its record. The caller issues the replacement token in the same family. Presenting a
+// token that was already rotated revokes the whole family.
+func (s *RefreshTokenService) Rotate(token string) (*models.RefreshToken, error) {
+	record, err := s.findByToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.IsRevoked() {
+		if record.RevokedReason != nil && *record.RevokedReason == models.RefreshTokenRevokedRotated {
+			return nil, s.handleReuse(record)
+		}
+		return nil, ErrRefreshTokenRevoked
+	}
+
+	if record.IsExpired() {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	// Mark the token as rotated; the revoked_at guard makes concurrent rotations of the same token detectable
+	reason := models.RefreshTokenRevokedRotated
+	result := s.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", record.ID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, s.handleReuse(record)
+	}
+
+	return record, nil
+}
+
+// Revoke revokes the family of the given refresh token, ending that login
+func (s *RefreshTokenService) Revoke(token string) error {
+	record, err := s.findByToken(token)
+	if err != nil {
+		return err
+	}
+
+	return s.RevokeFamily(record.FamilyID, models.RefreshTokenRevokedLogout)
+}
+
+// RevokeFamily revokes every active token in a family and ends the session it belongs to
+func (s *RefreshTokenService) RevokeFamily(familyID string, reason models.RefreshTokenRevocationReason) error {
+	revocation := map[string]interface{}{
+		"revoked_at":     time.Now(),
+		"revoked_reason": reason,
+	}
+
+	result := s.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Updates(revocation)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", result.Error)
+	}
+
+	result = s.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", familyID).
+		Updates(revocation)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every active refresh token belonging to a user and ends their sessions
+func (s *RefreshTokenService) RevokeAllForUser(userID string, reason models.RefreshTokenRevocationReason) error {
+	revocation := map[string]interface{}{
+		"revoked_at":     time.Now(),
+		"revoked_reason": reason,
+	}
+
+	result := s.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(revocation)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke user refresh tokens: %w", result.Error)
+	}
+
+	result = s.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(revocation)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", result.Error)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes refresh tokens that have passed their expiry time
+func (s *RefreshTokenService) DeleteExpired() error {
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{}).Error; err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// findByToken looks up a refresh token record by the raw token
+func (s *RefreshTokenService) findByToken(token string) (*models.RefreshToken, error) {
+	var record models.RefreshToken
+	if err := s.db.Where("token_hash = ?", HashRefreshToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return &record, nil
+}
+
+// handleReuse revokes the family of a token that was presented after being rotated
+func (s *RefreshTokenService) handleReuse(record *models.RefreshToken) error {
+	if err := s.RevokeFamily(record.FamilyID, models.RefreshTokenRevokedReuseDetected); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
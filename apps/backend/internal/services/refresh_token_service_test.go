@@ -0,0 +1,164 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"municollect/internal/models"
+)
+
+// newTestRefreshTokenService creates a refresh token service on an in-memory SQLite database
+// holding a session for the returned family
+func newTestRefreshTokenService(t *testing.T) (*RefreshTokenService, *gorm.DB, string) {
+	db, err := gorm.Open(sqlite.Open("file:"+uuid.NewString()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	// The models' Postgres defaults do not migrate to SQLite, so the tables are created by hand
+	require.NoError(t, db.Exec(`CREATE TABLE refresh_tokens (
+		id TEXT PRIMARY KEY, user_id TEXT NOT NULL, family_id TEXT NOT NULL, token_hash TEXT NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL, revoked_at DATETIME, revoked_reason TEXT, created_at DATETIME)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE sessions (
+		id TEXT PRIMARY KEY, user_id TEXT NOT NULL, device TEXT, user_agent TEXT, ip_address TEXT,
+		last_seen_at DATETIME NOT NULL, expires_at DATETIME NOT NULL, revoked_at DATETIME, revoked_reason TEXT,
+		created_at DATETIME, updated_at DATETIME)`).Error)
+
+	// SQLite allows one writer at a time
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	familyID := uuid.NewString()
+	require.NoError(t, db.Create(&models.Session{
+		ID:         familyID,
+		UserID:     "user-1",
+		LastSeenAt: time.Now(),
+		ExpiresAt:  time.Now().Add(RefreshTokenLifetime),
+	}).Error)
+
+	return NewRefreshTokenService(db), db, familyID
+}
+
+func TestRefreshTokenService_Rotate(t *testing.T) {
+	s, _, familyID := newTestRefreshTokenService(t)
+
+	_, err := s.Store("user-1", familyID, "token-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	record, err := s.Rotate("token-1")
+	require.NoError(t, err)
+	assert.Equal(t, familyID, record.FamilyID)
+
+	// The replacement continues the family
+	_, err = s.Store("user-1", record.FamilyID, "token-2", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	next, err := s.Rotate("token-2")
+	require.NoError(t, err)
+	assert.Equal(t, familyID, next.FamilyID)
+
+	_, err = s.Rotate("unknown")
+	assert.ErrorIs(t, err, ErrRefreshTokenNotFound)
+
+	_, err = s.Store("user-1", familyID, "expired", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = s.Rotate("expired")
+	assert.ErrorIs(t, err, ErrRefreshTokenExpired)
+}
+
+func TestRefreshTokenService_ReuseRevokesFamily(t *testing.T) {
+	s, db, familyID := newTestRefreshTokenService(t)
+
+	_, err := s.Store("user-1", familyID, "token-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = s.Rotate("token-1")
+	require.NoError(t, err)
+	_, err = s.Store("user-1", familyID, "token-2", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// Presenting the rotated token again revokes the whole family and ends the session
+	_, err = s.Rotate("token-1")
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	_, err = s.Rotate("token-2")
+	assert.ErrorIs(t, err, ErrRefreshTokenRevoked)
+
+	var session models.Session
+	require.NoError(t, db.First(&session, "id = ?", familyID).Error)
+	require.NotNil(t, session.RevokedReason)
+	assert.Equal(t, models.RefreshTokenRevokedReuseDetected, *session.RevokedReason)
+}
+
+func TestRefreshTokenService_ConcurrentRotation(t *testing.T) {
+	s, _, familyID := newTestRefreshTokenService(t)
+
+	_, err := s.Store("user-1", familyID, "token-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// Only one of several concurrent rotations of a token succeeds; the others count as reuse
+	const attempts = 5
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.Rotate("token-1")
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestRefreshTokenService_RevokeFamily(t *testing.T) {
+	s, db, familyID := newTestRefreshTokenService(t)
+
+	_, err := s.Store("user-1", familyID, "token-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = s.Store("user-1", "", "other-login", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Revoke("token-1"))
+
+	_, err = s.Rotate("token-1")
+	assert.ErrorIs(t, err, ErrRefreshTokenRevoked)
+
+	var session models.Session
+	require.NoError(t, db.First(&session, "id = ?", familyID).Error)
+	assert.False(t, session.IsActive())
+
+	// Other families are unaffected
+	_, err = s.Rotate("other-login")
+	assert.NoError(t, err)
+}
+
+func TestRefreshTokenService_DeleteExpired(t *testing.T) {
+	s, db, familyID := newTestRefreshTokenService(t)
+
+	_, err := s.Store("user-1", familyID, "expired", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	_, err = s.Store("user-1", familyID, "current", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteExpired())
+
+	var count int64
+	require.NoError(t, db.Model(&models.RefreshToken{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// sessionActivityInterval limits how often request activity is written to a session
+const sessionActivityInterval = time.Minute
+
+// ErrSessionNotFound is returned when a session does not exist or belongs to another user
+var ErrSessionNotFound = errors.New("session not found")
+
+// ClientInfo describes the device a request came from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// SessionService keeps the server-side record of each login
+type SessionService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// Start records a new login for the user. The session ID doubles as the refresh token family ID.
+func (s *SessionService) Start(userID string, client ClientInfo) (*models.Session, error) {
+	now := s.now()
+	session := &models.Session{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		Device:     DescribeDevice(client.UserAgent),
+		UserAgent:  truncate(client.UserAgent, 512),
+		IPAddress:  client.IPAddress,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(RefreshTokenLifetime),
+	}
+
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session, nil
+}
+
+// Refresh records a token refresh, extending the session to the lifetime of the new refresh token
+func (s *SessionService) Refresh(sessionID string, client ClientInfo) error {
+	now := s.now()
+	err := s.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"device":       DescribeDevice(client.UserAgent),
+			"user_agent":   truncate(client.UserAgent, 512),
+			"ip_address":   client.IPAddress,
+			"last_seen_at": now,
+			"expires_at":   now.Add(RefreshTokenLifetime),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to refresh session: %w", err)
+	}
+
+	return nil
+}
+
+// TouchSession records a request made with one of the session's access tokens.
+// Writes are throttled so busy clients do not update the row on every request.
+func (s *SessionService) TouchSession(sessionID, ipAddress string) error {
+	now := s.now()
+	err := s.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND last_seen_at < ?", sessionID, now.Add(-sessionActivityInterval)).
+		Updates(map[string]interface{}{
+			"ip_address":   ipAddress,
+			"last_seen_at": now,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record session activity: %w", err)
+	}
+
+	return nil
+}
+
+// ListActive returns the user's sessions that have not been revoked or expired, most recently seen first
+func (s *SessionService) ListActive(userID string) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, s.now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// GetForUser returns one of the user's sessions
+func (s *SessionService) GetForUser(userID, sessionID string) (*models.Session, error) {
+	var session models.Session
+	if err := s.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// DescribeDevice derives a short device description such as "Chrome on Windows" from a User-Agent
+func DescribeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	var browser string
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "SamsungBrowser/"):
+		browser = "Samsung Internet"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	case strings.HasPrefix(userAgent, "okhttp/"), strings.HasPrefix(userAgent, "Dart/"), strings.HasPrefix(userAgent, "CFNetwork"):
+		browser = "Mobile app"
+	default:
+		// Fall back to the product token, e.g. "curl" from "curl/8.4.0"
+		browser, _, _ = strings.Cut(userAgent, "/")
+		browser, _, _ = strings.Cut(browser, " ")
+	}
+
+	var os string
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iOS"):
+		os = "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(userAgent, "CrOS"):
+		os = "ChromeOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+
+	description := browser
+	if os != "" {
+		description += " on " + os
+	}
+	return truncate(description, 100)
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// twilioAPIURL is the base URL of the Twilio REST API
+const twilioAPIURL = "https://api.twilio.com/2010-04-01"
+
+// SMSMessage represents an outgoing text message
+type SMSMessage struct {
+	To   string
+	Body string
+}
+
+// SMSSender delivers outgoing text messages. Implementations can be swapped per environment.
+type SMSSender interface {
+	Send(msg SMSMessage) error
+}
+
+// LogSMSSender writes outgoing text messages to the application log instead of sending them.
+// The log then holds verification codes, so it is refused in production.
+type LogSMSSender struct{}
+
+// NewLogSMSSender creates a new log SMS sender
+func NewLogSMSSender() *LogSMSSender {
+	return &LogSMSSender{}
+}
+
+// Send logs the message
+func (s *LogSMSSender) Send(msg SMSMessage) error {
+	log.Printf("SMS: to=%s body=%q", msg.To, msg.Body)
+	return nil
+}
+
+// FileSMSSender writes each outgoing text message to its own file in a directory.
+// It is intended for development and tests, where the files act as an outbox.
+type FileSMSSender struct {
+	dir string
+}
+
+// NewFileSMSSender creates a new file SMS sender writing into dir
+func NewFileSMSSender(dir string) *FileSMSSender {
+	return &FileSMSSender{
+		dir: dir,
+	}
+}
+
+// Send writes the message to a new .txt file in the outbox directory
+func (s *FileSMSSender) Send(msg SMSMessage) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create SMS outbox: %w", err)
+	}
+
+	suffix, err := generateSecureToken(6)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("%d-%s.txt", time.Now().UnixNano(), suffix)
+	content := fmt.Sprintf("To: %s\nDate: %s\n\n%s\n", msg.To, time.Now().UTC().Format(time.RFC1123Z), msg.Body)
+
+	if err := os.WriteFile(filepath.Join(s.dir, filename), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write text message: %w", err)
+	}
+
+	return nil
+}
+
+// TwilioConfig holds the credentials and sender number used to send text messages through Twilio
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	// From is the Twilio phone number, or messaging service SID (MG...), messages are sent from
+	From string
+	// BaseURL overrides the Twilio API URL; it is only set in tests
+	BaseURL string
+}
+
+// TwilioSMSSender delivers outgoing text messages through the Twilio Messages API
+type TwilioSMSSender struct {
+	config TwilioConfig
+	client *http.Client
+}
+
+// NewTwilioSMSSender creates a new Twilio SMS sender
+func NewTwilioSMSSender(config TwilioConfig) *TwilioSMSSender {
+	if config.BaseURL == "" {
+		config.BaseURL = twilioAPIURL
+	}
+
+	return &TwilioSMSSender{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Send asks Twilio to deliver the message
+func (s *TwilioSMSSender) Send(msg SMSMessage) error {
+	form := url.Values{
+		"To":   {msg.To},
+		"Body": {msg.Body},
+	}
+	if strings.HasPrefix(s.config.From, "MG") {
+		form.Set("MessagingServiceSid", s.config.From)
+	} else {
+		form.Set("From", s.config.From)
+	}
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", s.config.BaseURL, url.PathEscape(s.config.AccountSID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create text message request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.config.AccountSID, s.config.AuthToken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send text message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiError struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &apiError) == nil && apiError.Message != "" {
+			return fmt.Errorf("twilio refused text message (%d, code %d): %s", resp.StatusCode, apiError.Code, apiError.Message)
+		}
+		return fmt.Errorf("twilio refused text message (%d)", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// NewSMSSenderFromEnv returns the SMS sender selected by SMS_SENDER ("log", "file" or "twilio").
+// The Twilio sender uses TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN and sends from TWILIO_FROM.
+func NewSMSSenderFromEnv() (SMSSender, error) {
+	switch os.Getenv("SMS_SENDER") {
+	case "", "log":
+		return NewLogSMSSender(), nil
+	case "file":
+		dir := os.Getenv("SMS_OUTBOX_DIR")
+		if dir == "" {
+			dir = "sms-outbox"
+		}
+		return NewFileSMSSender(dir), nil
+	case "twilio":
+		config := TwilioConfig{
+			AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:       os.Getenv("TWILIO_FROM"),
+		}
+		if config.AccountSID == "" || config.AuthToken == "" || config.From == "" {
+			return nil, errors.New("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM are required when SMS_SENDER is twilio")
+		}
+		return NewTwilioSMSSender(config), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS sender %q", os.Getenv("SMS_SENDER"))
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSMSSender_Send(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewFileSMSSender(dir)
+
+	require.NoError(t, sender.Send(SMSMessage{To: "+66812345678", Body: "Your MuniCollect code is 123456"}))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	content, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: +66812345678\n")
+	assert.Contains(t, string(content), "Your MuniCollect code is 123456")
+}
+
+func TestTwilioSMSSender_Send(t *testing.T) {
+	var form url.Values
+	var username, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/Accounts/AC123/Messages.json", r.URL.Path)
+		username, password, _ = r.BasicAuth()
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+
+		if form.Get("To") == "+10000000000" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 21211, "message": "Invalid 'To' Phone Number"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "SM123", "status": "queued"}`))
+	}))
+	defer server.Close()
+
+	sender := NewTwilioSMSSender(TwilioConfig{AccountSID: "AC123", AuthToken: "token", From: "+15005550006", BaseURL: server.URL})
+
+	require.NoError(t, sender.Send(SMSMessage{To: "+66812345678", Body: "Your MuniCollect code is 123456"}))
+	assert.Equal(t, "AC123", username)
+	assert.Equal(t, "token", password)
+	assert.Equal(t, "+66812345678", form.Get("To"))
+	assert.Equal(t, "+15005550006", form.Get("From"))
+	assert.Equal(t, "Your MuniCollect code is 123456", form.Get("Body"))
+
+	err := sender.Send(SMSMessage{To: "+10000000000", Body: "hello"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid 'To' Phone Number")
+
+	// Messaging service SIDs are sent as such instead of a sender number
+	sender = NewTwilioSMSSender(TwilioConfig{AccountSID: "AC123", AuthToken: "token", From: "MG123", BaseURL: server.URL})
+	require.NoError(t, sender.Send(SMSMessage{To: "+66812345678", Body: "hello"}))
+	assert.Equal(t, "MG123", form.Get("MessagingServiceSid"))
+	assert.Empty(t, form.Get("From"))
+}
+
+func TestNewSMSSenderFromEnv(t *testing.T) {
+	t.Setenv("SMS_SENDER", "")
+	sender, err := NewSMSSenderFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &LogSMSSender{}, sender)
+
+	t.Setenv("SMS_SENDER", "file")
+	sender, err = NewSMSSenderFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &FileSMSSender{}, sender)
+
+	t.Setenv("SMS_SENDER", "twilio")
+	t.Setenv("TWILIO_ACCOUNT_SID", "AC123")
+	t.Setenv("TWILIO_AUTH_TOKEN", "token")
+	t.Setenv("TWILIO_FROM", "")
+	_, err = NewSMSSenderFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("TWILIO_FROM", "+15005550006")
+	sender, err = NewSMSSenderFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &TwilioSMSSender{}, sender)
+
+	t.Setenv("SMS_SENDER", "carrier-pigeon")
+	_, err = NewSMSSenderFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/middleware"
+	"municollect/internal/models"
+)
+
+// TokenRevoker is the write side of the access token revocation store
+type TokenRevoker interface {
+	RevokeToken(jti string, expiresAt time.Time) error
+	RevokeSession(sessionID string, expiresAt time.Time) error
+	SetTokensValidAfter(userID string, validAfter time.Time) error
+}
+
+// DBRevocationStore is a database-backed access token revocation store.
+// Unlike the in-memory store it is shared by every backend replica.
+type DBRevocationStore struct {
+	db *gorm.DB
+}
+
+// NewDBRevocationStore creates a new database-backed revocation store
+func NewDBRevocationStore(db *gorm.DB) *DBRevocationStore {
+	return &DBRevocationStore{
+		db: db,
+	}
+}
+
+// RevokeToken revokes a single token until it would have expired anyway
+func (s *DBRevocationStore) RevokeToken(jti string, expiresAt time.Time) error {
+	record := &models.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record).Error; err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+// IsTokenRevoked reports whether the token with the given jti has been revoked
+func (s *DBRevocationStore) IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// RevokeSession revokes every token carrying the session ID. The session row is the
+// record of revocation, so the expiry is not needed here.
+func (s *DBRevocationStore) RevokeSession(sessionID string, expiresAt time.Time) error {
+	err := s.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// IsSessionRevoked reports whether the session with the given ID has been revoked
+func (s *DBRevocationStore) IsSessionRevoked(sessionID string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NOT NULL", sessionID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked session: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// SetTokensValidAfter invalidates every token issued to the user before validAfter
+func (s *DBRevocationStore) SetTokensValidAfter(userID string, validAfter time.Time) error {
+	cutoff := &models.UserTokenCutoff{
+		UserID:     userID,
+		ValidAfter: validAfter,
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"valid_after", "updated_at"}),
+	}).Create(cutoff).Error
+	if err != nil {
+		return fmt.Errorf("failed to set token cut-off: %w", err)
+	}
+
+	return nil
+}
+
+// GetTokensValidAfter returns the user's cut-off time, or the zero time if none is set
+func (s *DBRevocationStore) GetTokensValidAfter(userID string) (time.Time, error) {
+	var cutoff models.UserTokenCutoff
+	if err := s.db.Where("user_id = ?", userID).First(&cutoff).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get token cut-off: %w", err)
+	}
+
+	return cutoff.ValidAfter, nil
+}
+
+// DeleteExpired removes revoked token entries for tokens that have expired on their own
+func (s *DBRevocationStore) DeleteExpired() error {
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+
+	return nil
+}
+
+// TokenRevocationService revokes access and refresh tokens in response to
+// logouts, role changes, password changes and admin "sign out everywhere" actions
+type TokenRevocationService struct {
+	store         TokenRevoker
+	refreshTokens *RefreshTokenService
+}
+
+// NewTokenRevocationService creates a new token revocation service
+func NewTokenRevocationService(db *gorm.DB, store TokenRevoker) *TokenRevocationService {
+	return &TokenRevocationService{
+		store:         store,
+		refreshTokens: NewRefreshTokenService(db),
+	}
+}
+
+// RevokeAccessToken revokes a single access token by its jti
+func (s *TokenRevocationService) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	return s.store.RevokeToken(jti, expiresAt)
+}
+
+// RevokeSession ends one of the user's sessions: its refresh tokens can no longer be
+// used and its access tokens are rejected from now on
+func (s *TokenRevocationService) RevokeSession(sessionID string, reason models.RefreshTokenRevocationReason) error {
+	if err := s.refreshTokens.RevokeFamily(sessionID, reason); err != nil {
+		return err
+	}
+
+	return s.store.RevokeSession(sessionID, time.Now().Add(AccessTokenLifetime))
+}
+
+// InvalidateAccessTokens rejects every access token issued to the user so far.
+// Refresh tokens stay valid, so the user's next refresh picks up their current role.
+func (s *TokenRevocationService) InvalidateAccessTokens(userID string) error {
+	cutoff := middleware.NextTokenCutoff()
+	if err := s.store.SetTokensValidAfter(userID, cutoff); err != nil {
+		return err
+	}
+
+	// Wait out the rounding, so tokens issued to the user from now on are accepted
+	time.Sleep(time.Until(cutoff))
+	return nil
+}
+
+// SignOutEverywhere invalidates every access token and revokes every refresh token of the user
+func (s *TokenRevocationService) SignOutEverywhere(userID string, reason models.RefreshTokenRevocationReason) error {
+	if err := s.InvalidateAccessTokens(userID); err != nil {
+		return err
+	}
+
+	return s.refreshTokens.RevokeAllForUser(userID, reason)
+}
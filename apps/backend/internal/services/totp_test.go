@@ -0,0 +1,86 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"municollect/internal/models"
+)
+
+// RFC 6238 appendix B test secret ("12345678901234567890") in base32
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := TOTPCode(rfcTOTPSecret, TOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := ValidateTOTP(rfcTOTPSecret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now), step)
+
+	// One step of clock drift is tolerated
+	_, ok = ValidateTOTP(rfcTOTPSecret, "081804", now.Add(TOTPPeriod))
+	assert.True(t, ok)
+
+	// Codes further away are rejected
+	_, ok = ValidateTOTP(rfcTOTPSecret, "081804", now.Add(3*TOTPPeriod))
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(rfcTOTPSecret, "000000", now)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(rfcTOTPSecret, "81804", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	uri := TOTPProvisioningURI(secret, "admin@example.com", "MuniCollect")
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/MuniCollect:admin@example.com?"))
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, secret, parsed.Query().Get("secret"))
+	assert.Equal(t, "MuniCollect", parsed.Query().Get("issuer"))
+}
+
+func TestParseMFARequiredRoles(t *testing.T) {
+	defaults := parseMFARequiredRoles("")
+	assert.True(t, defaults[models.UserRoleAdmin])
+	assert.True(t, defaults[models.UserRoleMunicipalStaff])
+	assert.False(t, defaults[models.UserRoleResident])
+
+	assert.Empty(t, parseMFARequiredRoles("none"))
+
+	adminOnly := parseMFARequiredRoles(" admin , ")
+	assert.True(t, adminOnly[models.UserRoleAdmin])
+	assert.False(t, adminOnly[models.UserRoleMunicipalStaff])
+}
+
+func TestRecoveryCodeFormat(t *testing.T) {
+	code, err := generateRecoveryCode()
+	require.NoError(t, err)
+	assert.Len(t, code, 11)
+	assert.Equal(t, "-", code[5:6])
+	assert.Equal(t, normalizeRecoveryCode(code), normalizeRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+}
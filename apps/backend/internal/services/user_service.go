@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 	"municollect/internal/models"
@@ -32,10 +33,70 @@ func (s *UserService) GetUserByID(userID string) (*models.User, error) {
 	return &user, nil
 }
 
+// UserFilter represents filters for listing users
+type UserFilter struct {
+	Query    *string
+	Role     *models.UserRole
+	Disabled *bool
+}
+
+// ListUsers retrieves users with filtering and pagination. Query matches the
+// start of a user's first name, last name or full name, or their whole email address
+// or phone number, which are encrypted and can only be matched by blind index.
+func (s *UserService) ListUsers(filter *UserFilter, limit, offset int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+	
+	query := s.db.Model(&models.User{})
+	
+	// Apply filters
+	if filter.Query != nil && strings.TrimSpace(*filter.Query) != "" {
+		term := escapeLike(strings.ToLower(strings.TrimSpace(*filter.Query))) + "%"
+		query = query.Where(
+			"LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ? OR LOWER(first_name || ' ' || last_name) LIKE ? OR email_index = ? OR phone_index = ?",
+			term, term, term, models.EmailIndex(*filter.Query), models.PhoneIndex(*filter.Query),
+		)
+	}
+	if filter.Role != nil {
+		query = query.Where("role = ?", *filter.Role)
+	}
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			query = query.Where("disabled_at IS NOT NULL")
+		} else {
+			query = query.Where("disabled_at IS NULL")
+		}
+	}
+	
+	// Get total count
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	
+	// Get users with pagination
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+	
+	if err := query.Order("created_at DESC").Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	
+	return users, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // GetUserByEmail retrieves a user by their email
 func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := s.db.Where("email_index = ?", models.EmailIndex(email)).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("user not found")
 		}
@@ -56,6 +117,14 @@ func (s *UserService) UpdateUserProfile(userID string, updates models.UserDetail
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	
+	// A changed email address or phone number has to be verified again
+	if user.Email != updates.Email {
+		user.EmailVerifiedAt = nil
+	}
+	if user.Phone == nil || updates.Phone == nil || *user.Phone != *updates.Phone {
+		user.PhoneVerifiedAt = nil
+	}
+	
 	// Update fields
 	user.FirstName = updates.FirstName
 	user.LastName = updates.LastName
@@ -150,4 +219,25 @@ func (s *UserService) RemoveUserMunicipalityAssociation(userID, municipalityID s
 	}
 	
 	return nil
+}
+
+// UpdateUserRole changes a user's global role
+func (s *UserService) UpdateUserRole(userID string, role models.UserRole) (*models.User, error) {
+	if err := models.ValidateUserRole(role); err != nil {
+		return nil, err
+	}
+	
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	
+	if err := s.db.Model(&user).Update("role", role).Error; err != nil {
+		return nil, fmt.Errorf("failed to update user role: %w", err)
+	}
+	
+	return &user, nil
 }
\ No newline at end of file
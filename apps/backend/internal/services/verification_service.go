@@ -0,0 +1,226 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+	"municollect/internal/models"
+)
+
+// Verification code settings
+const (
+	VerificationCodeLifetime    = 15 * time.Minute
+	VerificationCodeResendDelay = time.Minute
+	VerificationCodeMaxAttempts = 5
+	verificationCodeDigits      = 6
+)
+
+// Verification errors
+var (
+	ErrVerificationCodeInvalid    = errors.New("verification code is invalid or has expired")
+	ErrVerificationCodeCooldown   = errors.New("a verification code was sent recently, please wait before requesting another")
+	ErrVerificationNoPhone        = errors.New("user has no phone number")
+	ErrVerificationAlreadyDone    = errors.New("already verified")
+	ErrUserNotVerified            = errors.New("user has not completed the verification required by this municipality")
+	ErrInvalidVerificationChannel = errors.New("invalid verification channel")
+)
+
+// VerificationService issues and confirms email and phone verification codes
+type VerificationService struct {
+	db     *gorm.DB
+	mailer MailSender
+	sms    SMSSender
+}
+
+// NewVerificationService creates a new verification service
+func NewVerificationService(db *gorm.DB, mailer MailSender, sms SMSSender) *VerificationService {
+	return &VerificationService{
+		db:     db,
+		mailer: mailer,
+		sms:    sms,
+	}
+}
+
+// SendCode issues a new verification code for the user's current email address or
+// phone number and delivers it over that channel. Earlier codes stop working.
+func (s *VerificationService) SendCode(userID string, channel models.VerificationChannel) error {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	target, err := verificationTarget(&user, channel)
+	if err != nil {
+		return err
+	}
+
+	if (channel == models.VerificationChannelEmail && user.IsEmailVerified()) ||
+		(channel == models.VerificationChannelPhone && user.IsPhoneVerified()) {
+		return ErrVerificationAlreadyDone
+	}
+
+	// Throttle resends so the endpoint cannot be used to flood an inbox or phone
+	var recent int64
+	if err := s.db.Model(&models.VerificationCode{}).
+		Where("user_id = ? AND channel = ? AND created_at > ?", userID, channel, time.Now().Add(-VerificationCodeResendDelay)).
+		Count(&recent).Error; err != nil {
+		return fmt.Errorf("failed to check recent verification codes: %w", err)
+	}
+	if recent > 0 {
+		return ErrVerificationCodeCooldown
+	}
+
+	code, err := generateNumericCode(verificationCodeDigits)
+	if err != nil {
+		return err
+	}
+
+	// Only the most recent code should work
+	if err := s.db.Model(&models.VerificationCode{}).
+		Where("user_id = ? AND channel = ? AND consumed_at IS NULL", userID, channel).
+		Update("consumed_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to invalidate previous verification codes: %w", err)
+	}
+
+	record := &models.VerificationCode{
+		UserID:    userID,
+		Channel:   channel,
+		Target:    target,
+		CodeHash:  hashToken(code),
+		ExpiresAt: time.Now().Add(VerificationCodeLifetime),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to create verification code: %w", err)
+	}
+
+	minutes := int(VerificationCodeLifetime.Minutes())
+	switch channel {
+	case models.VerificationChannelEmail:
+		err = s.mailer.Send(MailMessage{
+			To:      target,
+			Subject: "Verify your MuniCollect email address",
+			Body: fmt.Sprintf("Hello %s,\n\nYour MuniCollect verification code is %s. It expires in %d minutes.\n\n"+
+				"If you did not create an account, you can ignore this email.", user.FirstName, code, minutes),
+		})
+	case models.VerificationChannelPhone:
+		err = s.sms.Send(SMSMessage{
+			To:   target,
+			Body: fmt.Sprintf("Your MuniCollect verification code is %s. It expires in %d minutes.", code, minutes),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send verification code: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmCode checks a verification code and marks the matching contact detail as verified.
+// A code only confirms the address or number it was sent to.
+func (s *VerificationService) ConfirmCode(userID string, channel models.VerificationChannel, code string) (*models.User, error) {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	target, err := verificationTarget(&user, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	var record models.VerificationCode
+	if err := s.db.Where("user_id = ? AND channel = ? AND consumed_at IS NULL", userID, channel).
+		Order("created_at DESC").
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVerificationCodeInvalid
+		}
+		return nil, fmt.Errorf("failed to get verification code: %w", err)
+	}
+
+	if !record.IsUsable() || record.Target != target || record.Attempts >= VerificationCodeMaxAttempts {
+		return nil, ErrVerificationCodeInvalid
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(code)), []byte(record.CodeHash)) != 1 {
+		if err := s.db.Model(&record).Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+			return nil, fmt.Errorf("failed to record verification attempt: %w", err)
+		}
+		return nil, ErrVerificationCodeInvalid
+	}
+
+	now := time.Now()
+	column := "email_verified_at"
+	if channel == models.VerificationChannelPhone {
+		column = "phone_verified_at"
+	}
+
+	// Start transaction
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result := tx.Model(&models.VerificationCode{}).
+		Where("id = ? AND consumed_at IS NULL", record.ID).
+		Update("consumed_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to consume verification code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrVerificationCodeInvalid
+	}
+
+	if err := tx.Model(&user).Update(column, now).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to mark user as verified: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit verification: %w", err)
+	}
+
+	return &user, nil
+}
+
+// verificationTarget returns the email address or phone number a channel verifies
+func verificationTarget(user *models.User, channel models.VerificationChannel) (string, error) {
+	switch channel {
+	case models.VerificationChannelEmail:
+		return user.Email, nil
+	case models.VerificationChannelPhone:
+		if user.Phone == nil || *user.Phone == "" {
+			return "", ErrVerificationNoPhone
+		}
+		return *user.Phone, nil
+	default:
+		return "", ErrInvalidVerificationChannel
+	}
+}
+
+// generateNumericCode returns a random code of the given number of decimal digits
+func generateNumericCode(digits int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate verification code: %w", err)
+	}
+
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateNumericCode(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		code, err := generateNumericCode(6)
+		require.NoError(t, err)
+		assert.Len(t, code, 6)
+		for _, r := range code {
+			assert.True(t, r >= '0' && r <= '9')
+		}
+	}
+}
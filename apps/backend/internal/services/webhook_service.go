@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"municollect/internal/models"
+)
+
+// Webhook signatures are hex-encoded HMAC-SHA256 digests of "<timestamp>.<raw body>" under the
+// provider's webhook secret, sent as "sha256=<digest>" with the Unix timestamp in its own header
+const (
+	webhookSignaturePrefix = "sha256="
+
+	// WebhookTimestampTolerance is how far a webhook's timestamp may be from the current time.
+	// Older deliveries are rejected as replays; replays within it are de-duplicated by event ID.
+	WebhookTimestampTolerance = 5 * time.Minute
+
+	// WebhookRetryInterval is how often the background worker retries failed webhook events
+	WebhookRetryInterval = 30 * time.Second
+
+	// webhookMaxAttempts is how many times an event is tried before it is kept for review
+	webhookMaxAttempts = 10
+	// webhookRetryBase and webhookRetryMax bound the exponential delay between attempts
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax  = time.Hour
+	// webhookProcessingLease is how long an attempt holds an event before another may take it
+	webhookProcessingLease = 5 * time.Minute
+)
+
+// Webhook errors
+var (
+	ErrUnknownWebhookProvider  = errors.New("unknown payment provider")
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrStaleWebhook            = errors.New("webhook timestamp is outside the allowed window")
+)
+
+// WebhookSecrets holds the webhook signing secrets of each provider. A provider may have more
+// than one while its secret is rotated.
+type WebhookSecrets map[string][]string
+
+// WebhookSecretsFromEnv reads PAYMENT_WEBHOOK_SECRET_<PROVIDER> (comma-separated) for each provider
+func WebhookSecretsFromEnv(providers ...string) WebhookSecrets {
+	secrets := make(WebhookSecrets)
+	for _, provider := range providers {
+		for _, secret := range strings.Split(os.Getenv("PAYMENT_WEBHOOK_SECRET_"+strings.ToUpper(provider)), ",") {
+			if secret = strings.TrimSpace(secret); secret != "" {
+				secrets[provider] = append(secrets[provider], secret)
+			}
+		}
+	}
+	return secrets
+}
+
+// SignWebhook returns the signature header value for a webhook body sent at the given time
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookReceipt is the outcome of receiving a webhook
+type WebhookReceipt struct {
+	Event *models.WebhookEvent
+	// Duplicate is set when the event had already been received; it is not processed again
+	Duplicate bool
+	// Queued is set when the event could not be processed yet and will be retried
+	Queued bool
+}
+
+// WebhookService receives payment webhooks into a retryable inbox and applies them to payments
+type WebhookService struct {
+	db        *gorm.DB
+	payments  *PaymentService
+	providers *PaymentProviders
+	secrets   WebhookSecrets
+	now       func() time.Time
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(db *gorm.DB, payments *PaymentService, providers *PaymentProviders, secrets WebhookSecrets) *WebhookService {
+	return &WebhookService{
+		db:        db,
+		payments:  payments,
+		providers: providers,
+		secrets:   secrets,
+		now:       time.Now,
+	}
+}
+
+// Receive verifies a webhook's signature and timestamp, stores the raw event and applies it
+// to its payment. An event that cannot be applied yet is kept for the background worker to
+// retry, so it is received successfully as long as it was stored.
+func (s *WebhookService) Receive(providerName, timestamp, signature string, payload []byte) (*WebhookReceipt, error) {
+	provider, ok := s.providers.Get(providerName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWebhookProvider, providerName)
+	}
+	if err := s.verifySignature(providerName, timestamp, signature, payload); err != nil {
+		return nil, err
+	}
+
+	event, err := provider.ParseWebhook(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store the event, claimed by this request for its first attempt
+	now := s.now()
+	record := &models.WebhookEvent{
+		Provider:      providerName,
+		EventID:       event.ID,
+		Reference:     event.Reference,
+		Signature:     signature,
+		Payload:       string(payload),
+		Status:        models.WebhookEventPending,
+		Attempts:      1,
+		NextAttemptAt: now.Add(webhookProcessingLease),
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to store webhook event: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		var existing models.WebhookEvent
+		if err := s.db.Where("provider = ? AND event_id = ?", providerName, event.ID).First(&existing).Error; err != nil {
+			return nil, fmt.Errorf("failed to get webhook event: %w", err)
+		}
+		return &WebhookReceipt{Event: &existing, Duplicate: true}, nil
+	}
+
+	if err := s.process(record, event); err != nil {
+		log.Printf("Webhook event %s from %s queued for retry: %v", event.ID, providerName, err)
+		return &WebhookReceipt{Event: record, Queued: true}, nil
+	}
+
+	return &WebhookReceipt{Event: record}, nil
+}
+
+// RetryDue processes the pending events whose next attempt is due, and returns how many it tried
+func (s *WebhookService) RetryDue() (int, error) {
+	var events []models.WebhookEvent
+	if err := s.db.Where("status = ? AND next_attempt_at <= ?", models.WebhookEventPending, s.now()).
+		Order("next_attempt_at").Limit(100).Find(&events).Error; err != nil {
+		return 0, fmt.Errorf("failed to list due webhook events: %w", err)
+	}
+
+	tried := 0
+	for i := range events {
+		record := &events[i]
+
+		// Claim the event so a concurrent worker or request does not process it too
+		claim := s.db.Model(&models.WebhookEvent{}).
+			Where("id = ? AND status = ? AND attempts = ?", record.ID, models.WebhookEventPending, record.Attempts).
+			Updates(map[string]interface{}{
+				"attempts":        record.Attempts + 1,
+				"next_attempt_at": s.now().Add(webhookProcessingLease),
+			})
+		if claim.Error != nil {
+			return tried, fmt.Errorf("failed to claim webhook event: %w", claim.Error)
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+		record.Attempts++
+		tried++
+
+		err := s.retry(record)
+		if err != nil {
+			log.Printf("Webhook event %s from %s failed on attempt %d: %v", record.EventID, record.Provider, record.Attempts, err)
+		}
+	}
+
+	return tried, nil
+}
+
+// Run retries failed webhook events every interval until ctx is cancelled
+func (s *WebhookService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RetryDue(); err != nil {
+			log.Printf("Failed to retry webhook events: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// retry parses a stored event again and processes it
+func (s *WebhookService) retry(record *models.WebhookEvent) error {
+	provider, ok := s.providers.Get(record.Provider)
+	if !ok {
+		return s.fail(record, fmt.Errorf("%w: %s", ErrUnknownWebhookProvider, record.Provider))
+	}
+	event, err := provider.ParseWebhook([]byte(record.Payload))
+	if err != nil {
+		return s.finish(record, models.WebhookEventIgnored, nil, err)
+	}
+
+	return s.process(record, event)
+}
+
+// process applies an event to its payment, recording the outcome on the stored event. Events
+// that can never apply are ignored; other failures are scheduled for another attempt.
+func (s *WebhookService) process(record *models.WebhookEvent, event *ProviderEvent) error {
+	payment, err := s.payments.GetPaymentByProviderReference(record.Provider, event.Reference)
+	if err != nil {
+		// The charge reference is recorded just after the provider accepts the charge, so a
+		// webhook arriving first is simply retried
+		return s.fail(record, err)
+	}
+
+	if payment.Status == event.Status {
+		return s.finish(record, models.WebhookEventProcessed, &payment.ID, nil)
+	}
+
+	transactionData := webhookTransactionData(record, event)
+	if _, err := s.payments.UpdatePaymentStatus(AuditActor{}, payment.ID, event.Status, transactionData); err != nil {
+		if errors.Is(err, ErrInvalidStatusTransition) {
+			return s.finish(record, models.WebhookEventIgnored, &payment.ID, err)
+		}
+		return s.fail(record, err)
+	}
+
+	return s.finish(record, models.WebhookEventProcessed, &payment.ID, nil)
+}
+
+// fail schedules another attempt of the event, or gives up on it after too many
+func (s *WebhookService) fail(record *models.WebhookEvent, cause error) error {
+	if record.Attempts >= webhookMaxAttempts {
+		log.Printf("Webhook event %s from %s failed %d times and needs review: %v", record.EventID, record.Provider, record.Attempts, cause)
+		if err := s.finish(record, models.WebhookEventDead, record.PaymentID, cause); err != nil {
+			return err
+		}
+		return cause
+	}
+
+	record.NextAttemptAt = s.now().Add(webhookRetryDelay(record.Attempts))
+	record.LastError = truncateError(cause)
+	if err := s.db.Model(record).Updates(map[string]interface{}{
+		"next_attempt_at": record.NextAttemptAt,
+		"last_error":      record.LastError,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to schedule webhook retry: %w (after %v)", err, cause)
+	}
+
+	return cause
+}
+
+// finish records the final status of an event
+func (s *WebhookService) finish(record *models.WebhookEvent, status models.WebhookEventStatus, paymentID *string, cause error) error {
+	now := s.now()
+	record.Status = status
+	record.PaymentID = paymentID
+	record.ProcessedAt = &now
+	record.LastError = ""
+	if cause != nil {
+		record.LastError = truncateError(cause)
+	}
+
+	if err := s.db.Model(record).Updates(map[string]interface{}{
+		"status":       record.Status,
+		"payment_id":   record.PaymentID,
+		"processed_at": record.ProcessedAt,
+		"last_error":   record.LastError,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to record webhook event outcome: %w", err)
+	}
+
+	return nil
+}
+
+// verifySignature checks the webhook's timestamp and its signature under any of the provider's secrets
+func (s *WebhookService) verifySignature(provider, timestamp, signature string, payload []byte) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", ErrInvalidWebhookSignature)
+	}
+	sentAt := time.Unix(seconds, 0)
+	if age := s.now().Sub(sentAt); age > WebhookTimestampTolerance || age < -WebhookTimestampTolerance {
+		return ErrStaleWebhook
+	}
+
+	for _, secret := range s.secrets[provider] {
+		if hmac.Equal([]byte(SignWebhook(secret, sentAt, payload)), []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidWebhookSignature
+}
+
+// webhookTransactionData returns the transaction data recorded with the status change: the
+// webhook payload itself, or the provider's event data if the payload is not a JSON object
+func webhookTransactionData(record *models.WebhookEvent, event *ProviderEvent) map[string]interface{} {
+	data := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(record.Payload), &data); err != nil || data == nil {
+		data = make(map[string]interface{})
+		for key, value := range event.Data {
+			data[key] = value
+		}
+	}
+	data["webhookEventId"] = record.ID
+	return data
+}
+
+// webhookRetryDelay returns the delay before the attempt after the given one
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMax {
+		delay = webhookRetryMax
+	}
+	return delay
+}
+
+// truncateError returns an error message short enough for the last_error column
+func truncateError(err error) string {
+	message := err.Error()
+	if len(message) > 500 {
+		message = message[:500]
+	}
+	return message
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"municollect/internal/models"
+)
+
+func newTestWebhookService(secrets ...string) (*WebhookService, time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	providers := NewPaymentProviders()
+	providers.Register(NewMockPaymentProvider(MockProviderConfig{}), "online")
+
+	s := NewWebhookService(nil, nil, providers, WebhookSecrets{MockPaymentProviderName: secrets})
+	s.now = func() time.Time { return now }
+
+	return s, now
+}
+
+func TestWebhookService_VerifySignature(t *testing.T) {
+	s, now := newTestWebhookService("old-secret", "new-secret")
+	body := []byte(`{"id": "evt_1", "chargeId": "mock_ch_1", "status": "completed"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	// Any configured secret is accepted while a secret is rotated
+	assert.NoError(t, s.verifySignature(MockPaymentProviderName, timestamp, SignWebhook("old-secret", now, body), body))
+	assert.NoError(t, s.verifySignature(MockPaymentProviderName, timestamp, SignWebhook("new-secret", now, body), body))
+
+	assert.ErrorIs(t, s.verifySignature(MockPaymentProviderName, timestamp, SignWebhook("other-secret", now, body), body), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, s.verifySignature(MockPaymentProviderName, timestamp, SignWebhook("new-secret", now, body), []byte(`{}`)), ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, s.verifySignature(MockPaymentProviderName, "yesterday", SignWebhook("new-secret", now, body), body), ErrInvalidWebhookSignature)
+
+	// The signature covers the timestamp
+	later := now.Add(time.Minute)
+	assert.ErrorIs(t, s.verifySignature(MockPaymentProviderName, strconv.FormatInt(later.Unix(), 10), SignWebhook("new-secret", now, body), body), ErrInvalidWebhookSignature)
+
+	// Providers without a secret accept nothing
+	assert.ErrorIs(t, s.verifySignature("other", timestamp, SignWebhook("new-secret", now, body), body), ErrInvalidWebhookSignature)
+}
+
+func TestWebhookService_RejectsReplays(t *testing.T) {
+	s, now := newTestWebhookService("secret")
+	body := []byte(`{"id": "evt_1", "chargeId": "mock_ch_1", "status": "completed"}`)
+
+	for _, sentAt := range []time.Time{now.Add(-WebhookTimestampTolerance - time.Second), now.Add(WebhookTimestampTolerance + time.Second)} {
+		err := s.verifySignature(MockPaymentProviderName, strconv.FormatInt(sentAt.Unix(), 10), SignWebhook("secret", sentAt, body), body)
+		assert.ErrorIs(t, err, ErrStaleWebhook)
+	}
+
+	sentAt := now.Add(-WebhookTimestampTolerance)
+	assert.NoError(t, s.verifySignature(MockPaymentProviderName, strconv.FormatInt(sentAt.Unix(), 10), SignWebhook("secret", sentAt, body), body))
+}
+
+func TestWebhookService_ReceiveRejectsBeforeStoring(t *testing.T) {
+	s, now := newTestWebhookService("secret")
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"id": "evt_1", "chargeId": "mock_ch_1", "status": "completed"}`)
+
+	_, err := s.Receive("unknown", timestamp, SignWebhook("secret", now, body), body)
+	assert.ErrorIs(t, err, ErrUnknownWebhookProvider)
+
+	_, err = s.Receive(MockPaymentProviderName, timestamp, "sha256=00", body)
+	assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+
+	invalid := []byte(`{"id": "evt_1", "chargeId": "mock_ch_1", "status": "paid"}`)
+	_, err = s.Receive(MockPaymentProviderName, timestamp, SignWebhook("secret", now, invalid), invalid)
+	assert.ErrorIs(t, err, ErrInvalidProviderEvent)
+}
+
+func TestWebhookSecretsFromEnv(t *testing.T) {
+	t.Setenv("PAYMENT_WEBHOOK_SECRET_MOCK", "new-secret, old-secret,")
+
+	secrets := WebhookSecretsFromEnv(MockPaymentProviderName, "other")
+	assert.Equal(t, []string{"new-secret", "old-secret"}, secrets[MockPaymentProviderName])
+	assert.Empty(t, secrets["other"])
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, time.Minute, webhookRetryDelay(2))
+	assert.Equal(t, 4*time.Minute, webhookRetryDelay(4))
+	assert.Equal(t, time.Hour, webhookRetryDelay(webhookMaxAttempts))
+}
+
+func TestWebhookTransactionData(t *testing.T) {
+	record := &models.WebhookEvent{ID: "event-1", Payload: `{"id": "evt_1", "chargeId": "mock_ch_1", "status": "completed", "data": {"bank": "KBANK"}}`}
+	event := &ProviderEvent{ID: "evt_1", Data: map[string]interface{}{"provider": MockPaymentProviderName}}
+
+	data := webhookTransactionData(record, event)
+	assert.Equal(t, "evt_1", data["id"])
+	assert.Equal(t, map[string]interface{}{"bank": "KBANK"}, data["data"])
+	assert.Equal(t, "event-1", data["webhookEventId"])
+
+	// Payloads that are not JSON objects record the provider's event data instead
+	record.Payload = `[1, 2]`
+	data = webhookTransactionData(record, event)
+	require.Equal(t, MockPaymentProviderName, data["provider"])
+	assert.Equal(t, "event-1", data["webhookEventId"])
+}
@@ -0,0 +1,4 @@
+-- Rollback migration for refresh token store
+-- This migration drops the table created in 003_refresh_tokens.sql
+
+DROP TABLE IF EXISTS refresh_tokens CASCADE;
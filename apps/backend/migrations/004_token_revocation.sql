@@ -0,0 +1,22 @@
+-- Access token revocation
+-- Revoked access tokens are tracked by jti until they expire, and every user has an
+-- optional cut-off time before which all of their tokens are rejected
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+CREATE TABLE IF NOT EXISTS user_token_cutoffs (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    valid_after TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+-- Allow the new refresh token revocation reasons
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS chk_refresh_tokens_revoked_reason;
+ALTER TABLE refresh_tokens ADD CONSTRAINT chk_refresh_tokens_revoked_reason
+    CHECK (revoked_reason IS NULL OR revoked_reason IN ('rotated', 'logout', 'reuse_detected', 'password_changed', 'sign_out_all'));
@@ -0,0 +1,11 @@
+-- Rollback migration for access token revocation
+-- This migration drops the tables created in 004_token_revocation.sql
+
+DROP TABLE IF EXISTS user_token_cutoffs CASCADE;
+DROP TABLE IF EXISTS revoked_tokens CASCADE;
+
+UPDATE refresh_tokens SET revoked_reason = 'logout'
+    WHERE revoked_reason IN ('password_changed', 'sign_out_all');
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS chk_refresh_tokens_revoked_reason;
+ALTER TABLE refresh_tokens ADD CONSTRAINT chk_refresh_tokens_revoked_reason
+    CHECK (revoked_reason IS NULL OR revoked_reason IN ('rotated', 'logout', 'reuse_detected'));
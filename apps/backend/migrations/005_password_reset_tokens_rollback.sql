@@ -0,0 +1,4 @@
+-- Rollback migration for password reset tokens
+-- This migration drops the table created in 005_password_reset_tokens.sql
+
+DROP TABLE IF EXISTS password_reset_tokens CASCADE;
@@ -0,0 +1,7 @@
+-- Rollback migration for email and phone verification
+-- This migration drops the table and columns created in 006_contact_verification.sql
+
+DROP TABLE IF EXISTS verification_codes CASCADE;
+
+ALTER TABLE users DROP COLUMN IF EXISTS phone_verified_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
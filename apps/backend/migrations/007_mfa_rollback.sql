@@ -0,0 +1,5 @@
+-- Rollback migration for TOTP two-factor authentication
+-- This migration drops the tables created in 007_mfa.sql
+
+DROP TABLE IF EXISTS mfa_recovery_codes CASCADE;
+DROP TABLE IF EXISTS user_mfa CASCADE;
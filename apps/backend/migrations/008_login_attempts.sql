@@ -0,0 +1,17 @@
+-- Login brute-force protection
+-- Failed login counters per account and per client IP, shared by every backend replica
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    throttle_key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER DEFAULT 0 NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_updated_at ON login_attempts(updated_at);
+
+-- Allow security alert notifications
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS chk_notifications_type;
+ALTER TABLE notifications ADD CONSTRAINT chk_notifications_type
+    CHECK (type IN ('payment_reminder', 'payment_confirmation', 'payment_failed', 'system_update', 'security_alert'));
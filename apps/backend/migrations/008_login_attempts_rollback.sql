@@ -0,0 +1,9 @@
+-- Rollback migration for login brute-force protection
+-- This migration drops the table created in 008_login_attempts.sql
+
+DROP TABLE IF EXISTS login_attempts CASCADE;
+
+UPDATE notifications SET type = 'system_update' WHERE type = 'security_alert';
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS chk_notifications_type;
+ALTER TABLE notifications ADD CONSTRAINT chk_notifications_type
+    CHECK (type IN ('payment_reminder', 'payment_confirmation', 'payment_failed', 'system_update'));
@@ -0,0 +1,4 @@
+-- Rollback migration for API keys
+-- This migration drops the table created in 009_api_keys.sql
+
+DROP TABLE IF EXISTS api_keys CASCADE;
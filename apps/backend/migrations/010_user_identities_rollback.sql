@@ -0,0 +1,4 @@
+-- Rollback migration for external identities
+-- This migration drops the table created in 010_user_identities.sql
+
+DROP TABLE IF EXISTS user_identities CASCADE;
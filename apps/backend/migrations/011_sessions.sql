@@ -0,0 +1,36 @@
+-- Server-side login sessions
+-- One row per login on a device. The session ID is the family ID of the login's refresh
+-- tokens and the "sid" claim of its access tokens, so revoking a session ends both.
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device VARCHAR(100),
+    user_agent VARCHAR(512),
+    ip_address VARCHAR(45),
+    last_seen_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    revoked_reason VARCHAR(20),
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+ALTER TABLE sessions ADD CONSTRAINT chk_sessions_revoked_reason
+    CHECK (revoked_reason IS NULL OR revoked_reason IN ('rotated', 'logout', 'reuse_detected', 'password_changed', 'sign_out_all', 'session_ended'));
+
+-- Refresh tokens of a session revoked from the session list
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS chk_refresh_tokens_revoked_reason;
+ALTER TABLE refresh_tokens ADD CONSTRAINT chk_refresh_tokens_revoked_reason
+    CHECK (revoked_reason IS NULL OR revoked_reason IN ('rotated', 'logout', 'reuse_detected', 'password_changed', 'sign_out_all', 'session_ended'));
+
+-- Logins that are still active get a session so they show up in session listings.
+-- Device details are unknown for them until their next refresh.
+INSERT INTO sessions (id, user_id, device, last_seen_at, expires_at, created_at, updated_at)
+SELECT family_id, user_id, 'Unknown device', MAX(created_at), MAX(expires_at), MIN(created_at), NOW()
+FROM refresh_tokens
+WHERE revoked_at IS NULL AND expires_at > NOW()
+GROUP BY family_id, user_id
+ON CONFLICT (id) DO NOTHING;
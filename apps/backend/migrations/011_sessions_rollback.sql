@@ -0,0 +1,11 @@
+-- Rollback migration for login sessions
+-- This migration drops the table created in 011_sessions.sql and restores the
+-- refresh token revocation reasons of 004_token_revocation.sql
+
+DROP TABLE IF EXISTS sessions CASCADE;
+
+UPDATE refresh_tokens SET revoked_reason = 'logout'
+    WHERE revoked_reason = 'session_ended';
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS chk_refresh_tokens_revoked_reason;
+ALTER TABLE refresh_tokens ADD CONSTRAINT chk_refresh_tokens_revoked_reason
+    CHECK (revoked_reason IS NULL OR revoked_reason IN ('rotated', 'logout', 'reuse_detected', 'password_changed', 'sign_out_all'));
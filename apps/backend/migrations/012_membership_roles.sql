@@ -0,0 +1,27 @@
+-- Municipality-scoped roles
+-- Each user_municipalities row now carries the role the user holds in that municipality.
+-- Staff permissions are checked against this role rather than the global users.role.
+
+-- The association table was previously only created by GORM auto-migration
+CREATE TABLE IF NOT EXISTS user_municipalities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    municipality_id UUID NOT NULL REFERENCES municipalities(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_municipality_user ON user_municipalities(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_municipality_municipality ON user_municipalities(municipality_id);
+
+ALTER TABLE user_municipalities ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'resident';
+
+-- Existing staff and admins keep their standing in the municipalities they belong to
+UPDATE user_municipalities
+SET role = users.role
+FROM users
+WHERE users.id = user_municipalities.user_id
+  AND users.role IN ('municipal_staff', 'admin');
+
+ALTER TABLE user_municipalities ADD CONSTRAINT chk_user_municipalities_role
+    CHECK (role IN ('resident', 'municipal_staff', 'admin'));
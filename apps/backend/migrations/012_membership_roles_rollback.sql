@@ -0,0 +1,5 @@
+-- Rollback migration for municipality-scoped roles
+-- This migration removes the role column added in 012_membership_roles.sql
+
+ALTER TABLE user_municipalities DROP CONSTRAINT IF EXISTS chk_user_municipalities_role;
+ALTER TABLE user_municipalities DROP COLUMN IF EXISTS role;
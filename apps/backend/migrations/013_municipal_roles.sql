@@ -0,0 +1,24 @@
+-- Permission-based municipal roles
+-- Municipalities may define their own roles as sets of permissions. Membership roles now
+-- name either a built-in role (resident, admin, finance, services, general, collector)
+-- or one of the municipality's own roles.
+
+CREATE TABLE municipal_roles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    municipality_id UUID NOT NULL REFERENCES municipalities(id) ON DELETE CASCADE,
+    key VARCHAR(50) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(255),
+    permissions JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_municipal_roles_municipality_key ON municipal_roles(municipality_id, key);
+
+-- Membership roles are no longer limited to the global roles
+ALTER TABLE user_municipalities DROP CONSTRAINT IF EXISTS chk_user_municipalities_role;
+ALTER TABLE user_municipalities ALTER COLUMN role TYPE VARCHAR(50);
+
+-- Existing municipal staff get the read-only General role until an admin assigns them another
+UPDATE user_municipalities SET role = 'general' WHERE role = 'municipal_staff';
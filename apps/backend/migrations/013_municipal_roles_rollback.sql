@@ -0,0 +1,11 @@
+-- Rollback migration for permission-based municipal roles
+-- This migration removes the municipal_roles table added in 013_municipal_roles.sql
+
+-- Every staff role other than admin maps back to municipal_staff
+UPDATE user_municipalities SET role = 'municipal_staff' WHERE role NOT IN ('resident', 'admin');
+
+ALTER TABLE user_municipalities ALTER COLUMN role TYPE VARCHAR(20);
+ALTER TABLE user_municipalities ADD CONSTRAINT chk_user_municipalities_role
+    CHECK (role IN ('resident', 'municipal_staff', 'admin'));
+
+DROP TABLE IF EXISTS municipal_roles;
@@ -0,0 +1,8 @@
+-- Rollback migration for admin user management
+-- This migration removes the audit log and account flags added in 014_admin_user_management.sql
+
+DROP TABLE IF EXISTS audit_logs;
+
+DROP INDEX IF EXISTS idx_users_disabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_required;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,26 @@
+-- Staff invitations
+-- Municipal admins invite staff by email with a municipality role. Accepting the
+-- tokenized link creates the user, their credentials and their membership.
+
+CREATE TABLE staff_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    municipality_id UUID NOT NULL REFERENCES municipalities(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    invited_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_staff_invitations_token_hash ON staff_invitations(token_hash);
+CREATE INDEX idx_staff_invitations_municipality_id ON staff_invitations(municipality_id);
+CREATE INDEX idx_staff_invitations_email ON staff_invitations(email);
+
+-- At most one open invitation per email address and municipality
+CREATE UNIQUE INDEX idx_staff_invitations_open ON staff_invitations(municipality_id, email)
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
@@ -0,0 +1,4 @@
+-- Rollback migration for staff invitations
+-- This migration removes the staff_invitations table added in 015_staff_invitations.sql
+
+DROP TABLE IF EXISTS staff_invitations;
@@ -0,0 +1,7 @@
+-- Admin impersonation
+-- Actions taken with an impersonation token are recorded against the impersonated
+-- user with the admin who acted as them.
+
+ALTER TABLE audit_logs ADD COLUMN impersonator_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_audit_logs_impersonator_id ON audit_logs(impersonator_id);
@@ -0,0 +1,5 @@
+-- Rollback migration for admin impersonation
+-- This migration removes the impersonator_id column added in 016_impersonation.sql
+
+DROP INDEX IF EXISTS idx_audit_logs_impersonator_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS impersonator_id;
@@ -0,0 +1,41 @@
+-- Hash-chained, append-only audit log
+-- Each entry gets a gapless sequence number and a SHA-256 hash covering its content and
+-- the previous entry's hash. Entries written before this migration are numbered but not
+-- hashed; the chain starts with the first entry written afterwards.
+
+ALTER TABLE audit_logs ADD COLUMN sequence BIGINT;
+ALTER TABLE audit_logs ADD COLUMN changes JSONB;
+ALTER TABLE audit_logs ADD COLUMN request_id VARCHAR(100);
+ALTER TABLE audit_logs ADD COLUMN prev_hash VARCHAR(64);
+ALTER TABLE audit_logs ADD COLUMN hash VARCHAR(64);
+
+UPDATE audit_logs SET sequence = numbered.sequence
+FROM (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) AS sequence
+    FROM audit_logs
+) AS numbered
+WHERE audit_logs.id = numbered.id;
+
+ALTER TABLE audit_logs ALTER COLUMN sequence SET NOT NULL;
+
+CREATE UNIQUE INDEX idx_audit_logs_sequence ON audit_logs(sequence);
+CREATE INDEX idx_audit_logs_request_id ON audit_logs(request_id);
+
+-- Entries outlive the users they name, so deleting a user must not rewrite them
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_actor_id_fkey;
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_impersonator_id_fkey;
+
+-- Entries can only be inserted
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+
+CREATE TRIGGER trg_audit_logs_no_truncate
+    BEFORE TRUNCATE ON audit_logs
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only();
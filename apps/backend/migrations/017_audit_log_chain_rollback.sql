@@ -0,0 +1,20 @@
+-- Rollback migration for the hash-chained audit log
+-- This migration removes the columns, indexes and triggers added in 017_audit_log_chain.sql
+
+DROP TRIGGER IF EXISTS trg_audit_logs_no_truncate ON audit_logs;
+DROP TRIGGER IF EXISTS trg_audit_logs_append_only ON audit_logs;
+DROP FUNCTION IF EXISTS audit_logs_append_only();
+
+DROP INDEX IF EXISTS idx_audit_logs_request_id;
+DROP INDEX IF EXISTS idx_audit_logs_sequence;
+
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS hash;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS request_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS changes;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS sequence;
+
+UPDATE audit_logs SET actor_id = NULL WHERE actor_id NOT IN (SELECT id FROM users);
+UPDATE audit_logs SET impersonator_id = NULL WHERE impersonator_id NOT IN (SELECT id FROM users);
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_actor_id_fkey FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_impersonator_id_fkey FOREIGN KEY (impersonator_id) REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,31 @@
+-- PDPA data subject requests
+-- Residents ask for an export or the erasure of their personal data; admins approve or
+-- reject each request and approved ones are carried out by a background worker.
+
+ALTER TABLE users ADD COLUMN erased_at TIMESTAMP;
+
+CREATE TABLE data_subject_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason VARCHAR(500),
+    reviewed_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    review_note VARCHAR(500),
+    error VARCHAR(500),
+    archive BYTEA,
+    archive_expires_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    CONSTRAINT chk_data_subject_requests_type CHECK (type IN ('export', 'erasure')),
+    CONSTRAINT chk_data_subject_requests_status CHECK (status IN ('pending', 'approved', 'rejected', 'completed', 'failed'))
+);
+
+CREATE INDEX idx_data_subject_requests_user_id ON data_subject_requests(user_id);
+CREATE INDEX idx_data_subject_requests_status ON data_subject_requests(status);
+
+-- At most one open request of each type per user
+CREATE UNIQUE INDEX idx_data_subject_requests_open ON data_subject_requests(user_id, type)
+    WHERE status IN ('pending', 'approved');
@@ -0,0 +1,6 @@
+-- Rollback migration for PDPA data subject requests
+-- This migration removes the data_subject_requests table and erasure flag added in 018_data_subject_requests.sql
+
+DROP TABLE IF EXISTS data_subject_requests;
+
+ALTER TABLE users DROP COLUMN IF EXISTS erased_at;
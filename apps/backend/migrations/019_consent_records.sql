@@ -0,0 +1,21 @@
+-- Consent records
+-- Versioned, append-only record of each user's acceptance of the terms of service and
+-- privacy policy and of their consent to reminder and marketing messages per channel.
+
+CREATE TABLE consent_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL DEFAULT '',
+    version VARCHAR(50) NOT NULL,
+    granted BOOLEAN NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(512),
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    CONSTRAINT chk_consent_records_purpose CHECK (purpose IN ('terms_of_service', 'privacy_policy', 'payment_reminders', 'marketing')),
+    CONSTRAINT chk_consent_records_channel CHECK (channel IN ('', 'email', 'sms', 'in_app'))
+);
+
+-- The latest record per purpose and channel is the user's current choice
+CREATE INDEX idx_consent_records_user_purpose ON consent_records(user_id, purpose, channel, created_at DESC);
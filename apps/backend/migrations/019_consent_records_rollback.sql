@@ -0,0 +1,4 @@
+-- Rollback migration for consent records
+-- This migration removes the consent_records table added in 019_consent_records.sql
+
+DROP TABLE IF EXISTS consent_records;
@@ -0,0 +1,22 @@
+-- Field-level encryption of personal data
+-- Email addresses, phone numbers and payment transaction data are encrypted by the
+-- application with envelope encryption, so their columns become TEXT. Users are looked up
+-- by HMAC blind indexes of their email address and phone number instead.
+-- Existing rows stay readable as plaintext, but users cannot sign in until
+-- `fieldkeys -action=reencrypt` has encrypted them and filled in their blind indexes.
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+DROP INDEX IF EXISTS idx_users_email;
+DROP INDEX IF EXISTS idx_users_phone;
+
+ALTER TABLE users ALTER COLUMN email TYPE TEXT;
+ALTER TABLE users ALTER COLUMN phone TYPE TEXT;
+ALTER TABLE users ADD COLUMN email_index VARCHAR(64);
+ALTER TABLE users ADD COLUMN phone_index VARCHAR(64);
+
+CREATE UNIQUE INDEX idx_users_email_index ON users(email_index);
+CREATE INDEX idx_users_phone_index ON users(phone_index);
+
+ALTER TABLE municipalities ALTER COLUMN contact_phone TYPE TEXT;
+
+ALTER TABLE payment_transactions ALTER COLUMN transaction_data TYPE TEXT USING transaction_data::text;
@@ -0,0 +1,19 @@
+-- Rollback migration for field-level encryption
+-- This migration restores the plaintext columns changed in 020_field_encryption.sql.
+-- SQL cannot decrypt values: run `fieldkeys -action=decrypt` before rolling back.
+
+ALTER TABLE payment_transactions ALTER COLUMN transaction_data TYPE JSONB USING transaction_data::jsonb;
+
+ALTER TABLE municipalities ALTER COLUMN contact_phone TYPE VARCHAR(20);
+
+DROP INDEX IF EXISTS idx_users_phone_index;
+DROP INDEX IF EXISTS idx_users_email_index;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_index;
+ALTER TABLE users DROP COLUMN IF EXISTS email_index;
+
+ALTER TABLE users ALTER COLUMN phone TYPE VARCHAR(20);
+ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(255);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+CREATE INDEX IF NOT EXISTS idx_users_phone ON users(phone);
@@ -0,0 +1,31 @@
+-- National IDs and household registration
+-- Users get an application-encrypted 13-digit Thai citizen ID, looked up by its blind index.
+-- Households are identified by their house number within a municipality, and each user is
+-- bound to at most one of them.
+
+ALTER TABLE users ADD COLUMN national_id TEXT;
+ALTER TABLE users ADD COLUMN national_id_index VARCHAR(64);
+
+CREATE UNIQUE INDEX idx_users_national_id_index ON users(national_id_index);
+
+CREATE TABLE households (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    municipality_id UUID NOT NULL REFERENCES municipalities(id) ON DELETE CASCADE,
+    house_number VARCHAR(20) NOT NULL,
+    address VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_households_municipality_house_number ON households(municipality_id, house_number);
+
+CREATE TABLE household_members (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    household_id UUID NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    is_head BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX idx_household_members_household_id ON household_members(household_id);
+CREATE UNIQUE INDEX idx_household_members_user_id ON household_members(user_id);
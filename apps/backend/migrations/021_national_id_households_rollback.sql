@@ -0,0 +1,9 @@
+-- Rollback migration for national IDs and household registration
+-- This migration removes the tables and columns added in 021_national_id_households.sql
+
+DROP TABLE IF EXISTS household_members;
+DROP TABLE IF EXISTS households;
+
+DROP INDEX IF EXISTS idx_users_national_id_index;
+ALTER TABLE users DROP COLUMN IF EXISTS national_id_index;
+ALTER TABLE users DROP COLUMN IF EXISTS national_id;
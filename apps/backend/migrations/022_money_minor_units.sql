@@ -0,0 +1,16 @@
+-- Exact payment amounts
+-- Payment amounts are stored as the integer number of minor units of their currency
+-- (satang, cents) instead of DECIMAL(10,2), so totals add up without rounding errors.
+-- USD, EUR and GBP, the only currencies allowed so far, all have two decimal places,
+-- so every existing amount converts exactly.
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_amount_positive;
+DROP INDEX IF EXISTS idx_payments_amount;
+
+ALTER TABLE payments RENAME COLUMN amount TO amount_minor;
+ALTER TABLE payments ALTER COLUMN amount_minor TYPE BIGINT USING (amount_minor * 100)::BIGINT;
+
+CREATE INDEX idx_payments_amount ON payments(amount_minor);
+
+ALTER TABLE payments ADD CONSTRAINT chk_payments_amount_positive
+    CHECK (amount_minor > 0);
@@ -0,0 +1,13 @@
+-- Rollback migration for exact payment amounts
+-- This migration restores the DECIMAL(10,2) amounts replaced in 022_money_minor_units.sql.
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_amount_positive;
+DROP INDEX IF EXISTS idx_payments_amount;
+
+ALTER TABLE payments ALTER COLUMN amount_minor TYPE DECIMAL(10,2) USING amount_minor / 100.0;
+ALTER TABLE payments RENAME COLUMN amount_minor TO amount;
+
+CREATE INDEX idx_payments_amount ON payments(amount);
+
+ALTER TABLE payments ADD CONSTRAINT chk_payments_amount_positive
+    CHECK (amount > 0);
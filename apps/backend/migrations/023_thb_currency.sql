@@ -0,0 +1,24 @@
+-- Thai baht as a first-class currency
+-- Municipalities get a default currency that their payment configuration and payments use.
+-- Existing municipalities keep the currency of their payment configuration, so their
+-- payments stay consistent with it; new municipalities and payments default to THB.
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_currency;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_currency
+    CHECK (currency IN ('THB', 'USD', 'EUR', 'GBP'));
+ALTER TABLE payments ALTER COLUMN currency SET DEFAULT 'THB';
+
+ALTER TABLE municipalities ADD COLUMN default_currency VARCHAR(3) NOT NULL DEFAULT 'THB';
+
+UPDATE municipalities
+SET default_currency = payment_config->>'currency'
+WHERE payment_config->>'currency' IN ('THB', 'USD', 'EUR', 'GBP');
+
+UPDATE municipalities
+SET payment_config = jsonb_set(payment_config, '{currency}', to_jsonb(default_currency))
+WHERE payment_config IS NOT NULL
+  AND payment_config::text <> 'null'
+  AND (payment_config->>'currency') IS DISTINCT FROM default_currency;
+
+ALTER TABLE municipalities ADD CONSTRAINT chk_municipalities_default_currency
+    CHECK (default_currency IN ('THB', 'USD', 'EUR', 'GBP'));
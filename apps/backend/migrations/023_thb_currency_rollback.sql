@@ -0,0 +1,10 @@
+-- Rollback migration for Thai baht as a first-class currency
+-- This migration fails while any payment is in THB; convert or remove those payments first.
+
+ALTER TABLE municipalities DROP CONSTRAINT IF EXISTS chk_municipalities_default_currency;
+ALTER TABLE municipalities DROP COLUMN IF EXISTS default_currency;
+
+ALTER TABLE payments ALTER COLUMN currency SET DEFAULT 'USD';
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_currency;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_currency
+    CHECK (currency IN ('USD', 'EUR', 'GBP'));
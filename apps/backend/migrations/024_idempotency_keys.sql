@@ -0,0 +1,21 @@
+-- Idempotency keys
+-- Requests sent with an Idempotency-Key header, such as payment initiation, are remembered per
+-- user and scope with a fingerprint of the request and, once complete, its encrypted response,
+-- so a retry gets the same response instead of creating a duplicate payment
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scope VARCHAR(50) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    response_status INTEGER,
+    response_content_type VARCHAR(100),
+    response_body TEXT,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW() NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_keys_user_scope_key ON idempotency_keys(user_id, scope, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
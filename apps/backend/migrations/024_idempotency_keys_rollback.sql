@@ -0,0 +1,4 @@
+-- Rollback migration for idempotency keys
+-- This migration drops the table created in 024_idempotency_keys.sql
+
+DROP TABLE IF EXISTS idempotency_keys CASCADE;
@@ -0,0 +1,18 @@
+-- Payment providers
+-- Payments made with an online payment method are collected by a payment provider, whose
+-- results move them between statuses. The provider's charge reference identifies the payment
+-- in status queries and webhooks. Completed provider payments can be refunded.
+
+ALTER TABLE payments ADD COLUMN payment_method VARCHAR(50);
+ALTER TABLE payments ADD COLUMN provider VARCHAR(50);
+ALTER TABLE payments ADD COLUMN provider_reference VARCHAR(255);
+
+CREATE UNIQUE INDEX idx_payments_provider_reference ON payments(provider, provider_reference);
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status
+    CHECK (status IN ('pending', 'completed', 'failed', 'expired', 'refunded'));
+
+ALTER TABLE payment_transactions DROP CONSTRAINT IF EXISTS chk_payment_transactions_status;
+ALTER TABLE payment_transactions ADD CONSTRAINT chk_payment_transactions_status
+    CHECK (status IN ('pending', 'completed', 'failed', 'expired', 'refunded'));
@@ -0,0 +1,20 @@
+-- Rollback migration for payment providers
+-- This migration removes the columns added in 025_payment_providers.sql. Refunded payments
+-- are kept as completed, with their refund still recorded in their transaction history.
+
+UPDATE payments SET status = 'completed' WHERE status = 'refunded';
+UPDATE payment_transactions SET status = 'completed' WHERE status = 'refunded';
+
+ALTER TABLE payment_transactions DROP CONSTRAINT IF EXISTS chk_payment_transactions_status;
+ALTER TABLE payment_transactions ADD CONSTRAINT chk_payment_transactions_status
+    CHECK (status IN ('pending', 'completed', 'failed', 'expired'));
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS chk_payments_status;
+ALTER TABLE payments ADD CONSTRAINT chk_payments_status
+    CHECK (status IN ('pending', 'completed', 'failed', 'expired'));
+
+DROP INDEX IF EXISTS idx_payments_provider_reference;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS provider_reference;
+ALTER TABLE payments DROP COLUMN IF EXISTS provider;
+ALTER TABLE payments DROP COLUMN IF EXISTS payment_method;
@@ -0,0 +1,25 @@
+-- Webhook events
+-- Signed payment notifications from providers are stored, encrypted, before they are applied,
+-- unique per provider and event ID so repeated deliveries are applied once. Events that cannot
+-- be applied yet stay pending and are retried with backoff until they succeed or are marked dead.
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(50) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    reference VARCHAR(255) NOT NULL,
+    signature VARCHAR(255) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error VARCHAR(500),
+    payment_id UUID REFERENCES payments(id) ON DELETE SET NULL,
+    processed_at TIMESTAMP,
+    received_at TIMESTAMP DEFAULT NOW() NOT NULL,
+    CONSTRAINT chk_webhook_events_status CHECK (status IN ('pending', 'processed', 'ignored', 'dead'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_events_provider_event ON webhook_events(provider, event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_status_next_attempt ON webhook_events(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_payment_id ON webhook_events(payment_id);
@@ -0,0 +1,4 @@
+-- Rollback migration for webhook events
+-- This migration drops the table created in 026_webhook_events.sql
+
+DROP TABLE IF EXISTS webhook_events CASCADE;
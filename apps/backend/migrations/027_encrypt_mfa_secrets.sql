@@ -0,0 +1,6 @@
+-- Encrypted TOTP secrets
+-- MFA secrets are encrypted by the application like other personal data, so their column
+-- becomes TEXT. Existing secrets stay readable as plaintext until `fieldkeys -action=reencrypt`
+-- has encrypted them.
+
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE TEXT;
@@ -0,0 +1,5 @@
+-- Rollback migration for encrypted TOTP secrets
+-- This migration restores the plaintext column changed in 027_encrypt_mfa_secrets.sql.
+-- SQL cannot decrypt values: run `fieldkeys -action=decrypt` before rolling back.
+
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE VARCHAR(64);
@@ -0,0 +1,6 @@
+-- Login throttling by email blind index
+-- Failed login counters for accounts are now keyed by the blind index of the email address
+-- instead of the address itself. Counters under the old keys hold plaintext emails, including
+-- for addresses without an account, and are dropped; they only last for the failure window.
+
+DELETE FROM login_attempts WHERE throttle_key LIKE 'account:%@%';
@@ -0,0 +1,3 @@
+-- Rollback migration for login throttling by email blind index
+-- The counters dropped by 028_login_attempt_email_index.sql are not restored; new failures
+-- are counted under the keys of the running version.
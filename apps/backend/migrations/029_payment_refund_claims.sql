@@ -0,0 +1,5 @@
+-- Payment refund claims
+-- A refund claims its payment before the provider is asked to refund it, so concurrent refund
+-- requests cannot both reach the provider. The claim is released if the provider refuses.
+
+ALTER TABLE payments ADD COLUMN refund_started_at TIMESTAMP;
@@ -0,0 +1,4 @@
+-- Rollback migration for payment refund claims
+-- This migration removes the column added in 029_payment_refund_claims.sql.
+
+ALTER TABLE payments DROP COLUMN IF EXISTS refund_started_at;
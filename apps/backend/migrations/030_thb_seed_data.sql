@@ -0,0 +1,21 @@
+-- Seed data in Thai baht
+-- The sample municipalities and payments inserted by 002_seed_data.sql predate THB and were
+-- kept in USD by 023_thb_currency.sql. They are moved to THB so a fresh database prices
+-- everything in baht. Amounts keep their value in the new currency: both have two decimal
+-- places, so the minor units (cents, now satang) are unchanged. Rows no longer in USD are
+-- left alone.
+
+UPDATE municipalities
+SET default_currency = 'THB',
+    payment_config = jsonb_set(payment_config, '{currency}', '"THB"')
+WHERE id IN ('f47ac10b-58cc-4372-a567-0e02b2c3d479', 'f47ac10b-58cc-4372-a567-0e02b2c3d480', 'f47ac10b-58cc-4372-a567-0e02b2c3d481')
+  AND default_currency = 'USD';
+
+UPDATE payments
+SET currency = 'THB'
+WHERE id IN ('b47ac10b-58cc-4372-a567-0e02b2c3d479', 'b47ac10b-58cc-4372-a567-0e02b2c3d480', 'b47ac10b-58cc-4372-a567-0e02b2c3d481')
+  AND currency = 'USD';
+
+UPDATE notifications
+SET message = REPLACE(message, '$', '฿')
+WHERE id IN ('d47ac10b-58cc-4372-a567-0e02b2c3d479', 'd47ac10b-58cc-4372-a567-0e02b2c3d480');
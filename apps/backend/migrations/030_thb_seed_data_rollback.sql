@@ -0,0 +1,17 @@
+-- Rollback migration for seed data in Thai baht
+-- This migration moves the sample rows converted by 030_thb_seed_data.sql back to USD.
+
+UPDATE notifications
+SET message = REPLACE(message, '฿', '$')
+WHERE id IN ('d47ac10b-58cc-4372-a567-0e02b2c3d479', 'd47ac10b-58cc-4372-a567-0e02b2c3d480');
+
+UPDATE payments
+SET currency = 'USD'
+WHERE id IN ('b47ac10b-58cc-4372-a567-0e02b2c3d479', 'b47ac10b-58cc-4372-a567-0e02b2c3d480', 'b47ac10b-58cc-4372-a567-0e02b2c3d481')
+  AND currency = 'THB';
+
+UPDATE municipalities
+SET default_currency = 'USD',
+    payment_config = jsonb_set(payment_config, '{currency}', '"USD"')
+WHERE id IN ('f47ac10b-58cc-4372-a567-0e02b2c3d479', 'f47ac10b-58cc-4372-a567-0e02b2c3d480', 'f47ac10b-58cc-4372-a567-0e02b2c3d481')
+  AND default_currency = 'THB';
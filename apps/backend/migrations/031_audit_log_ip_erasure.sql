@@ -0,0 +1,24 @@
+-- Erasable client IP addresses in the audit log
+-- Erasing a user must remove the IP addresses recorded with their actions. New entries
+-- are hashed without the IP address (hash_version 2), so blanking it leaves the chain
+-- intact; older entries can then only be checked against the entry before them.
+-- The append-only trigger allows this one update and nothing else.
+
+ALTER TABLE audit_logs ADD COLUMN hash_version SMALLINT NOT NULL DEFAULT 1;
+ALTER TABLE audit_logs ADD COLUMN ip_erased_at TIMESTAMP;
+
+-- Entries can only be inserted, apart from erasing their IP address once
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        IF OLD.ip_erased_at IS NULL
+            AND NEW.ip_erased_at IS NOT NULL
+            AND COALESCE(NEW.ip_address, '') = ''
+            AND to_jsonb(NEW) - 'ip_address' - 'ip_erased_at' = to_jsonb(OLD) - 'ip_address' - 'ip_erased_at'
+        THEN
+            RETURN NEW;
+        END IF;
+    END IF;
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,13 @@
+-- Rollback migration for erasable audit log IP addresses
+-- This migration restores the strict append-only trigger from 017_audit_log_chain.sql and
+-- removes the columns added in 031_audit_log_ip_erasure.sql. Entries hashed with
+-- hash_version 2 no longer verify afterwards.
+
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS ip_erased_at;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS hash_version;